@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// apiKeyMarker starts every API key, so leaked keys are easy to spot in code and logs
+const apiKeyMarker = "snet_"
+
+// Sizes, in random bytes, of the public prefix and of the secret of an API key
+const (
+	apiKeyPrefixBytes = 6
+	apiKeySecretBytes = 32
+)
+
+var (
+	// ErrInvalidAPIKey is returned for API keys that are malformed or unknown
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrExpiredAPIKey is returned for API keys that are expired or revoked
+	ErrExpiredAPIKey = errors.New("expired API key")
+)
+
+// NewAPIKey generates an API key, returning it and its prefix. The key reads "snet_<prefix>_<secret>":
+// the prefix identifies it in listings and lookups, and the secret makes it unguessable.
+func NewAPIKey() (key, prefix string, err error) {
+	raw := make([]byte, apiKeyPrefixBytes+apiKeySecretBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(raw[:apiKeyPrefixBytes])
+	return apiKeyMarker + prefix + "_" + base64.RawURLEncoding.EncodeToString(raw[apiKeyPrefixBytes:]), prefix, nil
+}
+
+// ParseAPIKey returns the prefix of key, and whether key has the shape of an API key at all.
+func ParseAPIKey(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, apiKeyMarker)
+	if !ok {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || len(prefix) != hex.EncodedLen(apiKeyPrefixBytes) || len(secret) != base64.RawURLEncoding.EncodedLen(apiKeySecretBytes) {
+		return "", false
+	}
+	return prefix, true
+}
+
+// HashAPIKey returns the hash stored in place of key. The secret is random enough for a plain SHA-256,
+// unlike passwords, so lookups stay cheap.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyMatches reports whether key is the one hashed into hash, in constant time.
+func APIKeyMatches(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(key)), []byte(hash)) == 1
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	key, prefix, err := NewAPIKey()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "snet_"+prefix+"_"))
+
+	parsed, ok := ParseAPIKey(key)
+	assert.True(t, ok)
+	assert.Equal(t, prefix, parsed)
+
+	other, _, err := NewAPIKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+
+	hash := HashAPIKey(key)
+	assert.NotContains(t, hash, key)
+	assert.True(t, APIKeyMatches(key, hash))
+	assert.False(t, APIKeyMatches(other, hash))
+}
+
+func TestParseAPIKey_Invalido(t *testing.T) {
+	key, _, err := NewAPIKey()
+	require.NoError(t, err)
+
+	for _, raw := range []string{"", "snet_", "abc_def", strings.TrimPrefix(key, "snet_"), key + "x", key[:len(key)-1], strings.Replace(key, "_", "-", 2)} {
+		_, ok := ParseAPIKey(raw)
+		assert.False(t, ok, raw)
+	}
+}
+
+func TestScope_Allows(t *testing.T) {
+	assert.True(t, ScopeReadStores.Allows(ScopeReadStores))
+	assert.True(t, ScopeWriteStores.Allows(ScopeReadStores), "writing allows reading")
+	assert.False(t, ScopeReadStores.Allows(ScopeWriteStores))
+	assert.False(t, ScopeWriteStores.Allows(ScopeReadEstablishments))
+	assert.False(t, Scope("write:").Allows(Scope("read:")))
+
+	assert.True(t, ScopeWriteEstablishments.Valid())
+	assert.False(t, Scope("admin").Valid())
+}
+
+func TestPrincipal_HasScope(t *testing.T) {
+	user := Principal{Subject: "maria", Role: RoleViewer}
+	assert.True(t, user.HasScope(ScopeWriteStores), "users are only limited by their role")
+
+	key := Principal{Subject: "api-key:abc", Role: RoleViewer, Scopes: []Scope{ScopeReadStores}}
+	assert.True(t, key.HasScope(ScopeReadStores))
+	assert.False(t, key.HasScope(ScopeReadEstablishments))
+
+	assert.False(t, Principal{Scopes: []Scope{}}.HasScope(ScopeReadStores), "a key without scopes can do nothing")
+}
+
+func TestRoleForScopes(t *testing.T) {
+	assert.Equal(t, RoleViewer, RoleForScopes([]Scope{ScopeReadStores, ScopeReadEstablishments}))
+	assert.Equal(t, RoleEditor, RoleForScopes([]Scope{ScopeReadStores, ScopeWriteEstablishments}))
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+)
+
+// KeySet holds the RSA public keys of a JWKS document by key ID
+type KeySet struct {
+	keys map[string]*rsa.PublicKey
+}
+
+// jwk is the subset of a JSON Web Key needed to verify RS256 signatures
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// LoadJWKSFile reads a JWKS document from the file at path.
+func LoadJWKSFile(path string) (*KeySet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadJWKS(f)
+}
+
+// ReadJWKS reads a JWKS document ({"keys": [...]}). Keys that are not RSA signing keys are skipped,
+// and at least one RSA key is required.
+func ReadJWKS(r io.Reader) (*KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	set := &KeySet{keys: map[string]*rsa.PublicKey{}}
+	for i, k := range doc.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != algRS256) {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS key %d: %w", i, err)
+		}
+		if _, ok := set.keys[k.Kid]; ok {
+			return nil, fmt.Errorf("invalid JWKS: key ID %q is repeated", k.Kid)
+		}
+		set.keys[k.Kid] = key
+	}
+	if len(set.keys) == 0 {
+		return nil, errors.New("invalid JWKS: no RSA signing key")
+	}
+	return set, nil
+}
+
+// Key returns the key with the given ID. A token without a key ID may only use a set with a single key.
+func (s *KeySet) Key(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+func (k jwk) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil || len(n) == 0 {
+		return nil, errors.New(`"n" must be a base64url encoded modulus`)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, errors.New(`"e" must be a base64url encoded exponent`)
+	}
+	exponent := new(big.Int).SetBytes(e)
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
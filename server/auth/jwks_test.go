@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jwksOf renders the given public keys as a JWKS document
+func jwksOf(t *testing.T, keys map[string]*rsa.PublicKey) io.Reader {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	for kid, key := range keys {
+		doc.Keys = append(doc.Keys, jwk{
+			Kty: "RSA", Kid: kid, Use: "sig", Alg: "RS256",
+			N: base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	raw, err := json.Marshal(doc)
+	require.NoError(t, err)
+	return bytes.NewReader(raw)
+}
+
+func TestLoadJWKSFile(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(jwksOf(t, map[string]*rsa.PublicKey{"chave-1": &key.PublicKey}))
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, raw, 0o600))
+
+	keys, err := LoadJWKSFile(path)
+	require.NoError(t, err)
+	got, ok := keys.Key("chave-1")
+	assert.True(t, ok)
+	assert.True(t, key.PublicKey.Equal(got))
+	_, ok = keys.Key("")
+	assert.True(t, ok, "a token without kid can use the only key")
+
+	_, err = LoadJWKSFile(filepath.Join(t.TempDir(), "inexistente.json"))
+	assert.Error(t, err)
+}
+
+func TestReadJWKS_Invalido(t *testing.T) {
+	for _, doc := range []string{
+		`not json`,
+		`{"keys": []}`,
+		`{"keys": [{"kty": "EC", "kid": "a"}]}`,
+		`{"keys": [{"kty": "RSA", "kid": "a", "n": "!!", "e": "AQAB"}]}`,
+		`{"keys": [{"kty": "RSA", "kid": "a", "n": "AQAB", "e": "AQAB"}, {"kty": "RSA", "kid": "a", "n": "AQAB", "e": "AQAB"}]}`,
+	} {
+		_, err := ReadJWKS(strings.NewReader(doc))
+		assert.Error(t, err, doc)
+	}
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+)
+
+// Signing algorithms accepted in tokens
+const (
+	algHS256 = "HS256"
+	algRS256 = "RS256"
+)
+
+// DefaultLeeway is the clock skew tolerated when checking the expiration and start of a token
+const DefaultLeeway = time.Minute
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, badly signed or missing required claims
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrExpiredToken is returned for well signed tokens used outside of their validity period
+	ErrExpiredToken = errors.New("token is expired or not valid yet")
+)
+
+// Verifier checks a bearer credential and returns who it belongs to
+type Verifier interface {
+	Verify(token string) (Principal, error)
+}
+
+// JWTConfig configures which JWTs a JWTVerifier accepts. At least one of Secret and Keys is required.
+type JWTConfig struct {
+	// Secret verifies HS256 tokens
+	Secret []byte
+	// Keys verify RS256 tokens, chosen by the "kid" header
+	Keys *KeySet
+	// Issuer, when set, must match the "iss" claim
+	Issuer string
+	// Audience, when set, must be one of the "aud" claim
+	Audience string
+	// Leeway is the tolerated clock skew, DefaultLeeway when zero
+	Leeway time.Duration
+}
+
+// JWTVerifier verifies signed JWTs and reads the subject and role from their claims
+type JWTVerifier struct {
+	config JWTConfig
+	now    func() time.Time
+}
+
+// NewJWTVerifier returns a verifier for the tokens described by config.
+func NewJWTVerifier(config JWTConfig) (*JWTVerifier, error) {
+	if len(config.Secret) == 0 && config.Keys == nil {
+		return nil, errors.New("a secret or a JWKS is required to verify tokens")
+	}
+	if config.Leeway == 0 {
+		config.Leeway = DefaultLeeway
+	}
+	return &JWTVerifier{config: config, now: time.Now}, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+	Role      string   `json:"role"`
+	Tenant    string   `json:"tenant_id"`
+	Roles     []string `json:"roles"`
+}
+
+// audience is the "aud" claim, which may be a single string or a list of them
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New(`"aud" must be a string or a list of strings`)
+	}
+	*a = list
+	return nil
+}
+
+// SignHS256 issues a token for p signed with secret, which expires at expiresAt. Issuer and audience
+// are left out when empty. It is meant for operators minting tokens for the dashboard and scripts when
+// no identity provider issues them.
+func SignHS256(secret []byte, p Principal, issuer, audience string, expiresAt time.Time) (string, error) {
+	claims := map[string]interface{}{"sub": p.Subject, "role": p.Role, "exp": expiresAt.Unix()}
+	if p.Tenant != "" {
+		claims["tenant_id"] = p.Tenant
+	}
+	if issuer != "" {
+		claims["iss"] = issuer
+	}
+	if audience != "" {
+		claims["aud"] = audience
+	}
+	segments := make([]string, 0, 3)
+	for _, v := range []interface{}{map[string]string{"alg": algHS256, "typ": "JWT"}, claims} {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		segments = append(segments, base64.RawURLEncoding.EncodeToString(raw))
+	}
+	signed := strings.Join(segments, ".")
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Verify checks the signature and claims of a compact JWT. Tokens must carry "sub" and "exp";
+// the role comes from the "role" claim or, when absent, the most privileged of the "roles" claim,
+// and the tenant from the optional "tenant_id" claim.
+func (v *JWTVerifier) Verify(token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, fmt.Errorf("%w: not a compact JWT", ErrInvalidToken)
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Principal{}, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: signature is not base64url", ErrInvalidToken)
+	}
+	if err := v.verifySignature(header, parts[0]+"."+parts[1], signature); err != nil {
+		return Principal{}, err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Principal{}, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return Principal{}, err
+	}
+	role := Role(claims.Role)
+	if role == "" {
+		role = highestRole(claims.Roles)
+	}
+	return Principal{Subject: claims.Subject, Role: role, Tenant: claims.Tenant}, nil
+}
+
+func (v *JWTVerifier) verifySignature(header jwtHeader, signed string, signature []byte) error {
+	switch {
+	case header.Alg == algHS256 && len(v.config.Secret) > 0:
+		mac := hmac.New(sha256.New, v.config.Secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	case header.Alg == algRS256 && v.config.Keys != nil:
+		key, ok := v.config.Keys.Key(header.Kid)
+		if !ok {
+			return fmt.Errorf("%w: unknown key %q", ErrInvalidToken, header.Kid)
+		}
+		digest := sha256.Sum256([]byte(signed))
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	default:
+		return fmt.Errorf("%w: algorithm %q is not accepted", ErrInvalidToken, header.Alg)
+	}
+	return nil
+}
+
+func (v *JWTVerifier) checkClaims(claims jwtClaims) error {
+	if strings.TrimSpace(claims.Subject) == "" {
+		return fmt.Errorf(`%w: "sub" is required`, ErrInvalidToken)
+	}
+	if claims.Tenant != "" && !tenant.Valid(claims.Tenant) {
+		return fmt.Errorf(`%w: "tenant_id" is malformed`, ErrInvalidToken)
+	}
+	if claims.ExpiresAt == nil {
+		return fmt.Errorf(`%w: "exp" is required`, ErrInvalidToken)
+	}
+	now := v.now()
+	if now.After(numericDate(*claims.ExpiresAt).Add(v.config.Leeway)) {
+		return ErrExpiredToken
+	}
+	if claims.NotBefore != nil && now.Add(v.config.Leeway).Before(numericDate(*claims.NotBefore)) {
+		return ErrExpiredToken
+	}
+	if v.config.Issuer != "" && claims.Issuer != v.config.Issuer {
+		return fmt.Errorf(`%w: unexpected "iss"`, ErrInvalidToken)
+	}
+	if v.config.Audience != "" && !slices.Contains(claims.Audience, v.config.Audience) {
+		return fmt.Errorf(`%w: unexpected "aud"`, ErrInvalidToken)
+	}
+	return nil
+}
+
+// numericDate converts a JWT NumericDate, seconds since the epoch that may have a fraction, to a time.
+func numericDate(seconds float64) time.Time {
+	return time.UnixMilli(int64(seconds * 1000))
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("not base64url")
+	}
+	return json.Unmarshal(raw, v)
+}
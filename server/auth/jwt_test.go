@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testSecret = []byte("segredo-de-teste")
+	testNow    = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	raw, err := json.Marshal(v)
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func signHS256(t *testing.T, secret []byte, claims map[string]interface{}) string {
+	signed := encodeSegment(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	signed := encodeSegment(t, map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{"sub": "maria", "role": "editor", "exp": testNow.Add(time.Hour).Unix()}
+}
+
+func newTestVerifier(t *testing.T, config JWTConfig) *JWTVerifier {
+	v, err := NewJWTVerifier(config)
+	require.NoError(t, err)
+	v.now = func() time.Time { return testNow }
+	return v
+}
+
+func TestJWTVerifier_HS256(t *testing.T) {
+	v := newTestVerifier(t, JWTConfig{Secret: testSecret})
+
+	p, err := v.Verify(signHS256(t, testSecret, validClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, Principal{Subject: "maria", Role: RoleEditor}, p)
+
+	_, err = v.Verify(signHS256(t, []byte("outro segredo"), validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestSignHS256(t *testing.T) {
+	v := newTestVerifier(t, JWTConfig{Secret: testSecret, Issuer: "snet", Audience: "dashboard"})
+	principal := Principal{Subject: "maria", Role: RoleAdmin, Tenant: "acme"}
+
+	token, err := SignHS256(testSecret, principal, "snet", "dashboard", testNow.Add(time.Hour))
+	require.NoError(t, err)
+	p, err := v.Verify(token)
+	assert.NoError(t, err)
+	assert.Equal(t, principal, p)
+
+	token, err = SignHS256(testSecret, principal, "snet", "dashboard", testNow.Add(-time.Hour))
+	require.NoError(t, err)
+	_, err = v.Verify(token)
+	assert.ErrorIs(t, err, ErrExpiredToken)
+}
+
+func TestJWTVerifier_RS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keys, err := ReadJWKS(jwksOf(t, map[string]*rsa.PublicKey{"chave-1": &key.PublicKey}))
+	require.NoError(t, err)
+	v := newTestVerifier(t, JWTConfig{Keys: keys})
+
+	p, err := v.Verify(signRS256(t, key, "chave-1", validClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, "maria", p.Subject)
+
+	_, err = v.Verify(signRS256(t, key, "chave-2", validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken, "unknown key")
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, err = v.Verify(signRS256(t, other, "chave-1", validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken, "signed by another key")
+
+	_, err = v.Verify(signHS256(t, testSecret, validClaims()))
+	assert.ErrorIs(t, err, ErrInvalidToken, "HS256 is not accepted without a secret")
+}
+
+func TestJWTVerifier_Claims(t *testing.T) {
+	v := newTestVerifier(t, JWTConfig{Secret: testSecret, Issuer: "snet", Audience: "api"})
+	with := func(changes map[string]interface{}) string {
+		claims := validClaims()
+		claims["iss"], claims["aud"] = "snet", []string{"web", "api"}
+		for k, value := range changes {
+			if value == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = value
+			}
+		}
+		return signHS256(t, testSecret, claims)
+	}
+
+	_, err := v.Verify(with(nil))
+	assert.NoError(t, err)
+	p, err := v.Verify(with(map[string]interface{}{"role": nil, "roles": []string{"viewer", "admin", "auditor"}}))
+	assert.NoError(t, err)
+	assert.Equal(t, RoleAdmin, p.Role, "the most privileged role is used")
+	assert.Empty(t, p.Tenant)
+	p, err = v.Verify(with(map[string]interface{}{"tenant_id": "acme"}))
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", p.Tenant)
+	_, err = v.Verify(with(map[string]interface{}{"exp": testNow.Add(-30 * time.Second).Unix()}))
+	assert.NoError(t, err, "expired within the leeway")
+
+	for name, changes := range map[string]map[string]interface{}{
+		"sem sub":          {"sub": nil},
+		"sem exp":          {"exp": nil},
+		"emissor errado":   {"iss": "outro"},
+		"audiencia errada": {"aud": "web"},
+		"tenant invalido":  {"tenant_id": "Acme Ltda"},
+	} {
+		_, err := v.Verify(with(changes))
+		assert.ErrorIs(t, err, ErrInvalidToken, name)
+	}
+	_, err = v.Verify(with(map[string]interface{}{"exp": testNow.Add(-time.Hour).Unix()}))
+	assert.ErrorIs(t, err, ErrExpiredToken)
+	_, err = v.Verify(with(map[string]interface{}{"nbf": testNow.Add(time.Hour).Unix()}))
+	assert.ErrorIs(t, err, ErrExpiredToken)
+}
+
+func TestJWTVerifier_Malformado(t *testing.T) {
+	v := newTestVerifier(t, JWTConfig{Secret: testSecret})
+	token := signHS256(t, testSecret, validClaims())
+	none := encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, validClaims()) + "."
+
+	for _, raw := range []string{"", "abc", "a.b", token + "x", none} {
+		_, err := v.Verify(raw)
+		assert.ErrorIs(t, err, ErrInvalidToken, raw)
+	}
+
+	_, err := NewJWTVerifier(JWTConfig{})
+	assert.Error(t, err)
+}
+
+func TestRole_Allows(t *testing.T) {
+	assert.True(t, RoleAdmin.Allows(RoleEditor))
+	assert.True(t, RoleEditor.Allows(RoleEditor))
+	assert.False(t, RoleViewer.Allows(RoleEditor))
+	assert.False(t, Role("").Allows(RoleViewer))
+	assert.False(t, Role("root").Allows(RoleViewer))
+}
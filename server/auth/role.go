@@ -0,0 +1,78 @@
+// Package auth verifies the credentials sent to the API and describes who is calling it and what
+// they are allowed to do.
+package auth
+
+import "context"
+
+// Role is what a caller is allowed to do. Each role can do everything the roles before it can.
+type Role string
+
+// Roles, from the least to the most privileged
+const (
+	// RoleViewer can read establishments, stores and their related data
+	RoleViewer Role = "viewer"
+	// RoleEditor can also create and change establishments and stores
+	RoleEditor Role = "editor"
+	// RoleAdmin can also delete and restore them and read the audit log and deleted rows
+	RoleAdmin Role = "admin"
+)
+
+var roleRanks = map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3}
+
+// Valid reports whether r is one of the known roles.
+func (r Role) Valid() bool {
+	return roleRanks[r] > 0
+}
+
+// Allows reports whether r grants what required grants. Unknown roles grant nothing.
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[required]
+}
+
+// highestRole returns the most privileged known role of roles, or "" when none is known.
+func highestRole(roles []string) Role {
+	var highest Role
+	for _, name := range roles {
+		if role := Role(name); roleRanks[role] > roleRanks[highest] {
+			highest = role
+		}
+	}
+	return highest
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// Subject identifies the caller, and is recorded as the actor of the changes they make
+	Subject string
+	Role    Role
+	// Tenant is the organization the caller belongs to, or "" when they are not bound to one
+	Tenant string
+	// Scopes restricts an API key to some resources. It is nil for users, whose role alone decides.
+	Scopes []Scope
+}
+
+// HasScope reports whether p may do what scope grants, which users always may as far as scopes go.
+func (p Principal) HasScope(scope Scope) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	for _, granted := range p.Scopes {
+		if granted.Allows(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+type ctxKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the given principal.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext returns the principal carried by ctx and whether the request was authenticated.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(Principal)
+	return p, ok
+}
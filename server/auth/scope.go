@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+)
+
+// Scope is what an API key may do with one kind of resource
+type Scope string
+
+// Scopes of the API keys. Writing a resource also allows reading it.
+const (
+	ScopeReadEstablishments  Scope = "read:establishments"
+	ScopeWriteEstablishments Scope = "write:establishments"
+	ScopeReadStores          Scope = "read:stores"
+	ScopeWriteStores         Scope = "write:stores"
+)
+
+// Scopes lists every scope an API key can be granted
+var Scopes = []Scope{ScopeReadEstablishments, ScopeWriteEstablishments, ScopeReadStores, ScopeWriteStores}
+
+// Valid reports whether s is one of the known scopes.
+func (s Scope) Valid() bool {
+	return slices.Contains(Scopes, s)
+}
+
+// Allows reports whether s grants what required grants. Unknown scopes grant nothing.
+func (s Scope) Allows(required Scope) bool {
+	if !s.Valid() {
+		return false
+	}
+	if s == required {
+		return true
+	}
+	resource, ok := strings.CutPrefix(string(required), "read:")
+	return ok && s == Scope("write:"+resource)
+}
+
+// RoleForScopes returns the role that lets an API key do what its scopes allow: editor when it may write
+// something, viewer otherwise. API keys are never admins.
+func RoleForScopes(scopes []Scope) Role {
+	for _, s := range scopes {
+		if strings.HasPrefix(string(s), "write:") {
+			return RoleEditor
+		}
+	}
+	return RoleViewer
+}
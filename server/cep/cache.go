@@ -0,0 +1,73 @@
+package cep
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+// Cache is a Provider that keeps the addresses found by another one in memory for a while. Unknown CEPs are
+// cached too, while failed lookups are not, so they are retried on the next call.
+type Cache struct {
+	provider   Provider
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	// address is nil for a CEP the provider does not know
+	address *model.Address
+	expires time.Time
+}
+
+// NewCache caches the lookups of provider for ttl, holding at most maxEntries CEPs.
+func NewCache(provider Provider, ttl time.Duration, maxEntries int) *Cache {
+	return &Cache{provider: provider, ttl: ttl, maxEntries: maxEntries, now: time.Now, entries: map[string]cacheEntry{}}
+}
+
+func (c *Cache) Lookup(ctx context.Context, cep string) (*model.Address, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[cep]
+	c.mu.Unlock()
+	if !ok || !c.now().Before(entry.expires) {
+		address, err := c.provider.Lookup(ctx, cep)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		entry = cacheEntry{address: address, expires: c.now().Add(c.ttl)}
+		c.store(cep, entry)
+	}
+	if entry.address == nil {
+		return nil, ErrNotFound
+	}
+	address := *entry.address
+	return &address, nil
+}
+
+// store adds an entry, making room first by dropping the expired ones or, when none expired, an arbitrary one
+func (c *Cache) store(cep string, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[cep]; !ok && len(c.entries) >= c.maxEntries {
+		now := c.now()
+		for key, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, key)
+			}
+		}
+		for key := range c.entries {
+			if len(c.entries) < c.maxEntries {
+				break
+			}
+			delete(c.entries, key)
+		}
+	}
+	c.entries[cep] = entry
+}
@@ -0,0 +1,74 @@
+package cep
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+// countingProvider knows the CEP 01310100 and counts its lookups
+type countingProvider struct {
+	calls map[string]int
+	err   error
+}
+
+func (p *countingProvider) Lookup(ctx context.Context, cep string) (*model.Address, error) {
+	p.calls[cep]++
+	if p.err != nil {
+		return nil, p.err
+	}
+	if cep != "01310100" {
+		return nil, ErrNotFound
+	}
+	return &model.Address{CEP: cep, City: "São Paulo", State: "SP"}, nil
+}
+
+func TestCache_Lookup(t *testing.T) {
+	provider := &countingProvider{calls: map[string]int{}}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewCache(provider, time.Hour, 10)
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		address, err := cache.Lookup(context.Background(), "01310100")
+		require.NoError(t, err)
+		assert.Equal(t, "São Paulo", address.City)
+		address.City = "alterada"
+
+		_, err = cache.Lookup(context.Background(), "99999999")
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+	assert.Equal(t, map[string]int{"01310100": 1, "99999999": 1}, provider.calls)
+
+	// Expired entries are looked up again
+	now = now.Add(time.Hour)
+	_, err := cache.Lookup(context.Background(), "01310100")
+	require.NoError(t, err)
+	assert.Equal(t, 2, provider.calls["01310100"])
+}
+
+func TestCache_FalhaNaoFicaEmCache(t *testing.T) {
+	provider := &countingProvider{calls: map[string]int{}, err: errors.New("timeout")}
+	cache := NewCache(provider, time.Hour, 10)
+
+	_, err := cache.Lookup(context.Background(), "01310100")
+	assert.EqualError(t, err, "timeout")
+	provider.err = nil
+	_, err = cache.Lookup(context.Background(), "01310100")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, provider.calls["01310100"])
+}
+
+func TestCache_LimiteDeEntradas(t *testing.T) {
+	provider := &countingProvider{calls: map[string]int{}}
+	cache := NewCache(provider, time.Hour, 2)
+	for _, cep := range []string{"11111111", "22222222", "33333333", "44444444"} {
+		_, _ = cache.Lookup(context.Background(), cep)
+	}
+	assert.Len(t, cache.entries, 2)
+}
@@ -0,0 +1,72 @@
+package cep
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+// FileProvider is an offline Provider backed by a table of addresses
+type FileProvider struct {
+	addresses map[string]model.Address
+}
+
+// LoadFileProvider reads the addresses of a CSV file, see ReadFileProvider.
+func LoadFileProvider(path string) (*FileProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadFileProvider(f)
+}
+
+// ReadFileProvider reads addresses from CSV with the cep, street, neighborhood, city and state columns,
+// in this order and after a header line.
+func ReadFileProvider(r io.Reader) (*FileProvider, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 5
+	if _, err := cr.Read(); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("cep: address file is empty")
+		}
+		return nil, fmt.Errorf("cep: reading address file: %w", err)
+	}
+
+	p := &FileProvider{addresses: map[string]model.Address{}}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return p, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cep: reading address file: %w", err)
+		}
+		cep, err := Normalize(record[0])
+		if err != nil {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("cep: address file line %d: %w", line, err)
+		}
+		p.addresses[cep] = model.Address{
+			CEP:          cep,
+			Street:       strings.TrimSpace(record[1]),
+			Neighborhood: strings.TrimSpace(record[2]),
+			City:         strings.TrimSpace(record[3]),
+			State:        strings.TrimSpace(record[4]),
+		}
+	}
+}
+
+func (p *FileProvider) Lookup(ctx context.Context, cep string) (*model.Address, error) {
+	address, ok := p.addresses[cep]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &address, nil
+}
@@ -0,0 +1,34 @@
+package cep
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+func TestFileProvider_Lookup(t *testing.T) {
+	p, err := LoadFileProvider("testdata/addresses.csv")
+	require.NoError(t, err)
+
+	address, err := p.Lookup(context.Background(), "50030230")
+	require.NoError(t, err)
+	assert.Equal(t, &model.Address{CEP: "50030230", Street: "Rua da Aurora", Neighborhood: "Boa Vista", City: "Recife", State: "PE"}, address)
+
+	_, err = p.Lookup(context.Background(), "99999999")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestReadFileProvider_Invalido(t *testing.T) {
+	for name, content := range map[string]string{
+		"vazio":            "",
+		"cep invalido":     "cep,street,neighborhood,city,state\n0131,Rua,Bairro,Cidade,SP\n",
+		"colunas faltando": "cep,street,neighborhood,city,state\n01310100,Rua,Bairro,Cidade\n",
+	} {
+		_, err := ReadFileProvider(strings.NewReader(content))
+		assert.Error(t, err, name)
+	}
+}
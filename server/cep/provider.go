@@ -0,0 +1,31 @@
+// Package cep looks up the address of Brazilian zip codes (CEP).
+package cep
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/util"
+)
+
+var (
+	// ErrNotFound is returned by a Provider that does not know the CEP
+	ErrNotFound = errors.New("cep: not found")
+	// ErrInvalid is returned by Normalize for a malformed CEP
+	ErrInvalid = errors.New("cep: must have 8 digits, as 12345-678 or 12345678")
+)
+
+// Provider finds the address of a CEP, given as 8 digits. Implementations fail with ErrNotFound for CEPs
+// they do not know, and with any other error when they could not look it up.
+type Provider interface {
+	Lookup(ctx context.Context, cep string) (*model.Address, error)
+}
+
+// Normalize returns the 8 digits of a CEP written as 12345-678 or 12345678, failing with ErrInvalid otherwise.
+func Normalize(cep string) (string, error) {
+	if !util.IsValidCEP(cep) {
+		return "", ErrInvalid
+	}
+	return util.NormalizeCEP(cep), nil
+}
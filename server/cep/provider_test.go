@@ -0,0 +1,19 @@
+package cep
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{"01310-100": "01310100", "01310100": "01310100", " 50030-230 ": "50030230"} {
+		got, err := Normalize(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"", "0131010", "013101000", "01310-10", "01.310-100", "0131a100", "01310--100"} {
+		_, err := Normalize(in)
+		assert.ErrorIs(t, err, ErrInvalid, in)
+	}
+}
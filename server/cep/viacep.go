@@ -0,0 +1,82 @@
+package cep
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+const (
+	// DefaultViaCEPURL is the address of the public ViaCEP service
+	DefaultViaCEPURL = "https://viacep.com.br"
+	// viaCEPTimeout bounds lookups made with the default HTTP client
+	viaCEPTimeout = 5 * time.Second
+	// maxViaCEPResponse bounds the size of a response body read from the service
+	maxViaCEPResponse = 64 << 10
+)
+
+// ViaCEP is a Provider backed by the ViaCEP web service, or by any service answering GET /ws/{cep}/json/ the same way.
+type ViaCEP struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewViaCEP creates a ViaCEP client for the service at baseURL. A nil client uses one with a 5 second timeout.
+func NewViaCEP(baseURL string, client *http.Client) *ViaCEP {
+	if client == nil {
+		client = &http.Client{Timeout: viaCEPTimeout}
+	}
+	return &ViaCEP{baseURL: strings.TrimSuffix(baseURL, "/"), client: client}
+}
+
+// viaCEPResponse is the body ViaCEP answers with. Unknown CEPs come back with status 200 and erro set,
+// which older versions of the service send as the string "true".
+type viaCEPResponse struct {
+	Logradouro string     `json:"logradouro"`
+	Bairro     string     `json:"bairro"`
+	Localidade string     `json:"localidade"`
+	UF         string     `json:"uf"`
+	Erro       viaCEPFlag `json:"erro"`
+}
+
+type viaCEPFlag bool
+
+func (f *viaCEPFlag) UnmarshalJSON(b []byte) error {
+	*f = strings.Trim(string(b), `"`) == "true"
+	return nil
+}
+
+func (v *ViaCEP) Lookup(ctx context.Context, cep string) (*model.Address, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.baseURL+"/ws/"+cep+"/json/", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cep: viacep request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusBadRequest:
+		// Only sent for malformed CEPs, which can not exist
+		return nil, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("cep: viacep answered %s", resp.Status)
+	}
+	var body viaCEPResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxViaCEPResponse)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("cep: invalid viacep response: %w", err)
+	}
+	if body.Erro {
+		return nil, ErrNotFound
+	}
+	return &model.Address{CEP: cep, Street: body.Logradouro, Neighborhood: body.Bairro, City: body.Localidade, State: body.UF}, nil
+}
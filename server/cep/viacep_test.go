@@ -0,0 +1,45 @@
+package cep
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+func TestViaCEP_Lookup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ws/01310100/json/":
+			w.Write([]byte(`{"cep":"01310-100","logradouro":"Avenida Paulista","complemento":"de 612 a 1510 - lado par",
+				"bairro":"Bela Vista","localidade":"São Paulo","uf":"SP","ibge":"3550308"}`))
+		case "/ws/99999999/json/":
+			w.Write([]byte(`{"erro": true}`))
+		case "/ws/99999998/json/":
+			w.Write([]byte(`{"erro": "true"}`))
+		case "/ws/00000000/json/":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+	v := NewViaCEP(server.URL+"/", nil)
+
+	address, err := v.Lookup(context.Background(), "01310100")
+	require.NoError(t, err)
+	assert.Equal(t, &model.Address{CEP: "01310100", Street: "Avenida Paulista", Neighborhood: "Bela Vista", City: "São Paulo", State: "SP"}, address)
+
+	for _, cep := range []string{"99999999", "99999998", "00000000"} {
+		_, err = v.Lookup(context.Background(), cep)
+		assert.ErrorIs(t, err, ErrNotFound, cep)
+	}
+
+	_, err = v.Lookup(context.Background(), "12345678")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	"github.com/yMaatheus/tech-challenge-snet/config"
+)
+
+// newJWTVerifier builds the verifier of the tokens accepted by the API from its settings.
+func newJWTVerifier(settings config.AuthSettings) (*auth.JWTVerifier, error) {
+	jwtConfig := auth.JWTConfig{
+		Secret:   []byte(settings.Secret),
+		Issuer:   settings.Issuer,
+		Audience: settings.Audience,
+	}
+	if settings.JWKSFile != "" {
+		keys, err := auth.LoadJWKSFile(settings.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		jwtConfig.Keys = keys
+	}
+	return auth.NewJWTVerifier(jwtConfig)
+}
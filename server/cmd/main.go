@@ -4,22 +4,51 @@
 // @host      localhost:8080
 // @BasePath  /
 
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 "Bearer " followed by a JWT with the viewer, editor or admin role
+
+// @securityDefinitions.apikey  APIKeyAuth
+// @in                          header
+// @name                        X-API-Key
+// @description                 API key of an integration, limited to its scopes
+
 package main
 
 import (
+	"cmp"
+	"context"
 	"os"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	echoSwagger "github.com/swaggo/echo-swagger"
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	"github.com/yMaatheus/tech-challenge-snet/cep"
 	"github.com/yMaatheus/tech-challenge-snet/config"
 	"github.com/yMaatheus/tech-challenge-snet/docs"
+	"github.com/yMaatheus/tech-challenge-snet/domain/actor"
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+	"github.com/yMaatheus/tech-challenge-snet/geo"
 	"github.com/yMaatheus/tech-challenge-snet/handler"
+	"github.com/yMaatheus/tech-challenge-snet/job"
+	"github.com/yMaatheus/tech-challenge-snet/ratelimit"
 	"github.com/yMaatheus/tech-challenge-snet/repository"
 	"github.com/yMaatheus/tech-challenge-snet/service"
 	"go.uber.org/zap"
 )
 
+const (
+	// cepCacheEntries bounds how many zip codes are kept in the address cache
+	cepCacheEntries = 10000
+	// rateLimitSweepInterval is how often the idle buckets of the Postgres rate limit store are deleted
+	rateLimitSweepInterval = 10 * time.Minute
+	// idempotencySweepInterval is how often the expired idempotency keys are deleted
+	idempotencySweepInterval = time.Hour
+)
+
 func main() {
 	// Load environment variables
 	config.LoadEnv()
@@ -31,6 +60,18 @@ func main() {
 	}
 	defer logger.Sync()
 
+	// "token -sub <who> [-role viewer|editor|admin] [-tenant <id>] [-ttl 12h]" prints a signed token and exits
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		authSettings, err := config.LoadAuthSettings()
+		if err != nil {
+			logger.Fatal("Invalid auth settings", zap.Error(err))
+		}
+		if err := runToken(authSettings, os.Args[2:], os.Stdout, time.Now()); err != nil {
+			logger.Fatal("Failed to sign the token", zap.Error(err))
+		}
+		return
+	}
+
 	// Connect to the database
 	db, err := config.ConnectDB()
 	if err != nil {
@@ -38,22 +79,163 @@ func main() {
 	}
 	defer db.Close()
 
+	// "migrate up|down|status" manages the schema and exits instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(context.Background(), db, os.Args[2:], os.Stdout, logger); err != nil {
+			logger.Fatal("Migration failed", zap.Error(err))
+		}
+		return
+	}
+	if config.MigrateOnStartup() {
+		if err := runMigrate(context.Background(), db, []string{"up"}, os.Stdout, logger); err != nil {
+			logger.Fatal("Migration failed", zap.Error(err))
+		}
+	}
+
 	// Create Echo instance
 	e := echo.New()
+	e.HTTPErrorHandler = handler.NewHTTPErrorHandler(logger)
+	// The client IP is the last one in X-Forwarded-For not added by a proxy of the private network, such as
+	// the Fly proxy, so clients cannot dodge the rate limit by forging the header
+	e.IPExtractor = echo.ExtractIPFromXFFHeader()
 	// e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
-	e.Use(middleware.CORS())
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins: []string{"*"},
+		// Browsers only let scripts read the ETag they need for If-Match, the rate limit of the client
+		// and whether a response was replayed, when they are exposed
+		ExposeHeaders: []string{
+			handler.HeaderETag,
+			handler.HeaderRateLimitLimit, handler.HeaderRateLimitRemaining, handler.HeaderRateLimitReset,
+			handler.HeaderRateLimitPolicy, echo.HeaderRetryAfter, handler.HeaderIdempotentReplayed,
+		},
+	}))
+
+	transactor := repository.NewTransactor(db)
+	apiKeyService := service.NewAPIKeyService(transactor, repository.NewAPIKeyRepository(db))
+
+	// Rate limit of each IP before authentication, so bad credentials are throttled too. Each instance
+	// keeps its own buckets unless they are shared through Postgres.
+	rateLimitSettings, err := config.LoadRateLimitSettings()
+	if err != nil {
+		logger.Fatal("Invalid rate limit settings", zap.Error(err))
+	}
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if rateLimitSettings.Store == config.RateLimitStorePostgres {
+		postgresStore := repository.NewRateLimitStore(db)
+		go job.RunRateLimitSweep(context.Background(), postgresStore, rateLimitSweepInterval, logger)
+		rateLimitStore = postgresStore
+	}
+	e.Use(handler.RateLimitIP(rateLimitStore, rateLimitSettings.IP, logger))
+
+	// Authentication of every request, by token or API key, with the roles and scopes each route
+	// requires set by the handlers
+	authSettings, err := config.LoadAuthSettings()
+	if err != nil {
+		logger.Fatal("Invalid auth settings", zap.Error(err))
+	}
+	if authSettings.Disabled {
+		logger.Warn("Authentication is disabled, every request is treated as an admin")
+		e.Use(handler.AuthenticateAs(auth.Principal{Subject: actor.Anonymous, Role: auth.RoleAdmin}))
+	} else {
+		verifier, err := newJWTVerifier(authSettings)
+		if err != nil {
+			logger.Fatal("Failed to set up token verification", zap.Error(err))
+		}
+		e.Use(handler.Authenticate(verifier))
+		e.Use(handler.AuthenticateAPIKey(apiKeyService))
+	}
+
+	// Budgets of each client once authenticated, by API key, user or IP, with separate ones for reads and writes
+	e.Use(handler.RateLimit(rateLimitStore, rateLimitSettings.Read, rateLimitSettings.Write, logger))
+
+	// Tenant of each authenticated request, to which all of its queries are scoped. Without
+	// authentication every request acts on the rows that existed before multi-tenancy.
+	tenantFallback := ""
+	if authSettings.Disabled {
+		tenantFallback = tenant.Default
+	}
+	e.Use(handler.ResolveTenant(repository.NewTenantScoper(), tenantFallback))
+
+	// Responses of the POST requests sent with an Idempotency-Key, replayed to their retries
+	idempotencyTTL, err := config.IdempotencyKeyTTL()
+	if err != nil {
+		logger.Fatal("Invalid idempotency settings", zap.Error(err))
+	}
+	if idempotencyTTL > 0 {
+		idempotencyService := service.NewIdempotencyService(transactor, repository.NewIdempotencyRepository(db), idempotencyTTL)
+		e.Use(handler.Idempotency(idempotencyService, logger))
+		go job.RunIdempotencySweep(context.Background(), idempotencyService, idempotencySweepInterval, logger)
+	}
+
+	auditRepo := repository.NewAuditRepository(db)
+
+	// Service and Handler initialization for address lookups by zip code
+	addressSettings, err := config.LoadAddressSettings()
+	if err != nil {
+		logger.Fatal("Invalid address settings", zap.Error(err))
+	}
+	var cepProvider cep.Provider = cep.NewViaCEP(cmp.Or(addressSettings.URL, cep.DefaultViaCEPURL), nil)
+	if addressSettings.Provider == config.CEPProviderFile {
+		if cepProvider, err = cep.LoadFileProvider(addressSettings.File); err != nil {
+			logger.Fatal("Failed to load the address file", zap.Error(err))
+		}
+	}
+	addressService := service.NewAddressService(cep.NewCache(cepProvider, addressSettings.CacheTTL, cepCacheEntries), addressSettings.Check)
+	handler.NewAddressHandler(e, addressService, logger)
 
 	// Repository, Service and Handler initialization for Establishment
 	establishmentRepo := repository.NewEstablishmentRepository(db)
-	establishmentService := service.NewEstablishmentService(establishmentRepo)
+	establishmentService := service.NewEstablishmentService(establishmentRepo, transactor, auditRepo, addressService)
 	handler.NewEstablishmentHandler(e, establishmentService, logger)
 
+	// Geocoder for stores saved without coordinates, disabled unless a CEP dataset is configured
+	var geocoder geo.Geocoder
+	if path := config.GeocoderCEPFile(); path != "" {
+		dataset, err := geo.LoadCEPDatasetFile(path)
+		if err != nil {
+			logger.Fatal("Failed to load the CEP dataset", zap.Error(err))
+		}
+		geocoder = dataset
+	}
+
 	// Repository, Service and Handler initialization for Store
 	storeRepo := repository.NewStoreRepository(db)
-	storeService := service.NewStoreService(storeRepo)
+	storeService := service.NewStoreService(storeRepo, establishmentRepo, transactor, auditRepo, geocoder, addressService)
 	handler.NewStoreHandler(e, storeService, logger)
 
+	// Repository, Service and Handler initialization for Store transfers
+	storeTransferRepo := repository.NewStoreTransferRepository(db)
+	storeTransferService := service.NewStoreTransferService(transactor, storeRepo, establishmentRepo, storeTransferRepo, auditRepo)
+	handler.NewStoreTransferHandler(e, storeTransferService, logger)
+
+	// Service and Handler initialization for bulk imports
+	importService := service.NewImportService(transactor, establishmentRepo, storeRepo, establishmentService, storeService)
+	handler.NewImportHandler(e, importService, logger)
+
+	// Service and Handler initialization for exports
+	handler.NewExportHandler(e, service.NewExportService(transactor, establishmentRepo, storeRepo), logger)
+
+	// Repository, Service and Handler initialization for search
+	searchService := service.NewSearchService(transactor, repository.NewSearchRepository(db))
+	handler.NewSearchHandler(e, searchService, logger)
+
+	// Service and Handler initialization for the audit log
+	handler.NewAuditHandler(e, service.NewAuditService(transactor, auditRepo), logger)
+
+	// Handler initialization for the API keys of integrations
+	handler.NewAPIKeyHandler(e, apiKeyService, logger)
+
+	// Purge job for soft deleted establishments and stores
+	retention, purgeInterval, err := config.PurgeSettings()
+	if err != nil {
+		logger.Fatal("Invalid purge settings", zap.Error(err))
+	}
+	if purgeInterval > 0 {
+		purgeService := service.NewPurgeService(transactor, establishmentRepo, storeRepo)
+		go job.RunPurge(context.Background(), purgeService, retention, purgeInterval, logger)
+	}
+
 	// Swagger endpoint
 	docs.SwaggerInfo.Host = os.Getenv("SWAGGER_HOST")
 
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yMaatheus/tech-challenge-snet/database"
+	"github.com/yMaatheus/tech-challenge-snet/migrate"
+	"go.uber.org/zap"
+)
+
+const migrateUsage = "usage: migrate up | down [steps] | status"
+
+// runMigrate implements the migrate subcommand: "up" applies the pending migrations,
+// "down" rolls back the last one (or the given number of steps) and "status" lists them.
+func runMigrate(ctx context.Context, db *sql.DB, args []string, out io.Writer, logger *zap.Logger) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	migrator, err := migrate.New(db, database.Migrations())
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			logger.Info("Migration applied", zap.Int64("version", m.Version), zap.String("name", m.Name))
+		}
+		if err == nil && len(applied) == 0 {
+			logger.Info("Schema is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return fmt.Errorf("steps must be a positive integer, got %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			logger.Info("Migration rolled back", zap.Int64("version", m.Version), zap.String("name", m.Name))
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "-"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, s.State, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	"github.com/yMaatheus/tech-challenge-snet/config"
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+)
+
+// runToken implements the token subcommand, which prints a token signed with AUTH_JWT_SECRET for the
+// dashboard or a script to send as "Authorization: Bearer <token>".
+func runToken(settings config.AuthSettings, args []string, out io.Writer, now time.Time) error {
+	flags := flag.NewFlagSet("token", flag.ContinueOnError)
+	flags.SetOutput(out)
+	subject := flags.String("sub", "", "who the token is for, recorded as the author of their changes (required)")
+	role := flags.String("role", string(auth.RoleViewer), "viewer, editor or admin")
+	tenantID := flags.String("tenant", "", "tenant the token is bound to (default: none)")
+	ttl := flags.Duration("ttl", 12*time.Hour, "how long the token is valid")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	switch {
+	case settings.Secret == "":
+		return errors.New("AUTH_JWT_SECRET is required to sign tokens")
+	case *subject == "":
+		return errors.New("-sub is required")
+	case !auth.Role(*role).Valid():
+		return fmt.Errorf("-role must be viewer, editor or admin, got %q", *role)
+	case *tenantID != "" && !tenant.Valid(*tenantID):
+		return fmt.Errorf("invalid tenant %q", *tenantID)
+	case *ttl <= 0:
+		return errors.New("-ttl must be positive")
+	}
+	principal := auth.Principal{Subject: *subject, Role: auth.Role(*role), Tenant: *tenantID}
+	token, err := auth.SignHS256([]byte(settings.Secret), principal, settings.Issuer, settings.Audience, now.Add(*ttl))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, token)
+	return err
+}
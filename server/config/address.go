@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+// CEP providers
+const (
+	CEPProviderViaCEP = "viacep"
+	CEPProviderFile   = "file"
+)
+
+const (
+	// DefaultCEPCacheTTL is how long looked up addresses are kept in memory
+	DefaultCEPCacheTTL = 24 * time.Hour
+	// DefaultAddressCheck is the address check mode used when none is configured
+	DefaultAddressCheck = model.AddressCheckOff
+)
+
+// AddressSettings configures how addresses are looked up by zip code and checked on writes
+type AddressSettings struct {
+	// Provider is CEPProviderViaCEP or CEPProviderFile
+	Provider string
+	// URL is the address of the ViaCEP compatible service, empty for the public one
+	URL string
+	// File is the CSV of addresses read by the file provider
+	File     string
+	CacheTTL time.Duration
+	// Check is the mode records are checked in, one of model.AddressCheckModes
+	Check string
+}
+
+// LoadAddressSettings reads CEP_PROVIDER (viacep or file, default viacep), CEP_PROVIDER_URL, CEP_FILE,
+// CEP_CACHE_TTL (a Go duration, default 24h) and ADDRESS_CHECK (off, flag or reject, default off).
+func LoadAddressSettings() (AddressSettings, error) {
+	s := AddressSettings{
+		Provider: strings.ToLower(os.Getenv("CEP_PROVIDER")),
+		URL:      os.Getenv("CEP_PROVIDER_URL"),
+		File:     os.Getenv("CEP_FILE"),
+		Check:    strings.ToLower(os.Getenv("ADDRESS_CHECK")),
+	}
+	if s.Provider == "" {
+		s.Provider = CEPProviderViaCEP
+	}
+	if s.Provider != CEPProviderViaCEP && s.Provider != CEPProviderFile {
+		return s, fmt.Errorf("CEP_PROVIDER must be %s or %s, got %q", CEPProviderViaCEP, CEPProviderFile, s.Provider)
+	}
+	if s.Provider == CEPProviderFile && s.File == "" {
+		return s, fmt.Errorf("CEP_FILE is required when CEP_PROVIDER is %s", CEPProviderFile)
+	}
+	if s.Check == "" {
+		s.Check = DefaultAddressCheck
+	}
+	if !slices.Contains(model.AddressCheckModes, s.Check) {
+		return s, fmt.Errorf("ADDRESS_CHECK must be one of %s, got %q", strings.Join(model.AddressCheckModes, ", "), s.Check)
+	}
+	var err error
+	s.CacheTTL, err = durationEnv("CEP_CACHE_TTL", DefaultCEPCacheTTL)
+	return s, err
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// MinJWTSecretLength is the shortest HS256 secret accepted, as shorter ones can be brute forced
+const MinJWTSecretLength = 32
+
+// AuthSettings configures how requests are authenticated
+type AuthSettings struct {
+	// Disabled treats every request as an admin, for local development only
+	Disabled bool
+	// Secret verifies HS256 tokens
+	Secret string
+	// JWKSFile is the path of the JWKS whose keys verify RS256 tokens
+	JWKSFile string
+	// Issuer and Audience, when set, must match the "iss" and "aud" claims of the tokens
+	Issuer   string
+	Audience string
+}
+
+// LoadAuthSettings reads AUTH_JWT_SECRET, AUTH_JWKS_FILE, AUTH_ISSUER, AUTH_AUDIENCE and AUTH_DISABLED.
+// Unless authentication is disabled, a secret or a JWKS file is required.
+func LoadAuthSettings() (AuthSettings, error) {
+	s := AuthSettings{
+		Secret:   os.Getenv("AUTH_JWT_SECRET"),
+		JWKSFile: os.Getenv("AUTH_JWKS_FILE"),
+		Issuer:   os.Getenv("AUTH_ISSUER"),
+		Audience: os.Getenv("AUTH_AUDIENCE"),
+	}
+	if raw := os.Getenv("AUTH_DISABLED"); raw != "" {
+		disabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return s, fmt.Errorf("AUTH_DISABLED must be true or false, got %q", raw)
+		}
+		s.Disabled = disabled
+	}
+	if s.Disabled {
+		return s, nil
+	}
+	if s.Secret == "" && s.JWKSFile == "" {
+		return s, errors.New("AUTH_JWT_SECRET or AUTH_JWKS_FILE is required, or AUTH_DISABLED=true for local development")
+	}
+	if s.Secret != "" && len(s.Secret) < MinJWTSecretLength {
+		return s, fmt.Errorf("AUTH_JWT_SECRET must have at least %d characters", MinJWTSecretLength)
+	}
+	return s, nil
+}
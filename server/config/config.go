@@ -22,10 +22,15 @@ func ConnectDB() (*sql.DB, error) {
     if dsn == "" {
         return nil, errors.New("DATABASE_URL is not set")
     }
+    pool, err := LoadDBPoolSettings()
+    if err != nil {
+        return nil, err
+    }
     db, err := sql.Open("pgx", dsn)
     if err != nil {
         return nil, err
     }
+    pool.apply(db)
     // Test connection
     if err := db.Ping(); err != nil {
         return nil, errors.New("Failed to connect to the database: " + err.Error())
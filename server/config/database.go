@@ -0,0 +1,72 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultDBMaxOpenConns bounds the connections each instance opens to Postgres
+	DefaultDBMaxOpenConns = 20
+	// DefaultDBMaxIdleConns is how many of them are kept open while idle
+	DefaultDBMaxIdleConns = 10
+	// DefaultDBConnMaxIdleTime closes the connections idle for longer
+	DefaultDBConnMaxIdleTime = 5 * time.Minute
+	// DefaultDBConnMaxLifetime replaces the connections open for longer, so they move to a restarted or failed over database
+	DefaultDBConnMaxLifetime = 30 * time.Minute
+)
+
+// DBPoolSettings bounds the connection pool of an instance. Requests past MaxOpenConns wait for a
+// connection instead of exhausting max_connections of Postgres.
+type DBPoolSettings struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxIdleTime time.Duration
+	ConnMaxLifetime time.Duration
+}
+
+// LoadDBPoolSettings reads DB_MAX_OPEN_CONNS (default 20) and DB_MAX_IDLE_CONNS (default 10), and
+// DB_CONN_MAX_IDLE_TIME (default 5m) and DB_CONN_MAX_LIFETIME (default 30m) as Go durations.
+func LoadDBPoolSettings() (DBPoolSettings, error) {
+	var (
+		s   DBPoolSettings
+		err error
+	)
+	if s.MaxOpenConns, err = positiveIntEnv("DB_MAX_OPEN_CONNS", DefaultDBMaxOpenConns); err != nil {
+		return s, err
+	}
+	if s.MaxIdleConns, err = positiveIntEnv("DB_MAX_IDLE_CONNS", DefaultDBMaxIdleConns); err != nil {
+		return s, err
+	}
+	if s.MaxIdleConns > s.MaxOpenConns {
+		return s, fmt.Errorf("DB_MAX_IDLE_CONNS must not exceed DB_MAX_OPEN_CONNS (%d), got %d", s.MaxOpenConns, s.MaxIdleConns)
+	}
+	if s.ConnMaxIdleTime, err = durationEnv("DB_CONN_MAX_IDLE_TIME", DefaultDBConnMaxIdleTime); err != nil {
+		return s, err
+	}
+	s.ConnMaxLifetime, err = durationEnv("DB_CONN_MAX_LIFETIME", DefaultDBConnMaxLifetime)
+	return s, err
+}
+
+// apply sets the limits on the pool of db
+func (s DBPoolSettings) apply(db *sql.DB) {
+	db.SetMaxOpenConns(s.MaxOpenConns)
+	db.SetMaxIdleConns(s.MaxIdleConns)
+	db.SetConnMaxIdleTime(s.ConnMaxIdleTime)
+	db.SetConnMaxLifetime(s.ConnMaxLifetime)
+}
+
+func positiveIntEnv(key string, fallback int) (int, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", key, raw)
+	}
+	return n, nil
+}
@@ -0,0 +1,9 @@
+package config
+
+import "os"
+
+// GeocoderCEPFile returns the path of the CEP coordinates dataset in GEOCODER_CEP_FILE, which stores
+// without coordinates are geocoded with. It is empty when geocoding is disabled.
+func GeocoderCEPFile() string {
+	return os.Getenv("GEOCODER_CEP_FILE")
+}
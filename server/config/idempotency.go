@@ -0,0 +1,12 @@
+package config
+
+import "time"
+
+// DefaultIdempotencyKeyTTL is how long the response of a request sent with an Idempotency-Key is replayed
+const DefaultIdempotencyKeyTTL = 24 * time.Hour
+
+// IdempotencyKeyTTL reads from IDEMPOTENCY_KEY_TTL, as a Go duration (e.g. "24h"), how long the responses
+// of requests sent with an Idempotency-Key are kept. A TTL of 0 ignores the header.
+func IdempotencyKeyTTL() (time.Duration, error) {
+	return durationEnv("IDEMPOTENCY_KEY_TTL", DefaultIdempotencyKeyTTL)
+}
@@ -0,0 +1,12 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// MigrateOnStartup reports whether MIGRATE_ON_STARTUP asks the server to apply pending migrations before serving.
+func MigrateOnStartup() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("MIGRATE_ON_STARTUP"))
+	return enabled
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// DefaultSoftDeleteRetention is how long deleted rows are kept before being purged
+	DefaultSoftDeleteRetention = 30 * 24 * time.Hour
+	// DefaultPurgeInterval is how often the purge job runs
+	DefaultPurgeInterval = time.Hour
+)
+
+// PurgeSettings reads the retention of soft deleted rows from SOFT_DELETE_RETENTION and how often
+// they are purged from PURGE_INTERVAL, both as Go durations (e.g. "720h"). An interval of 0 disables the job.
+func PurgeSettings() (retention, interval time.Duration, err error) {
+	if retention, err = durationEnv("SOFT_DELETE_RETENTION", DefaultSoftDeleteRetention); err != nil {
+		return 0, 0, err
+	}
+	if interval, err = durationEnv("PURGE_INTERVAL", DefaultPurgeInterval); err != nil {
+		return 0, 0, err
+	}
+	return retention, interval, nil
+}
+
+func durationEnv(key string, fallback time.Duration) (time.Duration, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative duration such as 720h, got %q", key, raw)
+	}
+	return d, nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yMaatheus/tech-challenge-snet/ratelimit"
+)
+
+// Rate limit stores
+const (
+	RateLimitStoreMemory   = "memory"
+	RateLimitStorePostgres = "postgres"
+)
+
+const (
+	// DefaultRateLimitRead is the budget of each client on read routes
+	DefaultRateLimitRead = "600/1m"
+	// DefaultRateLimitWrite is the budget of each client on write routes
+	DefaultRateLimitWrite = "60/1m"
+	// DefaultRateLimitIP is the budget of each IP before authentication, shared by all of its clients
+	DefaultRateLimitIP = "1200/1m"
+)
+
+// RateLimitSettings configures how many requests each client may send
+type RateLimitSettings struct {
+	// Read limits GET, HEAD and OPTIONS requests, and Write every other one. A zero limit disables it.
+	Read  ratelimit.Limit
+	Write ratelimit.Limit
+	// IP limits every request of an IP before it is authenticated, so bad credentials are throttled too
+	IP ratelimit.Limit
+	// Store is RateLimitStoreMemory, for a single instance, or RateLimitStorePostgres, shared by all of them
+	Store string
+}
+
+// LoadRateLimitSettings reads RATE_LIMIT_READ (default 600/1m), RATE_LIMIT_WRITE (default 60/1m) and
+// RATE_LIMIT_IP (default 1200/1m), as <requests>/<period> with a Go duration or 0 to disable, and
+// RATE_LIMIT_STORE (memory or postgres, default memory).
+func LoadRateLimitSettings() (RateLimitSettings, error) {
+	s := RateLimitSettings{Store: strings.ToLower(os.Getenv("RATE_LIMIT_STORE"))}
+	if s.Store == "" {
+		s.Store = RateLimitStoreMemory
+	}
+	if s.Store != RateLimitStoreMemory && s.Store != RateLimitStorePostgres {
+		return s, fmt.Errorf("RATE_LIMIT_STORE must be %s or %s, got %q", RateLimitStoreMemory, RateLimitStorePostgres, s.Store)
+	}
+	var err error
+	if s.Read, err = limitEnv("RATE_LIMIT_READ", DefaultRateLimitRead); err != nil {
+		return s, err
+	}
+	if s.Write, err = limitEnv("RATE_LIMIT_WRITE", DefaultRateLimitWrite); err != nil {
+		return s, err
+	}
+	s.IP, err = limitEnv("RATE_LIMIT_IP", DefaultRateLimitIP)
+	return s, err
+}
+
+func limitEnv(key, fallback string) (ratelimit.Limit, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		raw = fallback
+	}
+	limit, err := ratelimit.ParseLimit(raw)
+	if err != nil {
+		return limit, fmt.Errorf("%s: %w", key, err)
+	}
+	return limit, nil
+}
@@ -0,0 +1,19 @@
+// Package database embeds the SQL migrations of the schema into the server binary.
+package database
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrations returns the versioned migration files, named <version>_<name>.up.sql and <version>_<name>.down.sql.
+func Migrations() fs.FS {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
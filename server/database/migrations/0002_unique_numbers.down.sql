@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS stores_establishment_id_number_key;
+DROP INDEX IF EXISTS establishments_number_key;
@@ -0,0 +1,3 @@
+CREATE UNIQUE INDEX IF NOT EXISTS establishments_number_key ON establishments (number);
+
+CREATE UNIQUE INDEX IF NOT EXISTS stores_establishment_id_number_key ON stores (establishment_id, number);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS store_transfers (
+    id SERIAL PRIMARY KEY,
+    store_id INT NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    from_establishment_id INT NOT NULL,
+    to_establishment_id INT NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    transferred_by VARCHAR(100) NOT NULL,
+    transferred_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS store_transfers_store_id_idx ON store_transfers (store_id, transferred_at);
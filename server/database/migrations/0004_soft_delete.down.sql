@@ -0,0 +1,12 @@
+-- Deleted rows would become visible again and could clash with the full unique indexes
+DELETE FROM stores WHERE deleted_at IS NOT NULL;
+DELETE FROM establishments e WHERE e.deleted_at IS NOT NULL AND NOT EXISTS (SELECT 1 FROM stores s WHERE s.establishment_id = e.id);
+
+DROP INDEX IF EXISTS stores_establishment_id_number_active_key;
+DROP INDEX IF EXISTS establishments_number_active_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS establishments_number_key ON establishments (number);
+CREATE UNIQUE INDEX IF NOT EXISTS stores_establishment_id_number_key ON stores (establishment_id, number);
+
+ALTER TABLE stores DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE establishments DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,13 @@
+-- Soft delete: rows with deleted_at set are hidden from the API until restored or purged
+ALTER TABLE establishments ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+ALTER TABLE stores ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Numbers only need to be unique among the rows that are not deleted
+DROP INDEX IF EXISTS establishments_number_key;
+
+DROP INDEX IF EXISTS stores_establishment_id_number_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS establishments_number_active_key ON establishments (number) WHERE deleted_at IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS stores_establishment_id_number_active_key ON stores (establishment_id, number) WHERE deleted_at IS NULL;
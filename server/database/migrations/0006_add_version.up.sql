@@ -0,0 +1,4 @@
+-- Optimistic concurrency: every write bumps version, which the API exposes as the ETag
+ALTER TABLE establishments ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE stores ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
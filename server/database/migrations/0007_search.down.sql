@@ -0,0 +1,17 @@
+DROP INDEX IF EXISTS stores_search_text_trgm_idx;
+
+DROP INDEX IF EXISTS stores_search_vector_idx;
+
+DROP INDEX IF EXISTS establishments_search_text_trgm_idx;
+
+DROP INDEX IF EXISTS establishments_search_vector_idx;
+
+ALTER TABLE stores DROP COLUMN IF EXISTS search_vector, DROP COLUMN IF EXISTS search_text;
+
+ALTER TABLE establishments DROP COLUMN IF EXISTS search_vector, DROP COLUMN IF EXISTS search_text;
+
+DROP FUNCTION IF EXISTS immutable_unaccent(text);
+
+DROP EXTENSION IF EXISTS pg_trgm;
+
+DROP EXTENSION IF EXISTS unaccent;
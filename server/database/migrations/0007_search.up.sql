@@ -0,0 +1,44 @@
+-- Full-text and fuzzy search over establishments and stores, ignoring case and accents
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- unaccent is only STABLE because its dictionary could change, so generated columns and
+-- indexes go through this IMMUTABLE wrapper, which pins the dictionary
+CREATE OR REPLACE FUNCTION immutable_unaccent(text) RETURNS text
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+    AS $$ SELECT public.unaccent('public.unaccent'::regdictionary, $1) $$;
+
+-- search_text holds the searchable fields without case and accents for trigram similarity,
+-- and search_vector their tokens for full-text search, weighted by field
+ALTER TABLE establishments
+    ADD COLUMN IF NOT EXISTS search_text TEXT GENERATED ALWAYS AS (
+        lower(immutable_unaccent(number || ' ' || name || ' ' || coalesce(corporate_name, '') || ' ' ||
+            coalesce(address, '') || ' ' || coalesce(city, '')))
+    ) STORED,
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', immutable_unaccent(number || ' ' || name)), 'A') ||
+        setweight(to_tsvector('simple', immutable_unaccent(coalesce(corporate_name, ''))), 'B') ||
+        setweight(to_tsvector('simple', immutable_unaccent(coalesce(city, ''))), 'C') ||
+        setweight(to_tsvector('simple', immutable_unaccent(coalesce(address, ''))), 'D')
+    ) STORED;
+
+ALTER TABLE stores
+    ADD COLUMN IF NOT EXISTS search_text TEXT GENERATED ALWAYS AS (
+        lower(immutable_unaccent(number || ' ' || name || ' ' || coalesce(corporate_name, '') || ' ' ||
+            coalesce(address, '') || ' ' || coalesce(city, '')))
+    ) STORED,
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', immutable_unaccent(number || ' ' || name)), 'A') ||
+        setweight(to_tsvector('simple', immutable_unaccent(coalesce(corporate_name, ''))), 'B') ||
+        setweight(to_tsvector('simple', immutable_unaccent(coalesce(city, ''))), 'C') ||
+        setweight(to_tsvector('simple', immutable_unaccent(coalesce(address, ''))), 'D')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS establishments_search_vector_idx ON establishments USING GIN (search_vector);
+
+CREATE INDEX IF NOT EXISTS establishments_search_text_trgm_idx ON establishments USING GIN (search_text gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS stores_search_vector_idx ON stores USING GIN (search_vector);
+
+CREATE INDEX IF NOT EXISTS stores_search_text_trgm_idx ON stores USING GIN (search_text gin_trgm_ops);
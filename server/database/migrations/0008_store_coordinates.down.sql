@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS stores_coordinates_idx;
+
+ALTER TABLE stores DROP CONSTRAINT IF EXISTS stores_coordinates_check;
+
+ALTER TABLE stores DROP COLUMN IF EXISTS longitude;
+
+ALTER TABLE stores DROP COLUMN IF EXISTS latitude;
@@ -0,0 +1,13 @@
+-- Store coordinates, set by the client or geocoded from the zip code, for the nearby search
+ALTER TABLE stores ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+
+ALTER TABLE stores ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+
+ALTER TABLE stores ADD CONSTRAINT stores_coordinates_check CHECK (
+    (latitude IS NULL) = (longitude IS NULL)
+    AND latitude BETWEEN -90 AND 90
+    AND longitude BETWEEN -180 AND 180
+);
+
+-- The nearby search narrows the stores to a bounding box before computing distances
+CREATE INDEX IF NOT EXISTS stores_coordinates_idx ON stores (latitude, longitude) WHERE deleted_at IS NULL AND latitude IS NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE stores DROP COLUMN IF EXISTS address_mismatch;
+
+ALTER TABLE establishments DROP COLUMN IF EXISTS address_mismatch;
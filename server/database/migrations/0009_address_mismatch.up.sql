@@ -0,0 +1,4 @@
+-- Set when the city or state of a row disagree with the address of its zip code
+ALTER TABLE establishments ADD COLUMN IF NOT EXISTS address_mismatch BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE stores ADD COLUMN IF NOT EXISTS address_mismatch BOOLEAN NOT NULL DEFAULT false;
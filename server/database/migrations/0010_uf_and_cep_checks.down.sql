@@ -0,0 +1,7 @@
+ALTER TABLE stores DROP CONSTRAINT IF EXISTS stores_zip_code_check;
+
+ALTER TABLE stores DROP CONSTRAINT IF EXISTS stores_state_check;
+
+ALTER TABLE establishments DROP CONSTRAINT IF EXISTS establishments_zip_code_check;
+
+ALTER TABLE establishments DROP CONSTRAINT IF EXISTS establishments_state_check;
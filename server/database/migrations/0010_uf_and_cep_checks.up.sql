@@ -0,0 +1,40 @@
+-- States are stored as upper case UFs and zip codes as the 8 CEP digits, matching what the API now writes
+UPDATE establishments SET state = upper(btrim(state)) WHERE state <> upper(btrim(state));
+
+UPDATE stores SET state = upper(btrim(state)) WHERE state <> upper(btrim(state));
+
+UPDATE establishments SET zip_code = replace(btrim(zip_code), '-', '')
+WHERE btrim(zip_code) ~ '^[0-9]{5}-[0-9]{3}$';
+
+UPDATE stores SET zip_code = replace(btrim(zip_code), '-', '')
+WHERE btrim(zip_code) ~ '^[0-9]{5}-[0-9]{3}$';
+
+-- Rows that still break the rules have to be fixed by hand, so fail with a message pointing at them
+DO $$
+DECLARE
+    invalid_establishments BIGINT;
+    invalid_stores BIGINT;
+BEGIN
+    SELECT count(*) INTO invalid_establishments FROM establishments
+    WHERE state NOT IN ('AC','AL','AP','AM','BA','CE','DF','ES','GO','MA','MT','MS','MG','PA','PB','PR','PE','PI','RJ','RN','RS','RO','RR','SC','SP','SE','TO')
+       OR zip_code !~ '^[0-9]{8}$';
+    SELECT count(*) INTO invalid_stores FROM stores
+    WHERE state NOT IN ('AC','AL','AP','AM','BA','CE','DF','ES','GO','MA','MT','MS','MG','PA','PB','PR','PE','PI','RJ','RN','RS','RO','RR','SC','SP','SE','TO')
+       OR zip_code !~ '^[0-9]{8}$';
+    IF invalid_establishments > 0 OR invalid_stores > 0 THEN
+        RAISE EXCEPTION '% establishment(s) and % store(s) have a state that is not a UF or a zip code that is not a CEP; fix them before migrating',
+            invalid_establishments, invalid_stores;
+    END IF;
+END $$;
+
+ALTER TABLE establishments ADD CONSTRAINT establishments_state_check CHECK (
+    state IN ('AC','AL','AP','AM','BA','CE','DF','ES','GO','MA','MT','MS','MG','PA','PB','PR','PE','PI','RJ','RN','RS','RO','RR','SC','SP','SE','TO')
+);
+
+ALTER TABLE establishments ADD CONSTRAINT establishments_zip_code_check CHECK (zip_code ~ '^[0-9]{8}$');
+
+ALTER TABLE stores ADD CONSTRAINT stores_state_check CHECK (
+    state IN ('AC','AL','AP','AM','BA','CE','DF','ES','GO','MA','MT','MS','MG','PA','PB','PR','PE','PI','RJ','RN','RS','RO','RR','SC','SP','SE','TO')
+);
+
+ALTER TABLE stores ADD CONSTRAINT stores_zip_code_check CHECK (zip_code ~ '^[0-9]{8}$');
@@ -0,0 +1,52 @@
+-- Every row belongs to a tenant (organization). Rows created before multi-tenancy go to the "default" tenant,
+-- and new rows must name theirs, so the defaults are dropped once the existing rows are filled.
+ALTER TABLE establishments ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE establishments ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE stores ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE stores ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE store_transfers ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE store_transfers ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE audit_log ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE establishments ADD CONSTRAINT establishments_tenant_id_check CHECK (tenant_id ~ '^[a-z0-9][a-z0-9_-]{0,62}$');
+
+-- Numbers only have to be unique within a tenant
+DROP INDEX IF EXISTS establishments_number_active_key;
+CREATE UNIQUE INDEX IF NOT EXISTS establishments_number_active_key ON establishments (tenant_id, number) WHERE deleted_at IS NULL;
+
+-- A store belongs to the tenant of its establishment, which the foreign key now enforces
+ALTER TABLE establishments ADD CONSTRAINT establishments_id_tenant_id_key UNIQUE (id, tenant_id);
+ALTER TABLE stores DROP CONSTRAINT IF EXISTS stores_establishment_id_fkey;
+ALTER TABLE stores ADD CONSTRAINT stores_establishment_id_fkey
+    FOREIGN KEY (establishment_id, tenant_id) REFERENCES establishments (id, tenant_id) ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS establishments_tenant_id_idx ON establishments (tenant_id, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS stores_tenant_id_idx ON stores (tenant_id, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS audit_log_tenant_id_idx ON audit_log (tenant_id, id);
+
+-- Row-level security backs the tenant filter of every query: a session only sees the rows of the tenant in
+-- app.tenant_id, unless app.all_tenants is on for maintenance jobs. FORCE applies it to the table owner too;
+-- superusers and roles with BYPASSRLS still skip it.
+ALTER TABLE establishments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE establishments FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON establishments
+    USING (tenant_id = current_setting('app.tenant_id', true) OR current_setting('app.all_tenants', true) = 'on');
+
+ALTER TABLE stores ENABLE ROW LEVEL SECURITY;
+ALTER TABLE stores FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON stores
+    USING (tenant_id = current_setting('app.tenant_id', true) OR current_setting('app.all_tenants', true) = 'on');
+
+ALTER TABLE store_transfers ENABLE ROW LEVEL SECURITY;
+ALTER TABLE store_transfers FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON store_transfers
+    USING (tenant_id = current_setting('app.tenant_id', true) OR current_setting('app.all_tenants', true) = 'on');
+
+ALTER TABLE audit_log ENABLE ROW LEVEL SECURITY;
+ALTER TABLE audit_log FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON audit_log
+    USING (tenant_id = current_setting('app.tenant_id', true) OR current_setting('app.all_tenants', true) = 'on');
@@ -0,0 +1,20 @@
+-- API keys of machine-to-machine integrations. Only the SHA-256 of each key is stored; the prefix is its
+-- public part, used to look it up. Keys are found before the tenant of a request is known, so this table
+-- has no row-level security and its queries filter by tenant themselves.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id TEXT NOT NULL CHECK (tenant_id ~ '^[a-z0-9][a-z0-9_-]{0,62}$'),
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(32) NOT NULL UNIQUE,
+    key_hash CHAR(64) NOT NULL,
+    -- Space separated, as in OAuth
+    scopes TEXT NOT NULL,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    rotated_from_id BIGINT REFERENCES api_keys (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_tenant_id_idx ON api_keys (tenant_id, id);
@@ -0,0 +1,42 @@
+-- Token buckets of the rate limiter, shared by every instance of the API. They are cheap to lose, so the
+-- table is UNLOGGED: a crash empties it, which only hands every client a full bucket again.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    -- When the bucket is full again, after which the row is the same as a missing one and can be swept
+    full_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_buckets_full_at_idx ON rate_limit_buckets (full_at);
+
+-- rate_limit_take refills the bucket of p_key, holding at most p_capacity tokens and gaining p_rate per
+-- second, then takes a token from it when it holds one. The row lock serializes concurrent requests of
+-- the same client across instances, and the clock of the database is the only one used.
+CREATE OR REPLACE FUNCTION rate_limit_take(p_key TEXT, p_capacity DOUBLE PRECISION, p_rate DOUBLE PRECISION,
+    OUT tokens DOUBLE PRECISION, OUT allowed BOOLEAN)
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    INSERT INTO rate_limit_buckets AS b (key, tokens, updated_at, full_at)
+    VALUES (p_key, p_capacity, clock_timestamp(), clock_timestamp())
+    ON CONFLICT (key) DO NOTHING;
+
+    SELECT LEAST(p_capacity, b.tokens + EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at) * p_rate)
+    INTO tokens
+    FROM rate_limit_buckets b
+    WHERE b.key = p_key
+    FOR UPDATE;
+
+    allowed := tokens >= 1;
+    IF allowed THEN
+        tokens := tokens - 1;
+    END IF;
+
+    UPDATE rate_limit_buckets b
+    SET tokens = rate_limit_take.tokens,
+        updated_at = clock_timestamp(),
+        full_at = clock_timestamp() + make_interval(secs => (p_capacity - rate_limit_take.tokens) / p_rate)
+    WHERE b.key = p_key;
+END
+$$;
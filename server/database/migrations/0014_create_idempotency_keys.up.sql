@@ -0,0 +1,23 @@
+-- Responses of the POST requests sent with an Idempotency-Key, replayed to their retries until they expire.
+-- Keys belong to the client (API key or user) that sent them, so clients of a tenant never clash. A row
+-- without a status is a request still being processed.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    tenant_id TEXT NOT NULL CHECK (tenant_id ~ '^[a-z0-9][a-z0-9_-]{0,62}$'),
+    client VARCHAR(200) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    -- SHA-256 of the method, path and body of the request
+    fingerprint CHAR(64) NOT NULL,
+    status SMALLINT,
+    headers JSONB,
+    body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (tenant_id, client, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+ALTER TABLE idempotency_keys ENABLE ROW LEVEL SECURITY;
+ALTER TABLE idempotency_keys FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON idempotency_keys
+    USING (tenant_id = current_setting('app.tenant_id', true) OR current_setting('app.all_tenants', true) = 'on');
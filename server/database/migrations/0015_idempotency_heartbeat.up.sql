@@ -0,0 +1,5 @@
+-- A request still being processed refreshes heartbeat_at while it runs, so its reservation is only taken as
+-- abandoned once the heartbeats stop, e.g. with the instance that ran it, however long the request takes.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMPTZ;
+UPDATE idempotency_keys SET heartbeat_at = created_at WHERE heartbeat_at IS NULL;
+ALTER TABLE idempotency_keys ALTER COLUMN heartbeat_at SET NOT NULL;
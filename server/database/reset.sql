@@ -1,2 +1,10 @@
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS rate_limit_buckets;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS store_transfers;
 DROP TABLE IF EXISTS stores;
 DROP TABLE IF EXISTS establishments;
+DROP TABLE IF EXISTS schema_migrations;
+DROP FUNCTION IF EXISTS immutable_unaccent(text);
+DROP FUNCTION IF EXISTS rate_limit_take(TEXT, DOUBLE PRECISION, DOUBLE PRECISION);
@@ -1,10 +1,10 @@
-INSERT INTO establishments (number, name, corporate_name, address, city, state, zip_code, address_number)
+INSERT INTO establishments (number, name, corporate_name, address, city, state, zip_code, address_number, tenant_id)
 VALUES
-  ('E001', 'Establishment One', 'Establishment One Ltd', '123 Main St', 'CityA', 'CA', '12345-678', '10'),
-  ('E002', 'Establishment Two', 'Establishment Two Inc', '456 Side Ave', 'CityB', 'CB', '23456-789', '20');
+  ('11222333000181', 'Establishment One', 'Establishment One Ltd', '123 Main St', 'São Paulo', 'SP', '01310100', '10', 'default'),
+  ('44555666000181', 'Establishment Two', 'Establishment Two Inc', '456 Side Ave', 'Rio de Janeiro', 'RJ', '20040020', '20', 'default');
 
-INSERT INTO stores (number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id)
+INSERT INTO stores (number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id, tenant_id)
 VALUES
-  ('S001', 'Store Alpha', 'Alpha Ltd', '111 Store Rd', 'CityA', 'CA', '12345-000', '1', 1),
-  ('S002', 'Store Beta', 'Beta Inc', '222 Store Ave', 'CityA', 'CA', '12345-111', '2', 1),
-  ('S003', 'Store Gamma', 'Gamma LLC', '333 Shop St', 'CityB', 'CB', '23456-000', '3', 2);
+  ('11222333000262', 'Store Alpha', 'Alpha Ltd', '111 Store Rd', 'São Paulo', 'SP', '01310000', '1', 1, 'default'),
+  ('11222333000343', 'Store Beta', 'Beta Inc', '222 Store Ave', 'São Paulo', 'SP', '01311000', '2', 1, 'default'),
+  ('44555666000262', 'Store Gamma', 'Gamma LLC', '333 Shop St', 'Rio de Janeiro', 'RJ', '20040000', '3', 2, 'default');
@@ -0,0 +1,23 @@
+// Package actor carries the identity of whoever performs a request through context.Context,
+// so services can record who made a change without depending on the HTTP layer.
+package actor
+
+import "context"
+
+// Anonymous is reported when a request does not identify its actor
+const Anonymous = "anonymous"
+
+type ctxKey struct{}
+
+// WithActor returns a copy of ctx carrying the given actor.
+func WithActor(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, name)
+}
+
+// FromContext returns the actor carried by ctx, or Anonymous when there is none.
+func FromContext(ctx context.Context) string {
+	if name, ok := ctx.Value(ctxKey{}).(string); ok && name != "" {
+		return name
+	}
+	return Anonymous
+}
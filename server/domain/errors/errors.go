@@ -0,0 +1,143 @@
+// Package errors defines the domain errors shared by the repository, service and handler layers.
+//
+// Every typed error matches one of the sentinel errors with errors.Is, so callers can
+// check the category of a failure without knowing its concrete type, while the
+// handler layer uses errors.As to read the details it reports to clients.
+package errors
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrForeignKey   = errors.New("referenced resource does not exist")
+	ErrUnavailable  = errors.New("service unavailable")
+	ErrPrecondition = errors.New("precondition failed")
+)
+
+// NotFoundError reports that the requested entity does not exist.
+type NotFoundError struct {
+	Entity string
+	ID     int64
+}
+
+// NotFound returns a NotFoundError for the entity with the given id.
+func NotFound(entity string, id int64) error {
+	return &NotFoundError{Entity: entity, ID: id}
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s not found", e.Entity)
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// ConflictError reports a write rejected because it clashes with the current state,
+// such as a duplicated unique value.
+type ConflictError struct {
+	Field   string
+	Message string
+}
+
+func (e *ConflictError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s already exists", e.Field)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// ValidationError reports invalid input, optionally with a message per field.
+type ValidationError struct {
+	Message string
+	Fields  map[string]string
+}
+
+// Validation returns a ValidationError with the given message.
+func Validation(message string) error {
+	return &ValidationError{Message: message}
+}
+
+// ValidationFields returns a ValidationError describing the invalid fields.
+func ValidationFields(fields map[string]string) error {
+	return &ValidationError{Message: "one or more fields are invalid", Fields: fields}
+}
+
+func (e *ValidationError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return ErrValidation.Error()
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// ForeignKeyError reports a write referencing a related entity that does not exist.
+type ForeignKeyError struct {
+	Field   string
+	Message string
+}
+
+func (e *ForeignKeyError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s references a resource that does not exist", e.Field)
+}
+
+func (e *ForeignKeyError) Is(target error) bool {
+	return target == ErrForeignKey
+}
+
+// PreconditionFailedError reports a write based on a version of the entity that is no longer current,
+// meaning someone else changed it since it was read.
+type PreconditionFailedError struct {
+	Entity string
+	ID     int64
+}
+
+// PreconditionFailed returns a PreconditionFailedError for the entity with the given id.
+func PreconditionFailed(entity string, id int64) error {
+	return &PreconditionFailedError{Entity: entity, ID: id}
+}
+
+func (e *PreconditionFailedError) Error() string {
+	return fmt.Sprintf("%s was modified since it was read; fetch it again and retry", e.Entity)
+}
+
+func (e *PreconditionFailedError) Is(target error) bool {
+	return target == ErrPrecondition
+}
+
+// UnavailableError reports that a dependency, such as the database, could not be reached.
+type UnavailableError struct {
+	Err error
+}
+
+// Unavailable wraps err as an UnavailableError.
+func Unavailable(err error) error {
+	return &UnavailableError{Err: err}
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrUnavailable, e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UnavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
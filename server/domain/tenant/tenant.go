@@ -0,0 +1,47 @@
+// Package tenant carries the organization a request acts on through context.Context, so repositories
+// can scope every query to it without depending on the HTTP layer.
+package tenant
+
+import (
+	"context"
+	"regexp"
+)
+
+// Default is the tenant the rows that existed before multi-tenancy belong to
+const Default = "default"
+
+// idPattern is the shape of tenant IDs, kept in sync with the CHECK constraints of the schema
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Valid reports whether id is a well formed tenant ID: lower case letters, digits, "-" and "_",
+// starting with a letter or digit and at most 63 characters long.
+func Valid(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+type ctxKey struct{}
+
+type allKey struct{}
+
+// WithTenant returns a copy of ctx scoped to the given tenant.
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant ctx is scoped to and whether there is one.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(ctxKey{}).(string)
+	return id, ok && id != ""
+}
+
+// WithAllTenants returns a copy of ctx that may act on the rows of every tenant. It is meant for
+// maintenance jobs such as the purge, never for requests.
+func WithAllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allKey{}, true)
+}
+
+// AllTenants reports whether ctx may act on the rows of every tenant.
+func AllTenants(ctx context.Context) bool {
+	all, _ := ctx.Value(allKey{}).(bool)
+	return all
+}
@@ -0,0 +1,94 @@
+package geo
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// cepRegionLength is the length of the CEP prefix that identifies a region (sector and subsector)
+const cepRegionLength = 5
+
+// CEPDataset is an offline Geocoder backed by a table of CEP coordinates, such as an export of a public
+// CEP database. Entries hold either a full 8 digit CEP or a 5 digit region prefix, which is used for the
+// CEPs of that region without an entry of their own.
+type CEPDataset struct {
+	points map[string]Point
+}
+
+// LoadCEPDatasetFile reads a dataset from a CSV file, see ReadCEPDataset.
+func LoadCEPDatasetFile(path string) (*CEPDataset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadCEPDataset(f)
+}
+
+// ReadCEPDataset reads a dataset from CSV with the cep, latitude and longitude columns, in this order and
+// after a header line. CEPs may be formatted (01310-100).
+func ReadCEPDataset(r io.Reader) (*CEPDataset, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 3
+	cr.ReuseRecord = true
+	if _, err := cr.Read(); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("geo: CEP dataset is empty")
+		}
+		return nil, fmt.Errorf("geo: reading CEP dataset: %w", err)
+	}
+
+	d := &CEPDataset{points: map[string]Point{}}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return d, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("geo: reading CEP dataset: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		cep := digits(record[0])
+		if len(cep) != 8 && len(cep) != cepRegionLength {
+			return nil, fmt.Errorf("geo: CEP dataset line %d: CEP must have 8 digits, or 5 for a region", line)
+		}
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		lng, lngErr := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if latErr != nil || lngErr != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+			return nil, fmt.Errorf("geo: CEP dataset line %d: invalid coordinates", line)
+		}
+		d.points[cep] = Point{Latitude: lat, Longitude: lng}
+	}
+}
+
+// Geocode returns the coordinates of the CEP, or of its region when the dataset does not have the CEP itself.
+func (d *CEPDataset) Geocode(ctx context.Context, zipCode string) (Point, error) {
+	cep := digits(zipCode)
+	if len(cep) != 8 {
+		return Point{}, ErrNotFound
+	}
+	if p, ok := d.points[cep]; ok {
+		return p, nil
+	}
+	if p, ok := d.points[cep[:cepRegionLength]]; ok {
+		return p, nil
+	}
+	return Point{}, ErrNotFound
+}
+
+// digits keeps only the digits of s
+func digits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
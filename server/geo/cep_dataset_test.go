@@ -0,0 +1,42 @@
+package geo
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCEPDataset_Geocode(t *testing.T) {
+	d, err := LoadCEPDatasetFile("testdata/ceps.csv")
+	require.NoError(t, err)
+
+	p, err := d.Geocode(context.Background(), "01310-100")
+	require.NoError(t, err)
+	assert.Equal(t, Point{Latitude: -23.5613, Longitude: -46.6565}, p)
+
+	// Without an entry of its own the CEP falls back to its region
+	p, err = d.Geocode(context.Background(), "01310200")
+	require.NoError(t, err)
+	assert.Equal(t, Point{Latitude: -23.5640, Longitude: -46.6527}, p)
+
+	for _, cep := range []string{"99999-999", "01310", "", "abc"} {
+		_, err = d.Geocode(context.Background(), cep)
+		assert.ErrorIs(t, err, ErrNotFound, cep)
+	}
+}
+
+func TestReadCEPDataset_Invalido(t *testing.T) {
+	for name, content := range map[string]string{
+		"vazio":              "",
+		"cep curto":          "cep,latitude,longitude\n0131,-23.5,-46.6\n",
+		"latitude invalida":  "cep,latitude,longitude\n01310100,-93.5,-46.6\n",
+		"longitude invalida": "cep,latitude,longitude\n01310100,-23.5,oeste\n",
+		"colunas faltando":   "cep,latitude,longitude\n01310100,-23.5\n",
+	} {
+		_, err := ReadCEPDataset(strings.NewReader(content))
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,22 @@
+// Package geo locates addresses on the map for the nearby store search.
+package geo
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned by a Geocoder that has no coordinates for the address
+var ErrNotFound = errors.New("geo: no coordinates for address")
+
+// Point is a position in decimal degrees
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Geocoder finds the coordinates of a Brazilian address from its zip code (CEP). Implementations fail with
+// ErrNotFound for zip codes they do not know, and with any other error when they could not look it up.
+type Geocoder interface {
+	Geocode(ctx context.Context, zipCode string) (Point, error)
+}
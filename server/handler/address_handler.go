@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yMaatheus/tech-challenge-snet/service"
+	"go.uber.org/zap"
+)
+
+// AddressHandler handles the zip code (CEP) lookups used to fill in addresses
+type AddressHandler struct {
+	Service service.AddressService
+	Logger  *zap.Logger
+}
+
+// NewAddressHandler sets up the route for address lookups
+func NewAddressHandler(e *echo.Echo, svc service.AddressService, logger *zap.Logger) {
+	h := &AddressHandler{Service: svc, Logger: logger}
+	e.GET("/addresses/:cep", h.Get, requireViewer)
+}
+
+// GetAddress godoc
+// @Summary      Look up an address by zip code
+// @Description  Get the street, neighborhood, city and state of a CEP, to fill in the address of an establishment or store
+// @Tags         addresses
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Produce      json
+// @Param        cep  path      string  true  "CEP, as 12345-678 or 12345678"
+// @Success      200  {object}  model.Address
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Failure      503  {object}  Problem
+// @Router       /addresses/{cep} [get]
+func (h *AddressHandler) Get(c echo.Context) error {
+	address, err := h.Service.Lookup(c.Request().Context(), c.Param("cep"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, address)
+}
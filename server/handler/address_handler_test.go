@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"go.uber.org/zap"
+)
+
+type mockAddressService struct {
+	LookupFn func(ctx context.Context, zipCode string) (*model.Address, error)
+}
+
+func (m *mockAddressService) Lookup(ctx context.Context, zipCode string) (*model.Address, error) {
+	return m.LookupFn(ctx, zipCode)
+}
+func (m *mockAddressService) Check(ctx context.Context, zipCode, city, state string) (bool, error) {
+	return false, nil
+}
+
+func setupAddressEcho(svc *mockAddressService) *echo.Echo {
+	e := echo.New()
+	logger := zap.NewNop()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(logger)
+	e.Use(AuthenticateAs(testAdmin))
+	NewAddressHandler(e, svc, logger)
+	return e
+}
+
+func TestGetAddress(t *testing.T) {
+	var got string
+	e := setupAddressEcho(&mockAddressService{LookupFn: func(ctx context.Context, zipCode string) (*model.Address, error) {
+		got = zipCode
+		return &model.Address{CEP: "01310100", Street: "Avenida Paulista", Neighborhood: "Bela Vista", City: "São Paulo", State: "SP"}, nil
+	}})
+	req := httptest.NewRequest(http.MethodGet, "/addresses/01310-100", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "01310-100", got)
+	assert.JSONEq(t, `{"cep":"01310100","street":"Avenida Paulista","neighborhood":"Bela Vista","city":"São Paulo","state":"SP"}`, rec.Body.String())
+}
+
+func TestGetAddress_Erros(t *testing.T) {
+	for status, err := range map[int]error{
+		http.StatusBadRequest:         domainerr.ValidationFields(map[string]string{"cep": "must have 8 digits, as 12345-678 or 12345678"}),
+		http.StatusNotFound:           domainerr.NotFound("address", 0),
+		http.StatusServiceUnavailable: domainerr.Unavailable(errors.New("timeout")),
+	} {
+		e := setupAddressEcho(&mockAddressService{LookupFn: func(ctx context.Context, zipCode string) (*model.Address, error) {
+			return nil, err
+		}})
+		req := httptest.NewRequest(http.MethodGet, "/addresses/01310100", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, status, rec.Code)
+	}
+}
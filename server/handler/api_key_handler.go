@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/service"
+	"go.uber.org/zap"
+)
+
+// APIKeyHandler handles the API keys of machine-to-machine integrations
+type APIKeyHandler struct {
+	Service service.APIKeyService
+	Logger  *zap.Logger
+}
+
+// NewAPIKeyHandler sets up the routes to manage API keys, which only admins may use
+func NewAPIKeyHandler(e *echo.Echo, svc service.APIKeyService, logger *zap.Logger) {
+	h := &APIKeyHandler{Service: svc, Logger: logger}
+	e.POST("/api-keys", h.Create, requireAdmin)
+	e.GET("/api-keys", h.List, requireAdmin)
+	e.DELETE("/api-keys/:id", h.Revoke, requireAdmin)
+	e.POST("/api-keys/:id/rotate", h.Rotate, requireAdmin)
+}
+
+// CreateAPIKey godoc
+// @Summary      Create an API key
+// @Description  Issues a key for an integration of the tenant of the request, sent back in the X-API-Key header of its requests.
+// @Description  The key is only returned in this response. Scopes: read:establishments, write:establishments, read:stores,
+// @Description  write:stores; writing a resource also allows reading it.
+// @Tags         api-keys
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        key  body     model.APIKeyRequest  true  "Name, scopes and expiry of the key"
+// @Success      201  {object} model.CreatedAPIKey
+// @Failure      400  {object} Problem
+// @Failure      401  {object} Problem
+// @Failure      403  {object} Problem
+// @Failure      500  {object} Problem
+// @Router       /api-keys [post]
+func (h *APIKeyHandler) Create(c echo.Context) error {
+	var req model.APIKeyRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	created, err := h.Service.Create(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+	h.Logger.Info("API key created", zap.Int64("id", created.ID), zap.String("prefix", created.Prefix), zap.Strings("scopes", created.Scopes))
+	return c.JSON(http.StatusCreated, created)
+}
+
+// ListAPIKeys godoc
+// @Summary      List the API keys
+// @Description  Lists the keys of the tenant of the request, including expired and revoked ones, without the keys themselves
+// @Tags         api-keys
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}  model.APIKey
+// @Failure      401  {object} Problem
+// @Failure      403  {object} Problem
+// @Failure      500  {object} Problem
+// @Router       /api-keys [get]
+func (h *APIKeyHandler) List(c echo.Context) error {
+	keys, err := h.Service.FindAll(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revoke an API key
+// @Description  The key stops working at once
+// @Tags         api-keys
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id   path      int  true  "API key ID"
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /api-keys/{id} [delete]
+func (h *APIKeyHandler) Revoke(c echo.Context) error {
+	id, err := parseID(c, "id", "API key")
+	if err != nil {
+		return err
+	}
+	if err := h.Service.Revoke(c.Request().Context(), id); err != nil {
+		return err
+	}
+	h.Logger.Info("API key revoked", zap.Int64("id", id))
+	return c.JSON(http.StatusOK, map[string]string{"message": "API key revoked successfully"})
+}
+
+// RotateAPIKey godoc
+// @Summary      Rotate an API key
+// @Description  Issues a key with the same name, scopes and expiry. The old key keeps working for the overlap (default 24h,
+// @Description  at most 720h, "0s" stops it at once) so the integration can switch keys without downtime.
+// @Tags         api-keys
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        id      path     int                        true   "API key ID"
+// @Param        rotate  body     model.APIKeyRotateRequest  false  "Overlap of the old key"
+// @Success      201  {object} model.CreatedAPIKey
+// @Failure      400  {object} Problem
+// @Failure      401  {object} Problem
+// @Failure      403  {object} Problem
+// @Failure      404  {object} Problem
+// @Failure      409  {object} Problem
+// @Failure      500  {object} Problem
+// @Router       /api-keys/{id}/rotate [post]
+func (h *APIKeyHandler) Rotate(c echo.Context) error {
+	id, err := parseID(c, "id", "API key")
+	if err != nil {
+		return err
+	}
+	var req model.APIKeyRotateRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	created, err := h.Service.Rotate(c.Request().Context(), id, req)
+	if err != nil {
+		return err
+	}
+	h.Logger.Info("API key rotated", zap.Int64("id", id), zap.Int64("new_id", created.ID), zap.String("prefix", created.Prefix))
+	return c.JSON(http.StatusCreated, created)
+}
@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"go.uber.org/zap"
+)
+
+// mockAPIKeyService knows the keys in principals, and reports the expired ones as such
+type mockAPIKeyService struct {
+	principals map[string]auth.Principal
+	expired    map[string]bool
+	created    model.APIKeyRequest
+	rotated    *model.APIKeyRotateRequest
+	revokedID  int64
+}
+
+func (m *mockAPIKeyService) Create(ctx context.Context, req model.APIKeyRequest) (*model.CreatedAPIKey, error) {
+	m.created = req
+	return &model.CreatedAPIKey{APIKey: model.APIKey{ID: 1, Name: req.Name, Prefix: "a1b2c3d4e5f6", Scopes: req.Scopes}, Key: "snet_a1b2c3d4e5f6_segredo"}, nil
+}
+func (m *mockAPIKeyService) FindAll(ctx context.Context) ([]model.APIKey, error) {
+	return []model.APIKey{{ID: 1, Name: "ERP", Prefix: "a1b2c3d4e5f6", KeyHash: "hash"}}, nil
+}
+func (m *mockAPIKeyService) Revoke(ctx context.Context, id int64) error {
+	m.revokedID = id
+	return nil
+}
+func (m *mockAPIKeyService) Rotate(ctx context.Context, id int64, req model.APIKeyRotateRequest) (*model.CreatedAPIKey, error) {
+	m.rotated = &req
+	return &model.CreatedAPIKey{APIKey: model.APIKey{ID: 2, RotatedFromID: &id}, Key: "snet_novo"}, nil
+}
+func (m *mockAPIKeyService) Authenticate(ctx context.Context, key string) (auth.Principal, error) {
+	if m.expired[key] {
+		return auth.Principal{}, auth.ErrExpiredAPIKey
+	}
+	if p, ok := m.principals[key]; ok {
+		return p, nil
+	}
+	return auth.Principal{}, auth.ErrInvalidAPIKey
+}
+
+func setupAPIKeyEcho(svc *mockAPIKeyService) *echo.Echo {
+	e := echo.New()
+	logger := zap.NewNop()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(logger)
+	e.Use(AuthenticateAs(testAdmin))
+	NewAPIKeyHandler(e, svc, logger)
+	return e
+}
+
+func TestCreateAPIKey_Success(t *testing.T) {
+	svc := &mockAPIKeyService{}
+	e := setupAPIKeyEcho(svc)
+	req := httptest.NewRequest(http.MethodPost, "/api-keys", strings.NewReader(`{"name":"ERP","scopes":["read:stores"]}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"key":"snet_a1b2c3d4e5f6_segredo"`)
+	assert.Equal(t, []string{"read:stores"}, svc.created.Scopes)
+}
+
+func TestCreateAPIKey_Invalido(t *testing.T) {
+	e := setupAPIKeyEcho(&mockAPIKeyService{})
+	req := httptest.NewRequest(http.MethodPost, "/api-keys", strings.NewReader(`{"scopes":[]}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"name"`)
+	assert.Contains(t, rec.Body.String(), `"scopes"`)
+}
+
+func TestListAPIKeys_SemHash(t *testing.T) {
+	e := setupAPIKeyEcho(&mockAPIKeyService{})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api-keys", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"prefix":"a1b2c3d4e5f6"`)
+	assert.NotContains(t, rec.Body.String(), "hash")
+}
+
+func TestRevokeERotateAPIKey(t *testing.T) {
+	svc := &mockAPIKeyService{}
+	e := setupAPIKeyEcho(svc)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api-keys/7", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int64(7), svc.revokedID)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api-keys/7/rotate", nil))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Nil(t, svc.rotated.Overlap, "the body is optional")
+	assert.Contains(t, rec.Body.String(), `"rotated_from_id":7`)
+
+	req := httptest.NewRequest(http.MethodPost, "/api-keys/7/rotate", strings.NewReader(`{"overlap":"1h"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "1h", *svc.rotated.Overlap)
+}
+
+func setupAPIKeyAuthEcho(search *mockSearchService) *echo.Echo {
+	e := echo.New()
+	logger := zap.NewNop()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(logger)
+	keys := &mockAPIKeyService{
+		principals: map[string]auth.Principal{
+			"leitura": {Subject: "api-key:1", Role: auth.RoleViewer, Scopes: []auth.Scope{auth.ScopeReadEstablishments}},
+			"escrita": {Subject: "api-key:2", Role: auth.RoleEditor, Scopes: []auth.Scope{auth.ScopeWriteEstablishments}},
+			"lojas":   {Subject: "api-key:3", Role: auth.RoleViewer, Scopes: []auth.Scope{auth.ScopeReadStores}},
+		},
+		expired: map[string]bool{"vencida": true},
+	}
+	e.Use(Authenticate(&fakeVerifier{tokens: map[string]auth.Principal{"admin": testAdmin}}))
+	e.Use(AuthenticateAPIKey(keys))
+	NewEstablishmentHandler(e, &mockEstablishmentService{}, logger)
+	NewSearchHandler(e, search, logger)
+	NewAPIKeyHandler(e, keys, logger)
+	return e
+}
+
+func apiKeyRequest(e *echo.Echo, method, target, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set(HeaderAPIKey, key)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGetEstablishmentByID_SemEscopoDeLojas(t *testing.T) {
+	e := setupAPIKeyAuthEcho(&mockSearchService{})
+
+	rec := apiKeyRequest(e, http.MethodGet, "/establishments/1", "leitura")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"id":1`)
+	assert.NotContains(t, rec.Body.String(), `"stores"`, "keys without read:stores do not see the stores")
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/establishments/1", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer admin")
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"stores":`)
+}
+
+func TestAuthenticateAPIKey_Escopos(t *testing.T) {
+	e := setupAPIKeyAuthEcho(&mockSearchService{})
+
+	cases := []struct {
+		key, method, target string
+		status              int
+	}{
+		{"leitura", http.MethodGet, "/establishments", http.StatusOK},
+		{"leitura", http.MethodPost, "/establishments", http.StatusForbidden},
+		{"escrita", http.MethodGet, "/establishments", http.StatusOK},
+		{"escrita", http.MethodPost, "/establishments", http.StatusBadRequest},
+		{"escrita", http.MethodDelete, "/establishments/1", http.StatusForbidden},
+		{"escrita", http.MethodGet, "/api-keys", http.StatusForbidden},
+		{"lojas", http.MethodGet, "/establishments", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		rec := apiKeyRequest(e, c.method, c.target, c.key)
+		assert.Equal(t, c.status, rec.Code, "%s %s %s", c.key, c.method, c.target)
+	}
+}
+
+func TestAuthenticateAPIKey_Recusada(t *testing.T) {
+	e := setupAPIKeyAuthEcho(&mockSearchService{})
+
+	rec := apiKeyRequest(e, http.MethodGet, "/establishments", "forjada")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "The API key is invalid.")
+	rec = apiKeyRequest(e, http.MethodGet, "/establishments", "vencida")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "The API key is expired or revoked.")
+
+	req := httptest.NewRequest(http.MethodGet, "/establishments", nil)
+	req.Header.Set(HeaderAPIKey, "leitura")
+	req.Header.Set(echo.HeaderAuthorization, "Bearer admin")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "only one credential per request")
+}
+
+func TestSearch_EscoposDaChave(t *testing.T) {
+	search := &mockSearchService{}
+	e := setupAPIKeyAuthEcho(search)
+
+	rec := apiKeyRequest(e, http.MethodGet, "/search?q=centro", "lojas")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{model.SearchTypeStore}, search.params.Types, "only the types the key may read")
+
+	rec = apiKeyRequest(e, http.MethodGet, "/search?q=centro&type=establishment", "lojas")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
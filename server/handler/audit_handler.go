@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/service"
+	"github.com/yMaatheus/tech-challenge-snet/util"
+	"go.uber.org/zap"
+)
+
+// AuditHandler exposes the audit log of establishments and stores
+type AuditHandler struct {
+	Service service.AuditService
+	Logger  *zap.Logger
+}
+
+// NewAuditHandler sets up the routes for the audit log
+func NewAuditHandler(e *echo.Echo, svc service.AuditService, logger *zap.Logger) {
+	h := &AuditHandler{Service: svc, Logger: logger}
+	e.GET("/audit", h.List, requireAdmin)
+}
+
+// ListAudit godoc
+// @Summary      List the audit log
+// @Description  Get a page of the changes made to establishments and stores, newest first, using keyset (cursor) pagination
+// @Tags         audit
+// @Security     BearerAuth
+// @Produce      json
+// @Param        entity  query  string  false  "Entity type (establishment, store)"
+// @Param        id      query  int     false  "Entity ID, requires entity"
+// @Param        from    query  string  false  "Only changes at or after this time (RFC 3339)"
+// @Param        to      query  string  false  "Only changes at or before this time (RFC 3339)"
+// @Param        limit   query  int     false  "Page size (1-100, default 20)"
+// @Param        cursor  query  string  false  "Cursor returned as next_cursor by the previous page"
+// @Success      200  {object} model.AuditPage
+// @Failure      400  {object} Problem
+// @Failure      401  {object} Problem
+// @Failure      403  {object} Problem
+// @Failure      500  {object} Problem
+// @Router       /audit [get]
+func (h *AuditHandler) List(c echo.Context) error {
+	params, err := parseAuditParams(c)
+	if err != nil {
+		return err
+	}
+	page, err := h.Service.FindAll(c.Request().Context(), params)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, page)
+}
+
+// parseAuditParams reads the filters and pagination of the audit log query params
+func parseAuditParams(c echo.Context) (model.AuditParams, error) {
+	params := model.AuditParams{
+		Entity: strings.TrimSpace(c.QueryParam("entity")),
+		Limit:  model.DefaultListLimit,
+		Cursor: c.QueryParam("cursor"),
+	}
+	if params.Entity != "" && !slices.Contains(model.AuditEntities, params.Entity) {
+		return params, domainerr.Validation("Invalid entity. Must be one of: " + strings.Join(model.AuditEntities, ", ") + ".")
+	}
+
+	if raw := c.QueryParam("id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			return params, domainerr.Validation("Invalid id. Must be a positive integer.")
+		}
+		if params.Entity == "" {
+			return params, domainerr.Validation("Filtering by id requires an entity.")
+		}
+		params.EntityID = id
+	}
+
+	for name, target := range map[string]**time.Time{"from": &params.From, "to": &params.To} {
+		if raw := c.QueryParam(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return params, domainerr.Validation("Invalid " + name + ". Must be an RFC 3339 timestamp such as 2024-01-31T15:04:05Z.")
+			}
+			*target = &t
+		}
+	}
+	if params.From != nil && params.To != nil && params.From.After(*params.To) {
+		return params, domainerr.Validation("Invalid time range: from must not be after to.")
+	}
+
+	if raw := c.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > model.MaxListLimit {
+			return params, domainerr.Validation("Invalid limit. Must be an integer between 1 and " + strconv.Itoa(model.MaxListLimit) + ".")
+		}
+		params.Limit = limit
+	}
+	if params.Cursor != "" {
+		if _, err := util.DecodeCursor(params.Cursor); err != nil {
+			return params, domainerr.Validation("Invalid cursor.")
+		}
+	}
+	return params, nil
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"go.uber.org/zap"
+)
+
+type mockAuditService struct {
+	params model.AuditParams
+}
+
+func (m *mockAuditService) FindAll(ctx context.Context, params model.AuditParams) (*model.AuditPage, error) {
+	m.params = params
+	return &model.AuditPage{
+		Items: []model.AuditEntry{{
+			ID: 1, Entity: model.AuditEntityStore, EntityID: 5, Action: model.AuditActionUpdate, Actor: "maria",
+			Changes: map[string]model.FieldChange{"name": {Before: "Antiga", After: "Nova"}},
+		}},
+		Total: 1,
+	}, nil
+}
+
+func setupAuditEcho(svc *mockAuditService) *echo.Echo {
+	e := echo.New()
+	logger := zap.NewNop()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(logger)
+	e.Use(AuthenticateAs(testAdmin))
+	NewAuditHandler(e, svc, logger)
+	return e
+}
+
+func TestListAudit_Success(t *testing.T) {
+	svc := &mockAuditService{}
+	e := setupAuditEcho(svc)
+	req := httptest.NewRequest(http.MethodGet, "/audit?entity=store&id=5&from=2024-01-01T00:00:00Z&to=2024-02-01T00:00:00Z&limit=10", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"changes":{"name":{"before":"Antiga","after":"Nova"}}`)
+	assert.Equal(t, model.AuditEntityStore, svc.params.Entity)
+	assert.Equal(t, int64(5), svc.params.EntityID)
+	assert.Equal(t, 10, svc.params.Limit)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), svc.params.From.UTC())
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), svc.params.To.UTC())
+}
+
+func TestListAudit_ParametrosInvalidos(t *testing.T) {
+	for _, query := range []string{
+		"entity=product",
+		"id=5",
+		"entity=store&id=abc",
+		"from=ontem",
+		"from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z",
+		"limit=0",
+		"cursor=invalido",
+	} {
+		t.Run(query, func(t *testing.T) {
+			e := setupAuditEcho(&mockAuditService{})
+			req := httptest.NewRequest(http.MethodGet, "/audit?"+query, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	"github.com/yMaatheus/tech-challenge-snet/domain/actor"
+)
+
+// Authenticate verifies the bearer token of each request and propagates who sent it into the request
+// context, where RequireRole checks the role and services read the subject to record who made a change.
+// Requests without an Authorization header pass through unauthenticated, so public routes keep working.
+func Authenticate(verifier auth.Verifier) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			if header == "" {
+				return next(c)
+			}
+			scheme, token, ok := strings.Cut(header, " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+				return unauthorized(c, "The Authorization header must be a bearer token.")
+			}
+			principal, err := verifier.Verify(strings.TrimSpace(token))
+			if errors.Is(err, auth.ErrExpiredToken) {
+				return unauthorized(c, "The token is expired.")
+			}
+			if err != nil {
+				return unauthorized(c, "The token is invalid.")
+			}
+			return next(withPrincipal(c, principal))
+		}
+	}
+}
+
+// HeaderAPIKey carries the API key of machine-to-machine integrations
+const HeaderAPIKey = "X-API-Key"
+
+// APIKeyAuthenticator checks API keys, as service.APIKeyService does
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (auth.Principal, error)
+}
+
+// AuthenticateAPIKey authenticates the requests sent with an X-API-Key header, whose principal is limited
+// to the scopes of the key. It goes after Authenticate, and a request may not carry both credentials.
+func AuthenticateAPIKey(keys APIKeyAuthenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(HeaderAPIKey)
+			if key == "" {
+				return next(c)
+			}
+			if _, ok := auth.FromContext(c.Request().Context()); ok {
+				return echo.NewHTTPError(http.StatusBadRequest, "Send either a bearer token or an API key, not both.")
+			}
+			principal, err := keys.Authenticate(c.Request().Context(), strings.TrimSpace(key))
+			switch {
+			case errors.Is(err, auth.ErrExpiredAPIKey):
+				return unauthorized(c, "The API key is expired or revoked.")
+			case errors.Is(err, auth.ErrInvalidAPIKey):
+				return unauthorized(c, "The API key is invalid.")
+			case err != nil:
+				return err
+			}
+			return next(withPrincipal(c, principal))
+		}
+	}
+}
+
+// AuthenticateAs treats every request as sent by principal. It stands in for Authenticate when
+// authentication is disabled for local development.
+func AuthenticateAs(principal auth.Principal) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return next(withPrincipal(c, principal))
+		}
+	}
+}
+
+// Route guards for the roles, shared by the handlers
+var (
+	requireViewer = RequireRole(auth.RoleViewer)
+	requireEditor = RequireRole(auth.RoleEditor)
+	requireAdmin  = RequireRole(auth.RoleAdmin)
+)
+
+// Route guards for the scopes of API keys, shared by the handlers
+var (
+	requireReadEstablishments  = RequireScope(auth.ScopeReadEstablishments)
+	requireWriteEstablishments = RequireScope(auth.ScopeWriteEstablishments)
+	requireReadStores          = RequireScope(auth.ScopeReadStores)
+	requireWriteStores         = RequireScope(auth.ScopeWriteStores)
+)
+
+// RequireScope rejects requests sent with an API key whose scopes do not grant scope (403). It goes after
+// a RequireRole guard, which rejects the unauthenticated ones.
+func RequireScope(scope auth.Scope) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, _ := auth.FromContext(c.Request().Context())
+			if !principal.HasScope(scope) {
+				return echo.NewHTTPError(http.StatusForbidden, "This action requires the "+string(scope)+" scope.")
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireRole rejects requests that are not authenticated (401) or whose role does not grant role (403).
+func RequireRole(role auth.Role) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := checkRole(c, role); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// checkRole returns the error RequireRole answers with when the request may not do what role grants.
+func checkRole(c echo.Context, role auth.Role) error {
+	principal, ok := auth.FromContext(c.Request().Context())
+	if !ok {
+		return unauthorized(c, "Authentication is required.")
+	}
+	if !principal.Role.Allows(role) {
+		return echo.NewHTTPError(http.StatusForbidden, "This action requires the "+string(role)+" role.")
+	}
+	return nil
+}
+
+func withPrincipal(c echo.Context, principal auth.Principal) echo.Context {
+	req := c.Request()
+	ctx := auth.WithPrincipal(req.Context(), principal)
+	c.SetRequest(req.WithContext(actor.WithActor(ctx, principal.Subject)))
+	return c
+}
+
+func unauthorized(c echo.Context, detail string) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+	return echo.NewHTTPError(http.StatusUnauthorized, detail)
+}
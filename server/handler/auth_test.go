@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	"github.com/yMaatheus/tech-challenge-snet/domain/actor"
+	"go.uber.org/zap"
+)
+
+// testAdmin authenticates the requests of the handler tests that are not about authorization
+var testAdmin = auth.Principal{Subject: "maria", Role: auth.RoleAdmin}
+
+// fakeVerifier accepts the tokens it knows, and answers any other with its error
+type fakeVerifier struct {
+	tokens map[string]auth.Principal
+	err    error
+}
+
+func (v *fakeVerifier) Verify(token string) (auth.Principal, error) {
+	if p, ok := v.tokens[token]; ok {
+		return p, nil
+	}
+	if v.err != nil {
+		return auth.Principal{}, v.err
+	}
+	return auth.Principal{}, auth.ErrInvalidToken
+}
+
+func setupAuthEcho(verifier auth.Verifier) *echo.Echo {
+	e := echo.New()
+	logger := zap.NewNop()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(logger)
+	e.Use(Authenticate(verifier))
+	NewEstablishmentHandler(e, &mockEstablishmentService{}, logger)
+	e.GET("/whoami", func(c echo.Context) error {
+		return c.String(http.StatusOK, actor.FromContext(c.Request().Context()))
+	}, requireViewer)
+	return e
+}
+
+func authRequest(e *echo.Echo, method, target, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if authorization != "" {
+		req.Header.Set(echo.HeaderAuthorization, authorization)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthenticate_NaoAutenticado(t *testing.T) {
+	e := setupAuthEcho(&fakeVerifier{err: auth.ErrExpiredToken, tokens: map[string]auth.Principal{}})
+
+	for authorization, detail := range map[string]string{
+		"":                   "Authentication is required.",
+		"Basic bWFyaWE6eA==": "The Authorization header must be a bearer token.",
+		"Bearer ":            "The Authorization header must be a bearer token.",
+		"Bearer vencido":     "The token is expired.",
+	} {
+		rec := authRequest(e, http.MethodGet, "/establishments", authorization)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, authorization)
+		assert.Equal(t, "Bearer", rec.Header().Get(echo.HeaderWWWAuthenticate), authorization)
+		assert.Contains(t, rec.Body.String(), detail, authorization)
+	}
+
+	rec := authRequest(setupAuthEcho(&fakeVerifier{}), http.MethodGet, "/establishments", "Bearer forjado")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "The token is invalid.")
+}
+
+func TestRequireRole_PorRota(t *testing.T) {
+	e := setupAuthEcho(&fakeVerifier{tokens: map[string]auth.Principal{
+		"leitor": {Subject: "joao", Role: auth.RoleViewer},
+		"editor": {Subject: "ana", Role: auth.RoleEditor},
+		"admin":  {Subject: "maria", Role: auth.RoleAdmin},
+		"outro":  {Subject: "pedro", Role: "auditor"},
+	}})
+
+	cases := []struct {
+		token, method, target string
+		status                int
+	}{
+		{"leitor", http.MethodGet, "/establishments", http.StatusOK},
+		{"leitor", http.MethodPost, "/establishments", http.StatusForbidden},
+		{"editor", http.MethodPost, "/establishments", http.StatusBadRequest},
+		{"editor", http.MethodDelete, "/establishments/1", http.StatusForbidden},
+		{"editor", http.MethodPost, "/establishments/1/restore", http.StatusForbidden},
+		{"admin", http.MethodPost, "/establishments/1/restore", http.StatusOK},
+		{"outro", http.MethodGet, "/establishments", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		rec := authRequest(e, c.method, c.target, "Bearer "+c.token)
+		assert.Equal(t, c.status, rec.Code, "%s %s %s", c.token, c.method, c.target)
+	}
+}
+
+func TestListEstablishments_IncludeDeletedSomenteAdmin(t *testing.T) {
+	e := setupAuthEcho(&fakeVerifier{tokens: map[string]auth.Principal{
+		"editor": {Subject: "ana", Role: auth.RoleEditor},
+		"admin":  {Subject: "maria", Role: auth.RoleAdmin},
+	}})
+
+	rec := authRequest(e, http.MethodGet, "/establishments?include_deleted=true", "Bearer editor")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = authRequest(e, http.MethodGet, "/establishments?include_deleted=false", "Bearer editor")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = authRequest(e, http.MethodGet, "/establishments?include_deleted=true", "Bearer admin")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAuthenticate_PropagaSubject(t *testing.T) {
+	e := setupAuthEcho(&fakeVerifier{tokens: map[string]auth.Principal{"leitor": {Subject: "joao", Role: auth.RoleViewer}}})
+	rec := authRequest(e, http.MethodGet, "/whoami", "bearer leitor")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "joao", rec.Body.String())
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"go.uber.org/zap"
+)
+
+// MIMEApplicationProblemJSON is the media type of RFC 7807 problem details
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body, returned by every failed request
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Field    string            `json:"field,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// NewHTTPErrorHandler returns the Echo error handler that maps domain errors to
+// status codes and renders them as problem details. Unexpected errors are logged
+// and answered with a generic 500 so internal details are not leaked.
+func NewHTTPErrorHandler(logger *zap.Logger) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+
+		p := problemFor(err)
+		p.Instance = c.Request().URL.Path
+		if p.Status >= http.StatusInternalServerError {
+			logger.Error("Request failed",
+				zap.String("method", c.Request().Method),
+				zap.String("path", c.Request().URL.Path),
+				zap.Int("status", p.Status),
+				zap.Error(err),
+			)
+		}
+
+		c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+		if c.Request().Method == http.MethodHead {
+			err = c.NoContent(p.Status)
+		} else {
+			err = c.JSON(p.Status, p)
+		}
+		if err != nil {
+			logger.Error("Failed to write error response", zap.Error(err))
+		}
+	}
+}
+
+func problemFor(err error) Problem {
+	var (
+		validation *domainerr.ValidationError
+		conflict   *domainerr.ConflictError
+		foreignKey *domainerr.ForeignKeyError
+		httpErr    *echo.HTTPError
+	)
+
+	switch {
+	case errors.Is(err, domainerr.ErrValidation):
+		p := newProblem(http.StatusBadRequest, err.Error())
+		if errors.As(err, &validation) {
+			p.Errors = validation.Fields
+		}
+		return p
+	case errors.Is(err, domainerr.ErrNotFound):
+		return newProblem(http.StatusNotFound, err.Error())
+	case errors.Is(err, domainerr.ErrConflict):
+		p := newProblem(http.StatusConflict, err.Error())
+		if errors.As(err, &conflict) {
+			p.Field = conflict.Field
+		}
+		return p
+	case errors.Is(err, domainerr.ErrForeignKey):
+		p := newProblem(http.StatusUnprocessableEntity, err.Error())
+		if errors.As(err, &foreignKey) && foreignKey.Field != "" {
+			p.Field = foreignKey.Field
+			p.Errors = map[string]string{foreignKey.Field: foreignKey.Error()}
+		}
+		return p
+	case errors.Is(err, domainerr.ErrPrecondition):
+		return newProblem(http.StatusPreconditionFailed, err.Error())
+	case errors.Is(err, domainerr.ErrUnavailable):
+		return newProblem(http.StatusServiceUnavailable, "The service is temporarily unavailable. Please try again later.")
+	case errors.As(err, &httpErr):
+		return newProblem(httpErr.Code, fmt.Sprint(httpErr.Message))
+	default:
+		return newProblem(http.StatusInternalServerError, "An unexpected error occurred.")
+	}
+}
+
+func newProblem(status int, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
@@ -5,9 +5,10 @@ import (
 	"strconv"
 
 	"github.com/labstack/echo/v4"
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
 	"github.com/yMaatheus/tech-challenge-snet/model"
 	"github.com/yMaatheus/tech-challenge-snet/service"
-	"github.com/yMaatheus/tech-challenge-snet/util"
 	"go.uber.org/zap"
 )
 
@@ -19,139 +20,293 @@ type EstablishmentHandler struct {
 
 // NewEstablishmentHandler registers establishment routes
 func NewEstablishmentHandler(e *echo.Echo, s service.EstablishmentService, logger *zap.Logger) {
-	h := &EstablishmentHandler{service: s}
-	e.POST("/establishments", h.Create)
-	e.GET("/establishments", h.List)
-	e.GET("/establishments/:id", h.GetByID)
-	e.PUT("/establishments/:id", h.Update)
-	e.DELETE("/establishments/:id", h.Delete)
+	h := &EstablishmentHandler{service: s, Logger: logger}
+	e.POST("/establishments", h.Create, requireEditor, requireWriteEstablishments)
+	e.GET("/establishments", h.List, requireViewer, requireReadEstablishments)
+	e.GET("/establishments/:id", h.GetByID, requireViewer, requireReadEstablishments)
+	e.PUT("/establishments/:id", h.Update, requireEditor, requireWriteEstablishments)
+	e.PATCH("/establishments/:id", h.Patch, requireEditor, requireWriteEstablishments)
+	e.DELETE("/establishments/:id", h.Delete, requireAdmin)
+	e.POST("/establishments/:id/restore", h.Restore, requireAdmin)
+}
+
+// establishmentWithoutStores writes an establishment without the stores member, which its own Stores
+// field hides, for the API keys that may not read stores
+type establishmentWithoutStores struct {
+	*model.EstablishmentWithStores
+	Stores []model.Store `json:"stores,omitempty"`
 }
 
 // Create godoc
 // @Summary      Create a new establishment
 // @Description  Creates a new establishment
 // @Tags         establishments
+// @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Accept       json
 // @Produce      json
-// @Param        establishment  body      model.Establishment  true  "Establishment to create"
+// @Param        establishment    body      model.Establishment  true   "Establishment to create"
+// @Param        Idempotency-Key  header    string               false  "Key to retry the request safely; retries get the first response"
 // @Success      201            {object}  map[string]interface{}
-// @Failure      400            {object}  map[string]interface{}
-// @Failure      500            {object}  map[string]interface{}
+// @Header       201            {string}  ETag  "Version of the establishment"
+// @Failure      400            {object}  Problem
+// @Failure      401            {object}  Problem
+// @Failure      403            {object}  Problem
+// @Failure      409            {object}  Problem
+// @Failure      500            {object}  Problem
 // @Router       /establishments [post]
 func (h *EstablishmentHandler) Create(c echo.Context) error {
 	var e model.Establishment
-	if err := c.Bind(&e); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request body"})
-	}
-	if err := util.Validate.Struct(&e); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"validation_error": util.ParseValidationError(err),
-		})
+	if err := bindAndValidate(c, &e); err != nil {
+		return err
 	}
 	if err := h.service.Create(c.Request().Context(), &e); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
+		return err
 	}
+	setETag(c, e.Version)
 	return c.JSON(http.StatusCreated, map[string]interface{}{"message": "Establishment created successfully", "id": e.ID})
 }
 
 // List godoc
-// @Summary      List all establishments
-// @Description  Get all establishments
+// @Summary      List establishments
+// @Description  Get a page of establishments with their stores total, using keyset (cursor) pagination
 // @Tags         establishments
+// @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Produce      json
-// @Success      200  {array}   model.EstablishmentWithStoresTotal
-// @Failure      500  {object}  map[string]interface{}
+// @Param        limit            query  int     false  "Page size (1-100, default 20)"
+// @Param        cursor           query  string  false  "Cursor returned as next_cursor by the previous page"
+// @Param        sort             query  string  false  "Sort field (id, number, name, city, state, zip_code), prefix with - for descending"
+// @Param        city             query  string  false  "Filter by city"
+// @Param        state            query  string  false  "Filter by state"
+// @Param        zip_prefix       query  string  false  "Filter by zip code prefix"
+// @Param        include_deleted  query  bool    false  "Also list soft deleted rows (admin only)"
+// @Success      200  {object}  model.EstablishmentPage
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /establishments [get]
 func (h *EstablishmentHandler) List(c echo.Context) error {
-	establishments, err := h.service.FindAll(c.Request().Context())
+	params, err := parseListParams(c, model.EstablishmentSortFields, false)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
+		return err
 	}
-	return c.JSON(http.StatusOK, establishments)
+	page, err := h.service.FindAll(c.Request().Context(), params)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, page)
 }
 
 // GetByID godoc
 // @Summary      Get establishment by ID
-// @Description  Get a specific establishment by its ID
+// @Description  Get a specific establishment by its ID with its stores. The stores are left out for API keys without the read:stores scope.
 // @Tags         establishments
+// @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Produce      json
 // @Param        id   path      int  true  "Establishment ID"
 // @Success      200  {object}  model.EstablishmentWithStores
-// @Failure      400  {object}  map[string]interface{}
-// @Failure      404  {object}  map[string]interface{}
-// @Failure      500  {object}  map[string]interface{}
+// @Header       200  {string}  ETag  "Version of the establishment, to send as If-Match when changing it"
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /establishments/{id} [get]
 func (h *EstablishmentHandler) GetByID(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || id <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error": "Invalid establishment ID. Must be a positive integer.",
-		})
+	id, err := parseID(c, "id", "establishment")
+	if err != nil {
+		return err
 	}
 	establishment, err := h.service.FindByID(c.Request().Context(), id)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
+		return err
 	}
-	if establishment == nil {
-		return c.JSON(http.StatusNotFound, map[string]interface{}{"error": "Establishment not found"})
+	setETag(c, establishment.Version)
+	if principal, _ := auth.FromContext(c.Request().Context()); !principal.HasScope(auth.ScopeReadStores) {
+		return c.JSON(http.StatusOK, establishmentWithoutStores{EstablishmentWithStores: establishment})
 	}
 	return c.JSON(http.StatusOK, establishment)
 }
 
 // Update godoc
 // @Summary      Update establishment
-// @Description  Update an existing establishment by its ID
+// @Description  Update an existing establishment by its ID. If-Match must hold the ETag the establishment was read with,
+// @Description  and the update fails with 412 when someone else changed it since.
 // @Tags         establishments
+// @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Accept       json
 // @Produce      json
 // @Param        id             path      int                 true  "Establishment ID"
+// @Param        If-Match       header    string              true  "ETag of the establishment, or * to skip the check"
 // @Param        establishment  body      model.Establishment true  "Establishment data"
 // @Success      200            {object}  map[string]interface{}
-// @Failure      400            {object}  map[string]interface{}
-// @Failure      500            {object}  map[string]interface{}
+// @Header       200            {string}  ETag  "New version of the establishment"
+// @Failure      400            {object}  Problem
+// @Failure      401            {object}  Problem
+// @Failure      403            {object}  Problem
+// @Failure      404            {object}  Problem
+// @Failure      409            {object}  Problem
+// @Failure      412            {object}  Problem
+// @Failure      428            {object}  Problem
+// @Failure      500            {object}  Problem
 // @Router       /establishments/{id} [put]
 func (h *EstablishmentHandler) Update(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || id <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error": "Invalid establishment ID. Must be a positive integer.",
-		})
+	id, err := parseID(c, "id", "establishment")
+	if err != nil {
+		return err
 	}
-	var e model.Establishment
-	if err := c.Bind(&e); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Invalid request body"})
+	version, err := parseIfMatch(c, "establishment", id)
+	if err != nil {
+		return err
 	}
-	if err := util.Validate.Struct(&e); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"validation_error": util.ParseValidationError(err),
-		})
+	var e model.Establishment
+	if err := bindAndValidate(c, &e); err != nil {
+		return err
 	}
 	e.ID = id
+	e.Version = version
 	if err := h.service.Update(c.Request().Context(), &e); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
+		return err
 	}
+	setETag(c, e.Version)
 	return c.JSON(http.StatusOK, map[string]interface{}{"message": "Establishment updated successfully"})
 }
 
+// Patch godoc
+// @Summary      Partially update establishment
+// @Description  Apply a JSON Merge Patch (RFC 7396, application/merge-patch+json or application/json) or a JSON Patch
+// @Description  (RFC 6902, application/json-patch+json) to an establishment. Only the merged result is validated and only
+// @Description  the changed columns are written. If-Match must hold the ETag the establishment was read with.
+// @Tags         establishments
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Accept       json
+// @Produce      json
+// @Param        id        path      int     true  "Establishment ID"
+// @Param        If-Match  header    string  true  "ETag of the establishment, or * to skip the check"
+// @Param        patch     body      object  true  "Merge patch object or array of JSON Patch operations"
+// @Success      200       {object}  model.Establishment
+// @Header       200       {string}  ETag  "New version of the establishment"
+// @Failure      400       {object}  Problem
+// @Failure      401       {object}  Problem
+// @Failure      403       {object}  Problem
+// @Failure      404       {object}  Problem
+// @Failure      409       {object}  Problem
+// @Failure      412       {object}  Problem
+// @Failure      415       {object}  Problem
+// @Failure      428       {object}  Problem
+// @Failure      500       {object}  Problem
+// @Router       /establishments/{id} [patch]
+func (h *EstablishmentHandler) Patch(c echo.Context) error {
+	id, err := parseID(c, "id", "establishment")
+	if err != nil {
+		return err
+	}
+	version, err := parseIfMatch(c, "establishment", id)
+	if err != nil {
+		return err
+	}
+	patch, err := bindPatch(c)
+	if err != nil {
+		return err
+	}
+	establishment, err := h.service.Patch(c.Request().Context(), id, version, patch)
+	if err != nil {
+		return err
+	}
+	setETag(c, establishment.Version)
+	return c.JSON(http.StatusOK, establishment)
+}
+
+// HeaderConfirmCascade must repeat the establishment ID to confirm a cascading delete
+const HeaderConfirmCascade = "X-Confirm-Cascade"
+
 // Delete godoc
 // @Summary      Delete establishment
-// @Description  Delete an establishment by its ID
+// @Description  Delete an establishment by its ID. An establishment with stores can only be deleted with cascade=true,
+// @Description  which removes its stores in the same transaction and requires the X-Confirm-Cascade header to repeat the establishment ID.
+// @Description  If-Match must hold the ETag the establishment was read with.
 // @Tags         establishments
+// @Security     BearerAuth
 // @Produce      json
-// @Param        id   path      int  true  "Establishment ID"
-// @Success      200  {object}  map[string]interface{}
-// @Failure      400  {object}  map[string]interface{}
-// @Failure      500  {object}  map[string]interface{}
+// @Param        id                 path      int     true   "Establishment ID"
+// @Param        If-Match           header    string  true   "ETag of the establishment, or * to skip the check"
+// @Param        cascade            query     bool    false  "Also delete the stores of the establishment"
+// @Param        X-Confirm-Cascade  header    string  false  "Establishment ID, required when cascade=true"
+// @Success      200  {object}  model.EstablishmentCascadeDeletion
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      412  {object}  Problem
+// @Failure      428  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /establishments/{id} [delete]
 func (h *EstablishmentHandler) Delete(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || id <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"error": "Invalid establishment ID. Must be a positive integer.",
-		})
+	id, err := parseID(c, "id", "establishment")
+	if err != nil {
+		return err
+	}
+	cascade := false
+	if raw := c.QueryParam("cascade"); raw != "" {
+		if cascade, err = strconv.ParseBool(raw); err != nil {
+			return domainerr.Validation("Invalid cascade. Must be true or false.")
+		}
+	}
+	version, err := parseIfMatch(c, "establishment", id)
+	if err != nil {
+		return err
+	}
+	if !cascade {
+		if err := h.service.Delete(c.Request().Context(), id, version); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{"message": "Establishment deleted successfully"})
+	}
+
+	if c.Request().Header.Get(HeaderConfirmCascade) != strconv.FormatInt(id, 10) {
+		return echo.NewHTTPError(http.StatusPreconditionRequired,
+			"Deleting an establishment with its stores requires the "+HeaderConfirmCascade+" header set to the establishment ID.")
+	}
+	storeIDs, err := h.service.DeleteCascade(c.Request().Context(), id, version)
+	if err != nil {
+		return err
+	}
+	h.Logger.Info("Establishment deleted with its stores", zap.Int64("id", id), zap.Int64s("store_ids", storeIDs))
+	return c.JSON(http.StatusOK, model.EstablishmentCascadeDeletion{
+		Message:         "Establishment and its stores deleted successfully",
+		DeletedStoreIDs: storeIDs,
+	})
+}
+
+// Restore godoc
+// @Summary      Restore establishment
+// @Description  Restore a soft deleted establishment. Its stores stay deleted and must be restored one by one.
+// @Tags         establishments
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id   path      int  true  "Establishment ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /establishments/{id}/restore [post]
+func (h *EstablishmentHandler) Restore(c echo.Context) error {
+	id, err := parseID(c, "id", "establishment")
+	if err != nil {
+		return err
 	}
-	if err := h.service.Delete(c.Request().Context(), id); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+	if err := h.service.Restore(c.Request().Context(), id); err != nil {
+		return err
 	}
-	return c.JSON(http.StatusOK, map[string]interface{}{"message": "Establishment deleted successfully"})
+	h.Logger.Info("Establishment restored", zap.Int64("id", id))
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "Establishment restored successfully"})
 }
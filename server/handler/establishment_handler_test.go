@@ -11,8 +11,10 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
 	"github.com/yMaatheus/tech-challenge-snet/model"
 	"github.com/yMaatheus/tech-challenge-snet/service"
+	"github.com/yMaatheus/tech-challenge-snet/util"
 	"go.uber.org/zap"
 )
 
@@ -21,8 +23,16 @@ type mockEstablishmentService struct {
 	findAllErr    error
 	findByIDErr   error
 	updateErr     error
+	updateVersion int64
+	deleteVersion int64
+	patch         model.Patch
+	patchErr      error
 	deleteErr     error
+	cascadeErr    error
+	cascadeCalled bool
+	restoreErr    error
 	returnNilOnID bool
+	listParams    model.ListParams
 }
 
 func (m *mockEstablishmentService) Create(_ context.Context, e *model.Establishment) error {
@@ -32,23 +42,28 @@ func (m *mockEstablishmentService) Create(_ context.Context, e *model.Establishm
 	e.ID = 1
 	return nil
 }
-func (m *mockEstablishmentService) FindAll(_ context.Context) ([]model.EstablishmentWithStoresTotal, error) {
+func (m *mockEstablishmentService) FindAll(_ context.Context, params model.ListParams) (*model.EstablishmentPage, error) {
+	m.listParams = params
 	if m.findAllErr != nil {
 		return nil, m.findAllErr
 	}
-	return []model.EstablishmentWithStoresTotal{
-		{
-			ID:            1,
-			Number:        "E001",
-			Name:          "Test",
-			CorporateName: "Corp Test",
-			Address:       "Rua Teste",
-			AddressNumber: "10",
-			City:          "Cidade Teste",
-			State:         "ST",
-			ZipCode:       "12345678",
-			StoresTotal:   2,
+	return &model.EstablishmentPage{
+		Items: []model.EstablishmentWithStoresTotal{
+			{
+				ID:            1,
+				Number:        "E001",
+				Name:          "Test",
+				CorporateName: "Corp Test",
+				Address:       "Rua Teste",
+				AddressNumber: "10",
+				City:          "Cidade Teste",
+				State:         "SP",
+				ZipCode:       "01310100",
+				StoresTotal:   2,
+			},
 		},
+		NextCursor: "next",
+		Total:      5,
 	}, nil
 }
 func (m *mockEstablishmentService) FindByID(_ context.Context, id int64) (*model.EstablishmentWithStores, error) {
@@ -56,7 +71,7 @@ func (m *mockEstablishmentService) FindByID(_ context.Context, id int64) (*model
 		return nil, m.findByIDErr
 	}
 	if m.returnNilOnID || id == 999 {
-		return nil, nil
+		return nil, domainerr.NotFound("establishment", id)
 	}
 	return &model.EstablishmentWithStores{
 		ID:            1,
@@ -66,21 +81,45 @@ func (m *mockEstablishmentService) FindByID(_ context.Context, id int64) (*model
 		Address:       "Rua 1",
 		AddressNumber: "10",
 		City:          "Cidade",
-		State:         "ST",
+		State:         "SP",
 		ZipCode:       "12345-000",
+		Version:       2,
 		Stores:        []model.Store{},
 	}, nil
 }
 func (m *mockEstablishmentService) Update(_ context.Context, e *model.Establishment) error {
+	m.updateVersion = e.Version
+	e.Version++
 	return m.updateErr
 }
-func (m *mockEstablishmentService) Delete(_ context.Context, id int64) error {
+func (m *mockEstablishmentService) Patch(_ context.Context, id, version int64, patch model.Patch) (*model.Establishment, error) {
+	m.patch = patch
+	if m.patchErr != nil {
+		return nil, m.patchErr
+	}
+	return &model.Establishment{ID: id, Name: "Patched", Version: version + 1}, nil
+}
+func (m *mockEstablishmentService) Delete(_ context.Context, id, version int64) error {
+	m.deleteVersion = version
 	return m.deleteErr
 }
+func (m *mockEstablishmentService) DeleteCascade(_ context.Context, id, version int64) ([]int64, error) {
+	m.cascadeCalled = true
+	if m.cascadeErr != nil {
+		return nil, m.cascadeErr
+	}
+	return []int64{3, 4}, nil
+}
+
+func (m *mockEstablishmentService) Restore(_ context.Context, id int64) error {
+	return m.restoreErr
+}
 
 func setupTestEchoWithService(svc service.EstablishmentService) *echo.Echo {
 	e := echo.New()
 	logger := zap.NewNop()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(logger)
+	e.Use(AuthenticateAs(testAdmin))
 	NewEstablishmentHandler(e, svc, logger)
 	return e
 }
@@ -93,13 +132,13 @@ func TestCreateEstablishment(t *testing.T) {
 	e := setupTestEcho()
 
 	reqBody, _ := json.Marshal(map[string]interface{}{
-		"number":         "E001",
+		"number":         "11.222.333/0001-81",
 		"name":           "Test",
 		"corporate_name": "Corp Test",
 		"address":        "Rua Teste",
 		"address_number": "10",
 		"city":           "Cidade Teste",
-		"state":          "ST",
+		"state":          "SP",
 		"zip_code":       "12345678",
 	})
 	req := httptest.NewRequest(http.MethodPost, "/establishments", bytes.NewReader(reqBody))
@@ -128,20 +167,59 @@ func TestCreateEstablishment_ValidationError(t *testing.T) {
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
-	assert.Contains(t, rec.Body.String(), "validation_error")
+	assert.Contains(t, rec.Body.String(), `"errors":{`)
+}
+
+func TestCreateEstablishment_InvalidCNPJ(t *testing.T) {
+	e := setupTestEcho()
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"number":         "11.222.333/0001-82",
+		"name":           "Test",
+		"address":        "Rua Teste",
+		"address_number": "10",
+		"city":           "Cidade Teste",
+		"state":          "SP",
+		"zip_code":       "12345678",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/establishments", bytes.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"number":"must be a valid CNPJ`)
+}
+
+func TestCreateEstablishment_InvalidStateAndCEP(t *testing.T) {
+	e := setupTestEcho()
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"number":         "11.222.333/0001-81",
+		"name":           "Test",
+		"address":        "Rua Teste",
+		"address_number": "10",
+		"city":           "Cidade Teste",
+		"state":          "XX",
+		"zip_code":       "1234-5678",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/establishments", bytes.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"state":"must be the abbreviation of a Brazilian state (UF)`)
+	assert.Contains(t, rec.Body.String(), `"zip_code":"must be a CEP`)
 }
 
 func TestCreateEstablishment_ServiceError(t *testing.T) {
 	mockSvc := &mockEstablishmentService{createErr: errors.New("fail create")}
 	e := setupTestEchoWithService(mockSvc)
 	reqBody, _ := json.Marshal(map[string]interface{}{
-		"number":         "E001",
+		"number":         "11.222.333/0001-81",
 		"name":           "Test",
 		"corporate_name": "Corp Test",
 		"address":        "Rua Teste",
 		"address_number": "10",
 		"city":           "Cidade Teste",
-		"state":          "ST",
+		"state":          "SP",
 		"zip_code":       "12345678",
 	})
 	req := httptest.NewRequest(http.MethodPost, "/establishments", bytes.NewReader(reqBody))
@@ -149,7 +227,32 @@ func TestCreateEstablishment_ServiceError(t *testing.T) {
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
-	assert.Contains(t, rec.Body.String(), "fail create")
+	assert.Contains(t, rec.Body.String(), "An unexpected error occurred.")
+	assert.NotContains(t, rec.Body.String(), "fail create")
+}
+
+func TestCreateEstablishment_Conflict(t *testing.T) {
+	mockSvc := &mockEstablishmentService{createErr: &domainerr.ConflictError{
+		Field:   "number",
+		Message: "an establishment with this number already exists",
+	}}
+	e := setupTestEchoWithService(mockSvc)
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"number":         "11.222.333/0001-81",
+		"name":           "Test",
+		"address":        "Rua Teste",
+		"address_number": "10",
+		"city":           "Cidade Teste",
+		"state":          "SP",
+		"zip_code":       "12345678",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/establishments", bytes.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"number"`)
+	assert.Contains(t, rec.Body.String(), "already exists")
 }
 
 func TestListEstablishments(t *testing.T) {
@@ -160,6 +263,52 @@ func TestListEstablishments(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Contains(t, rec.Body.String(), "Test")
 	assert.Contains(t, rec.Body.String(), "storesTotal")
+	assert.Contains(t, rec.Body.String(), `"next_cursor":"next"`)
+	assert.Contains(t, rec.Body.String(), `"total":5`)
+}
+
+func TestListEstablishments_QueryParams(t *testing.T) {
+	mockSvc := &mockEstablishmentService{}
+	e := setupTestEchoWithService(mockSvc)
+	cursor := util.EncodeCursor(util.Cursor{Sort: "-name", Value: "Test", ID: 1})
+	req := httptest.NewRequest(http.MethodGet, "/establishments?limit=5&sort=-name&city=Cidade&state=SP&zip_prefix=123&cursor="+cursor, nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, model.ListParams{
+		Limit:     5,
+		Cursor:    cursor,
+		Sort:      "name",
+		Desc:      true,
+		City:      "Cidade",
+		State:     "SP",
+		ZipPrefix: "123",
+	}, mockSvc.listParams)
+}
+
+func TestListEstablishments_DefaultLimit(t *testing.T) {
+	mockSvc := &mockEstablishmentService{}
+	e := setupTestEchoWithService(mockSvc)
+	req := httptest.NewRequest(http.MethodGet, "/establishments", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, model.DefaultListLimit, mockSvc.listParams.Limit)
+}
+
+func TestListEstablishments_InvalidParams(t *testing.T) {
+	e := setupTestEcho()
+	for _, query := range []string{
+		"limit=0", "limit=abc", "limit=101", "sort=corporate_name", "cursor=@@@", "establishment_id=1",
+		"cursor=" + util.EncodeCursor(util.Cursor{Value: "1", ID: 1}),
+		"sort=name&cursor=" + util.EncodeCursor(util.Cursor{Sort: "-name", Value: "Test", ID: 1}),
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/establishments?"+query, nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.Contains(t, rec.Body.String(), "Invalid", query)
+	}
 }
 
 func TestListEstablishments_ServiceError(t *testing.T) {
@@ -169,7 +318,8 @@ func TestListEstablishments_ServiceError(t *testing.T) {
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
-	assert.Contains(t, rec.Body.String(), "fail list")
+	assert.Contains(t, rec.Body.String(), "An unexpected error occurred.")
+	assert.NotContains(t, rec.Body.String(), "fail list")
 }
 
 func TestGetEstablishmentByID(t *testing.T) {
@@ -180,6 +330,7 @@ func TestGetEstablishmentByID(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Contains(t, rec.Body.String(), `"id":1`)
 	assert.Contains(t, rec.Body.String(), `"stores":`)
+	assert.Equal(t, `"2"`, rec.Header().Get(HeaderETag))
 }
 
 func TestGetEstablishmentByID_BadID(t *testing.T) {
@@ -197,7 +348,8 @@ func TestGetEstablishmentByID_NotFound(t *testing.T) {
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusNotFound, rec.Code)
-	assert.Contains(t, rec.Body.String(), "Establishment not found")
+	assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.Contains(t, rec.Body.String(), "establishment not found")
 }
 
 func TestGetEstablishmentByID_ServiceError(t *testing.T) {
@@ -207,22 +359,24 @@ func TestGetEstablishmentByID_ServiceError(t *testing.T) {
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
-	assert.Contains(t, rec.Body.String(), "find error")
+	assert.Contains(t, rec.Body.String(), "An unexpected error occurred.")
+	assert.NotContains(t, rec.Body.String(), "find error")
 }
 
 func TestUpdateEstablishment(t *testing.T) {
 	e := setupTestEcho()
 	reqBody, _ := json.Marshal(map[string]interface{}{
-		"number":         "E001",
+		"number":         "11.222.333/0001-81",
 		"name":           "Test Updated",
 		"corporate_name": "Corp Test",
 		"address":        "Rua Teste",
 		"address_number": "10",
 		"city":           "Cidade Teste",
-		"state":          "ST",
+		"state":          "SP",
 		"zip_code":       "12345678",
 	})
 	req := httptest.NewRequest(http.MethodPut, "/establishments/1", bytes.NewReader(reqBody))
+	req.Header.Set(HeaderIfMatch, `"1"`)
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
@@ -233,6 +387,7 @@ func TestUpdateEstablishment(t *testing.T) {
 func TestUpdateEstablishment_BadID(t *testing.T) {
 	e := setupTestEcho()
 	req := httptest.NewRequest(http.MethodPut, "/establishments/bad", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
@@ -242,6 +397,7 @@ func TestUpdateEstablishment_BadID(t *testing.T) {
 func TestUpdateEstablishment_InvalidBody(t *testing.T) {
 	e := setupTestEcho()
 	req := httptest.NewRequest(http.MethodPut, "/establishments/1", bytes.NewReader([]byte("{invalid")))
+	req.Header.Set(HeaderIfMatch, `"1"`)
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
@@ -253,37 +409,83 @@ func TestUpdateEstablishment_ValidationError(t *testing.T) {
 	e := setupTestEcho()
 	reqBody := []byte(`{"number":""}`)
 	req := httptest.NewRequest(http.MethodPut, "/establishments/1", bytes.NewReader(reqBody))
+	req.Header.Set(HeaderIfMatch, `"1"`)
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
-	assert.Contains(t, rec.Body.String(), "validation_error")
+	assert.Contains(t, rec.Body.String(), `"errors":{`)
 }
 
 func TestUpdateEstablishment_ServiceError(t *testing.T) {
 	mockSvc := &mockEstablishmentService{updateErr: errors.New("fail update")}
 	e := setupTestEchoWithService(mockSvc)
 	reqBody, _ := json.Marshal(map[string]interface{}{
-		"number":         "E001",
+		"number":         "11.222.333/0001-81",
 		"name":           "Test Updated",
 		"corporate_name": "Corp Test",
 		"address":        "Rua Teste",
 		"address_number": "10",
 		"city":           "Cidade Teste",
-		"state":          "ST",
+		"state":          "SP",
 		"zip_code":       "12345678",
 	})
 	req := httptest.NewRequest(http.MethodPut, "/establishments/1", bytes.NewReader(reqBody))
+	req.Header.Set(HeaderIfMatch, `"1"`)
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
-	assert.Contains(t, rec.Body.String(), "fail update")
+	assert.Contains(t, rec.Body.String(), "An unexpected error occurred.")
+	assert.NotContains(t, rec.Body.String(), "fail update")
+}
+
+func TestUpdateEstablishment_Conflict(t *testing.T) {
+	mockSvc := &mockEstablishmentService{updateErr: &domainerr.ConflictError{Field: "number"}}
+	e := setupTestEchoWithService(mockSvc)
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"number":         "11.222.333/0001-81",
+		"name":           "Test Updated",
+		"address":        "Rua Teste",
+		"address_number": "10",
+		"city":           "Cidade Teste",
+		"state":          "SP",
+		"zip_code":       "12345678",
+	})
+	req := httptest.NewRequest(http.MethodPut, "/establishments/1", bytes.NewReader(reqBody))
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"number"`)
+}
+
+func TestUpdateEstablishment_NotFound(t *testing.T) {
+	mockSvc := &mockEstablishmentService{updateErr: domainerr.NotFound("establishment", 999999)}
+	e := setupTestEchoWithService(mockSvc)
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"number":         "11.222.333/0001-81",
+		"name":           "Test Updated",
+		"address":        "Rua Teste",
+		"address_number": "10",
+		"city":           "Cidade Teste",
+		"state":          "SP",
+		"zip_code":       "12345678",
+	})
+	req := httptest.NewRequest(http.MethodPut, "/establishments/999999", bytes.NewReader(reqBody))
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "establishment not found")
 }
 
 func TestDeleteEstablishment(t *testing.T) {
 	e := setupTestEcho()
 	req := httptest.NewRequest(http.MethodDelete, "/establishments/1", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusOK, rec.Code)
@@ -293,6 +495,7 @@ func TestDeleteEstablishment(t *testing.T) {
 func TestDeleteEstablishment_BadID(t *testing.T) {
 	e := setupTestEcho()
 	req := httptest.NewRequest(http.MethodDelete, "/establishments/bad", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
@@ -303,8 +506,236 @@ func TestDeleteEstablishment_ServiceError(t *testing.T) {
 	mockSvc := &mockEstablishmentService{deleteErr: errors.New("fail delete")}
 	e := setupTestEchoWithService(mockSvc)
 	req := httptest.NewRequest(http.MethodDelete, "/establishments/1", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "fail delete")
+}
+
+func TestDeleteEstablishment_NotFound(t *testing.T) {
+	mockSvc := &mockEstablishmentService{deleteErr: domainerr.NotFound("establishment", 999999)}
+	e := setupTestEchoWithService(mockSvc)
+	req := httptest.NewRequest(http.MethodDelete, "/establishments/999999", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "establishment not found")
+}
+
+func TestDeleteEstablishment_HasStores(t *testing.T) {
+	mockSvc := &mockEstablishmentService{deleteErr: &domainerr.ConflictError{Message: "cannot delete establishment: it has related stores"}}
+	e := setupTestEchoWithService(mockSvc)
+	req := httptest.NewRequest(http.MethodDelete, "/establishments/1", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "it has related stores")
+}
+
+func TestDeleteEstablishment_Cascade(t *testing.T) {
+	mockSvc := &mockEstablishmentService{}
+	e := setupTestEchoWithService(mockSvc)
+	req := httptest.NewRequest(http.MethodDelete, "/establishments/1?cascade=true", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	req.Header.Set(HeaderConfirmCascade, "1")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, mockSvc.cascadeCalled)
+	assert.Contains(t, rec.Body.String(), `"deleted_store_ids":[3,4]`)
+}
+
+func TestDeleteEstablishment_Cascade_SemConfirmacao(t *testing.T) {
+	for _, confirmation := range []string{"", "2", "true"} {
+		mockSvc := &mockEstablishmentService{}
+		e := setupTestEchoWithService(mockSvc)
+		req := httptest.NewRequest(http.MethodDelete, "/establishments/1?cascade=true", nil)
+		req.Header.Set(HeaderIfMatch, `"1"`)
+		if confirmation != "" {
+			req.Header.Set(HeaderConfirmCascade, confirmation)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusPreconditionRequired, rec.Code, "confirmation %q", confirmation)
+		assert.Contains(t, rec.Body.String(), HeaderConfirmCascade)
+		assert.False(t, mockSvc.cascadeCalled)
+	}
+}
+
+func TestDeleteEstablishment_Cascade_Invalido(t *testing.T) {
+	e := setupTestEcho()
+	req := httptest.NewRequest(http.MethodDelete, "/establishments/1?cascade=talvez", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Invalid cascade")
+}
+
+func TestDeleteEstablishment_CascadeFalse_NaoRemoveStores(t *testing.T) {
+	mockSvc := &mockEstablishmentService{}
+	e := setupTestEchoWithService(mockSvc)
+	req := httptest.NewRequest(http.MethodDelete, "/establishments/1?cascade=false", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.False(t, mockSvc.cascadeCalled)
+}
+
+func TestDeleteEstablishment_Cascade_NotFound(t *testing.T) {
+	mockSvc := &mockEstablishmentService{cascadeErr: domainerr.NotFound("establishment", 1)}
+	e := setupTestEchoWithService(mockSvc)
+	req := httptest.NewRequest(http.MethodDelete, "/establishments/1?cascade=true", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	req.Header.Set(HeaderConfirmCascade, "1")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestEstablishmentHandler_ProblemDetails(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		detail string
+	}{
+		{domainerr.NotFound("establishment", 1), http.StatusNotFound, "establishment not found"},
+		{&domainerr.ForeignKeyError{Field: "establishment_id", Message: "establishment does not exist"}, http.StatusUnprocessableEntity, "establishment does not exist"},
+		{domainerr.Unavailable(errors.New("connection refused")), http.StatusServiceUnavailable, "temporarily unavailable"},
+		{domainerr.Validation("bad input"), http.StatusBadRequest, "bad input"},
+	}
+	for _, tc := range cases {
+		e := setupTestEchoWithService(&mockEstablishmentService{findByIDErr: tc.err})
+		req := httptest.NewRequest(http.MethodGet, "/establishments/1", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		var problem Problem
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+		assert.Equal(t, tc.status, rec.Code)
+		assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, tc.status, problem.Status)
+		assert.Equal(t, http.StatusText(tc.status), problem.Title)
+		assert.Equal(t, "/establishments/1", problem.Instance)
+		assert.Contains(t, problem.Detail, tc.detail)
+	}
+}
+
+func TestRestoreEstablishment(t *testing.T) {
+	e := setupTestEcho()
+	req := httptest.NewRequest(http.MethodPost, "/establishments/1/restore", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "restored successfully")
+}
+
+func TestRestoreEstablishment_NotFound(t *testing.T) {
+	mockSvc := &mockEstablishmentService{restoreErr: domainerr.NotFound("deleted establishment", 1)}
+	e := setupTestEchoWithService(mockSvc)
+	req := httptest.NewRequest(http.MethodPost, "/establishments/1/restore", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "deleted establishment not found")
+}
+
+func TestListEstablishments_IncludeDeleted(t *testing.T) {
+	mockSvc := &mockEstablishmentService{}
+	e := setupTestEchoWithService(mockSvc)
+	req := httptest.NewRequest(http.MethodGet, "/establishments?include_deleted=true", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, mockSvc.listParams.IncludeDeleted)
+
+	req = httptest.NewRequest(http.MethodGet, "/establishments?include_deleted=sim", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
-	assert.Contains(t, rec.Body.String(), "fail delete")
+	assert.Contains(t, rec.Body.String(), "Invalid include_deleted")
+}
+
+func TestUpdateEstablishment_IfMatch(t *testing.T) {
+	mockSvc := &mockEstablishmentService{}
+	e := setupTestEchoWithService(mockSvc)
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"number": "11.222.333/0001-81", "name": "Test", "address": "Rua", "address_number": "10",
+		"city": "Cidade", "state": "SP", "zip_code": "01310100",
+	})
+	req := httptest.NewRequest(http.MethodPut, "/establishments/1", bytes.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(HeaderIfMatch, `"2"`)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int64(2), mockSvc.updateVersion)
+	assert.Equal(t, `"3"`, rec.Header().Get(HeaderETag))
+}
+
+func TestUpdateEstablishment_VersaoDesatualizada(t *testing.T) {
+	mockSvc := &mockEstablishmentService{updateErr: domainerr.PreconditionFailed("establishment", 1)}
+	e := setupTestEchoWithService(mockSvc)
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"number": "11.222.333/0001-81", "name": "Test", "address": "Rua", "address_number": "10",
+		"city": "Cidade", "state": "SP", "zip_code": "01310100",
+	})
+	req := httptest.NewRequest(http.MethodPut, "/establishments/1", bytes.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.Contains(t, rec.Body.String(), "establishment was modified since it was read")
+}
+
+func TestDeleteEstablishment_SemIfMatch(t *testing.T) {
+	mockSvc := &mockEstablishmentService{}
+	e := setupTestEchoWithService(mockSvc)
+	req := httptest.NewRequest(http.MethodDelete, "/establishments/1?cascade=true", nil)
+	req.Header.Set(HeaderConfirmCascade, "1")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+	assert.False(t, mockSvc.cascadeCalled)
+}
+
+func TestDeleteEstablishment_IfMatch(t *testing.T) {
+	mockSvc := &mockEstablishmentService{}
+	e := setupTestEchoWithService(mockSvc)
+	req := httptest.NewRequest(http.MethodDelete, "/establishments/1", nil)
+	req.Header.Set(HeaderIfMatch, `"5"`)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int64(5), mockSvc.deleteVersion)
+}
+
+func TestPatchEstablishment(t *testing.T) {
+	mockSvc := &mockEstablishmentService{}
+	e := setupTestEchoWithService(mockSvc)
+	req := httptest.NewRequest(http.MethodPatch, "/establishments/1", bytes.NewReader([]byte(`[{"op":"replace","path":"/name","value":"Patched"}]`)))
+	req.Header.Set(echo.HeaderContentType, MIMEApplicationJSONPatchJSON)
+	req.Header.Set(HeaderIfMatch, `"4"`)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, model.PatchFormatJSON, mockSvc.patch.Format)
+	assert.Equal(t, `"5"`, rec.Header().Get(HeaderETag))
+	assert.Contains(t, rec.Body.String(), `"name":"Patched"`)
+}
+
+func TestPatchEstablishment_Conflito(t *testing.T) {
+	mockSvc := &mockEstablishmentService{patchErr: &domainerr.ConflictError{Message: "patch does not apply: test failed"}}
+	e := setupTestEchoWithService(mockSvc)
+	req := httptest.NewRequest(http.MethodPatch, "/establishments/1", bytes.NewReader([]byte(`[{"op":"test","path":"/name","value":"x"}]`)))
+	req.Header.Set(echo.HeaderContentType, MIMEApplicationJSONPatchJSON)
+	req.Header.Set(HeaderIfMatch, "*")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
 }
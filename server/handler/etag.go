@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+// HTTP headers of the optimistic concurrency control
+const (
+	HeaderETag    = "ETag"
+	HeaderIfMatch = "If-Match"
+)
+
+// setETag sends the version of an entity as its strong ETag, e.g. "3"
+func setETag(c echo.Context, version int64) {
+	c.Response().Header().Set(HeaderETag, strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// parseIfMatch reads the version a write expects from the If-Match header. The header is required,
+// so a client that did not read the entity first cannot overwrite someone else's change by accident.
+// "*" matches any version. Anything but "*" or a single ETag sent by this API, including weak ETags,
+// can never match, so it fails as a moved version would.
+func parseIfMatch(c echo.Context, entity string, id int64) (int64, error) {
+	raw := strings.TrimSpace(c.Request().Header.Get(HeaderIfMatch))
+	if raw == "" {
+		return 0, echo.NewHTTPError(http.StatusPreconditionRequired,
+			"Changing a "+entity+" requires the "+HeaderIfMatch+" header set to the ETag it was read with.")
+	}
+	if raw == "*" {
+		return model.AnyVersion, nil
+	}
+	unquoted, err := strconv.Unquote(raw)
+	if err != nil || !strings.HasPrefix(raw, `"`) {
+		return 0, domainerr.PreconditionFailed(entity, id)
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, domainerr.PreconditionFailed(entity, id)
+	}
+	return version, nil
+}
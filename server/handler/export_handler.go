@@ -0,0 +1,376 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/service"
+	"github.com/yMaatheus/tech-challenge-snet/util"
+	"go.uber.org/zap"
+)
+
+// MIMEApplicationNDJSON is the media type of newline delimited JSON, one object per line
+const MIMEApplicationNDJSON = "application/x-ndjson"
+
+// Export formats, chosen by the format query param or the Accept header
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatXLSX   = "xlsx"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// exportFormats lists the export formats in order of preference, with their media types
+var exportFormats = []struct{ format, mediaType string }{
+	{ExportFormatCSV, MIMETextCSV},
+	{ExportFormatXLSX, MIMEApplicationXLSX},
+	{ExportFormatNDJSON, MIMEApplicationNDJSON},
+}
+
+// exportFlushRows is how many rows are written between flushes of the response
+const exportFlushRows = 100
+
+// ExportHandler streams establishments and stores as CSV, XLSX or NDJSON files
+type ExportHandler struct {
+	Service service.ExportService
+	Logger  *zap.Logger
+}
+
+// NewExportHandler sets up the routes for exports
+func NewExportHandler(e *echo.Echo, svc service.ExportService, logger *zap.Logger) {
+	h := &ExportHandler{Service: svc, Logger: logger}
+	e.GET("/export/establishments", h.ExportEstablishments, requireViewer, requireReadEstablishments)
+	e.GET("/export/stores", h.ExportStores, requireViewer, requireReadStores)
+}
+
+// ExportEstablishments godoc
+// @Summary      Export establishments
+// @Description  Streams every establishment matching the filters, with its stores total, as CSV, XLSX or NDJSON.
+// @Description  The format comes from the format param or, when it is absent, from the Accept header, defaulting to CSV.
+// @Tags         export
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Produce      text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,application/x-ndjson
+// @Param        format           query  string  false  "File format (csv, xlsx, ndjson)"
+// @Param        sort             query  string  false  "Sort field, prefix with - for descending (id, number, name, city, state, zip_code)"
+// @Param        city             query  string  false  "Filter by city (case-insensitive)"
+// @Param        state            query  string  false  "Filter by state (case-insensitive)"
+// @Param        zip_prefix       query  string  false  "Filter by zip code prefix"
+// @Param        include_deleted  query  bool    false  "Also export soft deleted establishments (admin only)"
+// @Success      200  {file}    file
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      406  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /export/establishments [get]
+func (h *ExportHandler) ExportEstablishments(c echo.Context) error {
+	params, format, err := parseExportParams(c, model.EstablishmentSortFields, false)
+	if err != nil {
+		return err
+	}
+	columns := []exportColumn[*model.EstablishmentWithStoresTotal]{
+		{"id", func(e *model.EstablishmentWithStoresTotal) interface{} { return e.ID }},
+		{"number", func(e *model.EstablishmentWithStoresTotal) interface{} { return e.Number }},
+		{"name", func(e *model.EstablishmentWithStoresTotal) interface{} { return e.Name }},
+		{"corporate_name", func(e *model.EstablishmentWithStoresTotal) interface{} { return e.CorporateName }},
+		{"address", func(e *model.EstablishmentWithStoresTotal) interface{} { return e.Address }},
+		{"address_number", func(e *model.EstablishmentWithStoresTotal) interface{} { return e.AddressNumber }},
+		{"city", func(e *model.EstablishmentWithStoresTotal) interface{} { return e.City }},
+		{"state", func(e *model.EstablishmentWithStoresTotal) interface{} { return e.State }},
+		{"zip_code", func(e *model.EstablishmentWithStoresTotal) interface{} { return e.ZipCode }},
+		{"address_mismatch", func(e *model.EstablishmentWithStoresTotal) interface{} { return e.AddressMismatch }},
+		{"storesTotal", func(e *model.EstablishmentWithStoresTotal) interface{} { return e.StoresTotal }},
+		{"version", func(e *model.EstablishmentWithStoresTotal) interface{} { return e.Version }},
+	}
+	if params.IncludeDeleted {
+		columns = append(columns, exportColumn[*model.EstablishmentWithStoresTotal]{"deleted_at", func(e *model.EstablishmentWithStoresTotal) interface{} { return exportTime(e.DeletedAt) }})
+	}
+	return streamExport(c, h.Logger, "establishments", format, columns, func(fn func(*model.EstablishmentWithStoresTotal) error) error {
+		return h.Service.ExportEstablishments(c.Request().Context(), params, fn)
+	})
+}
+
+// ExportStores godoc
+// @Summary      Export stores
+// @Description  Streams every store matching the filters as CSV, XLSX or NDJSON.
+// @Description  The format comes from the format param or, when it is absent, from the Accept header, defaulting to CSV.
+// @Tags         export
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Produce      text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,application/x-ndjson
+// @Param        format            query  string  false  "File format (csv, xlsx, ndjson)"
+// @Param        sort              query  string  false  "Sort field, prefix with - for descending (id, number, name, city, state, zip_code)"
+// @Param        city              query  string  false  "Filter by city (case-insensitive)"
+// @Param        state             query  string  false  "Filter by state (case-insensitive)"
+// @Param        zip_prefix        query  string  false  "Filter by zip code prefix"
+// @Param        establishment_id  query  int     false  "Filter by establishment"
+// @Param        include_deleted   query  bool    false  "Also export soft deleted stores (admin only)"
+// @Success      200  {file}    file
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      406  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /export/stores [get]
+func (h *ExportHandler) ExportStores(c echo.Context) error {
+	params, format, err := parseExportParams(c, model.StoreSortFields, true)
+	if err != nil {
+		return err
+	}
+	columns := []exportColumn[*model.Store]{
+		{"id", func(s *model.Store) interface{} { return s.ID }},
+		{"number", func(s *model.Store) interface{} { return s.Number }},
+		{"name", func(s *model.Store) interface{} { return s.Name }},
+		{"corporate_name", func(s *model.Store) interface{} { return s.CorporateName }},
+		{"address", func(s *model.Store) interface{} { return s.Address }},
+		{"address_number", func(s *model.Store) interface{} { return s.AddressNumber }},
+		{"city", func(s *model.Store) interface{} { return s.City }},
+		{"state", func(s *model.Store) interface{} { return s.State }},
+		{"zip_code", func(s *model.Store) interface{} { return s.ZipCode }},
+		{"latitude", func(s *model.Store) interface{} { return exportFloat(s.Latitude) }},
+		{"longitude", func(s *model.Store) interface{} { return exportFloat(s.Longitude) }},
+		{"address_mismatch", func(s *model.Store) interface{} { return s.AddressMismatch }},
+		{"establishment_id", func(s *model.Store) interface{} { return s.EstablishmentID }},
+		{"version", func(s *model.Store) interface{} { return s.Version }},
+	}
+	if params.IncludeDeleted {
+		columns = append(columns, exportColumn[*model.Store]{"deleted_at", func(s *model.Store) interface{} { return exportTime(s.DeletedAt) }})
+	}
+	return streamExport(c, h.Logger, "stores", format, columns, func(fn func(*model.Store) error) error {
+		return h.Service.ExportStores(c.Request().Context(), params, fn)
+	})
+}
+
+// parseExportParams reads the list filters and the export format. Exports are not paginated,
+// so limit and cursor have no effect.
+func parseExportParams(c echo.Context, sortFields []string, filterByEstablishment bool) (model.ListParams, string, error) {
+	format, err := negotiateExportFormat(c)
+	if err != nil {
+		return model.ListParams{}, "", err
+	}
+	params, err := parseListParams(c, sortFields, filterByEstablishment)
+	params.Limit, params.Cursor = 0, ""
+	return params, format, err
+}
+
+// negotiateExportFormat picks the format from the format param or else from the media type
+// with the highest quality in the Accept header. CSV is used when neither says otherwise.
+func negotiateExportFormat(c echo.Context) (string, error) {
+	if format := strings.ToLower(strings.TrimSpace(c.QueryParam("format"))); format != "" {
+		for _, f := range exportFormats {
+			if f.format == format {
+				return format, nil
+			}
+		}
+		return "", domainerr.Validation("Invalid format. Must be one of: csv, xlsx, ndjson.")
+	}
+
+	accept := c.Request().Header.Get(echo.HeaderAccept)
+	if strings.TrimSpace(accept) == "" {
+		return ExportFormatCSV, nil
+	}
+	best, bestQuality := "", 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, mediaParams, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := mediaParams["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		for _, f := range exportFormats {
+			matches := mediaType == f.mediaType || mediaType == "*/*" ||
+				(strings.HasSuffix(mediaType, "/*") && strings.HasPrefix(f.mediaType, strings.TrimSuffix(mediaType, "*")))
+			if matches && quality > bestQuality {
+				best, bestQuality = f.format, quality
+			}
+		}
+	}
+	if best == "" {
+		return "", echo.NewHTTPError(http.StatusNotAcceptable, "Unsupported Accept header. Use text/csv, "+MIMEApplicationXLSX+" or "+MIMEApplicationNDJSON+".")
+	}
+	return best, nil
+}
+
+// exportColumn is a column of a CSV or XLSX export, named after the JSON field it holds
+type exportColumn[T any] struct {
+	name  string
+	value func(T) interface{}
+}
+
+// exportWriter encodes the items of an export in one of the export formats
+type exportWriter[T any] interface {
+	Write(item T) error
+	// Close writes whatever the format needs after the last item
+	Close() error
+}
+
+// streamExport writes the items export passes to fn as a file download. The response is flushed every
+// exportFlushRows rows, so the client receives the file while it is read from the database. Errors before
+// anything was sent are answered as usual. An error midway aborts the response, so the connection is
+// closed without ending the chunked body and the client sees a failed download instead of a short file.
+func streamExport[T any](c echo.Context, logger *zap.Logger, name, format string, columns []exportColumn[T], export func(fn func(T) error) error) error {
+	res := c.Response()
+	for _, f := range exportFormats {
+		if f.format == format {
+			res.Header().Set(echo.HeaderContentType, f.mediaType)
+		}
+	}
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+
+	buf := bufio.NewWriter(res)
+	w, err := newExportWriter(format, buf, columns)
+	if err == nil {
+		rows := 0
+		err = export(func(item T) error {
+			if err := w.Write(item); err != nil {
+				return err
+			}
+			if rows++; rows%exportFlushRows == 0 {
+				if err := buf.Flush(); err != nil {
+					return err
+				}
+				res.Flush()
+			}
+			return nil
+		})
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil && !res.Committed {
+		res.Header().Del(echo.HeaderContentDisposition)
+		return err
+	}
+	if err != nil {
+		logger.Error("Export interrupted", zap.String("path", c.Request().URL.Path), zap.Error(err))
+		panic(http.ErrAbortHandler)
+	}
+	return nil
+}
+
+func newExportWriter[T any](format string, w io.Writer, columns []exportColumn[T]) (exportWriter[T], error) {
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.name
+	}
+	switch format {
+	case ExportFormatXLSX:
+		xw, err := util.NewXLSXWriter(w, "export")
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, len(header))
+		for i, name := range header {
+			values[i] = name
+		}
+		if err := xw.Write(values); err != nil {
+			return nil, err
+		}
+		return &xlsxExportWriter[T]{w: xw, columns: columns}, nil
+	case ExportFormatNDJSON:
+		return &ndjsonExportWriter[T]{enc: json.NewEncoder(w)}, nil
+	default:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return nil, err
+		}
+		return &csvExportWriter[T]{w: cw, columns: columns}, nil
+	}
+}
+
+type csvExportWriter[T any] struct {
+	w       *csv.Writer
+	columns []exportColumn[T]
+}
+
+func (e *csvExportWriter[T]) Write(item T) error {
+	record := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		switch value := column.value(item).(type) {
+		case nil:
+		case string:
+			record[i] = csvText(value)
+		default:
+			record[i] = fmt.Sprint(value)
+		}
+	}
+	return e.w.Write(record)
+}
+
+// csvText escapes text that a spreadsheet would take as a formula, starting with =, +, -, @, a tab or a
+// carriage return, by prefixing it with an apostrophe, so an exported name cannot run as a formula
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func (e *csvExportWriter[T]) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type xlsxExportWriter[T any] struct {
+	w       *util.XLSXWriter
+	columns []exportColumn[T]
+}
+
+func (e *xlsxExportWriter[T]) Write(item T) error {
+	row := make([]interface{}, len(e.columns))
+	for i, column := range e.columns {
+		row[i] = column.value(item)
+	}
+	return e.w.Write(row)
+}
+
+func (e *xlsxExportWriter[T]) Close() error {
+	return e.w.Close()
+}
+
+// ndjsonExportWriter writes each item as its JSON object, the same one the list endpoints return
+type ndjsonExportWriter[T any] struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExportWriter[T]) Write(item T) error {
+	return e.enc.Encode(item)
+}
+
+func (e *ndjsonExportWriter[T]) Close() error {
+	return nil
+}
+
+// exportFloat unwraps an optional number, leaving the cell empty when it is nil
+func exportFloat(f *float64) interface{} {
+	if f == nil {
+		return nil
+	}
+	return *f
+}
+
+// exportTime formats an optional timestamp as RFC 3339, leaving the cell empty when it is nil
+func exportTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/util"
+	"go.uber.org/zap"
+)
+
+type mockExportService struct {
+	params         model.ListParams
+	establishments []model.EstablishmentWithStoresTotal
+	stores         []model.Store
+	err            error
+	// failAfter makes the export fail after this many rows, when positive
+	failAfter int
+}
+
+func (m *mockExportService) ExportEstablishments(ctx context.Context, params model.ListParams, fn func(*model.EstablishmentWithStoresTotal) error) error {
+	m.params = params
+	if m.err != nil {
+		return m.err
+	}
+	for i := range m.establishments {
+		if err := fn(&m.establishments[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (m *mockExportService) ExportStores(ctx context.Context, params model.ListParams, fn func(*model.Store) error) error {
+	m.params = params
+	for i := range m.stores {
+		if m.failAfter > 0 && i == m.failAfter {
+			return errors.New("conexão perdida")
+		}
+		if err := fn(&m.stores[i]); err != nil {
+			return err
+		}
+	}
+	return m.err
+}
+
+func setupExportEcho(svc *mockExportService) *echo.Echo {
+	e := echo.New()
+	logger := zap.NewNop()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(logger)
+	e.Use(AuthenticateAs(testAdmin))
+	NewExportHandler(e, svc, logger)
+	return e
+}
+
+func exportEstablishments() []model.EstablishmentWithStoresTotal {
+	return []model.EstablishmentWithStoresTotal{
+		{ID: 1, Number: "11222333000181", Name: "Loja, Centro", City: "Recife", State: "PE", StoresTotal: 2, Version: 1},
+		{ID: 2, Number: "11444777000161", Name: "Outra", City: "Recife", State: "PE", Version: 3},
+	}
+}
+
+func TestExportEstablishments_CSV(t *testing.T) {
+	svc := &mockExportService{establishments: exportEstablishments()}
+	e := setupExportEcho(svc)
+	req := httptest.NewRequest(http.MethodGet, "/export/establishments?city=Recife&sort=-name&limit=5", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, MIMETextCSV, rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, `attachment; filename="establishments.csv"`, rec.Header().Get(echo.HeaderContentDisposition))
+	assert.Equal(t, "id,number,name,corporate_name,address,address_number,city,state,zip_code,address_mismatch,storesTotal,version\n"+
+		"1,11222333000181,\"Loja, Centro\",,,,Recife,PE,,false,2,1\n"+
+		"2,11444777000161,Outra,,,,Recife,PE,,false,0,3\n", rec.Body.String())
+	assert.Equal(t, model.ListParams{City: "Recife", Sort: "name", Desc: true}, svc.params)
+}
+
+func TestExportEstablishments_NDJSONPorAccept(t *testing.T) {
+	e := setupExportEcho(&mockExportService{establishments: exportEstablishments()})
+	req := httptest.NewRequest(http.MethodGet, "/export/establishments", nil)
+	req.Header.Set(echo.HeaderAccept, "text/csv;q=0.5, application/x-ndjson")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, MIMEApplicationNDJSON, rec.Header().Get(echo.HeaderContentType))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"storesTotal":2`)
+}
+
+func TestExportStores_XLSXComExcluidos(t *testing.T) {
+	deletedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	lat, lng := -23.5613, -46.6565
+	svc := &mockExportService{stores: []model.Store{
+		{ID: 5, Number: "11222333000181", Name: "Loja", EstablishmentID: 1, Version: 2},
+		{ID: 6, Number: "11444777000161", Name: "Removida", EstablishmentID: 1, Latitude: &lat, Longitude: &lng, Version: 4, DeletedAt: &deletedAt},
+	}}
+	e := setupExportEcho(svc)
+	req := httptest.NewRequest(http.MethodGet, "/export/stores?format=xlsx&establishment_id=1&include_deleted=true", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, MIMEApplicationXLSX, rec.Header().Get(echo.HeaderContentType))
+	rows, err := util.ReadXLSX(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()), 100, 100)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, "deleted_at", rows[0][14])
+	assert.Equal(t, []string{"6", "11444777000161", "Removida", "", "", "", "", "", "", "-23.5613", "-46.6565", "false", "1", "4", "2024-05-01T12:00:00Z"}, rows[2])
+	assert.Equal(t, []string{"5", "11222333000181", "Loja", "", "", "", "", "", "", "", "", "false", "1", "2"}, rows[1])
+	assert.Equal(t, int64(1), svc.params.EstablishmentID)
+}
+
+func TestExportStores_FluxoGrande(t *testing.T) {
+	svc := &mockExportService{}
+	for i := 1; i <= 3*exportFlushRows; i++ {
+		svc.stores = append(svc.stores, model.Store{ID: int64(i), Name: fmt.Sprintf("Loja %d", i)})
+	}
+	e := setupExportEcho(svc)
+	req := httptest.NewRequest(http.MethodGet, "/export/stores?format=csv", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, 3*exportFlushRows+1, strings.Count(rec.Body.String(), "\n"))
+}
+
+func TestExportStores_ErroNoMeioInterrompe(t *testing.T) {
+	svc := &mockExportService{failAfter: 2 * exportFlushRows}
+	for i := 1; i <= 3*exportFlushRows; i++ {
+		svc.stores = append(svc.stores, model.Store{ID: int64(i)})
+	}
+	e := setupExportEcho(svc)
+	e.Use(middleware.Recover())
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	res, err := server.Client().Get(server.URL + "/export/stores")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode, "the status was already sent")
+	_, err = io.ReadAll(res.Body)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "the download fails instead of looking complete")
+}
+
+func TestExportEstablishments_CSVSemFormulas(t *testing.T) {
+	e := setupExportEcho(&mockExportService{establishments: []model.EstablishmentWithStoresTotal{
+		{ID: 1, Name: `=HYPERLINK("http://x")`, CorporateName: "+55", Address: "-1", City: "@SUM(A1)", State: "PE"},
+	}})
+	req := httptest.NewRequest(http.MethodGet, "/export/establishments", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `1,,"'=HYPERLINK(""http://x"")",'+55,'-1,,'@SUM(A1),PE,`)
+}
+
+func TestExportEstablishments_ErroAntesDeEnviar(t *testing.T) {
+	e := setupExportEcho(&mockExportService{err: errors.New("falha no banco")})
+	req := httptest.NewRequest(http.MethodGet, "/export/establishments", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.Empty(t, rec.Header().Get(echo.HeaderContentDisposition))
+}
+
+func TestExportEstablishments_FormatoInvalido(t *testing.T) {
+	e := setupExportEcho(&mockExportService{})
+	req := httptest.NewRequest(http.MethodGet, "/export/establishments?format=pdf", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/export/establishments", nil)
+	req.Header.Set(echo.HeaderAccept, "application/pdf")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+}
+
+func TestNegotiateExportFormat(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                            ExportFormatCSV,
+		"*/*":                         ExportFormatCSV,
+		"application/*":               ExportFormatXLSX,
+		"application/json, */*;q=0.1": ExportFormatCSV,
+		MIMEApplicationXLSX:           ExportFormatXLSX,
+		"text/csv;q=0.2, application/x-ndjson;q=0.9": ExportFormatNDJSON,
+	} {
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+		c.Request().Header.Set(echo.HeaderAccept, accept)
+		got, err := negotiateExportFormat(c)
+		assert.NoError(t, err, accept)
+		assert.Equal(t, want, got, accept)
+	}
+}
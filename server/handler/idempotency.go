@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/service"
+	"go.uber.org/zap"
+)
+
+const (
+	// HeaderIdempotencyKey lets clients retry a POST safely: retries sent with the same key get the response
+	// of the first request instead of repeating it
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed marks the responses replayed for a retry
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// maxIdempotentBodySize is the largest request body fingerprinted, room for an import file and its
+// multipart envelope. Larger requests are left to their handler, which rejects them.
+const maxIdempotentBodySize = maxImportSize + 1<<20
+
+// idempotencyHeartbeat is how often a request refreshes the reservation of its key while it runs
+var idempotencyHeartbeat = service.IdempotencyHeartbeatInterval
+
+// idempotentHeaders are the response headers replayed along with the body
+var idempotentHeaders = []string{echo.HeaderContentType, HeaderETag, echo.HeaderLocation}
+
+// idempotencySkipped are the POST routes whose responses are never kept, as they hold the secret of an API key
+var idempotencySkipped = map[string]bool{"/api-keys": true, "/api-keys/:id/rotate": true}
+
+// Idempotency makes the POST requests sent with an Idempotency-Key safe to retry. The response of the first
+// request with a key is kept by svc, per client, and replayed to the retries with the same method, path and
+// body; reusing the key for a different request, or while the first one still runs, gives 409. Server
+// errors are not kept, so their retries run again. It goes after ResolveTenant, as keys belong to the
+// tenant of the request, and unauthenticated requests pass through for the route guards to answer.
+func Idempotency(svc service.IdempotencyService, logger *zap.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			key := req.Header.Get(HeaderIdempotencyKey)
+			if req.Method != http.MethodPost || key == "" || idempotencySkipped[c.Path()] {
+				return next(c)
+			}
+			if len(key) > model.MaxIdempotencyKeyLength {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					"The Idempotency-Key header must have at most "+strconv.Itoa(model.MaxIdempotencyKeyLength)+" characters.")
+			}
+			if _, ok := tenant.FromContext(req.Context()); !ok {
+				return next(c)
+			}
+
+			body, err := io.ReadAll(io.LimitReader(req.Body, maxIdempotentBodySize+1))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "The request body could not be read.")
+			}
+			if len(body) > maxIdempotentBodySize {
+				req.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), req.Body))
+				return next(c)
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			client := clientKey(c)
+			record, err := svc.Begin(req.Context(), client, key, fingerprint(req, body))
+			if err != nil {
+				return err
+			}
+			if record != nil {
+				for name, value := range record.Response.Header {
+					c.Response().Header().Set(name, value)
+				}
+				c.Response().Header().Set(HeaderIdempotentReplayed, "true")
+				c.Response().WriteHeader(record.Response.Status)
+				_, err := c.Response().Write(record.Response.Body)
+				return err
+			}
+
+			// The outcome is recorded even when the client is gone, since that is when it retries
+			ctx := context.WithoutCancel(req.Context())
+			completed := false
+			defer func() {
+				if !completed {
+					if err := svc.Release(ctx, client, key); err != nil {
+						logger.Error("Failed to release an idempotency key", zap.String("key", key), zap.Error(err))
+					}
+				}
+			}()
+			defer keepReserved(ctx, svc, client, key, logger)()
+
+			capture := &responseCapture{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = capture
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			res := c.Response()
+			if res.Status >= http.StatusInternalServerError {
+				return nil
+			}
+			response := model.IdempotentResponse{Status: res.Status, Header: map[string]string{}, Body: capture.body.Bytes()}
+			for _, name := range idempotentHeaders {
+				if value := res.Header().Get(name); value != "" {
+					response.Header[name] = value
+				}
+			}
+			if err := svc.Complete(ctx, client, key, response); err != nil {
+				logger.Error("Failed to keep the response of an idempotent request", zap.String("key", key), zap.Error(err))
+				return nil
+			}
+			completed = true
+			return nil
+		}
+	}
+}
+
+// keepReserved sends the heartbeats of a reserved key until the returned function is called, so a request
+// running for longer than service.IdempotencyAbandonAfter, such as a large import, is not run again by a retry.
+func keepReserved(ctx context.Context, svc service.IdempotencyService, client, key string, logger *zap.Logger) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(idempotencyHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := svc.Heartbeat(ctx, client, key); err != nil {
+					logger.Warn("Failed to refresh an idempotency key", zap.String("key", key), zap.Error(err))
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// fingerprint tells requests apart by method, path with query and body
+func fingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, req.Method+" "+req.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseCapture copies the body written to the client
+type responseCapture struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseCapture) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseCapture) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"go.uber.org/zap"
+)
+
+// mockIdempotencyService keeps the records in memory, by client and key
+type mockIdempotencyService struct {
+	records    map[string]*model.IdempotencyRecord
+	released   []string
+	heartbeats int
+}
+
+func (m *mockIdempotencyService) Begin(ctx context.Context, client, key, fingerprint string) (*model.IdempotencyRecord, error) {
+	existing, ok := m.records[client+"/"+key]
+	switch {
+	case !ok:
+		m.records[client+"/"+key] = &model.IdempotencyRecord{Client: client, Key: key, Fingerprint: fingerprint}
+		return nil, nil
+	case existing.Fingerprint != fingerprint:
+		return nil, &domainerr.ConflictError{Field: "Idempotency-Key", Message: "this Idempotency-Key was already used with a different request"}
+	case existing.Response == nil:
+		return nil, &domainerr.ConflictError{Field: "Idempotency-Key", Message: "a request with this Idempotency-Key is still being processed"}
+	}
+	return existing, nil
+}
+func (m *mockIdempotencyService) Heartbeat(ctx context.Context, client, key string) error {
+	m.heartbeats++
+	return nil
+}
+func (m *mockIdempotencyService) Complete(ctx context.Context, client, key string, response model.IdempotentResponse) error {
+	m.records[client+"/"+key].Response = &response
+	return nil
+}
+func (m *mockIdempotencyService) Release(ctx context.Context, client, key string) error {
+	m.released = append(m.released, key)
+	delete(m.records, client+"/"+key)
+	return nil
+}
+func (m *mockIdempotencyService) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func setupIdempotencyEcho(svc *mockIdempotencyService, calls *int) *echo.Echo {
+	e := echo.New()
+	logger := zap.NewNop()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(logger)
+	e.Use(Authenticate(&fakeVerifier{tokens: map[string]auth.Principal{
+		"maria": {Subject: "maria", Role: auth.RoleEditor},
+		"joao":  {Subject: "joao", Role: auth.RoleEditor},
+	}}))
+	e.Use(ResolveTenant(&fakeScoper{}, "default"))
+	e.Use(Idempotency(svc, logger))
+	e.POST("/stores", func(c echo.Context) error {
+		*calls++
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := c.Bind(&body); err != nil {
+			return err
+		}
+		switch body.Name {
+		case "":
+			return echo.NewHTTPError(http.StatusBadRequest, "The name is required.")
+		case "falha":
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "Try again later.")
+		case "lenta":
+			time.Sleep(5 * idempotencyHeartbeat)
+		}
+		setETag(c, 1)
+		return c.JSON(http.StatusCreated, map[string]interface{}{"id": *calls, "name": body.Name})
+	}, requireEditor)
+	return e
+}
+
+func idempotentRequest(e *echo.Echo, token, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/stores", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotency_Replay(t *testing.T) {
+	svc := &mockIdempotencyService{records: map[string]*model.IdempotencyRecord{}}
+	calls := 0
+	e := setupIdempotencyEcho(svc, &calls)
+
+	first := idempotentRequest(e, "maria", "chave-1", `{"name":"Loja Centro"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(HeaderIdempotentReplayed))
+
+	retry := idempotentRequest(e, "maria", "chave-1", `{"name":"Loja Centro"}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, first.Header().Get(HeaderETag), retry.Header().Get(HeaderETag))
+	assert.Equal(t, echo.MIMEApplicationJSON, retry.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "true", retry.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, 1, calls, "the retry does not create another store")
+
+	other := idempotentRequest(e, "joao", "chave-1", `{"name":"Loja Centro"}`)
+	assert.Equal(t, http.StatusCreated, other.Code)
+	assert.Empty(t, other.Header().Get(HeaderIdempotentReplayed), "keys of other clients never clash")
+
+	idempotentRequest(e, "maria", "", `{"name":"Loja Centro"}`)
+	assert.Equal(t, 3, calls, "requests without a key always run")
+}
+
+func TestIdempotency_Conflito(t *testing.T) {
+	svc := &mockIdempotencyService{records: map[string]*model.IdempotencyRecord{}}
+	calls := 0
+	e := setupIdempotencyEcho(svc, &calls)
+
+	idempotentRequest(e, "maria", "chave-1", `{"name":"Loja Centro"}`)
+	rec := idempotentRequest(e, "maria", "chave-1", `{"name":"Loja Norte"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "already used with a different request")
+	assert.Equal(t, 1, calls)
+
+	rec = idempotentRequest(e, "maria", strings.Repeat("x", model.MaxIdempotencyKeyLength+1), `{"name":"Loja Centro"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestIdempotency_Erros(t *testing.T) {
+	svc := &mockIdempotencyService{records: map[string]*model.IdempotencyRecord{}}
+	calls := 0
+	e := setupIdempotencyEcho(svc, &calls)
+
+	rec := idempotentRequest(e, "maria", "chave-1", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = idempotentRequest(e, "maria", "chave-1", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get(HeaderIdempotentReplayed), "client errors are replayed too")
+	assert.Equal(t, 1, calls)
+
+	for i := 0; i < 2; i++ {
+		rec = idempotentRequest(e, "maria", "chave-2", `{"name":"falha"}`)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	}
+	assert.Equal(t, 3, calls, "server errors run again on retry")
+	assert.Equal(t, []string{"chave-2", "chave-2"}, svc.released)
+
+	rec = idempotentRequest(e, "", "chave-3", `{"name":"Loja Centro"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NotContains(t, svc.records, "ip:192.0.2.1/chave-3", "unauthenticated requests keep nothing")
+	assert.Len(t, svc.records, 1)
+}
+
+func TestIdempotency_HeartbeatDaRequisicaoLonga(t *testing.T) {
+	defer func(interval time.Duration) { idempotencyHeartbeat = interval }(idempotencyHeartbeat)
+	idempotencyHeartbeat = 10 * time.Millisecond
+	svc := &mockIdempotencyService{records: map[string]*model.IdempotencyRecord{}}
+	calls := 0
+	e := setupIdempotencyEcho(svc, &calls)
+
+	rec := idempotentRequest(e, "maria", "chave-1", `{"name": "lenta"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Positive(t, svc.heartbeats, "the key is kept while the request runs")
+
+	heartbeats := svc.heartbeats
+	rec = idempotentRequest(e, "maria", "chave-2", `{"name": "Loja"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	time.Sleep(3 * idempotencyHeartbeat)
+	assert.Equal(t, heartbeats, svc.heartbeats, "the heartbeats stop with the request")
+}
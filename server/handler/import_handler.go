@@ -0,0 +1,297 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/service"
+	"github.com/yMaatheus/tech-challenge-snet/util"
+	"go.uber.org/zap"
+)
+
+// Media types of the import files
+const (
+	MIMETextCSV         = "text/csv"
+	MIMEApplicationXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// maxImportSize is the largest import file accepted, in bytes
+const maxImportSize = 10 << 20
+
+// maxImportColumns is how many columns of an XLSX file are read, counting the blank ones between
+// the imported columns. The cells past it are ignored.
+const maxImportColumns = 64
+
+// ImportHandler handles the bulk import of establishments and stores
+type ImportHandler struct {
+	Service service.ImportService
+	Logger  *zap.Logger
+}
+
+// NewImportHandler sets up the routes for bulk imports
+func NewImportHandler(e *echo.Echo, svc service.ImportService, logger *zap.Logger) {
+	h := &ImportHandler{Service: svc, Logger: logger}
+	e.POST("/import/establishments", h.ImportEstablishments, requireEditor, requireWriteEstablishments)
+	e.POST("/import/stores", h.ImportStores, requireEditor, requireWriteStores)
+}
+
+// ImportEstablishments godoc
+// @Summary      Import establishments
+// @Description  Creates or updates establishments from a CSV or XLSX file whose first row names the columns (number, name, corporate_name, address, address_number, city, state, zip_code).
+// @Description  Send the file as the multipart field "file" or as the raw body. Each row is validated like POST /establishments and the report lists the failed rows by line number.
+// @Tags         import
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Accept       multipart/form-data,text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce      json
+// @Param        file     formData  file    false  "CSV or XLSX file"
+// @Param        mode     query     string  false  "create (default) fails on existing numbers, upsert updates them"
+// @Param        dry_run  query     bool    false  "Validate and apply every row, then roll everything back"
+// @Param        atomic   query     bool    false  "Import all rows or none (default true); false skips the failed rows"
+// @Success      200  {object} model.ImportReport
+// @Failure      400  {object} Problem
+// @Failure      401  {object} Problem
+// @Failure      403  {object} Problem
+// @Failure      415  {object} Problem
+// @Failure      422  {object} model.ImportReport "Some row failed in an atomic import, nothing was written"
+// @Failure      500  {object} Problem
+// @Router       /import/establishments [post]
+func (h *ImportHandler) ImportEstablishments(c echo.Context) error {
+	return h.importFile(c, model.EstablishmentImportColumns, h.Service.ImportEstablishments)
+}
+
+// ImportStores godoc
+// @Summary      Import stores
+// @Description  Creates or updates stores from a CSV or XLSX file whose first row names the columns (number, name, corporate_name, address, address_number, city, state, zip_code and establishment_id or establishment_number).
+// @Description  Send the file as the multipart field "file" or as the raw body. Each row is validated like POST /stores and the report lists the failed rows by line number.
+// @Tags         import
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Accept       multipart/form-data,text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce      json
+// @Param        file     formData  file    false  "CSV or XLSX file"
+// @Param        mode     query     string  false  "create (default) fails on existing numbers, upsert updates them"
+// @Param        dry_run  query     bool    false  "Validate and apply every row, then roll everything back"
+// @Param        atomic   query     bool    false  "Import all rows or none (default true); false skips the failed rows"
+// @Success      200  {object} model.ImportReport
+// @Failure      400  {object} Problem
+// @Failure      401  {object} Problem
+// @Failure      403  {object} Problem
+// @Failure      415  {object} Problem
+// @Failure      422  {object} model.ImportReport "Some row failed in an atomic import, nothing was written"
+// @Failure      500  {object} Problem
+// @Router       /import/stores [post]
+func (h *ImportHandler) ImportStores(c echo.Context) error {
+	return h.importFile(c, model.StoreImportColumns, h.Service.ImportStores)
+}
+
+type importFunc func(ctx context.Context, rows []model.ImportRow, opts model.ImportOptions) (*model.ImportReport, error)
+
+func (h *ImportHandler) importFile(c echo.Context, columns []string, importRows importFunc) error {
+	opts, err := parseImportOptions(c)
+	if err != nil {
+		return err
+	}
+	rows, err := readImportRows(c, columns)
+	if err != nil {
+		return err
+	}
+	report, err := importRows(c.Request().Context(), rows, opts)
+	if err != nil {
+		return err
+	}
+	if opts.Atomic && report.Failed > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// parseImportOptions reads the mode, dry_run and atomic query params
+func parseImportOptions(c echo.Context) (model.ImportOptions, error) {
+	opts := model.ImportOptions{Mode: model.ImportModeCreate, Atomic: true}
+	if raw := strings.TrimSpace(c.QueryParam("mode")); raw != "" {
+		if !slices.Contains(model.ImportModes, raw) {
+			return opts, domainerr.Validation("Invalid mode. Must be one of: " + strings.Join(model.ImportModes, ", ") + ".")
+		}
+		opts.Mode = raw
+	}
+	for name, target := range map[string]*bool{"dry_run": &opts.DryRun, "atomic": &opts.Atomic} {
+		if raw := c.QueryParam(name); raw != "" {
+			value, err := strconv.ParseBool(raw)
+			if err != nil {
+				return opts, domainerr.Validation("Invalid " + name + ". Must be true or false.")
+			}
+			*target = value
+		}
+	}
+	return opts, nil
+}
+
+// readImportRows reads the uploaded file and maps its data rows by the column names of the header
+func readImportRows(c echo.Context, columns []string) ([]model.ImportRow, error) {
+	data, mediaType, err := readImportFile(c)
+	if err != nil {
+		return nil, err
+	}
+	var records [][]string
+	var lines []int
+	switch mediaType {
+	case MIMETextCSV:
+		records, lines, err = readCSV(data)
+	case MIMEApplicationXLSX:
+		// the header plus the most data rows, though blank rows among them are skipped below
+		records, err = util.ReadXLSX(bytes.NewReader(data), int64(len(data)), model.MaxImportRows+1, maxImportColumns)
+		for i := range records {
+			lines = append(lines, i+1)
+		}
+	}
+	if errors.Is(err, util.ErrXLSXTooManyRows) {
+		return nil, tooManyImportRows()
+	}
+	if err != nil {
+		return nil, domainerr.Validation("Invalid file: " + err.Error())
+	}
+	if len(records) == 0 {
+		return nil, domainerr.Validation("The file is empty. The first row must name the columns.")
+	}
+
+	header := make([]string, len(records[0]))
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(columns, name) {
+			return nil, domainerr.Validation("Unknown column \"" + name + "\". Must be one of: " + strings.Join(columns, ", ") + ".")
+		}
+		if slices.Contains(header, name) {
+			return nil, domainerr.Validation("Column \"" + name + "\" is repeated.")
+		}
+		header[i] = name
+	}
+
+	var rows []model.ImportRow
+	for i, record := range records[1:] {
+		row := model.ImportRow{Line: lines[i+1], Values: make(map[string]string, len(header))}
+		blank := true
+		for j, value := range record {
+			value = strings.TrimSpace(value)
+			if j < len(header) && header[j] != "" {
+				row.Values[header[j]] = value
+			}
+			blank = blank && value == ""
+		}
+		if blank {
+			continue
+		}
+		if len(rows) == model.MaxImportRows {
+			return nil, tooManyImportRows()
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, domainerr.Validation("The file has no data rows.")
+	}
+	return rows, nil
+}
+
+// readImportFile reads the file from the multipart field "file" or from the raw body, returning
+// its media type. The type of an upload is taken from its own Content-Type or, failing that, its extension.
+func readImportFile(c echo.Context) ([]byte, string, error) {
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, maxImportSize)
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+
+	var body io.Reader = req.Body
+	if mediaType == echo.MIMEMultipartForm {
+		fileHeader, formErr := c.FormFile("file")
+		if formErr != nil {
+			if errors.As(formErr, new(*http.MaxBytesError)) {
+				return nil, "", importTooLarge()
+			}
+			return nil, "", domainerr.Validation("Missing file. Send it in the multipart field \"file\".")
+		}
+		mediaType, _, _ = mime.ParseMediaType(fileHeader.Header.Get(echo.HeaderContentType))
+		if !isImportMediaType(mediaType) {
+			switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+			case ".csv":
+				mediaType = MIMETextCSV
+			case ".xlsx":
+				mediaType = MIMEApplicationXLSX
+			}
+		}
+		file, openErr := fileHeader.Open()
+		if openErr != nil {
+			return nil, "", openErr
+		}
+		defer file.Close()
+		body = file
+	}
+	if !isImportMediaType(mediaType) {
+		return nil, "", echo.NewHTTPError(http.StatusUnsupportedMediaType, "Unsupported file type. Send a CSV ("+MIMETextCSV+") or XLSX ("+MIMEApplicationXLSX+") file.")
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		if errors.As(err, new(*http.MaxBytesError)) {
+			return nil, "", importTooLarge()
+		}
+		return nil, "", domainerr.Validation("Invalid request body")
+	}
+	return data, mediaType, nil
+}
+
+func isImportMediaType(mediaType string) bool {
+	return mediaType == MIMETextCSV || mediaType == MIMEApplicationXLSX
+}
+
+func tooManyImportRows() error {
+	return domainerr.Validation("The file has too many rows. Import at most " + strconv.Itoa(model.MaxImportRows) + " rows at a time.")
+}
+
+func importTooLarge() error {
+	return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "The file is too large. Send at most "+strconv.Itoa(maxImportSize>>20)+" MB.")
+}
+
+// readCSV reads the records of a CSV file with their line numbers, dropping the byte order mark
+// spreadsheets put at the start of UTF-8 files. Files saved by spreadsheets
+// in locales that use the comma as decimal separator are split by semicolons, so the delimiter
+// is taken from the header: semicolon when it has more of them than commas.
+func readCSV(data []byte) ([][]string, []int, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	r := csv.NewReader(bytes.NewReader(data))
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		r.Comma = ';'
+	}
+	r.FieldsPerRecord = -1
+
+	var (
+		records [][]string
+		lines   []int
+	)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, lines, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := r.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+}
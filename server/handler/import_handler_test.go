@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"go.uber.org/zap"
+)
+
+type mockImportService struct {
+	rows   []model.ImportRow
+	opts   model.ImportOptions
+	failed int
+}
+
+func (m *mockImportService) report(rows []model.ImportRow, opts model.ImportOptions) (*model.ImportReport, error) {
+	m.rows, m.opts = rows, opts
+	report := &model.ImportReport{Mode: opts.Mode, DryRun: opts.DryRun, Atomic: opts.Atomic, Rows: len(rows), Errors: map[int]model.ImportRowError{}}
+	if m.failed > 0 {
+		report.Failed = m.failed
+		report.Errors[2] = model.ImportRowError{Message: "one or more fields are invalid", Errors: map[string]string{"name": "is required"}}
+	} else {
+		report.Created = len(rows)
+	}
+	return report, nil
+}
+
+func (m *mockImportService) ImportEstablishments(ctx context.Context, rows []model.ImportRow, opts model.ImportOptions) (*model.ImportReport, error) {
+	return m.report(rows, opts)
+}
+func (m *mockImportService) ImportStores(ctx context.Context, rows []model.ImportRow, opts model.ImportOptions) (*model.ImportReport, error) {
+	return m.report(rows, opts)
+}
+
+func setupImportEcho(svc *mockImportService) *echo.Echo {
+	e := echo.New()
+	logger := zap.NewNop()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(logger)
+	e.Use(AuthenticateAs(testAdmin))
+	NewImportHandler(e, svc, logger)
+	return e
+}
+
+func TestImportEstablishments_CSV(t *testing.T) {
+	svc := &mockImportService{}
+	e := setupImportEcho(svc)
+	body := "\ufeffNumber;Name;city\n11.222.333/0001-81;Loja A; São Paulo \n;;\n11444777000161;\"Loja; B\";Campinas\n"
+	req := httptest.NewRequest(http.MethodPost, "/import/establishments?mode=upsert&dry_run=true", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, MIMETextCSV+"; charset=utf-8")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"created":2`)
+	assert.Equal(t, model.ImportOptions{Mode: model.ImportModeUpsert, DryRun: true, Atomic: true}, svc.opts)
+	require.Len(t, svc.rows, 2)
+	assert.Equal(t, model.ImportRow{Line: 2, Values: map[string]string{"number": "11.222.333/0001-81", "name": "Loja A", "city": "São Paulo"}}, svc.rows[0])
+	assert.Equal(t, 4, svc.rows[1].Line)
+	assert.Equal(t, "Loja; B", svc.rows[1].Values["name"])
+}
+
+func TestImportStores_Multipart(t *testing.T) {
+	svc := &mockImportService{}
+	e := setupImportEcho(svc)
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "lojas.csv")
+	require.NoError(t, err)
+	_, _ = fw.Write([]byte("number,name,establishment_number\n11222333000181,Loja A,11444777000161\n"))
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/import/stores?atomic=false", &body)
+	req.Header.Set(echo.HeaderContentType, mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.False(t, svc.opts.Atomic)
+	require.Len(t, svc.rows, 1)
+	assert.Equal(t, "11444777000161", svc.rows[0].Values["establishment_number"])
+}
+
+func TestImportEstablishments_AtomicoComFalha(t *testing.T) {
+	svc := &mockImportService{failed: 1}
+	e := setupImportEcho(svc)
+	req := httptest.NewRequest(http.MethodPost, "/import/establishments", strings.NewReader("number,name\n11222333000181,\n"))
+	req.Header.Set(echo.HeaderContentType, MIMETextCSV)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"errors":{"2":{"message":"one or more fields are invalid","errors":{"name":"is required"}}}`)
+}
+
+func TestImportEstablishments_ArquivoInvalido(t *testing.T) {
+	for name, c := range map[string]struct{ query, body string }{
+		"coluna desconhecida": {"", "number,price\n1,2\n"},
+		"coluna repetida":     {"", "number,name,number\n1,2,3\n"},
+		"sem linhas":          {"", "number,name\n,\n"},
+		"vazio":               {"", ""},
+		"modo invalido":       {"?mode=replace", "number\n1\n"},
+		"dry_run invalido":    {"?dry_run=talvez", "number\n1\n"},
+		"csv malformado":      {"", "number,name\n\"1,2\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			svc := &mockImportService{}
+			e := setupImportEcho(svc)
+			req := httptest.NewRequest(http.MethodPost, "/import/establishments"+c.query, strings.NewReader(c.body))
+			req.Header.Set(echo.HeaderContentType, MIMETextCSV)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Nil(t, svc.rows)
+		})
+	}
+}
+
+func TestImportEstablishments_TipoNaoSuportado(t *testing.T) {
+	e := setupImportEcho(&mockImportService{})
+	req := httptest.NewRequest(http.MethodPost, "/import/establishments", strings.NewReader(`[{"number":"1"}]`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}
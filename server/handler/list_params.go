@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/util"
+)
+
+// parseListParams reads the pagination, sorting and filtering query params shared by the list endpoints.
+// A sort prefixed with "-" orders descending, and include_deleted requires the admin role. The
+// establishment_id filter is only accepted by the lists that filterByEstablishment, and a cursor only
+// under the sort of the page that returned it.
+func parseListParams(c echo.Context, sortFields []string, filterByEstablishment bool) (model.ListParams, error) {
+	params := model.ListParams{
+		Limit:     model.DefaultListLimit,
+		Cursor:    c.QueryParam("cursor"),
+		City:      strings.TrimSpace(c.QueryParam("city")),
+		State:     strings.TrimSpace(c.QueryParam("state")),
+		ZipPrefix: strings.ReplaceAll(strings.TrimSpace(c.QueryParam("zip_prefix")), "-", ""),
+	}
+
+	if raw := c.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > model.MaxListLimit {
+			return params, domainerr.Validation("Invalid limit. Must be an integer between 1 and " + strconv.Itoa(model.MaxListLimit) + ".")
+		}
+		params.Limit = limit
+	}
+
+	if sort := c.QueryParam("sort"); sort != "" {
+		params.Desc = strings.HasPrefix(sort, "-")
+		params.Sort = strings.TrimPrefix(sort, "-")
+		if !slices.Contains(sortFields, params.Sort) {
+			return params, domainerr.Validation("Invalid sort. Must be one of: " + strings.Join(sortFields, ", ") + ".")
+		}
+	}
+
+	if params.Cursor != "" {
+		cursor, err := util.DecodeCursor(params.Cursor)
+		if err != nil {
+			return params, domainerr.Validation("Invalid cursor.")
+		}
+		if cursor.Sort != params.SortKey() {
+			return params, domainerr.Validation("Invalid cursor. It was returned for another sort; send the same sort as the first page.")
+		}
+	}
+
+	if raw := c.QueryParam("establishment_id"); raw != "" {
+		if !filterByEstablishment {
+			return params, domainerr.Validation("Invalid establishment_id. This list cannot be filtered by establishment.")
+		}
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			return params, domainerr.Validation("Invalid establishment_id. Must be a positive integer.")
+		}
+		params.EstablishmentID = id
+	}
+
+	if raw := c.QueryParam("include_deleted"); raw != "" {
+		include, err := strconv.ParseBool(raw)
+		if err != nil {
+			return params, domainerr.Validation("Invalid include_deleted. Must be true or false.")
+		}
+		// Deleted rows are only shown to admins, who are also the ones able to restore them
+		if include {
+			if err := checkRole(c, auth.RoleAdmin); err != nil {
+				return params, err
+			}
+		}
+		params.IncludeDeleted = include
+	}
+
+	return params, nil
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+// Media types of the patch documents accepted by the PATCH endpoints
+const (
+	MIMEApplicationMergePatchJSON = "application/merge-patch+json"
+	MIMEApplicationJSONPatchJSON  = "application/json-patch+json"
+)
+
+// maxPatchSize is the largest patch document accepted, in bytes
+const maxPatchSize = 64 << 10
+
+// HeaderAcceptPatch advertises the accepted patch media types when a PATCH is sent with another one
+const HeaderAcceptPatch = "Accept-Patch"
+
+// bindPatch reads a patch document, telling its format from the Content-Type.
+// Plain application/json is taken as a merge patch, which is what clients sending a partial object mean.
+func bindPatch(c echo.Context) (model.Patch, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	var patch model.Patch
+	switch mediaType {
+	case MIMEApplicationMergePatchJSON, echo.MIMEApplicationJSON:
+		patch.Format = model.PatchFormatMerge
+	case MIMEApplicationJSONPatchJSON:
+		patch.Format = model.PatchFormatJSON
+	default:
+		accepted := strings.Join([]string{MIMEApplicationMergePatchJSON, MIMEApplicationJSONPatchJSON}, ", ")
+		c.Response().Header().Set(HeaderAcceptPatch, accepted)
+		return patch, echo.NewHTTPError(http.StatusUnsupportedMediaType, "Unsupported patch media type. Use one of: "+accepted+".")
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, maxPatchSize))
+	if errors.As(err, new(*http.MaxBytesError)) {
+		return patch, echo.NewHTTPError(http.StatusRequestEntityTooLarge, "The patch is too large. Send at most "+strconv.Itoa(maxPatchSize>>10)+" KB.")
+	}
+	if err != nil || len(strings.TrimSpace(string(body))) == 0 {
+		return patch, domainerr.Validation("Invalid request body")
+	}
+	patch.Document = body
+	return patch, nil
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	"github.com/yMaatheus/tech-challenge-snet/domain/actor"
+	"github.com/yMaatheus/tech-challenge-snet/ratelimit"
+	"go.uber.org/zap"
+)
+
+// Headers describing the rate limit of the client on every response, as in the IETF RateLimit header
+// fields draft
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+)
+
+// RateLimit throttles each client with a token bucket from store, one for read routes (GET, HEAD and
+// OPTIONS) and another for write routes, with the read and write budgets. Clients are told apart by API
+// key, then by user, and the anonymous ones by IP, so it goes after the authentication middlewares.
+// Throttled requests get 429 with Retry-After. When the store fails the request is let through, since
+// an outage of the limiter should not take the API down with it. A zero limit disables its routes.
+func RateLimit(store ratelimit.Store, read, write ratelimit.Limit, logger *zap.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !rateLimited(c) {
+				return next(c)
+			}
+			class, limit := "write", write
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				class, limit = "read", read
+			}
+			if err := takeToken(c, store, class+":"+clientKey(c), limit, logger); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// RateLimitIP throttles each IP with a token bucket from store before the request is authenticated, so
+// clients sending bad tokens or API keys, which never reach the budgets of RateLimit, are held back too.
+// It goes before the authentication middlewares and behaves as RateLimit otherwise.
+func RateLimitIP(store ratelimit.Store, limit ratelimit.Limit, logger *zap.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !rateLimited(c) {
+				return next(c)
+			}
+			if err := takeToken(c, store, "ip:"+c.RealIP(), limit, logger); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// rateLimited tells whether the route counts against the budgets, which the health check and the docs don't
+func rateLimited(c echo.Context) bool {
+	path := c.Path()
+	return path != "/health" && !strings.HasPrefix(path, "/docs")
+}
+
+// takeToken takes a token from the bucket of key, describing it in the response headers, and returns
+// 429 when there was none left. A disabled limit or a failing store let the request through.
+func takeToken(c echo.Context, store ratelimit.Store, key string, limit ratelimit.Limit, logger *zap.Logger) error {
+	if !limit.Enabled() {
+		return nil
+	}
+	result, err := store.Take(c.Request().Context(), key, limit)
+	if err != nil {
+		logger.Warn("Rate limiter unavailable, letting the request through", zap.Error(err))
+		return nil
+	}
+	header := c.Response().Header()
+	header.Set(HeaderRateLimitLimit, strconv.Itoa(limit.Requests))
+	header.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+	header.Set(HeaderRateLimitReset, ceilSeconds(result.Reset))
+	header.Set(HeaderRateLimitPolicy, limit.Policy())
+	if !result.Allowed {
+		header.Set(echo.HeaderRetryAfter, ceilSeconds(result.RetryAfter))
+		return echo.NewHTTPError(http.StatusTooManyRequests, "Too many requests, retry after "+ceilSeconds(result.RetryAfter)+" seconds.")
+	}
+	return nil
+}
+
+// clientKey identifies who sent the request: the API key, the user of the token, or the IP when
+// there is neither
+func clientKey(c echo.Context) string {
+	principal, ok := auth.FromContext(c.Request().Context())
+	switch {
+	case !ok || principal.Subject == "" || principal.Subject == actor.Anonymous:
+		return "ip:" + c.RealIP()
+	case principal.Scopes != nil:
+		return "key:" + principal.Subject
+	default:
+		return "user:" + principal.Subject
+	}
+}
+
+// ceilSeconds writes d in whole seconds, rounded up so clients never retry too early
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	"github.com/yMaatheus/tech-challenge-snet/ratelimit"
+	"go.uber.org/zap"
+)
+
+// failingStore stands in for a rate limit store that is down
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func setupRateLimitEcho(store ratelimit.Store, read, write ratelimit.Limit) *echo.Echo {
+	e := echo.New()
+	logger := zap.NewNop()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(logger)
+	e.Use(Authenticate(&fakeVerifier{tokens: map[string]auth.Principal{
+		"maria": {Subject: "maria", Role: auth.RoleEditor},
+		"joao":  {Subject: "joao", Role: auth.RoleEditor},
+	}}))
+	e.Use(RateLimit(store, read, write, logger))
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }
+	e.GET("/stores", ok)
+	e.POST("/stores", ok)
+	e.GET("/health", ok)
+	return e
+}
+
+func rateLimitRequest(e *echo.Echo, method, token, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/stores", nil)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	req.RemoteAddr = ip + ":40000"
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimit(t *testing.T) {
+	read := ratelimit.Limit{Requests: 3, Period: time.Minute}
+	write := ratelimit.Limit{Requests: 1, Period: time.Minute}
+	e := setupRateLimitEcho(ratelimit.NewMemoryStore(), read, write)
+
+	rec := rateLimitRequest(e, http.MethodPost, "maria", "10.0.0.1")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get(HeaderRateLimitLimit))
+	assert.Equal(t, "0", rec.Header().Get(HeaderRateLimitRemaining))
+	assert.Equal(t, "60", rec.Header().Get(HeaderRateLimitReset))
+	assert.Equal(t, "1;w=60", rec.Header().Get(HeaderRateLimitPolicy))
+
+	rec = rateLimitRequest(e, http.MethodPost, "maria", "10.0.0.2")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "the budget follows the user, not the IP")
+	assert.Equal(t, "60", rec.Header().Get(echo.HeaderRetryAfter))
+	assert.Contains(t, rec.Body.String(), "Too many requests")
+
+	rec = rateLimitRequest(e, http.MethodGet, "maria", "10.0.0.1")
+	assert.Equal(t, http.StatusNoContent, rec.Code, "reads have their own budget")
+	assert.Equal(t, "3", rec.Header().Get(HeaderRateLimitLimit))
+	assert.Equal(t, "2", rec.Header().Get(HeaderRateLimitRemaining))
+
+	rec = rateLimitRequest(e, http.MethodPost, "joao", "10.0.0.1")
+	assert.Equal(t, http.StatusNoContent, rec.Code, "each user has their own budget")
+}
+
+func TestRateLimit_Anonimo(t *testing.T) {
+	e := setupRateLimitEcho(ratelimit.NewMemoryStore(), ratelimit.Limit{Requests: 1, Period: time.Minute}, ratelimit.Limit{})
+
+	assert.Equal(t, http.StatusNoContent, rateLimitRequest(e, http.MethodGet, "", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, rateLimitRequest(e, http.MethodGet, "", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusNoContent, rateLimitRequest(e, http.MethodGet, "", "10.0.0.2").Code, "anonymous clients are told apart by IP")
+
+	rec := rateLimitRequest(e, http.MethodPost, "", "10.0.0.1")
+	assert.Equal(t, http.StatusNoContent, rec.Code, "a zero limit disables it")
+	assert.Empty(t, rec.Header().Get(HeaderRateLimitLimit))
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Header().Get(HeaderRateLimitLimit), "health checks are not limited")
+}
+
+func TestRateLimitIP_CredenciaisInvalidas(t *testing.T) {
+	e := echo.New()
+	logger := zap.NewNop()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(logger)
+	e.Use(RateLimitIP(ratelimit.NewMemoryStore(), ratelimit.Limit{Requests: 3, Period: time.Minute}, logger))
+	e.Use(Authenticate(&fakeVerifier{tokens: map[string]auth.Principal{"maria": {Subject: "maria", Role: auth.RoleEditor}}}))
+	e.GET("/stores", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+
+	for range 3 {
+		assert.Equal(t, http.StatusUnauthorized, rateLimitRequest(e, http.MethodGet, "chute", "10.0.0.1").Code)
+	}
+	rec := rateLimitRequest(e, http.MethodGet, "chute", "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "bad credentials are throttled before authentication")
+	assert.Equal(t, "20", rec.Header().Get(echo.HeaderRetryAfter))
+	assert.Equal(t, http.StatusTooManyRequests, rateLimitRequest(e, http.MethodGet, "maria", "10.0.0.1").Code,
+		"a right guess is held back as well")
+
+	assert.Equal(t, http.StatusUnauthorized, rateLimitRequest(e, http.MethodGet, "chute", "10.0.0.2").Code, "each IP has its own budget")
+}
+
+func TestRateLimit_StoreIndisponivel(t *testing.T) {
+	limit := ratelimit.Limit{Requests: 1, Period: time.Minute}
+	e := setupRateLimitEcho(failingStore{}, limit, limit)
+
+	rec := rateLimitRequest(e, http.MethodPost, "maria", "10.0.0.1")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Header().Get(HeaderRateLimitLimit))
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/util"
+)
+
+// parseID reads a positive integer path param, failing with a validation error that names the entity
+func parseID(c echo.Context, param, entity string) (int64, error) {
+	id, err := strconv.ParseInt(c.Param(param), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, domainerr.Validation("Invalid " + entity + " ID. Must be a positive integer.")
+	}
+	return id, nil
+}
+
+// bindAndValidate binds the request body into v and runs the struct validations on it
+func bindAndValidate(c echo.Context, v interface{}) error {
+	if err := c.Bind(v); err != nil {
+		return domainerr.Validation("Invalid request body")
+	}
+	if err := util.Validate.Struct(v); err != nil {
+		return domainerr.ValidationFields(util.ParseValidationError(err))
+	}
+	return nil
+}
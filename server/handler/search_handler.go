@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/service"
+	"go.uber.org/zap"
+)
+
+// SearchHandler handles the text search over establishments and stores
+type SearchHandler struct {
+	Service service.SearchService
+	Logger  *zap.Logger
+}
+
+// NewSearchHandler sets up the route for searches
+func NewSearchHandler(e *echo.Echo, svc service.SearchService, logger *zap.Logger) {
+	h := &SearchHandler{Service: svc, Logger: logger}
+	e.GET("/search", h.Search, requireViewer)
+}
+
+// Search godoc
+// @Summary      Search establishments and stores
+// @Description  Searches name, corporate name, number, address and city, ignoring case and accents. Words match as prefixes
+// @Description  and misspellings are tolerated by trigram similarity. Results of both types come mixed, best first, with the
+// @Description  matched fields in highlights as HTML-escaped text where the matches are wrapped in <mark>.
+// @Tags         search
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Produce      json
+// @Param        q      query  string  true   "Search text, at least 2 characters"
+// @Param        type   query  string  false  "Only return one type of result (establishment, store)"
+// @Param        limit  query  int     false  "Maximum number of results (1-100, default 20)"
+// @Success      200  {object} model.SearchResults
+// @Failure      400  {object} Problem
+// @Failure      401  {object} Problem
+// @Failure      403  {object} Problem
+// @Failure      500  {object} Problem
+// @Router       /search [get]
+func (h *SearchHandler) Search(c echo.Context) error {
+	params, err := parseSearchParams(c)
+	if err != nil {
+		return err
+	}
+	if params.Types, err = searchableTypes(c, params.Types); err != nil {
+		return err
+	}
+	results, err := h.Service.Search(c.Request().Context(), params)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, results)
+}
+
+// searchScopes maps each result type to the scope an API key needs to see it
+var searchScopes = map[string]auth.Scope{
+	model.SearchTypeEstablishment: auth.ScopeReadEstablishments,
+	model.SearchTypeStore:         auth.ScopeReadStores,
+}
+
+// searchableTypes narrows the requested result types, or every type when none is requested, to the ones
+// the scopes of the caller allow. Asking only for types it may not see is forbidden.
+func searchableTypes(c echo.Context, requested []string) ([]string, error) {
+	principal, _ := auth.FromContext(c.Request().Context())
+	if principal.Scopes == nil {
+		return requested, nil
+	}
+	if len(requested) == 0 {
+		requested = model.SearchTypes
+	}
+	var allowed []string
+	for _, t := range requested {
+		if principal.HasScope(searchScopes[t]) {
+			allowed = append(allowed, t)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "Searching requires the read:establishments or read:stores scope.")
+	}
+	return allowed, nil
+}
+
+// parseSearchParams reads the query, type and limit query params
+func parseSearchParams(c echo.Context) (model.SearchParams, error) {
+	params := model.SearchParams{
+		Query: c.QueryParam("q"),
+		Limit: model.DefaultListLimit,
+	}
+	if t := strings.TrimSpace(c.QueryParam("type")); t != "" {
+		if !slices.Contains(model.SearchTypes, t) {
+			return params, domainerr.Validation("Invalid type. Must be one of: " + strings.Join(model.SearchTypes, ", ") + ".")
+		}
+		params.Types = []string{t}
+	}
+	if raw := c.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > model.MaxListLimit {
+			return params, domainerr.Validation("Invalid limit. Must be an integer between 1 and " + strconv.Itoa(model.MaxListLimit) + ".")
+		}
+		params.Limit = limit
+	}
+	return params, nil
+}
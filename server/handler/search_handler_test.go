@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"go.uber.org/zap"
+)
+
+type mockSearchService struct {
+	params model.SearchParams
+}
+
+func (m *mockSearchService) Search(ctx context.Context, params model.SearchParams) (*model.SearchResults, error) {
+	m.params = params
+	return &model.SearchResults{Items: []model.SearchResult{{
+		Type: model.SearchTypeStore, ID: 3, EstablishmentID: 1, Name: "Loja Centro", Score: 0.8,
+		Highlights: map[string]string{"name": "Loja <mark>Centro</mark>"},
+	}}}, nil
+}
+
+func setupSearchEcho(svc *mockSearchService) *echo.Echo {
+	e := echo.New()
+	logger := zap.NewNop()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(logger)
+	e.Use(AuthenticateAs(testAdmin))
+	NewSearchHandler(e, svc, logger)
+	return e
+}
+
+func TestSearch_Success(t *testing.T) {
+	svc := &mockSearchService{}
+	e := setupSearchEcho(svc)
+	req := httptest.NewRequest(http.MethodGet, "/search?q=centro&type=store&limit=5", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"type":"store","id":3,"establishment_id":1`)
+	assert.Contains(t, rec.Body.String(), `"highlights":{"name":"Loja \u003cmark\u003eCentro\u003c/mark\u003e"}`)
+	assert.Equal(t, model.SearchParams{Query: "centro", Types: []string{model.SearchTypeStore}, Limit: 5}, svc.params)
+}
+
+func TestSearch_ParametrosInvalidos(t *testing.T) {
+	for _, query := range []string{"q=loja&type=product", "q=loja&limit=0", "q=loja&limit=101"} {
+		t.Run(query, func(t *testing.T) {
+			e := setupSearchEcho(&mockSearchService{})
+			req := httptest.NewRequest(http.MethodGet, "/search?"+query, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/labstack/echo/v4"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
 	"github.com/yMaatheus/tech-challenge-snet/model"
 	"github.com/yMaatheus/tech-challenge-snet/service"
 	"github.com/yMaatheus/tech-challenge-snet/util"
@@ -20,143 +22,552 @@ type StoreHandler struct {
 // NewStoreHandler sets up the routes for Store
 func NewStoreHandler(e *echo.Echo, svc service.StoreService, logger *zap.Logger) {
 	h := &StoreHandler{Service: svc, Logger: logger}
-	e.POST("/stores", h.Create)
-	e.GET("/stores", h.List)
-	e.GET("/stores/:id", h.Get)
-	e.PUT("/stores/:id", h.Update)
-	e.DELETE("/stores/:id", h.Delete)
+	e.POST("/stores", h.Create, requireEditor, requireWriteStores)
+	e.GET("/stores", h.List, requireViewer, requireReadStores)
+	e.GET("/stores/nearby", h.Nearby, requireViewer, requireReadStores)
+	e.GET("/stores/:id", h.Get, requireViewer, requireReadStores)
+	e.PUT("/stores/:id", h.Update, requireEditor, requireWriteStores)
+	e.PATCH("/stores/:id", h.Patch, requireEditor, requireWriteStores)
+	e.DELETE("/stores/:id", h.Delete, requireAdmin)
+	e.POST("/stores/:id/restore", h.Restore, requireAdmin)
+
+	// Stores nested under their establishment, where the establishment in the path is authoritative
+	e.GET("/establishments/:id/stores", h.ListByEstablishment, requireViewer, requireReadStores)
+	e.POST("/establishments/:id/stores", h.CreateInEstablishment, requireEditor, requireWriteStores)
+	e.GET("/establishments/:id/stores/:storeId", h.GetInEstablishment, requireViewer, requireReadStores)
+	e.PUT("/establishments/:id/stores/:storeId", h.UpdateInEstablishment, requireEditor, requireWriteStores)
+	e.DELETE("/establishments/:id/stores/:storeId", h.DeleteInEstablishment, requireAdmin)
 }
 
 // CreateStore godoc
 // @Summary      Create a new store
 // @Tags         stores
+// @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Accept       json
 // @Produce      json
-// @Param        store  body     model.Store  true  "Store to create"
+// @Param        store            body     model.Store  true   "Store to create"
+// @Param        Idempotency-Key  header   string       false  "Key to retry the request safely; retries get the first response"
 // @Success      201    {object} model.Store
-// @Failure      400    {object} map[string]string
-// @Failure      500    {object} map[string]string
+// @Header       201    {string} ETag  "Version of the store"
+// @Failure      400    {object} Problem
+// @Failure      401    {object} Problem
+// @Failure      403    {object} Problem
+// @Failure      409    {object} Problem
+// @Failure      422    {object} Problem
+// @Failure      500    {object} Problem
 // @Router       /stores [post]
 func (h *StoreHandler) Create(c echo.Context) error {
 	var store model.Store
-	if err := c.Bind(&store); err != nil {
-		h.Logger.Warn("Failed to bind store", zap.Error(err))
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
-	}
-	if err := util.Validate.Struct(&store); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"validation_error": util.ParseValidationError(err),
-		})
+	if err := bindAndValidate(c, &store); err != nil {
+		return err
 	}
 	if err := h.Service.Create(c.Request().Context(), &store); err != nil {
-		h.Logger.Error("Failed to create store", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Could not create store"})
+		return err
 	}
+	setETag(c, store.Version)
 	h.Logger.Info("Store created", zap.Int64("id", store.ID))
 	return c.JSON(http.StatusCreated, store)
 }
 
 // ListStores godoc
-// @Summary      List all stores
+// @Summary      List stores
+// @Description  Get a page of stores using keyset (cursor) pagination
 // @Tags         stores
+// @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Produce      json
-// @Success      200  {array}  model.Store
-// @Failure      500  {object} map[string]string
+// @Param        limit             query  int     false  "Page size (1-100, default 20)"
+// @Param        cursor            query  string  false  "Cursor returned as next_cursor by the previous page"
+// @Param        sort              query  string  false  "Sort field (id, number, name, city, state, zip_code), prefix with - for descending"
+// @Param        city              query  string  false  "Filter by city"
+// @Param        state             query  string  false  "Filter by state"
+// @Param        zip_prefix        query  string  false  "Filter by zip code prefix"
+// @Param        include_deleted   query  bool    false  "Also list soft deleted rows (admin only)"
+// @Param        establishment_id  query  int     false  "Filter by establishment ID"
+// @Success      200  {object} model.StorePage
+// @Failure      400  {object} Problem
+// @Failure      401  {object} Problem
+// @Failure      403  {object} Problem
+// @Failure      500  {object} Problem
 // @Router       /stores [get]
 func (h *StoreHandler) List(c echo.Context) error {
-	stores, err := h.Service.FindAll(c.Request().Context())
+	params, err := parseListParams(c, model.StoreSortFields, true)
 	if err != nil {
-		h.Logger.Error("Failed to list stores", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Could not fetch stores"})
+		return err
+	}
+	page, err := h.Service.FindAll(c.Request().Context(), params)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, page)
+}
+
+// NearbyStores godoc
+// @Summary      List the stores near a point
+// @Description  Get the stores within radius_km of the point, nearest first, with their distance along the Earth's surface.
+// @Description  Stores without coordinates, neither sent nor geocoded from their zip code, are never listed.
+// @Tags         stores
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Produce      json
+// @Param        lat               query  number  true   "Latitude of the point (-90 to 90)"
+// @Param        lng               query  number  true   "Longitude of the point (-180 to 180)"
+// @Param        radius_km         query  number  false  "Search radius in kilometers (up to 500, default 10)"
+// @Param        establishment_id  query  int     false  "Only list the stores of this establishment"
+// @Param        limit             query  int     false  "Maximum number of stores (1-100, default 20)"
+// @Success      200  {object} model.NearbyStores
+// @Failure      400  {object} Problem
+// @Failure      401  {object} Problem
+// @Failure      403  {object} Problem
+// @Failure      500  {object} Problem
+// @Router       /stores/nearby [get]
+func (h *StoreHandler) Nearby(c echo.Context) error {
+	params, err := parseNearbyParams(c)
+	if err != nil {
+		return err
+	}
+	stores, err := h.Service.FindNearby(c.Request().Context(), params)
+	if err != nil {
+		return err
 	}
 	return c.JSON(http.StatusOK, stores)
 }
 
+// parseNearbyParams reads the point, radius, establishment and limit of a nearby search
+func parseNearbyParams(c echo.Context) (model.NearbyParams, error) {
+	params := model.NearbyParams{RadiusKm: model.DefaultNearbyRadiusKm, Limit: model.DefaultListLimit}
+	var err error
+	if params.Latitude, err = strconv.ParseFloat(c.QueryParam("lat"), 64); err != nil || !(params.Latitude >= -90 && params.Latitude <= 90) {
+		return params, domainerr.Validation("Invalid lat. Must be a number between -90 and 90.")
+	}
+	if params.Longitude, err = strconv.ParseFloat(c.QueryParam("lng"), 64); err != nil || !(params.Longitude >= -180 && params.Longitude <= 180) {
+		return params, domainerr.Validation("Invalid lng. Must be a number between -180 and 180.")
+	}
+	if raw := c.QueryParam("radius_km"); raw != "" {
+		if params.RadiusKm, err = strconv.ParseFloat(raw, 64); err != nil || !(params.RadiusKm > 0 && params.RadiusKm <= model.MaxNearbyRadiusKm) {
+			return params, domainerr.Validation("Invalid radius_km. Must be a number greater than 0 and up to " + strconv.Itoa(model.MaxNearbyRadiusKm) + ".")
+		}
+	}
+	if raw := c.QueryParam("establishment_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			return params, domainerr.Validation("Invalid establishment_id. Must be a positive integer.")
+		}
+		params.EstablishmentID = id
+	}
+	if raw := c.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > model.MaxListLimit {
+			return params, domainerr.Validation("Invalid limit. Must be an integer between 1 and " + strconv.Itoa(model.MaxListLimit) + ".")
+		}
+		params.Limit = limit
+	}
+	return params, nil
+}
+
 // GetStore godoc
 // @Summary      Get store by ID
 // @Tags         stores
+// @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Produce      json
 // @Param        id   path      int  true  "Store ID"
 // @Success      200  {object}  model.Store
-// @Failure      400  {object}  map[string]string
-// @Failure      404  {object}  map[string]string
-// @Failure      500  {object}  map[string]string
+// @Header       200  {string}  ETag  "Version of the store, to send as If-Match when changing it"
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /stores/{id} [get]
 func (h *StoreHandler) Get(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || id <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid store ID. Must be a positive integer.",
-		})
+	id, err := parseID(c, "id", "store")
+	if err != nil {
+		return err
 	}
 	store, err := h.Service.FindByID(c.Request().Context(), id)
 	if err != nil {
-		h.Logger.Error("Failed to get store", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Could not fetch store"})
-	}
-	if store == nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Store not found"})
+		return err
 	}
+	setETag(c, store.Version)
 	return c.JSON(http.StatusOK, store)
 }
 
 // UpdateStore godoc
 // @Summary      Update a store by ID
+// @Description  If-Match must hold the ETag the store was read with, and the update fails with 412 when someone else changed it since
 // @Tags         stores
+// @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Accept       json
 // @Produce      json
-// @Param        id    path     int         true  "Store ID"
-// @Param        store body     model.Store true  "Store update"
+// @Param        id        path     int         true  "Store ID"
+// @Param        If-Match  header   string      true  "ETag of the store, or * to skip the check"
+// @Param        store     body     model.Store true  "Store update"
 // @Success      200   {object} map[string]string
-// @Failure      400   {object} map[string]string
-// @Failure      500   {object} map[string]string
+// @Header       200   {string} ETag  "New version of the store"
+// @Failure      400   {object} Problem
+// @Failure      401   {object} Problem
+// @Failure      403   {object} Problem
+// @Failure      404   {object} Problem
+// @Failure      409   {object} Problem
+// @Failure      412   {object} Problem
+// @Failure      422   {object} Problem
+// @Failure      428   {object} Problem
+// @Failure      500   {object} Problem
 // @Router       /stores/{id} [put]
 func (h *StoreHandler) Update(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || id <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid store ID. Must be a positive integer.",
-		})
+	id, err := parseID(c, "id", "store")
+	if err != nil {
+		return err
 	}
-	var store model.Store
-	if err := c.Bind(&store); err != nil {
-		h.Logger.Warn("Failed to bind store", zap.Error(err))
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	version, err := parseIfMatch(c, "store", id)
+	if err != nil {
+		return err
 	}
-	if err := util.Validate.Struct(&store); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
-			"validation_error": util.ParseValidationError(err),
-		})
+	var store model.Store
+	if err := bindAndValidate(c, &store); err != nil {
+		return err
 	}
 	store.ID = id
+	store.Version = version
 	if err := h.Service.Update(c.Request().Context(), &store); err != nil {
-		h.Logger.Error("Failed to update store", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Could not update store"})
+		return err
 	}
+	setETag(c, store.Version)
 	h.Logger.Info("Store updated", zap.Int64("id", id))
 	return c.JSON(http.StatusOK, map[string]string{"message": "Store updated successfully"})
 }
 
+// PatchStore godoc
+// @Summary      Partially update a store by ID
+// @Description  Apply a JSON Merge Patch (RFC 7396, application/merge-patch+json or application/json) or a JSON Patch
+// @Description  (RFC 6902, application/json-patch+json) to a store. Only the merged result is validated and only
+// @Description  the changed columns are written. If-Match must hold the ETag the store was read with.
+// @Tags         stores
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Accept       json
+// @Produce      json
+// @Param        id        path     int     true  "Store ID"
+// @Param        If-Match  header   string  true  "ETag of the store, or * to skip the check"
+// @Param        patch     body     object  true  "Merge patch object or array of JSON Patch operations"
+// @Success      200   {object} model.Store
+// @Header       200   {string} ETag  "New version of the store"
+// @Failure      400   {object} Problem
+// @Failure      401   {object} Problem
+// @Failure      403   {object} Problem
+// @Failure      404   {object} Problem
+// @Failure      409   {object} Problem
+// @Failure      412   {object} Problem
+// @Failure      415   {object} Problem
+// @Failure      422   {object} Problem
+// @Failure      428   {object} Problem
+// @Failure      500   {object} Problem
+// @Router       /stores/{id} [patch]
+func (h *StoreHandler) Patch(c echo.Context) error {
+	id, err := parseID(c, "id", "store")
+	if err != nil {
+		return err
+	}
+	version, err := parseIfMatch(c, "store", id)
+	if err != nil {
+		return err
+	}
+	patch, err := bindPatch(c)
+	if err != nil {
+		return err
+	}
+	store, err := h.Service.Patch(c.Request().Context(), id, version, patch)
+	if err != nil {
+		return err
+	}
+	setETag(c, store.Version)
+	h.Logger.Info("Store patched", zap.Int64("id", id))
+	return c.JSON(http.StatusOK, store)
+}
+
 // DeleteStore godoc
 // @Summary      Delete a store by ID
 // @Tags         stores
+// @Security     BearerAuth
 // @Produce      json
-// @Param        id   path      int  true  "Store ID"
+// @Param        id        path      int     true  "Store ID"
+// @Param        If-Match  header    string  true  "ETag of the store, or * to skip the check"
 // @Success      200  {object}  map[string]string
-// @Failure      400  {object}  map[string]string
-// @Failure      500  {object}  map[string]string
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      412  {object}  Problem
+// @Failure      428  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /stores/{id} [delete]
 func (h *StoreHandler) Delete(c echo.Context) error {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || id <= 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid store ID. Must be a positive integer.",
-		})
+	id, err := parseID(c, "id", "store")
+	if err != nil {
+		return err
+	}
+	version, err := parseIfMatch(c, "store", id)
+	if err != nil {
+		return err
 	}
-	if err := h.Service.Delete(c.Request().Context(), id); err != nil {
-		h.Logger.Error("Failed to delete store", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Could not delete store"})
+	if err := h.Service.Delete(c.Request().Context(), id, version); err != nil {
+		return err
 	}
 	h.Logger.Info("Store deleted", zap.Int64("id", id))
 	return c.JSON(http.StatusOK, map[string]string{"message": "Store deleted successfully"})
 }
+
+// RestoreStore godoc
+// @Summary      Restore a soft deleted store
+// @Description  The establishment of the store must not be deleted
+// @Tags         stores
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id   path      int  true  "Store ID"
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /stores/{id}/restore [post]
+func (h *StoreHandler) Restore(c echo.Context) error {
+	id, err := parseID(c, "id", "store")
+	if err != nil {
+		return err
+	}
+	if err := h.Service.Restore(c.Request().Context(), id); err != nil {
+		return err
+	}
+	h.Logger.Info("Store restored", zap.Int64("id", id))
+	return c.JSON(http.StatusOK, map[string]string{"message": "Store restored successfully"})
+}
+
+// ListEstablishmentStores godoc
+// @Summary      List the stores of an establishment
+// @Description  Get a page of the stores of an establishment using keyset (cursor) pagination
+// @Tags         stores
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Produce      json
+// @Param        id               path   int     true   "Establishment ID"
+// @Param        limit            query  int     false  "Page size (1-100, default 20)"
+// @Param        cursor           query  string  false  "Cursor returned as next_cursor by the previous page"
+// @Param        sort             query  string  false  "Sort field (id, number, name, city, state, zip_code), prefix with - for descending"
+// @Param        city             query  string  false  "Filter by city"
+// @Param        state            query  string  false  "Filter by state"
+// @Param        zip_prefix       query  string  false  "Filter by zip code prefix"
+// @Param        include_deleted  query  bool    false  "Also list soft deleted rows (admin only)"
+// @Success      200  {object} model.StorePage
+// @Failure      400  {object} Problem
+// @Failure      401  {object} Problem
+// @Failure      403  {object} Problem
+// @Failure      404  {object} Problem
+// @Failure      500  {object} Problem
+// @Router       /establishments/{id}/stores [get]
+func (h *StoreHandler) ListByEstablishment(c echo.Context) error {
+	establishmentID, err := parseID(c, "id", "establishment")
+	if err != nil {
+		return err
+	}
+	params, err := parseListParams(c, model.StoreSortFields, false)
+	if err != nil {
+		return err
+	}
+	page, err := h.Service.FindAllByEstablishment(c.Request().Context(), establishmentID, params)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, page)
+}
+
+// CreateEstablishmentStore godoc
+// @Summary      Create a store in an establishment
+// @Description  The establishment_id of the body may be omitted, but must match the path when sent
+// @Tags         stores
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Accept       json
+// @Produce      json
+// @Param        id               path     int          true   "Establishment ID"
+// @Param        store            body     model.Store  true   "Store to create"
+// @Param        Idempotency-Key  header   string       false  "Key to retry the request safely; retries get the first response"
+// @Success      201    {object} model.Store
+// @Header       201    {string} ETag  "Version of the store"
+// @Failure      400    {object} Problem
+// @Failure      401    {object} Problem
+// @Failure      403    {object} Problem
+// @Failure      404    {object} Problem
+// @Failure      409    {object} Problem
+// @Failure      500    {object} Problem
+// @Router       /establishments/{id}/stores [post]
+func (h *StoreHandler) CreateInEstablishment(c echo.Context) error {
+	establishmentID, err := parseID(c, "id", "establishment")
+	if err != nil {
+		return err
+	}
+	store, err := bindNestedStore(c, establishmentID)
+	if err != nil {
+		return err
+	}
+	if err := h.Service.Create(c.Request().Context(), store); err != nil {
+		if errors.Is(err, domainerr.ErrForeignKey) {
+			return domainerr.NotFound("establishment", establishmentID)
+		}
+		return err
+	}
+	setETag(c, store.Version)
+	h.Logger.Info("Store created", zap.Int64("id", store.ID), zap.Int64("establishment_id", establishmentID))
+	return c.JSON(http.StatusCreated, store)
+}
+
+// GetEstablishmentStore godoc
+// @Summary      Get a store of an establishment
+// @Tags         stores
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Produce      json
+// @Param        id       path      int  true  "Establishment ID"
+// @Param        storeId  path      int  true  "Store ID"
+// @Success      200  {object}  model.Store
+// @Header       200  {string}  ETag  "Version of the store, to send as If-Match when changing it"
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /establishments/{id}/stores/{storeId} [get]
+func (h *StoreHandler) GetInEstablishment(c echo.Context) error {
+	establishmentID, storeID, err := parseNestedStoreIDs(c)
+	if err != nil {
+		return err
+	}
+	store, err := h.Service.FindByIDInEstablishment(c.Request().Context(), establishmentID, storeID)
+	if err != nil {
+		return err
+	}
+	setETag(c, store.Version)
+	return c.JSON(http.StatusOK, store)
+}
+
+// UpdateEstablishmentStore godoc
+// @Summary      Update a store of an establishment
+// @Description  The establishment_id of the body may be omitted, but must match the path when sent
+// @Tags         stores
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Accept       json
+// @Produce      json
+// @Param        id        path     int          true  "Establishment ID"
+// @Param        storeId   path     int          true  "Store ID"
+// @Param        If-Match  header   string       true  "ETag of the store, or * to skip the check"
+// @Param        store     body     model.Store  true  "Store update"
+// @Success      200   {object} map[string]string
+// @Header       200   {string} ETag  "New version of the store"
+// @Failure      400   {object} Problem
+// @Failure      401   {object} Problem
+// @Failure      403   {object} Problem
+// @Failure      404   {object} Problem
+// @Failure      409   {object} Problem
+// @Failure      412   {object} Problem
+// @Failure      428   {object} Problem
+// @Failure      500   {object} Problem
+// @Router       /establishments/{id}/stores/{storeId} [put]
+func (h *StoreHandler) UpdateInEstablishment(c echo.Context) error {
+	establishmentID, storeID, err := parseNestedStoreIDs(c)
+	if err != nil {
+		return err
+	}
+	version, err := parseIfMatch(c, "store", storeID)
+	if err != nil {
+		return err
+	}
+	store, err := bindNestedStore(c, establishmentID)
+	if err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	if _, err := h.Service.FindByIDInEstablishment(ctx, establishmentID, storeID); err != nil {
+		return err
+	}
+	store.ID = storeID
+	store.Version = version
+	if err := h.Service.Update(ctx, store); err != nil {
+		return err
+	}
+	setETag(c, store.Version)
+	h.Logger.Info("Store updated", zap.Int64("id", storeID), zap.Int64("establishment_id", establishmentID))
+	return c.JSON(http.StatusOK, map[string]string{"message": "Store updated successfully"})
+}
+
+// DeleteEstablishmentStore godoc
+// @Summary      Delete a store of an establishment
+// @Tags         stores
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id        path      int     true  "Establishment ID"
+// @Param        storeId   path      int     true  "Store ID"
+// @Param        If-Match  header    string  true  "ETag of the store, or * to skip the check"
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      412  {object}  Problem
+// @Failure      428  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /establishments/{id}/stores/{storeId} [delete]
+func (h *StoreHandler) DeleteInEstablishment(c echo.Context) error {
+	establishmentID, storeID, err := parseNestedStoreIDs(c)
+	if err != nil {
+		return err
+	}
+	version, err := parseIfMatch(c, "store", storeID)
+	if err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	if _, err := h.Service.FindByIDInEstablishment(ctx, establishmentID, storeID); err != nil {
+		return err
+	}
+	if err := h.Service.Delete(ctx, storeID, version); err != nil {
+		return err
+	}
+	h.Logger.Info("Store deleted", zap.Int64("id", storeID), zap.Int64("establishment_id", establishmentID))
+	return c.JSON(http.StatusOK, map[string]string{"message": "Store deleted successfully"})
+}
+
+func parseNestedStoreIDs(c echo.Context) (int64, int64, error) {
+	establishmentID, err := parseID(c, "id", "establishment")
+	if err != nil {
+		return 0, 0, err
+	}
+	storeID, err := parseID(c, "storeId", "store")
+	if err != nil {
+		return 0, 0, err
+	}
+	return establishmentID, storeID, nil
+}
+
+// bindNestedStore binds a store sent to a nested route, taking the establishment from the path
+// and rejecting a body that names a different one.
+func bindNestedStore(c echo.Context, establishmentID int64) (*model.Store, error) {
+	var store model.Store
+	if err := c.Bind(&store); err != nil {
+		return nil, domainerr.Validation("Invalid request body")
+	}
+	if store.EstablishmentID != 0 && store.EstablishmentID != establishmentID {
+		return nil, domainerr.ValidationFields(map[string]string{
+			"establishment_id": "must match the establishment in the path",
+		})
+	}
+	store.EstablishmentID = establishmentID
+	if err := util.Validate.Struct(&store); err != nil {
+		return nil, domainerr.ValidationFields(util.ParseValidationError(err))
+	}
+	return &store, nil
+}
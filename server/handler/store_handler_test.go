@@ -7,21 +7,30 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
 	"github.com/yMaatheus/tech-challenge-snet/model"
 	"github.com/yMaatheus/tech-challenge-snet/service"
+	"github.com/yMaatheus/tech-challenge-snet/util"
 	"go.uber.org/zap"
 )
 
 type mockStoreService struct {
-	CreateFn   func(ctx context.Context, store *model.Store) error
-	FindAllFn  func(ctx context.Context) ([]model.Store, error)
-	FindByIDFn func(ctx context.Context, id int64) (*model.Store, error)
-	UpdateFn   func(ctx context.Context, store *model.Store) error
-	DeleteFn   func(ctx context.Context, id int64) error
+	CreateFn     func(ctx context.Context, store *model.Store) error
+	FindAllFn    func(ctx context.Context, params model.ListParams) (*model.StorePage, error)
+	FindNearbyFn func(ctx context.Context, params model.NearbyParams) (*model.NearbyStores, error)
+	FindByIDFn   func(ctx context.Context, id int64) (*model.Store, error)
+	UpdateFn     func(ctx context.Context, store *model.Store) error
+	PatchFn      func(ctx context.Context, id, version int64, patch model.Patch) (*model.Store, error)
+	DeleteFn     func(ctx context.Context, id, version int64) error
+	RestoreFn    func(ctx context.Context, id int64) error
+
+	FindAllByEstablishmentFn  func(ctx context.Context, establishmentID int64, params model.ListParams) (*model.StorePage, error)
+	FindByIDInEstablishmentFn func(ctx context.Context, establishmentID, id int64) (*model.Store, error)
 }
 
 func (m *mockStoreService) Create(ctx context.Context, store *model.Store) error {
@@ -31,22 +40,51 @@ func (m *mockStoreService) Create(ctx context.Context, store *model.Store) error
 	store.ID = 1
 	return nil
 }
-func (m *mockStoreService) FindAll(ctx context.Context) ([]model.Store, error) {
+func (m *mockStoreService) FindAll(ctx context.Context, params model.ListParams) (*model.StorePage, error) {
 	if m.FindAllFn != nil {
-		return m.FindAllFn(ctx)
+		return m.FindAllFn(ctx, params)
 	}
-	return []model.Store{
-		{ID: 1, Name: "Loja A", Number: "S001", EstablishmentID: 1},
+	return &model.StorePage{
+		Items: []model.Store{
+			{ID: 1, Name: "Loja A", Number: "S001", EstablishmentID: 1},
+		},
+		Total: 1,
 	}, nil
 }
+func (m *mockStoreService) FindNearby(ctx context.Context, params model.NearbyParams) (*model.NearbyStores, error) {
+	if m.FindNearbyFn != nil {
+		return m.FindNearbyFn(ctx, params)
+	}
+	return &model.NearbyStores{Items: []model.StoreDistance{}}, nil
+}
 func (m *mockStoreService) FindByID(ctx context.Context, id int64) (*model.Store, error) {
 	if m.FindByIDFn != nil {
 		return m.FindByIDFn(ctx, id)
 	}
 	if id == 1 {
-		return &model.Store{ID: 1, Name: "Loja A", Number: "S001", EstablishmentID: 1}, nil
+		return &model.Store{ID: 1, Name: "Loja A", Number: "S001", EstablishmentID: 1, Version: 3}, nil
+	}
+	return nil, domainerr.NotFound("store", id)
+}
+func (m *mockStoreService) FindAllByEstablishment(ctx context.Context, establishmentID int64, params model.ListParams) (*model.StorePage, error) {
+	if m.FindAllByEstablishmentFn != nil {
+		return m.FindAllByEstablishmentFn(ctx, establishmentID, params)
 	}
-	return nil, nil
+	params.EstablishmentID = establishmentID
+	return m.FindAll(ctx, params)
+}
+func (m *mockStoreService) FindByIDInEstablishment(ctx context.Context, establishmentID, id int64) (*model.Store, error) {
+	if m.FindByIDInEstablishmentFn != nil {
+		return m.FindByIDInEstablishmentFn(ctx, establishmentID, id)
+	}
+	store, err := m.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if store.EstablishmentID != establishmentID {
+		return nil, domainerr.NotFound("store", id)
+	}
+	return store, nil
 }
 func (m *mockStoreService) Update(ctx context.Context, store *model.Store) error {
 	if m.UpdateFn != nil {
@@ -54,9 +92,22 @@ func (m *mockStoreService) Update(ctx context.Context, store *model.Store) error
 	}
 	return nil
 }
-func (m *mockStoreService) Delete(ctx context.Context, id int64) error {
+func (m *mockStoreService) Patch(ctx context.Context, id, version int64, patch model.Patch) (*model.Store, error) {
+	if m.PatchFn != nil {
+		return m.PatchFn(ctx, id, version, patch)
+	}
+	return &model.Store{ID: id, Name: "Loja A", Version: version + 1}, nil
+}
+func (m *mockStoreService) Delete(ctx context.Context, id, version int64) error {
 	if m.DeleteFn != nil {
-		return m.DeleteFn(ctx, id)
+		return m.DeleteFn(ctx, id, version)
+	}
+	return nil
+}
+
+func (m *mockStoreService) Restore(ctx context.Context, id int64) error {
+	if m.RestoreFn != nil {
+		return m.RestoreFn(ctx, id)
 	}
 	return nil
 }
@@ -64,6 +115,8 @@ func (m *mockStoreService) Delete(ctx context.Context, id int64) error {
 func setupStoreEcho(service service.StoreService) *echo.Echo {
 	e := echo.New()
 	logger := zap.NewNop()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(logger)
+	e.Use(AuthenticateAs(testAdmin))
 	NewStoreHandler(e, service, logger)
 	return e
 }
@@ -71,8 +124,8 @@ func setupStoreEcho(service service.StoreService) *echo.Echo {
 func TestCreateStore_Success(t *testing.T) {
 	e := setupStoreEcho(&mockStoreService{})
 	store := model.Store{
-		Number: "S001", Name: "StoreTest", CorporateName: "Corp", Address: "Rua", City: "Cidade",
-		State: "ST", ZipCode: "12345678", AddressNumber: "10", EstablishmentID: 1,
+		Number: "44.555.666/0001-81", Name: "StoreTest", CorporateName: "Corp", Address: "Rua", City: "Cidade",
+		State: "SP", ZipCode: "01310100", AddressNumber: "10", EstablishmentID: 1,
 	}
 	body, _ := json.Marshal(store)
 	req := httptest.NewRequest(http.MethodPost, "/stores", bytes.NewReader(body))
@@ -91,7 +144,36 @@ func TestCreateStore_ValidationError(t *testing.T) {
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
-	assert.Contains(t, rec.Body.String(), "validation_error")
+	assert.Contains(t, rec.Body.String(), `"errors":{`)
+}
+
+func TestCreateStore_InvalidCNPJ(t *testing.T) {
+	e := setupStoreEcho(&mockStoreService{})
+	store := model.Store{
+		Number: "S001", Name: "StoreTest", CorporateName: "Corp", Address: "Rua", City: "Cidade",
+		State: "SP", ZipCode: "01310100", AddressNumber: "10", EstablishmentID: 1,
+	}
+	body, _ := json.Marshal(store)
+	req := httptest.NewRequest(http.MethodPost, "/stores", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"number":"must be a valid CNPJ`)
+}
+
+func TestCreateStore_AlphanumericCNPJ(t *testing.T) {
+	e := setupStoreEcho(&mockStoreService{})
+	store := model.Store{
+		Number: "12.ABC.345/01DE-35", Name: "StoreTest", CorporateName: "Corp", Address: "Rua", City: "Cidade",
+		State: "SP", ZipCode: "01310100", AddressNumber: "10", EstablishmentID: 1,
+	}
+	body, _ := json.Marshal(store)
+	req := httptest.NewRequest(http.MethodPost, "/stores", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
 }
 
 func TestCreateStore_BadBody(t *testing.T) {
@@ -103,6 +185,47 @@ func TestCreateStore_BadBody(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 }
 
+func TestCreateStore_Conflict(t *testing.T) {
+	mockSvc := &mockStoreService{
+		CreateFn: func(ctx context.Context, store *model.Store) error {
+			return &domainerr.ConflictError{Field: "number", Message: "a store with this number already exists in this establishment"}
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	store := model.Store{
+		Number: "44.555.666/0001-81", Name: "StoreTest", CorporateName: "Corp", Address: "Rua", City: "Cidade",
+		State: "SP", ZipCode: "01310100", AddressNumber: "10", EstablishmentID: 1,
+	}
+	body, _ := json.Marshal(store)
+	req := httptest.NewRequest(http.MethodPost, "/stores", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"number"`)
+	assert.Contains(t, rec.Body.String(), "already exists in this establishment")
+}
+
+func TestCreateStore_EstablishmentNotFound(t *testing.T) {
+	mockSvc := &mockStoreService{
+		CreateFn: func(ctx context.Context, store *model.Store) error {
+			return &domainerr.ForeignKeyError{Field: "establishment_id", Message: "establishment does not exist"}
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	store := model.Store{
+		Number: "44.555.666/0001-81", Name: "StoreTest", CorporateName: "Corp", Address: "Rua", City: "Cidade",
+		State: "SP", ZipCode: "01310100", AddressNumber: "10", EstablishmentID: 42,
+	}
+	body, _ := json.Marshal(store)
+	req := httptest.NewRequest(http.MethodPost, "/stores", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"errors":{"establishment_id":"establishment does not exist"}`)
+}
+
 func TestListStores_Success(t *testing.T) {
 	e := setupStoreEcho(&mockStoreService{})
 	req := httptest.NewRequest(http.MethodGet, "/stores", nil)
@@ -114,14 +237,61 @@ func TestListStores_Success(t *testing.T) {
 
 func TestListStores_Error(t *testing.T) {
 	mockSvc := &mockStoreService{
-		FindAllFn: func(ctx context.Context) ([]model.Store, error) { return nil, errors.New("db error") },
+		FindAllFn: func(ctx context.Context, params model.ListParams) (*model.StorePage, error) {
+			return nil, errors.New("db error")
+		},
 	}
 	e := setupStoreEcho(mockSvc)
 	req := httptest.NewRequest(http.MethodGet, "/stores", nil)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
-	assert.Contains(t, rec.Body.String(), "Could not fetch stores")
+	assert.Contains(t, rec.Body.String(), "An unexpected error occurred.")
+	assert.NotContains(t, rec.Body.String(), "Could not fetch stores")
+}
+
+func TestListStores_Filters(t *testing.T) {
+	var got model.ListParams
+	mockSvc := &mockStoreService{
+		FindAllFn: func(ctx context.Context, params model.ListParams) (*model.StorePage, error) {
+			got = params
+			return &model.StorePage{Items: []model.Store{}}, nil
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	req := httptest.NewRequest(http.MethodGet, "/stores?establishment_id=2&sort=city&limit=50", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int64(2), got.EstablishmentID)
+	assert.Equal(t, "city", got.Sort)
+	assert.False(t, got.Desc)
+	assert.Equal(t, 50, got.Limit)
+}
+
+func TestListStores_InvalidEstablishmentID(t *testing.T) {
+	e := setupStoreEcho(&mockStoreService{})
+	req := httptest.NewRequest(http.MethodGet, "/stores?establishment_id=abc", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Invalid establishment_id")
+}
+
+func TestListStores_CursorDeOutraOrdem(t *testing.T) {
+	e := setupStoreEcho(&mockStoreService{})
+	cursor := util.EncodeCursor(util.Cursor{Sort: "city", Value: "Recife", ID: 3})
+
+	req := httptest.NewRequest(http.MethodGet, "/stores?sort=city&cursor="+cursor, nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/stores?sort=-city&cursor="+cursor, nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "another sort")
 }
 
 func TestGetStore_Success(t *testing.T) {
@@ -131,6 +301,7 @@ func TestGetStore_Success(t *testing.T) {
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Contains(t, rec.Body.String(), "Loja A")
+	assert.Equal(t, `"3"`, rec.Header().Get(HeaderETag))
 }
 
 func TestGetStore_NotFound(t *testing.T) {
@@ -139,6 +310,7 @@ func TestGetStore_NotFound(t *testing.T) {
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "store not found")
 }
 
 func TestGetStore_InvalidID(t *testing.T) {
@@ -153,11 +325,12 @@ func TestGetStore_InvalidID(t *testing.T) {
 func TestUpdateStore_Success(t *testing.T) {
 	e := setupStoreEcho(&mockStoreService{})
 	store := model.Store{
-		Number: "S002", Name: "Loja Atualizada", CorporateName: "Corp", Address: "Rua", City: "Cidade",
-		State: "ST", ZipCode: "12345678", AddressNumber: "20", EstablishmentID: 1,
+		Number: "44555666000262", Name: "Loja Atualizada", CorporateName: "Corp", Address: "Rua", City: "Cidade",
+		State: "SP", ZipCode: "01310100", AddressNumber: "20", EstablishmentID: 1,
 	}
 	body, _ := json.Marshal(store)
 	req := httptest.NewRequest(http.MethodPut, "/stores/1", bytes.NewReader(body))
+	req.Header.Set(HeaderIfMatch, `"1"`)
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
@@ -165,11 +338,55 @@ func TestUpdateStore_Success(t *testing.T) {
 	assert.Contains(t, rec.Body.String(), "Store updated successfully")
 }
 
+func TestUpdateStore_Conflict(t *testing.T) {
+	mockSvc := &mockStoreService{
+		UpdateFn: func(ctx context.Context, store *model.Store) error {
+			return &domainerr.ConflictError{Field: "number"}
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	store := model.Store{
+		Number: "44555666000262", Name: "Loja Atualizada", CorporateName: "Corp", Address: "Rua", City: "Cidade",
+		State: "SP", ZipCode: "01310100", AddressNumber: "20", EstablishmentID: 1,
+	}
+	body, _ := json.Marshal(store)
+	req := httptest.NewRequest(http.MethodPut, "/stores/1", bytes.NewReader(body))
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"number"`)
+}
+
+func TestUpdateStore_NotFound(t *testing.T) {
+	mockSvc := &mockStoreService{
+		UpdateFn: func(ctx context.Context, store *model.Store) error {
+			return domainerr.NotFound("store", store.ID)
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	store := model.Store{
+		Number: "44555666000262", Name: "Loja Atualizada", CorporateName: "Corp", Address: "Rua", City: "Cidade",
+		State: "SP", ZipCode: "01310100", AddressNumber: "20", EstablishmentID: 1,
+	}
+	body, _ := json.Marshal(store)
+	req := httptest.NewRequest(http.MethodPut, "/stores/999999", bytes.NewReader(body))
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "store not found")
+	assert.NotContains(t, rec.Body.String(), "updated successfully")
+}
+
 func TestUpdateStore_InvalidID(t *testing.T) {
 	e := setupStoreEcho(&mockStoreService{})
 	store := model.Store{}
 	body, _ := json.Marshal(store)
 	req := httptest.NewRequest(http.MethodPut, "/stores/xyz", bytes.NewReader(body))
+	req.Header.Set(HeaderIfMatch, `"1"`)
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
@@ -181,16 +398,18 @@ func TestUpdateStore_ValidationError(t *testing.T) {
 	e := setupStoreEcho(&mockStoreService{})
 	body := []byte(`{"name": ""}`)
 	req := httptest.NewRequest(http.MethodPut, "/stores/1", bytes.NewReader(body))
+	req.Header.Set(HeaderIfMatch, `"1"`)
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
-	assert.Contains(t, rec.Body.String(), "validation_error")
+	assert.Contains(t, rec.Body.String(), `"errors":{`)
 }
 
 func TestDeleteStore_Success(t *testing.T) {
 	e := setupStoreEcho(&mockStoreService{})
 	req := httptest.NewRequest(http.MethodDelete, "/stores/1", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusOK, rec.Code)
@@ -200,6 +419,7 @@ func TestDeleteStore_Success(t *testing.T) {
 func TestDeleteStore_InvalidID(t *testing.T) {
 	e := setupStoreEcho(&mockStoreService{})
 	req := httptest.NewRequest(http.MethodDelete, "/stores/abc", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
@@ -208,12 +428,444 @@ func TestDeleteStore_InvalidID(t *testing.T) {
 
 func TestDeleteStore_Error(t *testing.T) {
 	mockSvc := &mockStoreService{
-		DeleteFn: func(ctx context.Context, id int64) error { return errors.New("db error") },
+		DeleteFn: func(ctx context.Context, id, version int64) error { return errors.New("db error") },
 	}
 	e := setupStoreEcho(mockSvc)
 	req := httptest.NewRequest(http.MethodDelete, "/stores/1", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
-	assert.Contains(t, rec.Body.String(), "Could not delete store")
+	assert.Contains(t, rec.Body.String(), "An unexpected error occurred.")
+	assert.NotContains(t, rec.Body.String(), "Could not delete store")
+}
+
+func TestDeleteStore_NotFound(t *testing.T) {
+	mockSvc := &mockStoreService{
+		DeleteFn: func(ctx context.Context, id, version int64) error { return domainerr.NotFound("store", id) },
+	}
+	e := setupStoreEcho(mockSvc)
+	req := httptest.NewRequest(http.MethodDelete, "/stores/999999", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "store not found")
+}
+
+func TestListEstablishmentStores_Success(t *testing.T) {
+	var gotID int64
+	var gotParams model.ListParams
+	mockSvc := &mockStoreService{
+		FindAllByEstablishmentFn: func(ctx context.Context, establishmentID int64, params model.ListParams) (*model.StorePage, error) {
+			gotID, gotParams = establishmentID, params
+			return &model.StorePage{Items: []model.Store{{ID: 1, Name: "Loja A", EstablishmentID: establishmentID}}, Total: 1}, nil
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	req := httptest.NewRequest(http.MethodGet, "/establishments/7/stores?limit=10&sort=-name", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Loja A")
+	assert.Equal(t, int64(7), gotID)
+	assert.Equal(t, 10, gotParams.Limit)
+	assert.True(t, gotParams.Desc)
+}
+
+func TestListEstablishmentStores_EstablishmentNotFound(t *testing.T) {
+	mockSvc := &mockStoreService{
+		FindAllByEstablishmentFn: func(ctx context.Context, establishmentID int64, params model.ListParams) (*model.StorePage, error) {
+			return nil, domainerr.NotFound("establishment", establishmentID)
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	req := httptest.NewRequest(http.MethodGet, "/establishments/7/stores", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "establishment not found")
+}
+
+func TestCreateEstablishmentStore_UsesPathEstablishment(t *testing.T) {
+	var created model.Store
+	mockSvc := &mockStoreService{
+		CreateFn: func(ctx context.Context, store *model.Store) error {
+			created = *store
+			store.ID = 5
+			return nil
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	body := []byte(`{"number":"44.555.666/0001-81","name":"Loja","address":"Rua","city":"Cidade","state":"SP","zip_code":"12345678","address_number":"1"}`)
+	req := httptest.NewRequest(http.MethodPost, "/establishments/3/stores", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, int64(3), created.EstablishmentID)
+	assert.Contains(t, rec.Body.String(), `"establishment_id":3`)
+}
+
+func TestCreateEstablishmentStore_EstablishmentMismatch(t *testing.T) {
+	e := setupStoreEcho(&mockStoreService{})
+	body := []byte(`{"number":"44.555.666/0001-81","name":"Loja","address":"Rua","city":"Cidade","state":"SP","zip_code":"12345678","address_number":"1","establishment_id":4}`)
+	req := httptest.NewRequest(http.MethodPost, "/establishments/3/stores", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"establishment_id":"must match the establishment in the path"`)
+}
+
+func TestCreateEstablishmentStore_EstablishmentNotFound(t *testing.T) {
+	mockSvc := &mockStoreService{
+		CreateFn: func(ctx context.Context, store *model.Store) error {
+			return &domainerr.ForeignKeyError{Field: "establishment_id"}
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	body := []byte(`{"number":"44.555.666/0001-81","name":"Loja","address":"Rua","city":"Cidade","state":"SP","zip_code":"12345678","address_number":"1"}`)
+	req := httptest.NewRequest(http.MethodPost, "/establishments/3/stores", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "establishment not found")
+}
+
+func TestGetEstablishmentStore(t *testing.T) {
+	e := setupStoreEcho(&mockStoreService{})
+
+	req := httptest.NewRequest(http.MethodGet, "/establishments/1/stores/1", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Loja A")
+
+	// Store 1 belongs to establishment 1, so it is not reachable under establishment 2
+	req = httptest.NewRequest(http.MethodGet, "/establishments/2/stores/1", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestGetEstablishmentStore_InvalidStoreID(t *testing.T) {
+	e := setupStoreEcho(&mockStoreService{})
+	req := httptest.NewRequest(http.MethodGet, "/establishments/1/stores/abc", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Invalid store ID. Must be a positive integer.")
+}
+
+func TestUpdateEstablishmentStore(t *testing.T) {
+	var updated model.Store
+	mockSvc := &mockStoreService{
+		UpdateFn: func(ctx context.Context, store *model.Store) error {
+			updated = *store
+			return nil
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	body := []byte(`{"number":"44.555.666/0001-81","name":"Loja","address":"Rua","city":"Cidade","state":"SP","zip_code":"12345678","address_number":"1"}`)
+	req := httptest.NewRequest(http.MethodPut, "/establishments/1/stores/1", bytes.NewReader(body))
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int64(1), updated.ID)
+	assert.Equal(t, int64(1), updated.EstablishmentID)
+}
+
+func TestUpdateEstablishmentStore_WrongEstablishment(t *testing.T) {
+	updateCalled := false
+	mockSvc := &mockStoreService{
+		UpdateFn: func(ctx context.Context, store *model.Store) error {
+			updateCalled = true
+			return nil
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	body := []byte(`{"number":"44.555.666/0001-81","name":"Loja","address":"Rua","city":"Cidade","state":"SP","zip_code":"12345678","address_number":"1"}`)
+	req := httptest.NewRequest(http.MethodPut, "/establishments/2/stores/1", bytes.NewReader(body))
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.False(t, updateCalled)
+}
+
+func TestDeleteEstablishmentStore(t *testing.T) {
+	var deletedID int64
+	mockSvc := &mockStoreService{
+		DeleteFn: func(ctx context.Context, id, version int64) error {
+			deletedID = id
+			return nil
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+
+	req := httptest.NewRequest(http.MethodDelete, "/establishments/2/stores/1", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Zero(t, deletedID)
+
+	req = httptest.NewRequest(http.MethodDelete, "/establishments/1/stores/1", nil)
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int64(1), deletedID)
+}
+
+func TestRestoreStore(t *testing.T) {
+	e := setupStoreEcho(&mockStoreService{})
+	req := httptest.NewRequest(http.MethodPost, "/stores/1/restore", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Store restored successfully")
+}
+
+func TestRestoreStore_EstablishmentDeleted(t *testing.T) {
+	e := setupStoreEcho(&mockStoreService{
+		RestoreFn: func(ctx context.Context, id int64) error {
+			return &domainerr.ConflictError{Field: "establishment_id", Message: "cannot restore store: its establishment is deleted"}
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/stores/1/restore", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "its establishment is deleted")
+}
+
+func TestUpdateStore_IfMatch(t *testing.T) {
+	var expected int64
+	mockSvc := &mockStoreService{
+		UpdateFn: func(ctx context.Context, store *model.Store) error {
+			expected = store.Version
+			store.Version++
+			return nil
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	body, _ := json.Marshal(model.Store{
+		Number: "44555666000262", Name: "Loja", Address: "Rua", City: "Cidade",
+		State: "SP", ZipCode: "01310100", AddressNumber: "20", EstablishmentID: 1,
+	})
+	req := httptest.NewRequest(http.MethodPut, "/stores/1", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(HeaderIfMatch, `"3"`)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int64(3), expected)
+	assert.Equal(t, `"4"`, rec.Header().Get(HeaderETag))
+}
+
+func TestUpdateStore_SemIfMatch(t *testing.T) {
+	called := false
+	mockSvc := &mockStoreService{
+		UpdateFn: func(ctx context.Context, store *model.Store) error {
+			called = true
+			return nil
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	req := httptest.NewRequest(http.MethodPut, "/stores/1", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+	assert.Contains(t, rec.Body.String(), HeaderIfMatch)
+	assert.False(t, called)
+}
+
+func TestDeleteStore_VersaoDesatualizada(t *testing.T) {
+	mockSvc := &mockStoreService{
+		DeleteFn: func(ctx context.Context, id, version int64) error {
+			return domainerr.PreconditionFailed("store", id)
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	req := httptest.NewRequest(http.MethodDelete, "/stores/1", nil)
+	req.Header.Set(HeaderIfMatch, `"2"`)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+}
+
+func TestDeleteStore_IfMatchInvalido(t *testing.T) {
+	for _, ifMatch := range []string{`W/"1"`, `1`, `"abc"`, `"0"`, `"1", "2"`} {
+		t.Run(ifMatch, func(t *testing.T) {
+			called := false
+			mockSvc := &mockStoreService{
+				DeleteFn: func(ctx context.Context, id, version int64) error {
+					called = true
+					return nil
+				},
+			}
+			e := setupStoreEcho(mockSvc)
+			req := httptest.NewRequest(http.MethodDelete, "/stores/1", nil)
+			req.Header.Set(HeaderIfMatch, ifMatch)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+			assert.False(t, called)
+		})
+	}
+}
+
+func TestDeleteStore_IfMatchQualquerVersao(t *testing.T) {
+	version := int64(-1)
+	mockSvc := &mockStoreService{
+		DeleteFn: func(ctx context.Context, id, v int64) error {
+			version = v
+			return nil
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	req := httptest.NewRequest(http.MethodDelete, "/stores/1", nil)
+	req.Header.Set(HeaderIfMatch, "*")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, model.AnyVersion, version)
+}
+
+func TestPatchStore(t *testing.T) {
+	cases := map[string]string{
+		MIMEApplicationMergePatchJSON: model.PatchFormatMerge,
+		echo.MIMEApplicationJSON:      model.PatchFormatMerge,
+		MIMEApplicationJSONPatchJSON:  model.PatchFormatJSON,
+	}
+	for contentType, format := range cases {
+		t.Run(contentType, func(t *testing.T) {
+			var got model.Patch
+			mockSvc := &mockStoreService{
+				PatchFn: func(ctx context.Context, id, version int64, patch model.Patch) (*model.Store, error) {
+					got = patch
+					return &model.Store{ID: id, Name: "Loja B", Version: version + 1}, nil
+				},
+			}
+			e := setupStoreEcho(mockSvc)
+			req := httptest.NewRequest(http.MethodPatch, "/stores/1", bytes.NewReader([]byte(`{"name":"Loja B"}`)))
+			req.Header.Set(echo.HeaderContentType, contentType)
+			req.Header.Set(HeaderIfMatch, `"1"`)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, format, got.Format)
+			assert.JSONEq(t, `{"name":"Loja B"}`, string(got.Document))
+			assert.Equal(t, `"2"`, rec.Header().Get(HeaderETag))
+			assert.Contains(t, rec.Body.String(), `"name":"Loja B"`)
+		})
+	}
+}
+
+func TestPatchStore_MediaTypeNaoSuportado(t *testing.T) {
+	e := setupStoreEcho(&mockStoreService{})
+	req := httptest.NewRequest(http.MethodPatch, "/stores/1", bytes.NewReader([]byte(`name=Loja`)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	assert.Contains(t, rec.Header().Get(HeaderAcceptPatch), MIMEApplicationMergePatchJSON)
+}
+
+func TestPatchStore_SemIfMatch(t *testing.T) {
+	e := setupStoreEcho(&mockStoreService{})
+	req := httptest.NewRequest(http.MethodPatch, "/stores/1", bytes.NewReader([]byte(`{"name":"Loja B"}`)))
+	req.Header.Set(echo.HeaderContentType, MIMEApplicationMergePatchJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+}
+
+func TestPatchStore_CorpoVazio(t *testing.T) {
+	e := setupStoreEcho(&mockStoreService{})
+	req := httptest.NewRequest(http.MethodPatch, "/stores/1", nil)
+	req.Header.Set(echo.HeaderContentType, MIMEApplicationMergePatchJSON)
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestPatchStore_MuitoGrande(t *testing.T) {
+	e := setupStoreEcho(&mockStoreService{})
+	body := `{"name":"` + strings.Repeat("a", maxPatchSize) + `"}`
+	req := httptest.NewRequest(http.MethodPatch, "/stores/1", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, MIMEApplicationMergePatchJSON)
+	req.Header.Set(HeaderIfMatch, `"1"`)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+func TestNearbyStores(t *testing.T) {
+	var got model.NearbyParams
+	lat, lng := -23.5613, -46.6565
+	mockSvc := &mockStoreService{
+		FindNearbyFn: func(ctx context.Context, params model.NearbyParams) (*model.NearbyStores, error) {
+			got = params
+			return &model.NearbyStores{Items: []model.StoreDistance{
+				{Store: model.Store{ID: 3, Name: "Loja Paulista", Latitude: &lat, Longitude: &lng}, DistanceKm: 0.42},
+			}}, nil
+		},
+	}
+	e := setupStoreEcho(mockSvc)
+	req := httptest.NewRequest(http.MethodGet, "/stores/nearby?lat=-23.56&lng=-46.65&radius_km=2.5&establishment_id=1&limit=5", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, model.NearbyParams{Latitude: -23.56, Longitude: -46.65, RadiusKm: 2.5, EstablishmentID: 1, Limit: 5}, got)
+	assert.Contains(t, rec.Body.String(), `"latitude":-23.5613,"longitude":-46.6565`)
+	assert.Contains(t, rec.Body.String(), `"distance_km":0.42`)
+
+	req = httptest.NewRequest(http.MethodGet, "/stores/nearby?lat=0&lng=0", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, model.NearbyParams{RadiusKm: model.DefaultNearbyRadiusKm, Limit: model.DefaultListLimit}, got)
+}
+
+func TestNearbyStores_ParametrosInvalidos(t *testing.T) {
+	for _, query := range []string{
+		"lng=-46.65",
+		"lat=-23.56",
+		"lat=91&lng=0",
+		"lat=NaN&lng=0",
+		"lat=0&lng=-180.5",
+		"lat=0&lng=0&radius_km=0",
+		"lat=0&lng=0&radius_km=501",
+		"lat=0&lng=0&establishment_id=x",
+		"lat=0&lng=0&limit=101",
+	} {
+		e := setupStoreEcho(&mockStoreService{})
+		req := httptest.NewRequest(http.MethodGet, "/stores/nearby?"+query, nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestCreateStore_CoordenadaSemPar(t *testing.T) {
+	e := setupStoreEcho(&mockStoreService{})
+	body := `{"number":"11222333000181","name":"Loja","address":"Rua","city":"São Paulo","state":"SP","zip_code":"01310100","address_number":"1","establishment_id":1,"latitude":-23.5}`
+	req := httptest.NewRequest(http.MethodPost, "/stores", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"longitude":"is required when latitude is set"`)
 }
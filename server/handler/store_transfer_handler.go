@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/service"
+	"go.uber.org/zap"
+)
+
+// StoreTransferHandler handles the transfer of stores between establishments
+type StoreTransferHandler struct {
+	Service service.StoreTransferService
+	Logger  *zap.Logger
+}
+
+// NewStoreTransferHandler sets up the routes for store transfers
+func NewStoreTransferHandler(e *echo.Echo, svc service.StoreTransferService, logger *zap.Logger) {
+	h := &StoreTransferHandler{Service: svc, Logger: logger}
+	e.POST("/stores/:id/transfer", h.Transfer, requireEditor, requireWriteStores)
+	e.GET("/stores/:id/transfers", h.List, requireViewer, requireReadStores)
+}
+
+// TransferStore godoc
+// @Summary      Transfer a store to another establishment
+// @Description  Moves the store and records who transferred it, when and why. The actor is the subject of the token.
+// @Tags         stores
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Accept       json
+// @Produce      json
+// @Param        id        path     int                         true   "Store ID"
+// @Param        transfer  body     model.StoreTransferRequest  true   "Target establishment and reason"
+// @Success      201  {object} model.StoreTransfer
+// @Failure      400  {object} Problem
+// @Failure      401  {object} Problem
+// @Failure      403  {object} Problem
+// @Failure      404  {object} Problem
+// @Failure      409  {object} Problem
+// @Failure      422  {object} Problem
+// @Failure      500  {object} Problem
+// @Router       /stores/{id}/transfer [post]
+func (h *StoreTransferHandler) Transfer(c echo.Context) error {
+	id, err := parseID(c, "id", "store")
+	if err != nil {
+		return err
+	}
+	var req model.StoreTransferRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	transfer, err := h.Service.Transfer(c.Request().Context(), id, req)
+	if err != nil {
+		return err
+	}
+	h.Logger.Info("Store transferred",
+		zap.Int64("id", id),
+		zap.Int64("from", transfer.FromEstablishmentID),
+		zap.Int64("to", transfer.ToEstablishmentID),
+		zap.String("by", transfer.TransferredBy),
+	)
+	return c.JSON(http.StatusCreated, transfer)
+}
+
+// ListStoreTransfers godoc
+// @Summary      List the transfers of a store
+// @Description  Get the transfer history of a store, newest first
+// @Tags         stores
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Produce      json
+// @Param        id   path      int  true  "Store ID"
+// @Success      200  {array}   model.StoreTransfer
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /stores/{id}/transfers [get]
+func (h *StoreTransferHandler) List(c echo.Context) error {
+	id, err := parseID(c, "id", "store")
+	if err != nil {
+		return err
+	}
+	transfers, err := h.Service.FindByStoreID(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, transfers)
+}
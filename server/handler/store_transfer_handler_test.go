@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/domain/actor"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/service"
+	"go.uber.org/zap"
+)
+
+type mockStoreTransferService struct {
+	TransferFn      func(ctx context.Context, storeID int64, req model.StoreTransferRequest) (*model.StoreTransfer, error)
+	FindByStoreIDFn func(ctx context.Context, storeID int64) ([]model.StoreTransfer, error)
+}
+
+func (m *mockStoreTransferService) Transfer(ctx context.Context, storeID int64, req model.StoreTransferRequest) (*model.StoreTransfer, error) {
+	if m.TransferFn != nil {
+		return m.TransferFn(ctx, storeID, req)
+	}
+	return &model.StoreTransfer{
+		ID: 1, StoreID: storeID, FromEstablishmentID: 1, ToEstablishmentID: req.ToEstablishmentID,
+		Reason: req.Reason, TransferredBy: actor.FromContext(ctx),
+	}, nil
+}
+func (m *mockStoreTransferService) FindByStoreID(ctx context.Context, storeID int64) ([]model.StoreTransfer, error) {
+	if m.FindByStoreIDFn != nil {
+		return m.FindByStoreIDFn(ctx, storeID)
+	}
+	return []model.StoreTransfer{{ID: 1, StoreID: storeID, FromEstablishmentID: 1, ToEstablishmentID: 2}}, nil
+}
+
+func setupStoreTransferEcho(service service.StoreTransferService) *echo.Echo {
+	e := echo.New()
+	logger := zap.NewNop()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(logger)
+	e.Use(AuthenticateAs(testAdmin))
+	NewStoreTransferHandler(e, service, logger)
+	return e
+}
+
+func TestTransferStore_Success(t *testing.T) {
+	e := setupStoreTransferEcho(&mockStoreTransferService{})
+	req := httptest.NewRequest(http.MethodPost, "/stores/5/transfer", strings.NewReader(`{"to_establishment_id": 2, "reason": "reorganização"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"store_id":5`)
+	assert.Contains(t, rec.Body.String(), `"to_establishment_id":2`)
+	assert.Contains(t, rec.Body.String(), `"transferred_by":"maria"`)
+}
+
+func TestTransferStore_ValidationError(t *testing.T) {
+	e := setupStoreTransferEcho(&mockStoreTransferService{})
+	req := httptest.NewRequest(http.MethodPost, "/stores/5/transfer", strings.NewReader(`{"reason": "sem destino"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "to_establishment_id")
+}
+
+func TestTransferStore_InvalidID(t *testing.T) {
+	e := setupStoreTransferEcho(&mockStoreTransferService{})
+	req := httptest.NewRequest(http.MethodPost, "/stores/abc/transfer", strings.NewReader(`{"to_establishment_id": 2}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestTransferStore_ServiceErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"store inexistente", domainerr.NotFound("store", 5), http.StatusNotFound},
+		{"mesmo estabelecimento", &domainerr.ConflictError{Field: "to_establishment_id", Message: "store already belongs to this establishment"}, http.StatusConflict},
+		{"destino inexistente", &domainerr.ForeignKeyError{Field: "to_establishment_id", Message: "establishment does not exist"}, http.StatusUnprocessableEntity},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := setupStoreTransferEcho(&mockStoreTransferService{
+				TransferFn: func(ctx context.Context, storeID int64, req model.StoreTransferRequest) (*model.StoreTransfer, error) {
+					return nil, tc.err
+				},
+			})
+			req := httptest.NewRequest(http.MethodPost, "/stores/5/transfer", strings.NewReader(`{"to_establishment_id": 2}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tc.status, rec.Code)
+			assert.Contains(t, rec.Body.String(), tc.err.Error())
+		})
+	}
+}
+
+func TestListStoreTransfers_Success(t *testing.T) {
+	e := setupStoreTransferEcho(&mockStoreTransferService{})
+	req := httptest.NewRequest(http.MethodGet, "/stores/5/transfers", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"store_id":5`)
+}
+
+func TestListStoreTransfers_NotFound(t *testing.T) {
+	e := setupStoreTransferEcho(&mockStoreTransferService{
+		FindByStoreIDFn: func(ctx context.Context, storeID int64) ([]model.StoreTransfer, error) {
+			return nil, domainerr.NotFound("store", storeID)
+		},
+	})
+	req := httptest.NewRequest(http.MethodGet, "/stores/5/transfers", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+)
+
+// HeaderTenantID selects the tenant of a request sent with a token not bound to one
+const HeaderTenantID = "X-Tenant-ID"
+
+// TenantScoper scopes the data access of a request to a tenant, as repository.TenantScoper does.
+type TenantScoper interface {
+	WithinTenant(ctx context.Context, tenantID string, fn func(ctx context.Context) error) error
+}
+
+// ResolveTenant scopes each authenticated request to a tenant and serves it through scoper, so every
+// query it runs only sees the rows of that tenant. The tenant comes from the "tenant_id" claim of the
+// token; callers bound to one may only repeat it in the X-Tenant-ID header, while admins not bound to
+// any pick it with the header. fallback is used when neither is set, and "" makes the tenant required.
+// Requests without a principal pass through, so RequireRole answers them.
+func ResolveTenant(scoper TenantScoper, fallback string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, ok := auth.FromContext(c.Request().Context())
+			if !ok {
+				return next(c)
+			}
+			tenantID, err := tenantOf(principal, c.Request().Header.Get(HeaderTenantID), fallback)
+			if err != nil {
+				return err
+			}
+			return scoper.WithinTenant(c.Request().Context(), tenantID, func(ctx context.Context) error {
+				c.SetRequest(c.Request().WithContext(ctx))
+				return next(c)
+			})
+		}
+	}
+}
+
+// tenantOf picks the tenant of a request sent by principal with the given X-Tenant-ID header.
+func tenantOf(principal auth.Principal, header, fallback string) (string, error) {
+	switch {
+	case header != "" && !tenant.Valid(header):
+		return "", echo.NewHTTPError(http.StatusBadRequest, "The "+HeaderTenantID+" header is not a valid tenant ID.")
+	case principal.Tenant != "":
+		if header != "" && header != principal.Tenant {
+			return "", echo.NewHTTPError(http.StatusForbidden, "The token does not grant access to this tenant.")
+		}
+		return principal.Tenant, nil
+	case header != "":
+		if !principal.Role.Allows(auth.RoleAdmin) {
+			return "", echo.NewHTTPError(http.StatusForbidden, "Only admins may pick the tenant with the "+HeaderTenantID+" header.")
+		}
+		return header, nil
+	case fallback != "":
+		return fallback, nil
+	}
+	return "", echo.NewHTTPError(http.StatusBadRequest, "The tenant is required: use a token with a tenant_id claim or the "+HeaderTenantID+" header.")
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+	"go.uber.org/zap"
+)
+
+// fakeScoper scopes the context like repository.TenantScoper, recording the tenants it was asked for
+type fakeScoper struct {
+	tenants []string
+}
+
+func (s *fakeScoper) WithinTenant(ctx context.Context, tenantID string, fn func(ctx context.Context) error) error {
+	s.tenants = append(s.tenants, tenantID)
+	return fn(tenant.WithTenant(ctx, tenantID))
+}
+
+func setupTenantEcho(scoper TenantScoper, fallback string) *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = NewHTTPErrorHandler(zap.NewNop())
+	e.Use(Authenticate(&fakeVerifier{tokens: map[string]auth.Principal{
+		"acme":   {Subject: "ana", Role: auth.RoleEditor, Tenant: "acme"},
+		"editor": {Subject: "joao", Role: auth.RoleEditor},
+		"admin":  {Subject: "maria", Role: auth.RoleAdmin},
+	}}))
+	e.Use(ResolveTenant(scoper, fallback))
+	e.GET("/tenant", func(c echo.Context) error {
+		id, _ := tenant.FromContext(c.Request().Context())
+		return c.String(http.StatusOK, id)
+	}, requireViewer)
+	return e
+}
+
+func tenantRequest(e *echo.Echo, token, tenantID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/tenant", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	if tenantID != "" {
+		req.Header.Set(HeaderTenantID, tenantID)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestResolveTenant(t *testing.T) {
+	scoper := &fakeScoper{}
+	e := setupTenantEcho(scoper, "")
+
+	cases := []struct {
+		token, header string
+		status        int
+		tenant        string
+	}{
+		{"acme", "", http.StatusOK, "acme"},
+		{"acme", "acme", http.StatusOK, "acme"},
+		{"admin", "globex", http.StatusOK, "globex"},
+		{"acme", "globex", http.StatusForbidden, ""},
+		{"editor", "globex", http.StatusForbidden, ""},
+		{"editor", "", http.StatusBadRequest, ""},
+		{"admin", "Globex Ltda", http.StatusBadRequest, ""},
+	}
+	for _, c := range cases {
+		rec := tenantRequest(e, c.token, c.header)
+		assert.Equal(t, c.status, rec.Code, "%s %s", c.token, c.header)
+		if c.status == http.StatusOK {
+			assert.Equal(t, c.tenant, rec.Body.String(), "%s %s", c.token, c.header)
+		}
+	}
+	assert.Equal(t, []string{"acme", "acme", "globex"}, scoper.tenants, "rejected requests never reach the database")
+}
+
+func TestResolveTenant_Padrao(t *testing.T) {
+	e := setupTenantEcho(&fakeScoper{}, tenant.Default)
+
+	rec := tenantRequest(e, "editor", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, tenant.Default, rec.Body.String())
+	rec = tenantRequest(e, "acme", "")
+	assert.Equal(t, "acme", rec.Body.String(), "the claim wins over the fallback")
+}
+
+func TestResolveTenant_NaoAutenticado(t *testing.T) {
+	scoper := &fakeScoper{}
+	e := setupTenantEcho(scoper, tenant.Default)
+
+	req := httptest.NewRequest(http.MethodGet, "/tenant", nil)
+	req.Header.Set(HeaderTenantID, "acme")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Empty(t, scoper.tenants)
+}
@@ -0,0 +1,31 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/yMaatheus/tech-challenge-snet/service"
+	"go.uber.org/zap"
+)
+
+// RunIdempotencySweep deletes the expired idempotency keys every interval, until ctx is cancelled.
+// Failures are logged and retried on the next tick.
+func RunIdempotencySweep(ctx context.Context, svc service.IdempotencyService, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		deleted, err := svc.DeleteExpired(ctx)
+		if err != nil {
+			logger.Error("Failed to delete expired idempotency keys", zap.Error(err))
+			continue
+		}
+		if deleted > 0 {
+			logger.Info("Deleted expired idempotency keys", zap.Int64("keys", deleted))
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"go.uber.org/zap"
+)
+
+type mockIdempotencyService struct {
+	cancel context.CancelFunc
+}
+
+func (m *mockIdempotencyService) Begin(ctx context.Context, client, key, fingerprint string) (*model.IdempotencyRecord, error) {
+	return nil, nil
+}
+func (m *mockIdempotencyService) Heartbeat(ctx context.Context, client, key string) error {
+	return nil
+}
+func (m *mockIdempotencyService) Complete(ctx context.Context, client, key string, response model.IdempotentResponse) error {
+	return nil
+}
+func (m *mockIdempotencyService) Release(ctx context.Context, client, key string) error {
+	return nil
+}
+func (m *mockIdempotencyService) DeleteExpired(ctx context.Context) (int64, error) {
+	m.cancel()
+	return 1, nil
+}
+
+func TestRunIdempotencySweep_SweepsUntilCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	svc := &mockIdempotencyService{cancel: cancel}
+
+	done := make(chan struct{})
+	go func() {
+		RunIdempotencySweep(ctx, svc, time.Millisecond, zap.NewNop())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunIdempotencySweep did not stop after the context was cancelled")
+	}
+}
@@ -0,0 +1,41 @@
+// Package job holds the background jobs started alongside the HTTP server.
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/yMaatheus/tech-challenge-snet/service"
+	"go.uber.org/zap"
+)
+
+// RunPurge purges the rows soft deleted longer than retention ago, once right away and then
+// every interval, until ctx is cancelled. Failures are logged and retried on the next tick.
+func RunPurge(ctx context.Context, svc service.PurgeService, retention, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purge(ctx, svc, retention, logger)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func purge(ctx context.Context, svc service.PurgeService, retention time.Duration, logger *zap.Logger) {
+	before := time.Now().Add(-retention)
+	result, err := svc.Purge(ctx, before)
+	if err != nil {
+		logger.Error("Failed to purge deleted rows", zap.Error(err))
+		return
+	}
+	if result.Establishments > 0 || result.Stores > 0 {
+		logger.Info("Purged deleted rows",
+			zap.Time("deleted_before", before),
+			zap.Int64("establishments", result.Establishments),
+			zap.Int64("stores", result.Stores),
+		)
+	}
+}
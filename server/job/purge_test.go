@@ -0,0 +1,41 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"go.uber.org/zap"
+)
+
+type mockPurgeService struct {
+	calls  chan time.Time
+	cancel context.CancelFunc
+}
+
+func (m *mockPurgeService) Purge(ctx context.Context, before time.Time) (*model.PurgeResult, error) {
+	m.calls <- before
+	m.cancel()
+	return &model.PurgeResult{Stores: 1}, nil
+}
+
+func TestRunPurge_PurgesRowsOlderThanRetention(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	svc := &mockPurgeService{calls: make(chan time.Time, 1), cancel: cancel}
+
+	done := make(chan struct{})
+	go func() {
+		RunPurge(ctx, svc, 24*time.Hour, time.Hour, zap.NewNop())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunPurge did not stop after the context was cancelled")
+	}
+	before := <-svc.calls
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), before, time.Minute)
+}
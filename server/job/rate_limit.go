@@ -0,0 +1,32 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/yMaatheus/tech-challenge-snet/repository"
+	"go.uber.org/zap"
+)
+
+// RunRateLimitSweep deletes the rate limit buckets that are full again every interval, until ctx is
+// cancelled, so clients that went quiet do not pile up in the table. Failures are logged and retried on
+// the next tick.
+func RunRateLimitSweep(ctx context.Context, store repository.RateLimitStore, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		swept, err := store.Sweep(ctx)
+		if err != nil {
+			logger.Error("Failed to sweep rate limit buckets", zap.Error(err))
+			continue
+		}
+		if swept > 0 {
+			logger.Debug("Swept rate limit buckets", zap.Int64("buckets", swept))
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yMaatheus/tech-challenge-snet/ratelimit"
+	"go.uber.org/zap"
+)
+
+type mockRateLimitStore struct {
+	cancel context.CancelFunc
+}
+
+func (m *mockRateLimitStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{Allowed: true}, nil
+}
+
+func (m *mockRateLimitStore) Sweep(ctx context.Context) (int64, error) {
+	m.cancel()
+	return 3, nil
+}
+
+func TestRunRateLimitSweep_SweepsUntilCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	store := &mockRateLimitStore{cancel: cancel}
+
+	done := make(chan struct{})
+	go func() {
+		RunRateLimitSweep(ctx, store, time.Millisecond, zap.NewNop())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunRateLimitSweep did not stop after the context was cancelled")
+	}
+}
@@ -0,0 +1,298 @@
+// Package migrate applies the versioned SQL migrations of the schema.
+//
+// Migrations are pairs of files named <version>_<name>.up.sql and <version>_<name>.down.sql.
+// Each one runs in its own transaction and is recorded in the schema_migrations table with the
+// checksum of its up file, so a migration edited after being applied is detected instead of
+// silently diverging. A Postgres advisory lock keeps concurrent runners, such as several
+// instances starting at once, from applying the same migration twice.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// lockKey identifies the advisory lock held while migrating
+const lockKey int64 = 7_271_950_311
+
+// States reported by Status
+const (
+	StateApplied  = "applied"
+	StatePending  = "pending"
+	StateModified = "modified" // applied, but the up file changed since
+	StateMissing  = "missing"  // applied, but there is no file for it anymore
+)
+
+// ErrChecksumMismatch reports an applied migration whose file changed since it was applied.
+var ErrChecksumMismatch = errors.New("migration changed after being applied")
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned schema change.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus is a migration along with whether and when it was applied.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	State     string
+	AppliedAt *time.Time
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New loads the migrations in fsys, failing when they are malformed.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Load reads the migration files at the root of fsys, sorted by version.
+// Every version needs an up file; the down file is optional, but a version without it cannot be rolled back.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+			sum := sha256.Sum256(body)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Checksum == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Up applies every pending migration in version order, returning the ones applied.
+// It refuses to run when an applied migration was modified or no longer exists.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := apply(ctx, conn, migration.Up, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+					migration.Version, migration.Name, migration.Checksum)
+				return err
+			}); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the last steps applied migrations, newest first, returning the ones rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+			if err := apply(ctx, conn, migration.Down, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists every known migration with its state, including applied migrations whose file is missing.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := readApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name, State: StatePending}
+			if row, ok := applied[migration.Version]; ok {
+				status.State = StateApplied
+				if row.checksum != migration.Checksum {
+					status.State = StateModified
+				}
+				status.AppliedAt = &row.appliedAt
+				delete(applied, migration.Version)
+			}
+			statuses = append(statuses, status)
+		}
+		for version, row := range applied {
+			statuses = append(statuses, MigrationStatus{Version: version, Name: row.name, State: StateMissing, AppliedAt: &row.appliedAt})
+		}
+		sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+		return nil
+	})
+	return statuses, err
+}
+
+// verify reads the applied migrations and checks they all match the loaded files.
+func (m *Migrator) verify(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	applied, err := readApplied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[int64]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+	for version, row := range applied {
+		migration, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d_%s is applied but its file is missing", version, row.name)
+		}
+		if row.checksum != migration.Checksum {
+			return nil, fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, version, migration.Name)
+		}
+	}
+	return applied, nil
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory lock,
+// creating the schema_migrations table first if needed.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Session level advisory locks belong to the connection, so lock and unlock must share it
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+func readApplied(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int64]appliedMigration{}
+	for rows.Next() {
+		var version int64
+		var row appliedMigration
+		if err := rows.Scan(&version, &row.name, &row.checksum, &row.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = row
+	}
+	return applied, rows.Err()
+}
+
+// apply runs a migration script and its bookkeeping in a single transaction.
+func apply(ctx context.Context, conn *sql.Conn, script string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := record(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
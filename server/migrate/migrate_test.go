@@ -0,0 +1,148 @@
+package migrate
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yMaatheus/tech-challenge-snet/database"
+	"github.com/yMaatheus/tech-challenge-snet/testutil"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"0002_add_index.up.sql":   {Data: []byte("CREATE INDEX a ON t (a);")},
+		"0002_add_index.down.sql": {Data: []byte("DROP INDEX a;")},
+		"0001_create.up.sql":      {Data: []byte("CREATE TABLE t (a INT);")},
+		"README.md":               {Data: []byte("ignored")},
+	})
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "create", migrations[0].Name)
+	assert.Empty(t, migrations[0].Down)
+	assert.Equal(t, int64(2), migrations[1].Version)
+	assert.Equal(t, "DROP INDEX a;", migrations[1].Down)
+	assert.Len(t, migrations[1].Checksum, 64)
+	assert.NotEqual(t, migrations[0].Checksum, migrations[1].Checksum)
+}
+
+func TestLoad_SemUp(t *testing.T) {
+	_, err := Load(fstest.MapFS{"0001_create.down.sql": {Data: []byte("DROP TABLE t;")}})
+	assert.ErrorContains(t, err, "has no up file")
+}
+
+func TestLoad_NomesDiferentes(t *testing.T) {
+	_, err := Load(fstest.MapFS{
+		"0001_create.up.sql":  {Data: []byte("CREATE TABLE t (a INT);")},
+		"0001_other.down.sql": {Data: []byte("DROP TABLE t;")},
+	})
+	assert.ErrorContains(t, err, "has two names")
+}
+
+func TestLoad_EmbeddedMigrations(t *testing.T) {
+	migrations, err := Load(database.Migrations())
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		assert.Equal(t, int64(i+1), m.Version, "versions are sequential")
+		assert.NotEmpty(t, m.Down, "migration %d_%s must be reversible", m.Version, m.Name)
+	}
+}
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	testutil.ExecSQLFile(t, db, "../database/reset.sql")
+	ctx := context.Background()
+
+	migrator, err := New(db, database.Migrations())
+	require.NoError(t, err)
+	total := len(migrator.migrations)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, total)
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied, "a second run has nothing to apply")
+
+	reverted, err := migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	assert.Equal(t, int64(total), reverted[0].Version)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, total)
+	assert.Equal(t, StateApplied, statuses[0].State)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	assert.Equal(t, StatePending, statuses[total-1].State)
+
+	reverted, err = migrator.Down(ctx, total)
+	require.NoError(t, err)
+	assert.Len(t, reverted, total-1)
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, total)
+}
+
+func TestMigrator_ChecksumMismatch(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	testutil.ExecSQLFile(t, db, "../database/reset.sql")
+	ctx := context.Background()
+
+	original := fstest.MapFS{"0001_create.up.sql": {Data: []byte("CREATE TABLE migrate_test (a INT);")}}
+	migrator, err := New(db, original)
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = db.Exec("DROP TABLE IF EXISTS migrate_test") })
+
+	edited := fstest.MapFS{
+		"0001_create.up.sql": {Data: []byte("CREATE TABLE migrate_test (a BIGINT);")},
+		"0002_more.up.sql":   {Data: []byte("ALTER TABLE migrate_test ADD COLUMN b INT;")},
+	}
+	migrator, err = New(db, edited)
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, StateModified, statuses[0].State)
+	assert.Equal(t, StatePending, statuses[1].State)
+}
+
+func TestMigrator_ConcurrentUp(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	testutil.ExecSQLFile(t, db, "../database/reset.sql")
+
+	var wg sync.WaitGroup
+	counts := make([]int, 4)
+	errs := make([]error, len(counts))
+	for i := range counts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			migrator, err := New(db, database.Migrations())
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			applied, err := migrator.Up(context.Background())
+			counts[i], errs[i] = len(applied), err
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range counts {
+		assert.NoError(t, errs[i])
+		total += counts[i]
+	}
+	migrations, _ := Load(database.Migrations())
+	assert.Equal(t, len(migrations), total, "each migration is applied exactly once")
+}
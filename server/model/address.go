@@ -0,0 +1,24 @@
+package model
+
+// Address is the address a zip code (CEP) belongs to
+type Address struct {
+	// CEP is the zip code as 8 digits
+	CEP          string `json:"cep"`
+	Street       string `json:"street"`
+	Neighborhood string `json:"neighborhood"`
+	City         string `json:"city"`
+	State        string `json:"state"`
+}
+
+// Address check modes, which decide what happens to a record whose city or state disagree with its zip code
+const (
+	// AddressCheckOff does not look up zip codes on writes
+	AddressCheckOff = "off"
+	// AddressCheckFlag saves the record with address_mismatch set
+	AddressCheckFlag = "flag"
+	// AddressCheckReject refuses the record with a validation error
+	AddressCheckReject = "reject"
+)
+
+// AddressCheckModes lists the accepted address check modes
+var AddressCheckModes = []string{AddressCheckOff, AddressCheckFlag, AddressCheckReject}
@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// APIKey authenticates a machine-to-machine integration. Only a hash of the key itself is kept.
+type APIKey struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// Prefix is the public part of the key, shown to tell keys apart without revealing them
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	// RotatedFromID is the key this one replaced, if it was created by a rotation
+	RotatedFromID *int64 `json:"rotated_from_id,omitempty"`
+	TenantID      string `json:"-"`
+	KeyHash       string `json:"-"`
+}
+
+// Active reports whether the key can still authenticate at the given time.
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// APIKeyRequest is the body to create an API key
+type APIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1"`
+	// ExpiresAt is when the key stops working; it never expires when omitted
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyRotateRequest is the body to rotate an API key
+type APIKeyRotateRequest struct {
+	// Overlap is how long the old key keeps working next to the new one, as a duration such as "24h".
+	// It defaults to DefaultAPIKeyOverlap, and "0s" revokes the old key at once.
+	Overlap *string `json:"overlap"`
+}
+
+// Bounds of the overlap of a rotation
+const (
+	DefaultAPIKeyOverlap = 24 * time.Hour
+	MaxAPIKeyOverlap     = 30 * 24 * time.Hour
+)
+
+// CreatedAPIKey is an API key along with the key itself, which is only ever shown when it is created
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// Entities and actions recorded in the audit log
+const (
+	AuditEntityEstablishment = "establishment"
+	AuditEntityStore         = "store"
+
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+)
+
+// AuditEntities lists the entities the audit log can be filtered by
+var AuditEntities = []string{AuditEntityEstablishment, AuditEntityStore}
+
+// FieldChange holds the values of a field before and after a change
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEntry records a change made to an establishment or store
+type AuditEntry struct {
+	ID        int64                  `json:"id"`
+	Entity    string                 `json:"entity"`
+	EntityID  int64                  `json:"entity_id"`
+	Action    string                 `json:"action"`
+	Actor     string                 `json:"actor"`
+	Changes   map[string]FieldChange `json:"changes"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditParams holds the pagination and filters of an audit log query
+type AuditParams struct {
+	Entity   string
+	EntityID int64
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+	Cursor   string
+}
+
+// AuditPage is a page of audit entries, newest first
+type AuditPage struct {
+	Items      []AuditEntry `json:"items"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	Total      int64        `json:"total"`
+}
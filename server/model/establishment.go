@@ -3,12 +3,22 @@ package model
 // Establishment represents a business establishment
 type Establishment struct {
 	ID            int64  `json:"id"`
-	Number        string `json:"number" validate:"required"`
+	Number        string `json:"number" validate:"required,cnpj"`
 	Name          string `json:"name" validate:"required"`
 	CorporateName string `json:"corporate_name"`
 	Address       string `json:"address" validate:"required"`
 	City          string `json:"city" validate:"required"`
-	State         string `json:"state" validate:"required,len=2"`
-	ZipCode       string `json:"zip_code" validate:"required"`
+	State         string `json:"state" validate:"required,uf"`
+	ZipCode       string `json:"zip_code" validate:"required,cep"`
 	AddressNumber string `json:"address_number" validate:"required"`
+	// AddressMismatch is set when the city or state disagree with the address of the zip code, when addresses are checked
+	AddressMismatch bool `json:"address_mismatch" readonly:"true"`
+	// Version is bumped by every write and sent as the ETag; updates carry the expected one, read from If-Match
+	Version int64 `json:"version" readonly:"true"`
+}
+
+// EstablishmentCascadeDeletion is the result of deleting an establishment together with its stores
+type EstablishmentCascadeDeletion struct {
+	Message         string  `json:"message"`
+	DeletedStoreIDs []int64 `json:"deleted_store_ids"`
 }
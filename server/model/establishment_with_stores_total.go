@@ -1,14 +1,20 @@
 package model
 
+import "time"
+
 type EstablishmentWithStoresTotal struct {
-	ID            int64  `json:"id"`
-	Number        string `json:"number"`
-	Name          string `json:"name"`
-	CorporateName string `json:"corporate_name"`
-	Address       string `json:"address"`
-	City          string `json:"city"`
-	State         string `json:"state"`
-	ZipCode       string `json:"zip_code"`
-	AddressNumber string `json:"address_number"`
-	StoresTotal   int    `json:"storesTotal"`
+	ID              int64  `json:"id"`
+	Number          string `json:"number"`
+	Name            string `json:"name"`
+	CorporateName   string `json:"corporate_name"`
+	Address         string `json:"address"`
+	City            string `json:"city"`
+	State           string `json:"state"`
+	ZipCode         string `json:"zip_code"`
+	AddressNumber   string `json:"address_number"`
+	AddressMismatch bool   `json:"address_mismatch"`
+	Version         int64  `json:"version"`
+	StoresTotal     int    `json:"storesTotal"`
+	// DeletedAt is only set when deleted establishments are listed with include_deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// MaxIdempotencyKeyLength is the longest Idempotency-Key accepted
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyRecord is the first request sent by a client with an Idempotency-Key, whose response is
+// replayed to the retries sent with the same key
+type IdempotencyRecord struct {
+	// Client is who sent the request, such as "user:maria"; keys of different clients never clash
+	Client string
+	Key    string
+	// Fingerprint is the SHA-256 of the method, path and body of the request, in hex
+	Fingerprint string
+	// Response is nil while the request is still being processed
+	Response  *IdempotentResponse
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// IdempotentResponse is the response kept for the retries of a request
+type IdempotentResponse struct {
+	Status int
+	// Header holds the headers replayed along with the body, such as Content-Type and ETag
+	Header map[string]string
+	Body   []byte
+}
@@ -0,0 +1,58 @@
+package model
+
+// MaxImportRows is the largest number of data rows a single import may have
+const MaxImportRows = 10000
+
+// Import modes
+const (
+	// ImportModeCreate only creates rows, so a number that already exists is reported as a conflict
+	ImportModeCreate = "create"
+	// ImportModeUpsert updates the row with the same number, or creates it when there is none
+	ImportModeUpsert = "upsert"
+)
+
+// ImportModes lists the accepted import modes
+var ImportModes = []string{ImportModeCreate, ImportModeUpsert}
+
+// EstablishmentImportColumns lists the columns an establishment import file may have
+var EstablishmentImportColumns = []string{"number", "name", "corporate_name", "address", "address_number", "city", "state", "zip_code"}
+
+// StoreImportColumns lists the columns a store import file may have. The establishment is given
+// either by establishment_id or by establishment_number.
+var StoreImportColumns = []string{"number", "name", "corporate_name", "address", "address_number", "city", "state", "zip_code", "establishment_id", "establishment_number"}
+
+// ImportOptions controls how an import is applied
+type ImportOptions struct {
+	Mode string
+	// DryRun validates and applies every row, then rolls everything back
+	DryRun bool
+	// Atomic imports all rows in one transaction, writing nothing when any row fails.
+	// Otherwise each row is committed on its own and the failed ones are skipped.
+	Atomic bool
+}
+
+// ImportRow is a data row of an import file, with its values by column name
+type ImportRow struct {
+	// Line is the line of the row in the file, counting the header as line 1
+	Line   int
+	Values map[string]string
+}
+
+// ImportRowError describes why a row was not imported
+type ImportRowError struct {
+	Message string            `json:"message"`
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+// ImportReport is the outcome of an import
+type ImportReport struct {
+	Mode    string `json:"mode"`
+	DryRun  bool   `json:"dry_run"`
+	Atomic  bool   `json:"atomic"`
+	Rows    int    `json:"rows"`
+	Created int    `json:"created"`
+	Updated int    `json:"updated"`
+	Failed  int    `json:"failed"`
+	// Errors holds the failed rows keyed by line number
+	Errors map[int]ImportRowError `json:"errors"`
+}
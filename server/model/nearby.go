@@ -0,0 +1,29 @@
+package model
+
+const (
+	// DefaultNearbyRadiusKm is the search radius used when the client does not send one
+	DefaultNearbyRadiusKm = 10
+	// MaxNearbyRadiusKm is the largest search radius a client may request
+	MaxNearbyRadiusKm = 500
+)
+
+// NearbyParams holds the point, radius and options of a nearby stores search
+type NearbyParams struct {
+	Latitude  float64
+	Longitude float64
+	RadiusKm  float64
+	// EstablishmentID only searches the stores of one establishment when set
+	EstablishmentID int64
+	Limit           int
+}
+
+// StoreDistance is a store found by a nearby search with its distance to the searched point
+type StoreDistance struct {
+	Store
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// NearbyStores holds the stores found by a nearby search, nearest first
+type NearbyStores struct {
+	Items []StoreDistance `json:"items"`
+}
@@ -0,0 +1,54 @@
+package model
+
+const (
+	// DefaultListLimit is the page size used when the client does not send one
+	DefaultListLimit = 20
+	// MaxListLimit is the largest page size a client may request
+	MaxListLimit = 100
+)
+
+// EstablishmentSortFields lists the fields establishments can be sorted by
+var EstablishmentSortFields = []string{"id", "number", "name", "city", "state", "zip_code"}
+
+// StoreSortFields lists the fields stores can be sorted by
+var StoreSortFields = []string{"id", "number", "name", "city", "state", "zip_code"}
+
+// ListParams holds the pagination, sorting and filtering options of a list query
+type ListParams struct {
+	Limit           int
+	Cursor          string
+	Sort            string
+	Desc            bool
+	City            string
+	State           string
+	ZipPrefix       string
+	EstablishmentID int64
+	// IncludeDeleted also lists soft deleted rows
+	IncludeDeleted bool
+}
+
+// SortKey names the order of the list as the sort param does, such as "-name", with "id" when it is not set
+func (p ListParams) SortKey() string {
+	sort := p.Sort
+	if sort == "" {
+		sort = "id"
+	}
+	if p.Desc {
+		return "-" + sort
+	}
+	return sort
+}
+
+// EstablishmentPage is a page of establishments returned by a list query
+type EstablishmentPage struct {
+	Items      []EstablishmentWithStoresTotal `json:"items"`
+	NextCursor string                         `json:"next_cursor,omitempty"`
+	Total      int64                          `json:"total"`
+}
+
+// StorePage is a page of stores returned by a list query
+type StorePage struct {
+	Items      []Store `json:"items"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	Total      int64   `json:"total"`
+}
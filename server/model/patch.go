@@ -0,0 +1,13 @@
+package model
+
+// Formats of the documents accepted by the PATCH endpoints
+const (
+	PatchFormatMerge = "merge" // RFC 7396 JSON Merge Patch
+	PatchFormatJSON  = "json"  // RFC 6902 JSON Patch
+)
+
+// Patch is a partial update to apply to the JSON representation of an entity
+type Patch struct {
+	Format   string
+	Document []byte
+}
@@ -0,0 +1,7 @@
+package model
+
+// PurgeResult counts the rows permanently removed by a purge
+type PurgeResult struct {
+	Establishments int64 `json:"establishments"`
+	Stores         int64 `json:"stores"`
+}
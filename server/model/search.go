@@ -0,0 +1,46 @@
+package model
+
+// Types of the search results
+const (
+	SearchTypeEstablishment = "establishment"
+	SearchTypeStore         = "store"
+)
+
+// SearchTypes lists the result types a search can be restricted to
+var SearchTypes = []string{SearchTypeEstablishment, SearchTypeStore}
+
+// MinSearchQueryLength is the shortest query accepted, in characters
+const MinSearchQueryLength = 2
+
+// SearchParams holds a search query and its options
+type SearchParams struct {
+	Query string
+	// Terms are the words of the query in lower case without accents, matched as prefixes
+	Terms []string
+	// Types restricts the results to some types, or includes every type when empty
+	Types []string
+	Limit int
+}
+
+// SearchResult is an establishment or store matching a search
+type SearchResult struct {
+	Type string `json:"type"`
+	ID   int64  `json:"id"`
+	// EstablishmentID is only set for stores
+	EstablishmentID int64  `json:"establishment_id,omitempty"`
+	Number          string `json:"number"`
+	Name            string `json:"name"`
+	CorporateName   string `json:"corporate_name"`
+	Address         string `json:"address"`
+	City            string `json:"city"`
+	State           string `json:"state"`
+	// Score ranks the results, higher first
+	Score float64 `json:"score"`
+	// Highlights holds the matched fields as HTML-escaped text with the matches wrapped in <mark>
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// SearchResults are the results of a search, best first
+type SearchResults struct {
+	Items []SearchResult `json:"items"`
+}
@@ -1,14 +1,26 @@
 package model
 
+import "time"
+
 type Store struct {
 	ID              int64  `json:"id"`
-	Number          string `json:"number" validate:"required"`
+	Number          string `json:"number" validate:"required,cnpj"`
 	Name            string `json:"name" validate:"required"`
 	CorporateName   string `json:"corporate_name"`
 	Address         string `json:"address" validate:"required"`
 	City            string `json:"city" validate:"required"`
-	State           string `json:"state" validate:"required,len=2"`
-	ZipCode         string `json:"zip_code" validate:"required"`
+	State           string `json:"state" validate:"required,uf"`
+	ZipCode         string `json:"zip_code" validate:"required,cep"`
 	AddressNumber   string `json:"address_number" validate:"required"`
 	EstablishmentID int64  `json:"establishment_id" validate:"required"`
+	// Latitude and Longitude locate the store for nearby searches. They go together, and when both are
+	// left out they are geocoded from the zip code, staying empty if the zip code is unknown.
+	Latitude  *float64 `json:"latitude,omitempty" validate:"required_with=Longitude,omitempty,latitude"`
+	Longitude *float64 `json:"longitude,omitempty" validate:"required_with=Latitude,omitempty,longitude"`
+	// AddressMismatch is set when the city or state disagree with the address of the zip code, when addresses are checked
+	AddressMismatch bool `json:"address_mismatch" readonly:"true"`
+	// Version is bumped by every write and sent as the ETag; updates carry the expected one, read from If-Match
+	Version int64 `json:"version" readonly:"true"`
+	// DeletedAt is only set when deleted stores are listed with include_deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty" readonly:"true"`
 }
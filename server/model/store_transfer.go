@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// StoreTransfer records a store moving from one establishment to another
+type StoreTransfer struct {
+	ID                  int64     `json:"id"`
+	StoreID             int64     `json:"store_id"`
+	FromEstablishmentID int64     `json:"from_establishment_id"`
+	ToEstablishmentID   int64     `json:"to_establishment_id"`
+	Reason              string    `json:"reason"`
+	TransferredBy       string    `json:"transferred_by"`
+	TransferredAt       time.Time `json:"transferred_at"`
+}
+
+// StoreTransferRequest is the body of a store transfer
+type StoreTransferRequest struct {
+	ToEstablishmentID int64  `json:"to_establishment_id" validate:"required"`
+	Reason            string `json:"reason" validate:"max=255"`
+}
@@ -0,0 +1,4 @@
+package model
+
+// AnyVersion as the expected version of a write skips the version check, like If-Match: *
+const AnyVersion int64 = 0
@@ -0,0 +1,94 @@
+// Package ratelimit throttles API clients with token buckets kept in a pluggable Store.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is the budget of a client: a bucket of Requests tokens, one per request, refilled evenly over
+// Period. A client may burst the whole bucket at once and then sustain Requests per Period.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit reads a limit written as "<requests>/<period>" with a Go duration, such as "600/1m".
+// "0" is the zero Limit, which disables limiting.
+func ParseLimit(raw string) (Limit, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "0" {
+		return Limit{}, nil
+	}
+	requests, period, ok := strings.Cut(raw, "/")
+	n, err := strconv.Atoi(requests)
+	if !ok || err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: must be <requests>/<period> such as 600/1m", raw)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: must be <requests>/<period> such as 600/1m", raw)
+	}
+	return Limit{Requests: n, Period: d}, nil
+}
+
+// Enabled reports whether the limit throttles anything
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// Rate is how many tokens the bucket gains per second
+func (l Limit) Rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Policy describes the limit as the RateLimit-Policy header does, e.g. "600;w=60"
+func (l Limit) Policy() string {
+	return fmt.Sprintf("%d;w=%d", l.Requests, int64(math.Ceil(l.Period.Seconds())))
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed bool
+	Limit   Limit
+	// Remaining is how many more requests the bucket allows right now
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, zero when this one was
+	RetryAfter time.Duration
+}
+
+// ResultFor describes a bucket left with tokens after a request, allowed or not
+func ResultFor(limit Limit, tokens float64, allowed bool) Result {
+	rate := limit.Rate()
+	r := Result{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     secondsDuration((float64(limit.Requests) - tokens) / rate),
+	}
+	if !allowed {
+		r.RetryAfter = secondsDuration((1 - tokens) / rate)
+	}
+	return r
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(math.Max(seconds, 0) * float64(time.Second))
+}
+
+// Store keeps the buckets of the clients
+type Store interface {
+	// Take refills the bucket of key for the time since its last request, then takes a token from it
+	// when it holds one. A key never seen before starts with a full bucket.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
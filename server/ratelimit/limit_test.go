@@ -0,0 +1,29 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	l, err := ParseLimit("600/1m")
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Requests: 600, Period: time.Minute}, l)
+	assert.True(t, l.Enabled())
+	assert.Equal(t, 10.0, l.Rate())
+	assert.Equal(t, "600;w=60", l.Policy())
+
+	l, err = ParseLimit("0")
+	require.NoError(t, err)
+	assert.False(t, l.Enabled())
+}
+
+func TestParseLimit_Invalido(t *testing.T) {
+	for _, raw := range []string{"", "600", "600/", "/1m", "-1/1m", "0/1m", "600/0s", "600/minuto"} {
+		_, err := ParseLimit(raw)
+		assert.Error(t, err, raw)
+	}
+}
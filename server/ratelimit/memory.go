@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often MemoryStore drops the buckets that are full again
+const sweepInterval = time.Minute
+
+// MemoryStore keeps the buckets in the memory of the process, so each instance of the API has its own
+// budget. Multi-instance deployments share a Postgres-backed store instead.
+type MemoryStore struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is when the bucket will be full again, after which it is the same as a missing one
+	full time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{now: time.Now, buckets: map[string]*bucket{}}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+
+	capacity := float64(limit.Requests)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity}
+		s.buckets[key] = b
+	} else {
+		b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*limit.Rate())
+	}
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.updated = now
+	result := ResultFor(limit, b.tokens, allowed)
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+// sweep drops the full buckets at most once per sweepInterval, so idle clients do not pile up
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_Take(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Requests: 3, Period: 3 * time.Second}
+
+	for i := 2; i >= 0; i-- {
+		r, err := store.Take(context.Background(), "ip:1.2.3.4", limit)
+		require.NoError(t, err)
+		assert.True(t, r.Allowed)
+		assert.Equal(t, i, r.Remaining)
+		assert.Zero(t, r.RetryAfter)
+	}
+
+	r, err := store.Take(context.Background(), "ip:1.2.3.4", limit)
+	require.NoError(t, err)
+	assert.False(t, r.Allowed, "the burst is spent")
+	assert.Equal(t, 0, r.Remaining)
+	assert.Equal(t, time.Second, r.RetryAfter)
+	assert.Equal(t, 3*time.Second, r.Reset)
+
+	other, err := store.Take(context.Background(), "ip:5.6.7.8", limit)
+	require.NoError(t, err)
+	assert.True(t, other.Allowed, "each key has its own bucket")
+
+	now = now.Add(1500 * time.Millisecond)
+	r, err = store.Take(context.Background(), "ip:1.2.3.4", limit)
+	require.NoError(t, err)
+	assert.True(t, r.Allowed, "tokens refill over the period")
+	assert.Equal(t, 0, r.Remaining)
+	assert.Equal(t, 2500*time.Millisecond, r.Reset)
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Requests: 10, Period: time.Minute}
+
+	_, err := store.Take(context.Background(), "user:maria", limit)
+	require.NoError(t, err)
+	now = now.Add(30 * time.Second)
+	for i := 0; i < 10; i++ {
+		_, err = store.Take(context.Background(), "user:joao", limit)
+		require.NoError(t, err)
+	}
+	assert.Len(t, store.buckets, 2)
+
+	now = now.Add(45 * time.Second)
+	_, err = store.Take(context.Background(), "user:ana", limit)
+	require.NoError(t, err)
+	assert.NotContains(t, store.buckets, "user:maria", "full buckets are dropped")
+	assert.Contains(t, store.buckets, "user:joao")
+	assert.Contains(t, store.buckets, "user:ana")
+}
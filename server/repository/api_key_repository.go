@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+// APIKeyRepository stores the API keys of each tenant.
+type APIKeyRepository interface {
+	Create(ctx context.Context, k *model.APIKey) error
+	FindAll(ctx context.Context) ([]model.APIKey, error)
+	// FindByID returns the key with the given id in the tenant of ctx, or nil when there is none.
+	FindByID(ctx context.Context, id int64) (*model.APIKey, error)
+	// FindByPrefix returns the key with the given prefix in any tenant, or nil when there is none.
+	// It serves authentication, which runs before the tenant of the request is known.
+	FindByPrefix(ctx context.Context, prefix string) (*model.APIKey, error)
+	// Revoke stops a key that is not revoked yet from working.
+	Revoke(ctx context.Context, id int64) error
+	// ExpireBy makes a key expire at the given time, unless it already expires earlier.
+	ExpireBy(ctx context.Context, id int64, at time.Time) error
+	// TouchLastUsed records that a key was just used. It writes at most once a minute per key,
+	// so busy integrations do not turn every request into a write.
+	TouchLastUsed(ctx context.Context, id int64) error
+}
+
+type apiKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) APIKeyRepository {
+	return &apiKeyRepository{db}
+}
+
+const apiKeyColumns = "id, name, prefix, scopes, created_by, created_at, expires_at, last_used_at, revoked_at, rotated_from_id, tenant_id, key_hash"
+
+func (r *apiKeyRepository) Create(ctx context.Context, k *model.APIKey) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO api_keys (name, prefix, key_hash, scopes, created_by, expires_at, rotated_from_id, tenant_id)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at`
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, k.Name, k.Prefix, k.KeyHash, strings.Join(k.Scopes, " "), k.CreatedBy, k.ExpiresAt, k.RotatedFromID, tenantID).
+		Scan(&k.ID, &k.CreatedAt)
+	if err != nil {
+		return translateError(err)
+	}
+	k.TenantID = tenantID
+	return nil
+}
+
+func (r *apiKeyRepository) FindAll(ctx context.Context) ([]model.APIKey, error) {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn(ctx, r.db).QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE tenant_id=$1 ORDER BY id", tenantID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+	keys := []model.APIKey{}
+	for rows.Next() {
+		var k model.APIKey
+		if err := scanAPIKey(rows, &k); err != nil {
+			return nil, translateError(err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, translateError(rows.Err())
+}
+
+func (r *apiKeyRepository) FindByID(ctx context.Context, id int64) (*model.APIKey, error) {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.findOne(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id=$1 AND tenant_id=$2", id, tenantID)
+}
+
+func (r *apiKeyRepository) FindByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	return r.findOne(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE prefix=$1", prefix)
+}
+
+func (r *apiKeyRepository) findOne(ctx context.Context, query string, args ...interface{}) (*model.APIKey, error) {
+	var k model.APIKey
+	err := scanAPIKey(conn(ctx, r.db).QueryRowContext(ctx, query, args...), &k)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &k, nil
+}
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }, k *model.APIKey) error {
+	var (
+		scopes      string
+		rotatedFrom sql.NullInt64
+	)
+	if err := row.Scan(&k.ID, &k.Name, &k.Prefix, &scopes, &k.CreatedBy, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt,
+		&rotatedFrom, &k.TenantID, &k.KeyHash); err != nil {
+		return err
+	}
+	k.Scopes = strings.Fields(scopes)
+	if rotatedFrom.Valid {
+		k.RotatedFromID = &rotatedFrom.Int64
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, id int64) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE api_keys SET revoked_at = NOW() WHERE id=$1 AND tenant_id=$2 AND revoked_at IS NULL", id, tenantID)
+	if err != nil {
+		return translateError(err)
+	}
+	return requireAffected(res, "api key", id)
+}
+
+func (r *apiKeyRepository) ExpireBy(ctx context.Context, id int64, at time.Time) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE api_keys SET expires_at = LEAST(COALESCE(expires_at, $3), $3) WHERE id=$1 AND tenant_id=$2", id, tenantID, at)
+	if err != nil {
+		return translateError(err)
+	}
+	return requireAffected(res, "api key", id)
+}
+
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, id int64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `UPDATE api_keys SET last_used_at = NOW()
+		WHERE id=$1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`, id)
+	return translateError(err)
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/testutil"
+)
+
+func TestAPIKeyRepository(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	repo := NewAPIKeyRepository(db)
+	acme, globex := tenantContext("acme"), tenantContext("globex")
+
+	expires := time.Now().Add(48 * time.Hour).Truncate(time.Microsecond)
+	k := &model.APIKey{Name: "ERP", Prefix: "a1b2c3d4e5f6", KeyHash: "f0e1d2c3b4a59687f0e1d2c3b4a59687f0e1d2c3b4a59687f0e1d2c3b4a59687",
+		Scopes: []string{"read:stores", "write:stores"}, CreatedBy: "maria", ExpiresAt: &expires}
+	require.NoError(t, repo.Create(acme, k))
+	assert.NotZero(t, k.ID)
+
+	// Authentication finds the key without knowing its tenant
+	got, err := repo.FindByPrefix(context.Background(), "a1b2c3d4e5f6")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "acme", got.TenantID)
+	assert.Equal(t, k.KeyHash, got.KeyHash)
+	assert.Equal(t, []string{"read:stores", "write:stores"}, got.Scopes)
+	assert.Nil(t, got.LastUsedAt)
+
+	// Other tenants do not see it
+	keys, err := repo.FindAll(globex)
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+	assert.ErrorIs(t, repo.Revoke(globex, k.ID), domainerr.ErrNotFound)
+
+	require.NoError(t, repo.TouchLastUsed(context.Background(), k.ID))
+	got, err = repo.FindByID(acme, k.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.LastUsedAt)
+
+	// Expiring later than the current expiry keeps it
+	require.NoError(t, repo.ExpireBy(acme, k.ID, expires.Add(time.Hour)))
+	got, _ = repo.FindByID(acme, k.ID)
+	assert.True(t, expires.Equal(*got.ExpiresAt))
+	require.NoError(t, repo.ExpireBy(acme, k.ID, expires.Add(-time.Hour)))
+	got, _ = repo.FindByID(acme, k.ID)
+	assert.True(t, expires.Add(-time.Hour).Equal(*got.ExpiresAt))
+
+	require.NoError(t, repo.Revoke(acme, k.ID))
+	assert.ErrorIs(t, repo.Revoke(acme, k.ID), domainerr.ErrNotFound, "a key is only revoked once")
+	keys, err = repo.FindAll(acme)
+	assert.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].RevokedAt)
+
+	dup := &model.APIKey{Name: "Outra", Prefix: "a1b2c3d4e5f6", KeyHash: k.KeyHash, Scopes: []string{"read:stores"}, CreatedBy: "maria"}
+	assert.Error(t, repo.Create(globex, dup), "prefixes are unique across tenants")
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/util"
+)
+
+// AuditRepository stores the audit log of changes to establishments and stores.
+// Create joins the transaction carried by ctx, so an entry is only kept if the change it records is.
+type AuditRepository interface {
+	Create(ctx context.Context, entry *model.AuditEntry) error
+	FindAll(ctx context.Context, params model.AuditParams) (*model.AuditPage, error)
+}
+
+type auditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) AuditRepository {
+	return &auditRepository{db}
+}
+
+func (r *auditRepository) Create(ctx context.Context, entry *model.AuditEntry) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO audit_log (entity, entity_id, action, actor, changes, tenant_id)
+              VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, entry.Entity, entry.EntityID, entry.Action, entry.Actor, changes, tenantID).
+		Scan(&entry.ID, &entry.CreatedAt)
+	return translateError(err)
+}
+
+// FindAll pages through the audit log of the tenant of ctx newest first, using the entry id as the keyset.
+func (r *auditRepository) FindAll(ctx context.Context, params model.AuditParams) (*model.AuditPage, error) {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		where []string
+		args  []interface{}
+	)
+	add := func(format string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(format, len(args)))
+	}
+	add("tenant_id = $%d", tenantID)
+	if params.Entity != "" {
+		add("entity = $%d", params.Entity)
+	}
+	if params.EntityID > 0 {
+		add("entity_id = $%d", params.EntityID)
+	}
+	if params.From != nil {
+		add("created_at >= $%d", *params.From)
+	}
+	if params.To != nil {
+		add("created_at <= $%d", *params.To)
+	}
+	whereClause := func() string {
+		return "WHERE " + strings.Join(where, " AND ")
+	}
+
+	page := &model.AuditPage{Items: []model.AuditEntry{}}
+	if err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log "+whereClause(), args...).Scan(&page.Total); err != nil {
+		return nil, translateError(err)
+	}
+
+	if params.Cursor != "" {
+		cursor, err := util.DecodeCursor(params.Cursor)
+		if err != nil {
+			return nil, domainerr.Validation(err.Error())
+		}
+		add("id < $%d", cursor.ID)
+	}
+	limit := listLimit(params.Limit)
+	args = append(args, limit+1)
+	query := fmt.Sprintf("SELECT id, entity, entity_id, action, actor, changes, created_at FROM audit_log %s ORDER BY id DESC LIMIT $%d", whereClause(), len(args))
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			e       model.AuditEntry
+			changes []byte
+		)
+		if err := rows.Scan(&e.ID, &e.Entity, &e.EntityID, &e.Action, &e.Actor, &changes, &e.CreatedAt); err != nil {
+			return nil, translateError(err)
+		}
+		if len(page.Items) == limit {
+			page.NextCursor = util.EncodeCursor(util.Cursor{ID: page.Items[limit-1].ID})
+			break
+		}
+		if err := json.Unmarshal(changes, &e.Changes); err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, e)
+	}
+	return page, translateError(rows.Err())
+}
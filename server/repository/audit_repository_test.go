@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/testutil"
+)
+
+func TestAuditRepository_CreateAndFindAll(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	repo := NewAuditRepository(db)
+	ctx := tenantContext(tenant.Default)
+
+	entries := []*model.AuditEntry{
+		{Entity: model.AuditEntityEstablishment, EntityID: 1, Action: model.AuditActionCreate, Actor: "maria",
+			Changes: map[string]model.FieldChange{"name": {After: "Est1"}}},
+		{Entity: model.AuditEntityStore, EntityID: 1, Action: model.AuditActionCreate, Actor: "maria"},
+		{Entity: model.AuditEntityStore, EntityID: 1, Action: model.AuditActionUpdate, Actor: "joao",
+			Changes: map[string]model.FieldChange{"name": {Before: "Loja", After: "Loja Nova"}}},
+	}
+	for _, entry := range entries {
+		assert.NoError(t, repo.Create(ctx, entry))
+		assert.NotZero(t, entry.ID)
+		assert.False(t, entry.CreatedAt.IsZero())
+	}
+
+	page, err := repo.FindAll(ctx, model.AuditParams{Entity: model.AuditEntityStore, EntityID: 1, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total)
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, entries[2].ID, page.Items[0].ID, "newest entry comes first")
+	assert.Equal(t, model.FieldChange{Before: "Loja", After: "Loja Nova"}, page.Items[0].Changes["name"])
+	assert.NotEmpty(t, page.NextCursor)
+
+	page, err = repo.FindAll(ctx, model.AuditParams{Entity: model.AuditEntityStore, EntityID: 1, Limit: 1, Cursor: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, entries[1].ID, page.Items[0].ID)
+	assert.Empty(t, page.NextCursor)
+
+	future := entries[2].CreatedAt.Add(time.Hour)
+	page, err = repo.FindAll(ctx, model.AuditParams{From: &future, Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Items)
+}
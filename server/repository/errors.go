@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+)
+
+// SQLSTATE codes Postgres reports for the constraint violations we translate.
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	checkViolation      = "23514"
+)
+
+// uniqueConstraints maps the unique constraints of the schema to the conflict they represent.
+var uniqueConstraints = map[string]domainerr.ConflictError{
+	"establishments_number_active_key": {
+		Field:   "number",
+		Message: "an establishment with this number already exists",
+	},
+	"stores_establishment_id_number_active_key": {
+		Field:   "number",
+		Message: "a store with this number already exists in this establishment",
+	},
+}
+
+// foreignKeys maps the foreign keys of the schema to the reference they represent.
+var foreignKeys = map[string]domainerr.ForeignKeyError{
+	"stores_establishment_id_fkey": {
+		Field:   "establishment_id",
+		Message: "establishment does not exist",
+	},
+}
+
+// checkConstraints maps the check constraints of the schema to the field they validate.
+var checkConstraints = map[string]map[string]string{
+	"establishments_state_check":    {"state": "must be a Brazilian state (UF)"},
+	"establishments_zip_code_check": {"zip_code": "must be a CEP with 8 digits"},
+	"stores_state_check":            {"state": "must be a Brazilian state (UF)"},
+	"stores_zip_code_check":         {"zip_code": "must be a CEP with 8 digits"},
+	"stores_coordinates_check":      {"latitude": "latitude and longitude must be set together and within range"},
+}
+
+// translateError converts database errors into domain errors, returning any other error unchanged.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if isUnavailable(err) {
+		return domainerr.Unavailable(err)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case uniqueViolation:
+		if conflict, ok := uniqueConstraints[pgErr.ConstraintName]; ok {
+			return &conflict
+		}
+		return &domainerr.ConflictError{Message: pgErr.Detail}
+	case foreignKeyViolation:
+		if fk, ok := foreignKeys[pgErr.ConstraintName]; ok {
+			return &fk
+		}
+		return &domainerr.ForeignKeyError{Message: pgErr.Detail}
+	case checkViolation:
+		if fields, ok := checkConstraints[pgErr.ConstraintName]; ok {
+			return domainerr.ValidationFields(fields)
+		}
+		return domainerr.Validation(pgErr.Message)
+	}
+	return err
+}
+
+// requireAffected returns a NotFoundError when a write did not touch any row, meaning the entity does not exist.
+func requireAffected(res sql.Result, entity string, id int64) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return translateError(err)
+	}
+	if n == 0 {
+		return domainerr.NotFound(entity, id)
+	}
+	return nil
+}
+
+// isUnavailable reports whether err means the database could not be reached,
+// as opposed to rejecting the statement.
+func isUnavailable(err error) bool {
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || pgconn.Timeout(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Class 08 is "connection exception", 57P0x are server shutdowns and 53300 is "too many connections"
+		return strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "57P0") || pgErr.Code == "53300"
+	}
+	return false
+}
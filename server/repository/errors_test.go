@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+)
+
+func TestTranslateError(t *testing.T) {
+	assert.NoError(t, translateError(nil))
+
+	plain := errors.New("boom")
+	assert.Equal(t, plain, translateError(plain))
+
+	err := translateError(&pgconn.PgError{Code: uniqueViolation, ConstraintName: "establishments_number_active_key"})
+	var conflict *domainerr.ConflictError
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "number", conflict.Field)
+	assert.ErrorIs(t, err, domainerr.ErrConflict)
+
+	err = translateError(&pgconn.PgError{Code: foreignKeyViolation, ConstraintName: "stores_establishment_id_fkey"})
+	var fk *domainerr.ForeignKeyError
+	assert.ErrorAs(t, err, &fk)
+	assert.Equal(t, "establishment_id", fk.Field)
+	assert.ErrorIs(t, err, domainerr.ErrForeignKey)
+
+	err = translateError(&pgconn.PgError{Code: checkViolation, ConstraintName: "stores_state_check"})
+	var validation *domainerr.ValidationError
+	assert.ErrorAs(t, err, &validation)
+	assert.Contains(t, validation.Fields, "state")
+
+	err = translateError(&pgconn.PgError{Code: "57P01"})
+	assert.ErrorIs(t, err, domainerr.ErrUnavailable)
+}
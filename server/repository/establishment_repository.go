@@ -3,20 +3,44 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"time"
 
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
 	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/util"
 )
 
 // EstablishmentRepository defines methods for interacting with the establishments table.
+// Deletes are soft: deleted establishments are hidden from every Find method until restored or purged.
 type EstablishmentRepository interface {
 	Create(ctx context.Context, e *model.Establishment) error
 	FindAll(ctx context.Context) ([]model.Establishment, error)
-	FindAllWithStoresTotal(ctx context.Context) ([]model.EstablishmentWithStoresTotal, error)
+	FindAllWithStoresTotal(ctx context.Context, params model.ListParams) (*model.EstablishmentPage, error)
+	// StreamAllWithStoresTotal calls fn for every establishment matching the filters and sort of params,
+	// reading them one at a time instead of loading a page. It stops at the first error fn returns.
+	StreamAllWithStoresTotal(ctx context.Context, params model.ListParams, fn func(*model.EstablishmentWithStoresTotal) error) error
 	FindByID(ctx context.Context, id int64) (*model.Establishment, error)
+	// FindByIDForUpdate loads an establishment and locks its row until the surrounding transaction ends,
+	// which also blocks stores from being created under it meanwhile.
+	FindByIDForUpdate(ctx context.Context, id int64) (*model.Establishment, error)
+	// FindByIDForShare loads an establishment and holds a shared lock on its row until the surrounding
+	// transaction ends, so it cannot be deleted while a store is written under it. The foreign key of
+	// the store alone does not prevent that, as deleting an establishment only sets its deleted_at.
+	FindByIDForShare(ctx context.Context, id int64) (*model.Establishment, error)
+	// FindByNumber returns the establishment with the given normalized number, or nil when there is none.
+	FindByNumber(ctx context.Context, number string) (*model.Establishment, error)
 	Update(ctx context.Context, e *model.Establishment) error
+	// UpdateColumns writes only the given columns of e, named after their JSON fields, setting e.Version to the new version.
+	UpdateColumns(ctx context.Context, e *model.Establishment, columns []string) error
 	Delete(ctx context.Context, id int64) error
 	FindStoresByEstablishmentID(ctx context.Context, establishmentID int64) ([]model.Store, error)
 	HasStores(ctx context.Context, id int64) (bool, error)
+	// DeleteStores soft deletes every store of an establishment, returning the ids of the removed stores.
+	DeleteStores(ctx context.Context, establishmentID int64) ([]int64, error)
+	Restore(ctx context.Context, id int64) error
+	// Purge permanently removes the establishments deleted before the given time that no longer have stores.
+	// It spans every tenant, so it needs a context from tenant.WithAllTenants.
+	Purge(ctx context.Context, before time.Time) (int64, error)
 }
 
 // establishmentRepository is a concrete implementation of EstablishmentRepository.
@@ -29,133 +53,313 @@ func NewEstablishmentRepository(db *sql.DB) EstablishmentRepository {
 }
 
 func (r *establishmentRepository) Create(ctx context.Context, e *model.Establishment) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
 	query := `
         INSERT INTO establishments
-            (number, name, corporate_name, address, city, state, zip_code, address_number)
+            (number, name, corporate_name, address, city, state, zip_code, address_number, address_mismatch, tenant_id)
         VALUES
-            ($1, $2, $3, $4, $5, $6, $7, $8)
-        RETURNING id;
+            ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING id, version;
     `
-	return r.db.QueryRowContext(ctx, query,
+	err = conn(ctx, r.db).QueryRowContext(ctx, query,
 		e.Number, e.Name, e.CorporateName, e.Address,
-		e.City, e.State, e.ZipCode, e.AddressNumber,
-	).Scan(&e.ID)
+		e.City, e.State, e.ZipCode, e.AddressNumber, e.AddressMismatch, tenantID,
+	).Scan(&e.ID, &e.Version)
+	return translateError(err)
 }
 
 func (r *establishmentRepository) FindAll(ctx context.Context) ([]model.Establishment, error) {
-	query := `SELECT id, number, name, corporate_name, address, city, state, zip_code, address_number FROM establishments`
-	rows, err := r.db.QueryContext(ctx, query)
+	tenantID, err := tenantOf(ctx)
 	if err != nil {
 		return nil, err
 	}
+	query := `SELECT id, number, name, corporate_name, address, city, state, zip_code, address_number, address_mismatch, version FROM establishments WHERE tenant_id = $1 AND deleted_at IS NULL`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, translateError(err)
+	}
 	defer rows.Close()
 
 	var establishments []model.Establishment
 	for rows.Next() {
 		var e model.Establishment
-		err := rows.Scan(&e.ID, &e.Number, &e.Name, &e.CorporateName, &e.Address, &e.City, &e.State, &e.ZipCode, &e.AddressNumber)
+		err := rows.Scan(&e.ID, &e.Number, &e.Name, &e.CorporateName, &e.Address, &e.City, &e.State, &e.ZipCode, &e.AddressNumber, &e.AddressMismatch, &e.Version)
 		if err != nil {
-			return nil, err
+			return nil, translateError(err)
 		}
 		establishments = append(establishments, e)
 	}
-	return establishments, nil
+	return establishments, translateError(rows.Err())
 }
 
-func (r *establishmentRepository) FindAllWithStoresTotal(ctx context.Context) ([]model.EstablishmentWithStoresTotal, error) {
-	query := `
-		SELECT 
-			e.id, e.number, e.name, e.corporate_name, e.address, e.city, e.state, e.zip_code, e.address_number,
-			COUNT(s.id) AS stores_total
-		FROM establishments e
-		LEFT JOIN stores s ON e.id = s.establishment_id
-		GROUP BY e.id, e.number, e.name, e.corporate_name, e.address, e.city, e.state, e.zip_code, e.address_number
-		ORDER BY e.id
-	`
-	rows, err := r.db.QueryContext(ctx, query)
+func (r *establishmentRepository) FindAllWithStoresTotal(ctx context.Context, params model.ListParams) (*model.EstablishmentPage, error) {
+	q, err := newListQuery(ctx, "e", model.EstablishmentSortFields, params)
 	if err != nil {
 		return nil, err
 	}
+
+	page := &model.EstablishmentPage{Items: []model.EstablishmentWithStoresTotal{}}
+	countQuery := `SELECT COUNT(*) FROM establishments e ` + q.whereClause()
+	if err := conn(ctx, r.db).QueryRowContext(ctx, countQuery, q.args...).Scan(&page.Total); err != nil {
+		return nil, translateError(err)
+	}
+
+	if err := q.addCursor(params.Cursor); err != nil {
+		return nil, err
+	}
+	limit := listLimit(params.Limit)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, establishmentsWithStoresTotalQuery(q)+" "+q.orderAndLimit(limit), q.args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
 	defer rows.Close()
 
-	var establishments []model.EstablishmentWithStoresTotal
+	var sortValue, lastSortValue string
 	for rows.Next() {
 		var e model.EstablishmentWithStoresTotal
-		err := rows.Scan(
-			&e.ID,
-			&e.Number,
-			&e.Name,
-			&e.CorporateName,
-			&e.Address,
-			&e.City,
-			&e.State,
-			&e.ZipCode,
-			&e.AddressNumber,
-			&e.StoresTotal,
-		)
-		if err != nil {
-			return nil, err
+		if err := scanEstablishmentWithStoresTotal(rows, &e, &sortValue); err != nil {
+			return nil, translateError(err)
 		}
-		establishments = append(establishments, e)
+		if len(page.Items) == limit {
+			page.NextCursor = util.EncodeCursor(util.Cursor{Sort: params.SortKey(), Value: lastSortValue, ID: page.Items[limit-1].ID})
+			break
+		}
+		page.Items = append(page.Items, e)
+		lastSortValue = sortValue
+	}
+	return page, translateError(rows.Err())
+}
+
+func (r *establishmentRepository) StreamAllWithStoresTotal(ctx context.Context, params model.ListParams, fn func(*model.EstablishmentWithStoresTotal) error) error {
+	q, err := newListQuery(ctx, "e", model.EstablishmentSortFields, params)
+	if err != nil {
+		return err
+	}
+	rows, err := conn(ctx, r.db).QueryContext(ctx, establishmentsWithStoresTotalQuery(q)+" "+q.orderClause(), q.args...)
+	if err != nil {
+		return translateError(err)
 	}
-	return establishments, nil
+	defer rows.Close()
+
+	var sortValue string
+	for rows.Next() {
+		var e model.EstablishmentWithStoresTotal
+		if err := scanEstablishmentWithStoresTotal(rows, &e, &sortValue); err != nil {
+			return translateError(err)
+		}
+		if err := fn(&e); err != nil {
+			return err
+		}
+	}
+	return translateError(rows.Err())
+}
+
+// establishmentsWithStoresTotalQuery selects the establishments matching q with their stores total and sort value
+func establishmentsWithStoresTotalQuery(q *listQuery) string {
+	return `
+		SELECT 
+			e.id, e.number, e.name, e.corporate_name, e.address, e.city, e.state, e.zip_code, e.address_number,
+			e.address_mismatch, e.version, e.deleted_at, COUNT(s.id) AS stores_total, ` + q.sortColumn + `::text AS sort_value
+		FROM establishments e
+		LEFT JOIN stores s ON e.id = s.establishment_id AND s.deleted_at IS NULL
+		` + q.whereClause() + `
+		GROUP BY e.id, e.number, e.name, e.corporate_name, e.address, e.city, e.state, e.zip_code, e.address_number, e.address_mismatch, e.version, e.deleted_at`
+}
+
+func scanEstablishmentWithStoresTotal(rows *sql.Rows, e *model.EstablishmentWithStoresTotal, sortValue *string) error {
+	return rows.Scan(
+		&e.ID,
+		&e.Number,
+		&e.Name,
+		&e.CorporateName,
+		&e.Address,
+		&e.City,
+		&e.State,
+		&e.ZipCode,
+		&e.AddressNumber,
+		&e.AddressMismatch,
+		&e.Version,
+		&e.DeletedAt,
+		&e.StoresTotal,
+		sortValue,
+	)
 }
 
 func (r *establishmentRepository) FindByID(ctx context.Context, id int64) (*model.Establishment, error) {
-	query := `SELECT id, number, name, corporate_name, address, city, state, zip_code, address_number FROM establishments WHERE id = $1`
+	return r.findOne(ctx, `SELECT id, number, name, corporate_name, address, city, state, zip_code, address_number, address_mismatch, version FROM establishments WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL`, id)
+}
+
+func (r *establishmentRepository) FindByIDForUpdate(ctx context.Context, id int64) (*model.Establishment, error) {
+	return r.findOne(ctx, `SELECT id, number, name, corporate_name, address, city, state, zip_code, address_number, address_mismatch, version FROM establishments WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL FOR UPDATE`, id)
+}
+
+func (r *establishmentRepository) FindByIDForShare(ctx context.Context, id int64) (*model.Establishment, error) {
+	return r.findOne(ctx, `SELECT id, number, name, corporate_name, address, city, state, zip_code, address_number, address_mismatch, version FROM establishments WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL FOR SHARE`, id)
+}
+
+func (r *establishmentRepository) FindByNumber(ctx context.Context, number string) (*model.Establishment, error) {
+	return r.findOne(ctx, `SELECT id, number, name, corporate_name, address, city, state, zip_code, address_number, address_mismatch, version FROM establishments WHERE number = $1 AND tenant_id = $2 AND deleted_at IS NULL`, number)
+}
+
+// findOne runs a query selecting a single establishment by key, which must take the tenant as its last argument.
+func (r *establishmentRepository) findOne(ctx context.Context, query string, key interface{}) (*model.Establishment, error) {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
 	var e model.Establishment
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&e.ID, &e.Number, &e.Name, &e.CorporateName, &e.Address, &e.City, &e.State, &e.ZipCode, &e.AddressNumber,
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, key, tenantID).Scan(
+		&e.ID, &e.Number, &e.Name, &e.CorporateName, &e.Address, &e.City, &e.State, &e.ZipCode, &e.AddressNumber, &e.AddressMismatch, &e.Version,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	return &e, nil
 }
 
 func (r *establishmentRepository) Update(ctx context.Context, e *model.Establishment) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
 	query := `
         UPDATE establishments SET
             number = $1, name = $2, corporate_name = $3, address = $4,
-            city = $5, state = $6, zip_code = $7, address_number = $8
-        WHERE id = $9
+            city = $5, state = $6, zip_code = $7, address_number = $8, address_mismatch = $9, version = version + 1
+        WHERE id = $10 AND tenant_id = $11 AND deleted_at IS NULL
+        RETURNING version
     `
-	_, err := r.db.ExecContext(ctx, query,
+	err = conn(ctx, r.db).QueryRowContext(ctx, query,
 		e.Number, e.Name, e.CorporateName, e.Address, e.City,
-		e.State, e.ZipCode, e.AddressNumber, e.ID,
-	)
-	return err
+		e.State, e.ZipCode, e.AddressNumber, e.AddressMismatch, e.ID, tenantID,
+	).Scan(&e.Version)
+	if err == sql.ErrNoRows {
+		return domainerr.NotFound("establishment", e.ID)
+	}
+	return translateError(err)
+}
+
+// establishmentColumns maps the columns a partial update may write to their value in an establishment
+var establishmentColumns = map[string]func(e *model.Establishment) interface{}{
+	"number":           func(e *model.Establishment) interface{} { return e.Number },
+	"name":             func(e *model.Establishment) interface{} { return e.Name },
+	"corporate_name":   func(e *model.Establishment) interface{} { return e.CorporateName },
+	"address":          func(e *model.Establishment) interface{} { return e.Address },
+	"city":             func(e *model.Establishment) interface{} { return e.City },
+	"state":            func(e *model.Establishment) interface{} { return e.State },
+	"zip_code":         func(e *model.Establishment) interface{} { return e.ZipCode },
+	"address_number":   func(e *model.Establishment) interface{} { return e.AddressNumber },
+	"address_mismatch": func(e *model.Establishment) interface{} { return e.AddressMismatch },
+}
+
+func (r *establishmentRepository) UpdateColumns(ctx context.Context, e *model.Establishment, columns []string) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	query, args, err := updateColumnsQuery("establishments", columns, establishmentColumns, e, e.ID, tenantID)
+	if err != nil {
+		return err
+	}
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, args...).Scan(&e.Version)
+	if err == sql.ErrNoRows {
+		return domainerr.NotFound("establishment", e.ID)
+	}
+	return translateError(err)
 }
 
 func (r *establishmentRepository) Delete(ctx context.Context, id int64) error {
-	query := `DELETE FROM establishments WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
-	return err
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE establishments SET deleted_at = NOW(), version = version + 1 WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL`
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, id, tenantID)
+	if err != nil {
+		return translateError(err)
+	}
+	return requireAffected(res, "establishment", id)
 }
 
 func (r *establishmentRepository) FindStoresByEstablishmentID(ctx context.Context, establishmentID int64) ([]model.Store, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT id, number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id FROM stores WHERE establishment_id=$1", establishmentID)
+	tenantID, err := tenantOf(ctx)
 	if err != nil {
 		return nil, err
 	}
+	rows, err := conn(ctx, r.db).QueryContext(ctx, "SELECT id, number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id, latitude, longitude, address_mismatch, version FROM stores WHERE establishment_id=$1 AND tenant_id=$2 AND deleted_at IS NULL", establishmentID, tenantID)
+	if err != nil {
+		return nil, translateError(err)
+	}
 	defer rows.Close()
 	var stores []model.Store
 	for rows.Next() {
 		var s model.Store
-		if err := rows.Scan(&s.ID, &s.Number, &s.Name, &s.CorporateName, &s.Address, &s.City, &s.State, &s.ZipCode, &s.AddressNumber, &s.EstablishmentID); err != nil {
-			return nil, err
+		if err := rows.Scan(&s.ID, &s.Number, &s.Name, &s.CorporateName, &s.Address, &s.City, &s.State, &s.ZipCode, &s.AddressNumber, &s.EstablishmentID, &s.Latitude, &s.Longitude, &s.AddressMismatch, &s.Version); err != nil {
+			return nil, translateError(err)
 		}
 		stores = append(stores, s)
 	}
-	return stores, nil
+	return stores, translateError(rows.Err())
 }
 
 func (r *establishmentRepository) HasStores(ctx context.Context, id int64) (bool, error) {
-	query := `SELECT COUNT(1) FROM stores WHERE establishment_id = $1`
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return false, err
+	}
+	query := `SELECT COUNT(1) FROM stores WHERE establishment_id = $1 AND tenant_id = $2 AND deleted_at IS NULL`
 	var count int
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&count)
-	return count > 0, err
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, id, tenantID).Scan(&count)
+	return count > 0, translateError(err)
+}
+
+func (r *establishmentRepository) DeleteStores(ctx context.Context, establishmentID int64) ([]int64, error) {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `UPDATE stores SET deleted_at = NOW(), version = version + 1 WHERE establishment_id = $1 AND tenant_id = $2 AND deleted_at IS NULL RETURNING id`, establishmentID, tenantID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, translateError(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, translateError(rows.Err())
+}
+
+// Restore brings back a soft deleted establishment, failing with NotFound when there is no deleted establishment with this id.
+func (r *establishmentRepository) Restore(ctx context.Context, id int64) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := conn(ctx, r.db).ExecContext(ctx, `UPDATE establishments SET deleted_at = NULL, version = version + 1 WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NOT NULL`, id, tenantID)
+	if err != nil {
+		return translateError(err)
+	}
+	return requireAffected(res, "deleted establishment", id)
+}
+
+func (r *establishmentRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM establishments e
+		WHERE e.deleted_at < $1 AND NOT EXISTS (SELECT 1 FROM stores s WHERE s.establishment_id = e.id)`, before)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	n, err := res.RowsAffected()
+	return n, translateError(err)
 }
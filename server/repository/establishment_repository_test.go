@@ -4,33 +4,51 @@ import (
 	"context"
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/database"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+	"github.com/yMaatheus/tech-challenge-snet/migrate"
 	"github.com/yMaatheus/tech-challenge-snet/model"
 	"github.com/yMaatheus/tech-challenge-snet/testutil"
 )
 
+// resetDB drops every table and migrates the schema from scratch
 func resetDB(t *testing.T, db *sql.DB) {
 	testutil.ExecSQLFile(t, db, "../database/reset.sql")
-	testutil.ExecSQLFile(t, db, "../database/migration.sql")
+	migrator, err := migrate.New(db, database.Migrations())
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+}
+
+// tenantContext scopes the queries of a test to the given tenant
+func tenantContext(id string) context.Context {
+	return tenant.WithTenant(context.Background(), id)
 }
 
 func TestEstablishmentRepository_CRUD(t *testing.T) {
 	db := testutil.GetTestDB(t)
 	resetDB(t, db)
 	repo := NewEstablishmentRepository(db)
-	ctx := context.Background()
+	ctx := tenantContext(tenant.Default)
 
 	// Create
 	est := &model.Establishment{
-		Number:        "T123",
-		Name:          "Test Establishment",
-		CorporateName: "Test Corp",
-		Address:       "123 Road",
-		City:          "Testville",
-		State:         "TS",
-		ZipCode:       "99999-999",
-		AddressNumber: "42",
+		Number:          "T123",
+		Name:            "Test Establishment",
+		CorporateName:   "Test Corp",
+		Address:         "123 Road",
+		City:            "Testville",
+		State:           "SP",
+		ZipCode:         "01310100",
+		AddressNumber:   "42",
+		AddressMismatch: true,
 	}
 	err := repo.Create(ctx, est)
 	assert.NoError(t, err)
@@ -40,13 +58,35 @@ func TestEstablishmentRepository_CRUD(t *testing.T) {
 	found, err := repo.FindByID(ctx, est.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, est.Name, found.Name)
+	assert.True(t, found.AddressMismatch)
+
+	// FindByNumber
+	found, err = repo.FindByNumber(ctx, "T123")
+	assert.NoError(t, err)
+	assert.Equal(t, est.ID, found.ID)
+	found, err = repo.FindByNumber(ctx, "T999")
+	assert.NoError(t, err)
+	assert.Nil(t, found)
 
 	// Update
+	assert.Equal(t, int64(1), est.Version)
 	est.Name = "Updated Name"
 	err = repo.Update(ctx, est)
 	assert.NoError(t, err)
+	assert.Equal(t, int64(2), est.Version, "every update bumps the version")
 	updated, _ := repo.FindByID(ctx, est.ID)
 	assert.Equal(t, "Updated Name", updated.Name)
+	assert.Equal(t, int64(2), updated.Version)
+
+	// UpdateColumns only writes the given columns
+	partial := *updated
+	partial.City = "Partial City"
+	partial.Name = "Not written"
+	assert.NoError(t, repo.UpdateColumns(ctx, &partial, []string{"city"}))
+	assert.Equal(t, int64(3), partial.Version)
+	updated, _ = repo.FindByID(ctx, est.ID)
+	assert.Equal(t, "Partial City", updated.City)
+	assert.Equal(t, "Updated Name", updated.Name)
 
 	// FindAll
 	list, err := repo.FindAll(ctx)
@@ -60,3 +100,245 @@ func TestEstablishmentRepository_CRUD(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Nil(t, notFound)
 }
+
+func TestEstablishmentRepository_FindAllWithStoresTotal_Pagination(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	repo := NewEstablishmentRepository(db)
+	ctx := tenantContext(tenant.Default)
+
+	for _, name := range []string{"Charlie", "Alpha", "Bravo"} {
+		err := repo.Create(ctx, &model.Establishment{
+			Number: "N-" + name, Name: name, Address: "Rua", City: "Cidade",
+			State: "SP", ZipCode: "01001000", AddressNumber: "1",
+		})
+		assert.NoError(t, err)
+	}
+	err := repo.Create(ctx, &model.Establishment{
+		Number: "N-Delta", Name: "Delta", Address: "Rua", City: "Outra",
+		State: "RJ", ZipCode: "20000000", AddressNumber: "1",
+	})
+	assert.NoError(t, err)
+
+	// First page sorted by name
+	page, err := repo.FindAllWithStoresTotal(ctx, model.ListParams{Limit: 2, Sort: "name"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), page.Total)
+	assert.Len(t, page.Items, 2)
+	assert.Equal(t, "Alpha", page.Items[0].Name)
+	assert.Equal(t, "Bravo", page.Items[1].Name)
+	assert.NotEmpty(t, page.NextCursor)
+
+	// Second page continues after the cursor
+	page, err = repo.FindAllWithStoresTotal(ctx, model.ListParams{Limit: 2, Sort: "name", Cursor: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 2)
+	assert.Equal(t, "Charlie", page.Items[0].Name)
+	assert.Equal(t, "Delta", page.Items[1].Name)
+	assert.Empty(t, page.NextCursor)
+
+	// Filters
+	page, err = repo.FindAllWithStoresTotal(ctx, model.ListParams{State: "sp", ZipPrefix: "0100", Sort: "name", Desc: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), page.Total)
+	assert.Equal(t, "Charlie", page.Items[0].Name)
+
+	page, err = repo.FindAllWithStoresTotal(ctx, model.ListParams{City: "outra"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.Total)
+	assert.Equal(t, "Delta", page.Items[0].Name)
+
+	// Streaming goes through every matching row, in the same order, without pages
+	var names []string
+	err = repo.StreamAllWithStoresTotal(ctx, model.ListParams{State: "sp", Sort: "name", Desc: true}, func(e *model.EstablishmentWithStoresTotal) error {
+		names = append(names, e.Name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Charlie", "Bravo", "Alpha"}, names)
+}
+
+func TestEstablishmentRepository_Create_DuplicateNumber(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	repo := NewEstablishmentRepository(db)
+	ctx := tenantContext(tenant.Default)
+
+	newEst := func() *model.Establishment {
+		return &model.Establishment{
+			Number: "11222333000181", Name: "Est", Address: "Rua", City: "Cidade",
+			State: "SP", ZipCode: "01001000", AddressNumber: "1",
+		}
+	}
+	assert.NoError(t, repo.Create(ctx, newEst()))
+
+	err := repo.Create(ctx, newEst())
+	var conflict *domainerr.ConflictError
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "number", conflict.Field)
+}
+
+func TestEstablishmentRepository_UpdateDelete_NotFound(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	repo := NewEstablishmentRepository(db)
+	ctx := tenantContext(tenant.Default)
+
+	err := repo.Update(ctx, &model.Establishment{ID: 999999, Number: "11222333000181", Name: "Ghost"})
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+
+	err = repo.Delete(ctx, 999999)
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+}
+
+func TestEstablishmentRepository_DeleteStores(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	_, err := db.Exec(`
+		INSERT INTO establishments (number, name, corporate_name, address, city, state, zip_code, address_number, tenant_id)
+		VALUES ('E001', 'Est1', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 'default'),
+		       ('E002', 'Est2', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 'default');
+		INSERT INTO stores (number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id, tenant_id)
+		VALUES ('S001', 'Loja 1', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 1, 'default'),
+		       ('S002', 'Loja 2', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 1, 'default'),
+		       ('S003', 'Loja 3', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 2, 'default');
+	`)
+	assert.NoError(t, err)
+	repo := NewEstablishmentRepository(db)
+	ctx := tenantContext(tenant.Default)
+
+	err = NewTransactor(db).WithinTx(ctx, func(ctx context.Context) error {
+		est, err := repo.FindByIDForUpdate(ctx, 1)
+		assert.NoError(t, err)
+		assert.NotNil(t, est)
+		ids, err := repo.DeleteStores(ctx, 1)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []int64{1, 2}, ids)
+		return repo.Delete(ctx, 1)
+	})
+	assert.NoError(t, err)
+
+	got, err := repo.FindByID(ctx, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+	hasStores, err := repo.HasStores(ctx, 2)
+	assert.NoError(t, err)
+	assert.True(t, hasStores, "stores of other establishments are kept")
+}
+
+func TestEstablishmentRepository_FindByIDForShare(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	_, err := db.Exec(`
+		INSERT INTO establishments (number, name, corporate_name, address, city, state, zip_code, address_number, tenant_id)
+		VALUES ('E001', 'Est1', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 'default');
+	`)
+	assert.NoError(t, err)
+	repo := NewEstablishmentRepository(db)
+	ctx := tenantContext(tenant.Default)
+
+	err = NewTransactor(db).WithinTx(ctx, func(ctx context.Context) error {
+		est, err := repo.FindByIDForShare(ctx, 1)
+		assert.NoError(t, err)
+		assert.NotNil(t, est)
+
+		// A concurrent soft delete waits for the lock
+		other, err := db.BeginTx(context.Background(), nil)
+		assert.NoError(t, err)
+		defer other.Rollback()
+		_, err = other.Exec(`SET LOCAL lock_timeout = '100ms'`)
+		assert.NoError(t, err)
+		_, err = other.Exec(`UPDATE establishments SET deleted_at = now() WHERE id = 1`)
+		assert.Error(t, err, "the establishment is locked until the transaction ends")
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestEstablishmentRepository_SoftDeleteAndRestore(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	repo := NewEstablishmentRepository(db)
+	ctx := tenantContext(tenant.Default)
+
+	est := &model.Establishment{Number: "T123", Name: "Soft", CorporateName: "Corp", Address: "Rua", City: "Cidade", State: "SP", ZipCode: "01310100", AddressNumber: "1"}
+	assert.NoError(t, repo.Create(ctx, est))
+	assert.NoError(t, repo.Delete(ctx, est.ID))
+
+	got, err := repo.FindByID(ctx, est.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, got, "deleted establishments are hidden")
+	err = repo.Delete(ctx, est.ID)
+	assert.ErrorIs(t, err, domainerr.ErrNotFound, "an establishment is deleted only once")
+
+	page, err := repo.FindAllWithStoresTotal(ctx, model.ListParams{})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Items)
+	page, err = repo.FindAllWithStoresTotal(ctx, model.ListParams{IncludeDeleted: true})
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.NotNil(t, page.Items[0].DeletedAt)
+
+	// The number is free again while the establishment is deleted
+	again := *est
+	again.ID = 0
+	assert.NoError(t, repo.Create(ctx, &again))
+	err = repo.Restore(ctx, est.ID)
+	assert.ErrorIs(t, err, domainerr.ErrConflict)
+	assert.NoError(t, repo.Delete(ctx, again.ID))
+
+	assert.NoError(t, repo.Restore(ctx, est.ID))
+	got, err = repo.FindByID(ctx, est.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, got)
+	err = repo.Restore(ctx, est.ID)
+	assert.ErrorIs(t, err, domainerr.ErrNotFound, "only deleted establishments can be restored")
+}
+
+func TestEstablishmentRepository_Purge(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	_, err := db.Exec(`
+		INSERT INTO establishments (number, name, corporate_name, address, city, state, zip_code, address_number, deleted_at, tenant_id)
+		VALUES ('E001', 'Antigo', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', NOW() - INTERVAL '40 days', 'default'),
+		       ('E002', 'Recente', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', NOW() - INTERVAL '1 day', 'default'),
+		       ('E003', 'Com loja', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', NOW() - INTERVAL '40 days', 'default'),
+		       ('E004', 'Ativo', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', NULL, 'default');
+		INSERT INTO stores (number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id, deleted_at, tenant_id)
+		VALUES ('S001', 'Loja', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 3, NOW() - INTERVAL '1 day', 'default');
+	`)
+	assert.NoError(t, err)
+
+	n, err := NewEstablishmentRepository(db).Purge(tenant.WithAllTenants(context.Background()), time.Now().Add(-30*24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n, "only old deleted establishments without stores are purged")
+
+	var names []string
+	rows, err := db.Query("SELECT name FROM establishments ORDER BY id")
+	assert.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		assert.NoError(t, rows.Scan(&name))
+		names = append(names, name)
+	}
+	assert.Equal(t, []string{"Recente", "Com loja", "Ativo"}, names)
+}
+
+func TestEstablishmentRepository_CheckConstraints(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	repo := NewEstablishmentRepository(db)
+	ctx := tenantContext(tenant.Default)
+
+	est := &model.Establishment{Number: "T123", Name: "Check", CorporateName: "Corp", Address: "Rua", City: "Cidade", State: "XX", ZipCode: "01310100", AddressNumber: "1"}
+	err := repo.Create(ctx, est)
+	var validation *domainerr.ValidationError
+	assert.ErrorAs(t, err, &validation)
+	assert.Contains(t, validation.Fields, "state")
+
+	est.State, est.ZipCode = "SP", "01310-100"
+	err = repo.Create(ctx, est)
+	assert.ErrorAs(t, err, &validation)
+	assert.Contains(t, validation.Fields, "zip_code")
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+// IdempotencyRepository stores the responses of the requests sent with an Idempotency-Key, per tenant.
+type IdempotencyRepository interface {
+	// Reserve records that the request of r is being processed and returns nil, unless its key is already
+	// taken by a live record of the client, which it returns instead. Records that expired, and reservations
+	// whose last heartbeat came before abandonedBefore without the request completing, are replaced.
+	Reserve(ctx context.Context, r *model.IdempotencyRecord, abandonedBefore time.Time) (*model.IdempotencyRecord, error)
+	// Heartbeat marks the reservation of a request as still being processed at the given time.
+	Heartbeat(ctx context.Context, client, key string, at time.Time) error
+	// Complete stores the response of a reserved request.
+	Complete(ctx context.Context, client, key string, response model.IdempotentResponse) error
+	// Release deletes the reservation of a request that failed, so a retry runs it again.
+	Release(ctx context.Context, client, key string) error
+	// DeleteExpired removes the records that expired before the given time. It spans every tenant, so it
+	// needs a context from tenant.WithAllTenants.
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+type idempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) IdempotencyRepository {
+	return &idempotencyRepository{db}
+}
+
+func (r *idempotencyRepository) Reserve(ctx context.Context, rec *model.IdempotencyRecord, abandonedBefore time.Time) (*model.IdempotencyRecord, error) {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := `INSERT INTO idempotency_keys (tenant_id, client, key, fingerprint, created_at, expires_at, heartbeat_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $5)
+              ON CONFLICT (tenant_id, client, key) DO UPDATE
+              SET fingerprint = EXCLUDED.fingerprint, status = NULL, headers = NULL, body = NULL,
+                  created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at, heartbeat_at = EXCLUDED.heartbeat_at
+              WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+                 OR (idempotency_keys.status IS NULL AND idempotency_keys.heartbeat_at < $7)`
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, tenantID, rec.Client, rec.Key, rec.Fingerprint, rec.CreatedAt, rec.ExpiresAt, abandonedBefore)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if n > 0 {
+		return nil, nil
+	}
+
+	existing := &model.IdempotencyRecord{Client: rec.Client, Key: rec.Key}
+	var (
+		status  sql.NullInt32
+		headers []byte
+		body    []byte
+	)
+	err = conn(ctx, r.db).QueryRowContext(ctx,
+		`SELECT fingerprint, status, headers, body, created_at, expires_at FROM idempotency_keys
+         WHERE tenant_id=$1 AND client=$2 AND key=$3`, tenantID, rec.Client, rec.Key).
+		Scan(&existing.Fingerprint, &status, &headers, &body, &existing.CreatedAt, &existing.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released or deleted since the insert, so the key is free again
+		return r.Reserve(ctx, rec, abandonedBefore)
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if status.Valid {
+		existing.Response = &model.IdempotentResponse{Status: int(status.Int32), Body: body}
+		if err := json.Unmarshal(headers, &existing.Response.Header); err != nil {
+			return nil, err
+		}
+	}
+	return existing, nil
+}
+
+func (r *idempotencyRepository) Heartbeat(ctx context.Context, client, key string, at time.Time) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = conn(ctx, r.db).ExecContext(ctx,
+		"UPDATE idempotency_keys SET heartbeat_at=$4 WHERE tenant_id=$1 AND client=$2 AND key=$3 AND status IS NULL", tenantID, client, key, at)
+	return translateError(err)
+}
+
+func (r *idempotencyRepository) Complete(ctx context.Context, client, key string, response model.IdempotentResponse) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	headers, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+	_, err = conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE idempotency_keys SET status=$4, headers=$5, body=$6 WHERE tenant_id=$1 AND client=$2 AND key=$3`,
+		tenantID, client, key, response.Status, headers, response.Body)
+	return translateError(err)
+}
+
+func (r *idempotencyRepository) Release(ctx context.Context, client, key string) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = conn(ctx, r.db).ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE tenant_id=$1 AND client=$2 AND key=$3 AND status IS NULL", tenantID, client, key)
+	return translateError(err)
+}
+
+func (r *idempotencyRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at < $1", before)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	n, err := res.RowsAffected()
+	return n, translateError(err)
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/testutil"
+)
+
+func TestIdempotencyRepository(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	repo := NewIdempotencyRepository(db)
+	acme, globex := tenantContext("acme"), tenantContext("globex")
+
+	now := time.Now().Truncate(time.Microsecond)
+	record := func(fingerprint string, at time.Time) *model.IdempotencyRecord {
+		return &model.IdempotencyRecord{Client: "user:maria", Key: "chave-1", Fingerprint: fingerprint, CreatedAt: at, ExpiresAt: at.Add(time.Hour)}
+	}
+	abandoned := now.Add(-5 * time.Minute)
+
+	existing, err := repo.Reserve(acme, record("a", now), abandoned)
+	require.NoError(t, err)
+	assert.Nil(t, existing, "the first request reserves the key")
+
+	existing, err = repo.Reserve(acme, record("b", now), abandoned)
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.Equal(t, "a", existing.Fingerprint)
+	assert.Nil(t, existing.Response, "still being processed")
+
+	existing, err = repo.Reserve(globex, record("b", now), abandoned)
+	require.NoError(t, err)
+	assert.Nil(t, existing, "other tenants do not see the key")
+
+	response := model.IdempotentResponse{Status: 201, Header: map[string]string{"Content-Type": "application/json"}, Body: []byte(`{"id":1}`)}
+	require.NoError(t, repo.Complete(acme, "user:maria", "chave-1", response))
+	existing, err = repo.Reserve(acme, record("a", now), abandoned)
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.Equal(t, response, *existing.Response)
+
+	// Completed keys are kept until they expire
+	require.NoError(t, repo.Release(acme, "user:maria", "chave-1"))
+	existing, err = repo.Reserve(acme, record("b", now.Add(time.Hour)), abandoned)
+	require.NoError(t, err)
+	assert.Nil(t, existing, "expired keys are replaced")
+
+	// Released and abandoned reservations free the key
+	require.NoError(t, repo.Release(acme, "user:maria", "chave-1"))
+	existing, err = repo.Reserve(acme, record("c", now), abandoned)
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+	existing, err = repo.Reserve(acme, record("d", now), now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	// Heartbeats keep a long request from being taken as abandoned
+	require.NoError(t, repo.Heartbeat(acme, "user:maria", "chave-1", now.Add(10*time.Minute)))
+	existing, err = repo.Reserve(acme, record("e", now.Add(11*time.Minute)), now.Add(6*time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.Equal(t, "d", existing.Fingerprint)
+
+	deleted, err := repo.DeleteExpired(tenant.WithAllTenants(context.Background()), now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/util"
+)
+
+// listQuery accumulates the WHERE conditions and arguments of a paginated list query.
+type listQuery struct {
+	alias      string
+	sortColumn string
+	desc       bool
+	where      []string
+	args       []interface{}
+}
+
+// newListQuery builds the filters shared by the list and count queries of a table aliased as alias,
+// starting with the tenant of ctx.
+func newListQuery(ctx context.Context, alias string, sortFields []string, p model.ListParams) (*listQuery, error) {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort := p.Sort
+	if sort == "" {
+		sort = "id"
+	}
+	if !slices.Contains(sortFields, sort) {
+		return nil, domainerr.Validation(fmt.Sprintf("invalid sort field %q", sort))
+	}
+
+	q := &listQuery{alias: alias, sortColumn: alias + "." + sort, desc: p.Desc}
+	q.addFilter(alias+".tenant_id = $%d", tenantID)
+	if !p.IncludeDeleted {
+		q.where = append(q.where, alias+".deleted_at IS NULL")
+	}
+	if p.City != "" {
+		q.addFilter("LOWER("+alias+".city) = LOWER($%d)", p.City)
+	}
+	if p.State != "" {
+		q.addFilter("UPPER("+alias+".state) = UPPER($%d)", p.State)
+	}
+	if p.ZipPrefix != "" {
+		q.addFilter(alias+".zip_code LIKE $%d", escapeLike(p.ZipPrefix)+"%")
+	}
+	return q, nil
+}
+
+func (q *listQuery) addFilter(format string, arg interface{}) {
+	q.args = append(q.args, arg)
+	q.where = append(q.where, fmt.Sprintf(format, len(q.args)))
+}
+
+// whereClause renders the accumulated conditions, or an empty string when there are none.
+func (q *listQuery) whereClause() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(q.where, " AND ")
+}
+
+// addCursor restricts the query to the rows after the given cursor in the current sort order.
+func (q *listQuery) addCursor(c string) error {
+	if c == "" {
+		return nil
+	}
+	cursor, err := util.DecodeCursor(c)
+	if err != nil {
+		return domainerr.Validation(err.Error())
+	}
+	value, id := cursor.Value, cursor.ID
+	op := ">"
+	if q.desc {
+		op = "<"
+	}
+	idColumn := q.alias + ".id"
+	if q.sortColumn == idColumn {
+		q.addFilter(idColumn+" "+op+" $%d", id)
+		return nil
+	}
+	q.args = append(q.args, value, id)
+	q.where = append(q.where, fmt.Sprintf("(%s, %s) %s ($%d, $%d)", q.sortColumn, idColumn, op, len(q.args)-1, len(q.args)))
+	return nil
+}
+
+// orderClause renders the ORDER BY clause, breaking ties by id so the order is stable.
+func (q *listQuery) orderClause() string {
+	dir := "ASC"
+	if q.desc {
+		dir = "DESC"
+	}
+	idColumn := q.alias + ".id"
+	if q.sortColumn != idColumn {
+		return fmt.Sprintf("ORDER BY %s %s, %s %s", q.sortColumn, dir, idColumn, dir)
+	}
+	return fmt.Sprintf("ORDER BY %s %s", idColumn, dir)
+}
+
+// orderAndLimit renders the ORDER BY and LIMIT clauses, fetching one extra row to detect a next page.
+func (q *listQuery) orderAndLimit(limit int) string {
+	q.args = append(q.args, limit+1)
+	return fmt.Sprintf("%s LIMIT $%d", q.orderClause(), len(q.args))
+}
+
+// listLimit clamps the requested page size to the allowed range.
+func listLimit(limit int) int {
+	if limit <= 0 {
+		return model.DefaultListLimit
+	}
+	if limit > model.MaxListLimit {
+		return model.MaxListLimit
+	}
+	return limit
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
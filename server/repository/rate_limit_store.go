@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/yMaatheus/tech-challenge-snet/ratelimit"
+)
+
+// RateLimitStore keeps the rate limit buckets in Postgres, so every instance of the API draws from the
+// same budget per client.
+type RateLimitStore interface {
+	ratelimit.Store
+	// Sweep deletes the buckets that are full again, which behave as missing ones, and returns how many.
+	Sweep(ctx context.Context) (int64, error)
+}
+
+type rateLimitStore struct {
+	db *sql.DB
+}
+
+func NewRateLimitStore(db *sql.DB) RateLimitStore {
+	return &rateLimitStore{db}
+}
+
+func (s *rateLimitStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	var tokens float64
+	var allowed bool
+	err := s.db.QueryRowContext(ctx, `SELECT tokens, allowed FROM rate_limit_take($1, $2, $3)`,
+		key, float64(limit.Requests), limit.Rate()).Scan(&tokens, &allowed)
+	if err != nil {
+		return ratelimit.Result{}, translateError(err)
+	}
+	return ratelimit.ResultFor(limit, tokens, allowed), nil
+}
+
+func (s *rateLimitStore) Sweep(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE full_at <= clock_timestamp()`)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return res.RowsAffected()
+}
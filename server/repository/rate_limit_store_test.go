@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yMaatheus/tech-challenge-snet/ratelimit"
+	"github.com/yMaatheus/tech-challenge-snet/testutil"
+)
+
+func TestRateLimitStore(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	store := NewRateLimitStore(db)
+	ctx := context.Background()
+	limit := ratelimit.Limit{Requests: 2, Period: time.Hour}
+
+	r, err := store.Take(ctx, "write:ip:10.0.0.1", limit)
+	require.NoError(t, err)
+	assert.True(t, r.Allowed)
+	assert.Equal(t, 1, r.Remaining)
+	r, err = store.Take(ctx, "write:ip:10.0.0.1", limit)
+	require.NoError(t, err)
+	assert.True(t, r.Allowed)
+	assert.Equal(t, 0, r.Remaining)
+
+	r, err = store.Take(ctx, "write:ip:10.0.0.1", limit)
+	require.NoError(t, err)
+	assert.False(t, r.Allowed, "the bucket is empty")
+	assert.InDelta(t, 30*time.Minute, r.RetryAfter, float64(time.Second))
+
+	r, err = store.Take(ctx, "write:ip:10.0.0.2", limit)
+	require.NoError(t, err)
+	assert.True(t, r.Allowed, "each key has its own bucket")
+
+	// A fast refill makes the other bucket full again right away, so only it is swept
+	_, err = store.Take(ctx, "read:ip:10.0.0.1", ratelimit.Limit{Requests: 1000, Period: time.Millisecond})
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	swept, err := store.Sweep(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), swept)
+	r, err = store.Take(ctx, "write:ip:10.0.0.1", limit)
+	require.NoError(t, err)
+	assert.False(t, r.Allowed, "sweeping keeps the buckets that are not full")
+}
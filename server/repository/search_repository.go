@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/util"
+)
+
+// SearchRepository searches establishments and stores by name, corporate name, number, address and city.
+type SearchRepository interface {
+	// Search returns the rows matching params, best first. A row matches when it has every term as a
+	// word prefix (full-text search), when the query is similar to some of its words (trigram similarity)
+	// or when the query is the start of its number. Deleted rows and rows of other tenants are never returned.
+	Search(ctx context.Context, params model.SearchParams) ([]model.SearchResult, error)
+}
+
+type searchRepository struct {
+	db *sql.DB
+}
+
+func NewSearchRepository(db *sql.DB) SearchRepository {
+	return &searchRepository{db}
+}
+
+// searchTables maps each result type to its table and the column of its establishment
+var searchTables = map[string]struct{ table, establishmentID string }{
+	model.SearchTypeEstablishment: {"establishments", "NULL::int"},
+	model.SearchTypeStore:         {"stores", "t.establishment_id"},
+}
+
+// minNumberPrefix is the shortest query also matched as the start of a number
+const minNumberPrefix = 3
+
+func (r *searchRepository) Search(ctx context.Context, params model.SearchParams) ([]model.SearchResult, error) {
+	// Terms only hold letters and digits, so they are safe inside a tsquery
+	prefixes := make([]string, len(params.Terms))
+	for i, term := range params.Terms {
+		prefixes[i] = term + ":*"
+	}
+	numberPrefix := ""
+	if number := util.NormalizeCNPJ(params.Query); len(number) >= minNumberPrefix && isAlphanumeric(number) {
+		numberPrefix = escapeLike(number) + "%"
+	}
+
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var selects []string
+	for _, t := range model.SearchTypes {
+		if len(params.Types) > 0 && !slices.Contains(params.Types, t) {
+			continue
+		}
+		source := searchTables[t]
+		selects = append(selects, fmt.Sprintf(`
+			SELECT '%s' AS type, t.id, %s AS establishment_id, t.number, t.name, coalesce(t.corporate_name, ''),
+				coalesce(t.address, ''), coalesce(t.city, ''), coalesce(t.state, ''),
+				ts_rank(t.search_vector, q.ts) + word_similarity(q.text, t.search_text) AS score
+			FROM %s t, query q
+			WHERE t.tenant_id = $5 AND t.deleted_at IS NULL
+				AND (t.search_vector @@ q.ts OR q.text <%% t.search_text OR ($3 <> '' AND t.number LIKE $3))`,
+			t, source.establishmentID, source.table))
+	}
+
+	query := `
+		WITH query AS (
+			SELECT lower(immutable_unaccent($1)) AS text, to_tsquery('simple', immutable_unaccent($2)) AS ts
+		)` + strings.Join(selects, "\n UNION ALL") + `
+		ORDER BY score DESC, type, id
+		LIMIT $4`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, params.Query, strings.Join(prefixes, " & "), numberPrefix, listLimit(params.Limit), tenantID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	results := []model.SearchResult{}
+	for rows.Next() {
+		var (
+			res             model.SearchResult
+			establishmentID sql.NullInt64
+		)
+		if err := rows.Scan(&res.Type, &res.ID, &establishmentID, &res.Number, &res.Name, &res.CorporateName,
+			&res.Address, &res.City, &res.State, &res.Score); err != nil {
+			return nil, translateError(err)
+		}
+		res.EstablishmentID = establishmentID.Int64
+		results = append(results, res)
+	}
+	return results, translateError(rows.Err())
+}
+
+func isAlphanumeric(s string) bool {
+	for _, r := range s {
+		if !(r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/testutil"
+)
+
+func TestSearchRepository_Search(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	_, err := db.Exec(`
+		INSERT INTO establishments (number, name, corporate_name, address, city, state, zip_code, address_number, tenant_id)
+		VALUES ('11222333000181', 'Padaria São João', 'Pães e Doces LTDA', 'Rua das Flores', 'Recife', 'PE', '50000000', '10', 'default'),
+		       ('11444777000161', 'Farmácia Central', 'Central Saúde SA', 'Avenida Brasil', 'São Paulo', 'SP', '01000000', '20', 'default');
+		INSERT INTO stores (number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id, tenant_id)
+		VALUES ('22333444000155', 'Padaria Boa Viagem', 'Pães e Doces LTDA', 'Avenida Boa Viagem', 'Recife', 'PE', '51000000', '5', 1, 'default');
+		INSERT INTO stores (number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id, deleted_at, tenant_id)
+		VALUES ('33444555000166', 'Padaria Removida', 'Pães e Doces LTDA', 'Rua B', 'Recife', 'PE', '51000000', '6', 1, NOW(), 'default');
+	`)
+	assert.NoError(t, err)
+	repo := NewSearchRepository(db)
+	ctx := tenantContext(tenant.Default)
+
+	// Accent-insensitive prefix search mixes both types and skips deleted rows
+	results, err := repo.Search(ctx, model.SearchParams{Query: "padaria", Terms: []string{"padaria"}, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	types := map[string]int64{}
+	for _, r := range results {
+		types[r.Type] = r.ID
+	}
+	assert.Equal(t, map[string]int64{model.SearchTypeEstablishment: 1, model.SearchTypeStore: 1}, types)
+
+	results, err = repo.Search(ctx, model.SearchParams{Query: "sao joa", Terms: []string{"sao", "joa"}, Limit: 10})
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, results) {
+		assert.Equal(t, "Padaria São João", results[0].Name)
+	}
+
+	// Misspellings are matched by trigram similarity
+	results, err = repo.Search(ctx, model.SearchParams{Query: "farmacai", Terms: []string{"farmacai"}, Limit: 10})
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, results) {
+		assert.Equal(t, "Farmácia Central", results[0].Name)
+	}
+
+	// Numbers match by prefix, and results can be restricted by type
+	results, err = repo.Search(ctx, model.SearchParams{Query: "22.333.444", Terms: []string{"22", "333", "444"}, Types: []string{model.SearchTypeStore}, Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, model.SearchTypeStore, results[0].Type)
+		assert.Equal(t, int64(1), results[0].EstablishmentID)
+	}
+}
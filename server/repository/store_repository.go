@@ -3,16 +3,39 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"math"
+	"strconv"
+	"time"
 
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
 	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/util"
 )
 
+// StoreRepository defines methods for interacting with the stores table.
+// Deletes are soft: deleted stores are hidden from every Find method until restored or purged.
 type StoreRepository interface {
 	Create(ctx context.Context, store *model.Store) error
-	FindAll(ctx context.Context) ([]model.Store, error)
+	FindAll(ctx context.Context, params model.ListParams) (*model.StorePage, error)
+	// StreamAll calls fn for every store matching the filters and sort of params, reading them one at a time
+	// instead of loading a page. It stops at the first error fn returns.
+	StreamAll(ctx context.Context, params model.ListParams, fn func(*model.Store) error) error
+	// FindNearby returns the stores within params.RadiusKm of the point, nearest first. Stores without coordinates are never returned.
+	FindNearby(ctx context.Context, params model.NearbyParams) ([]model.StoreDistance, error)
 	FindByID(ctx context.Context, id int64) (*model.Store, error)
+	// FindByIDForUpdate loads a store and locks its row until the surrounding transaction ends.
+	FindByIDForUpdate(ctx context.Context, id int64) (*model.Store, error)
+	// FindByNumber returns the store with the given normalized number in an establishment, or nil when there is none.
+	FindByNumber(ctx context.Context, establishmentID int64, number string) (*model.Store, error)
 	Update(ctx context.Context, store *model.Store) error
+	// UpdateColumns writes only the given columns of store, named after their JSON fields, setting store.Version to the new version.
+	UpdateColumns(ctx context.Context, store *model.Store, columns []string) error
 	Delete(ctx context.Context, id int64) error
+	// Restore brings back a soft deleted store, returning the establishment it belongs to.
+	Restore(ctx context.Context, id int64) (int64, error)
+	// Purge permanently removes the stores deleted before the given time.
+	// It spans every tenant, so it needs a context from tenant.WithAllTenants.
+	Purge(ctx context.Context, before time.Time) (int64, error)
 }
 
 type storeRepository struct {
@@ -23,49 +46,273 @@ func NewStoreRepository(db *sql.DB) StoreRepository {
 	return &storeRepository{db}
 }
 
+// Create inserts the store in the tenant of ctx. The foreign key to its establishment includes the tenant,
+// so an establishment of another tenant is reported as missing.
 func (r *storeRepository) Create(ctx context.Context, s *model.Store) error {
-	query := `INSERT INTO stores (number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id)
-              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
-	return r.db.QueryRowContext(ctx, query, s.Number, s.Name, s.CorporateName, s.Address, s.City, s.State, s.ZipCode, s.AddressNumber, s.EstablishmentID).Scan(&s.ID)
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO stores (number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id, latitude, longitude, address_mismatch, tenant_id)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id, version`
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, s.Number, s.Name, s.CorporateName, s.Address, s.City, s.State, s.ZipCode, s.AddressNumber, s.EstablishmentID, s.Latitude, s.Longitude, s.AddressMismatch, tenantID).Scan(&s.ID, &s.Version)
+	return translateError(err)
 }
 
-func (r *storeRepository) FindAll(ctx context.Context) ([]model.Store, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT id, number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id FROM stores")
+func (r *storeRepository) FindAll(ctx context.Context, params model.ListParams) (*model.StorePage, error) {
+	q, err := storeListQuery(ctx, params)
 	if err != nil {
 		return nil, err
 	}
+
+	page := &model.StorePage{Items: []model.Store{}}
+	if err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM stores s "+q.whereClause(), q.args...).Scan(&page.Total); err != nil {
+		return nil, translateError(err)
+	}
+
+	if err := q.addCursor(params.Cursor); err != nil {
+		return nil, err
+	}
+	limit := listLimit(params.Limit)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, storesQuery(q)+" "+q.orderAndLimit(limit), q.args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
 	defer rows.Close()
-	var stores []model.Store
+	var sortValue, lastSortValue string
 	for rows.Next() {
 		var s model.Store
-		if err := rows.Scan(&s.ID, &s.Number, &s.Name, &s.CorporateName, &s.Address, &s.City, &s.State, &s.ZipCode, &s.AddressNumber, &s.EstablishmentID); err != nil {
-			return nil, err
+		if err := scanStore(rows, &s, &sortValue); err != nil {
+			return nil, translateError(err)
+		}
+		if len(page.Items) == limit {
+			page.NextCursor = util.EncodeCursor(util.Cursor{Sort: params.SortKey(), Value: lastSortValue, ID: page.Items[limit-1].ID})
+			break
+		}
+		page.Items = append(page.Items, s)
+		lastSortValue = sortValue
+	}
+	return page, translateError(rows.Err())
+}
+
+func (r *storeRepository) StreamAll(ctx context.Context, params model.ListParams, fn func(*model.Store) error) error {
+	q, err := storeListQuery(ctx, params)
+	if err != nil {
+		return err
+	}
+	rows, err := conn(ctx, r.db).QueryContext(ctx, storesQuery(q)+" "+q.orderClause(), q.args...)
+	if err != nil {
+		return translateError(err)
+	}
+	defer rows.Close()
+	var sortValue string
+	for rows.Next() {
+		var s model.Store
+		if err := scanStore(rows, &s, &sortValue); err != nil {
+			return translateError(err)
+		}
+		if err := fn(&s); err != nil {
+			return err
+		}
+	}
+	return translateError(rows.Err())
+}
+
+// storeListQuery builds the filters of the store list, which can also be narrowed to an establishment
+func storeListQuery(ctx context.Context, params model.ListParams) (*listQuery, error) {
+	q, err := newListQuery(ctx, "s", model.StoreSortFields, params)
+	if err != nil {
+		return nil, err
+	}
+	if params.EstablishmentID > 0 {
+		q.addFilter("s.establishment_id = $%d", params.EstablishmentID)
+	}
+	return q, nil
+}
+
+// storesQuery selects the stores matching q with their sort value
+func storesQuery(q *listQuery) string {
+	return "SELECT s.id, s.number, s.name, s.corporate_name, s.address, s.city, s.state, s.zip_code, s.address_number, s.establishment_id, s.latitude, s.longitude, s.address_mismatch, s.version, s.deleted_at, " +
+		q.sortColumn + "::text FROM stores s " + q.whereClause()
+}
+
+func scanStore(rows *sql.Rows, s *model.Store, sortValue *string) error {
+	return rows.Scan(&s.ID, &s.Number, &s.Name, &s.CorporateName, &s.Address, &s.City, &s.State, &s.ZipCode, &s.AddressNumber, &s.EstablishmentID, &s.Latitude, &s.Longitude, &s.AddressMismatch, &s.Version, &s.DeletedAt, sortValue)
+}
+
+// earthRadiusKm is the mean radius of the Earth used for distances
+const earthRadiusKm = 6371.0
+
+func (r *storeRepository) FindNearby(ctx context.Context, params model.NearbyParams) ([]model.StoreDistance, error) {
+	// The bounding box lets the coordinates index skip the stores that are certainly too far,
+	// and the haversine distance is only computed for the ones left
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	box := newBoundingBox(params.Latitude, params.Longitude, params.RadiusKm)
+	args := []interface{}{params.Latitude, params.Longitude, params.RadiusKm, box.minLat, box.maxLat, box.minLng, box.maxLng, listLimit(params.Limit), tenantID}
+	establishmentFilter := ""
+	if params.EstablishmentID > 0 {
+		args = append(args, params.EstablishmentID)
+		establishmentFilter = " AND s.establishment_id = $10"
+	}
+	query := `SELECT id, number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id, latitude, longitude, address_mismatch, version, distance_km
+		FROM (
+			SELECT s.*, 2 * ` + strconv.FormatFloat(earthRadiusKm, 'f', -1, 64) + ` * asin(least(1, sqrt(
+				power(sin(radians(s.latitude - $1) / 2), 2) +
+				cos(radians($1)) * cos(radians(s.latitude)) * power(sin(radians(s.longitude - $2) / 2), 2)
+			))) AS distance_km
+			FROM stores s
+			WHERE s.tenant_id = $9 AND s.deleted_at IS NULL AND s.latitude BETWEEN $4 AND $5 AND s.longitude BETWEEN $6 AND $7` + establishmentFilter + `
+		) s
+		WHERE distance_km <= $3
+		ORDER BY distance_km, id
+		LIMIT $8`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+	stores := []model.StoreDistance{}
+	for rows.Next() {
+		var s model.StoreDistance
+		if err := rows.Scan(&s.ID, &s.Number, &s.Name, &s.CorporateName, &s.Address, &s.City, &s.State, &s.ZipCode, &s.AddressNumber, &s.EstablishmentID, &s.Latitude, &s.Longitude, &s.AddressMismatch, &s.Version, &s.DistanceKm); err != nil {
+			return nil, translateError(err)
 		}
 		stores = append(stores, s)
 	}
-	return stores, nil
+	return stores, translateError(rows.Err())
+}
+
+// boundingBox holds the range of coordinates within some distance of a point
+type boundingBox struct {
+	minLat, maxLat, minLng, maxLng float64
+}
+
+// newBoundingBox returns the smallest box holding every point within radiusKm of the given one. Near the poles
+// and across the antimeridian the box spans every longitude instead of wrapping around.
+func newBoundingBox(lat, lng, radiusKm float64) boundingBox {
+	angle := radiusKm / earthRadiusKm
+	dLat := angle * 180 / math.Pi
+	box := boundingBox{minLat: lat - dLat, maxLat: lat + dLat, minLng: -180, maxLng: 180}
+	if box.minLat <= -90 || box.maxLat >= 90 {
+		box.minLat, box.maxLat = math.Max(box.minLat, -90), math.Min(box.maxLat, 90)
+		return box
+	}
+	dLng := math.Asin(math.Sin(angle)/math.Cos(lat*math.Pi/180)) * 180 / math.Pi
+	if lng-dLng >= -180 && lng+dLng <= 180 {
+		box.minLng, box.maxLng = lng-dLng, lng+dLng
+	}
+	return box
 }
 
 func (r *storeRepository) FindByID(ctx context.Context, id int64) (*model.Store, error) {
+	return r.findOne(ctx, "SELECT id, number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id, latitude, longitude, address_mismatch, version FROM stores WHERE id=$1 AND tenant_id=$2 AND deleted_at IS NULL", id)
+}
+
+func (r *storeRepository) FindByIDForUpdate(ctx context.Context, id int64) (*model.Store, error) {
+	return r.findOne(ctx, "SELECT id, number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id, latitude, longitude, address_mismatch, version FROM stores WHERE id=$1 AND tenant_id=$2 AND deleted_at IS NULL FOR UPDATE", id)
+}
+
+func (r *storeRepository) FindByNumber(ctx context.Context, establishmentID int64, number string) (*model.Store, error) {
+	return r.findOne(ctx, "SELECT id, number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id, latitude, longitude, address_mismatch, version FROM stores WHERE establishment_id=$1 AND number=$2 AND tenant_id=$3 AND deleted_at IS NULL", establishmentID, number)
+}
+
+// findOne runs a query selecting a single store by the given keys, which must take the tenant as its last argument.
+func (r *storeRepository) findOne(ctx context.Context, query string, keys ...interface{}) (*model.Store, error) {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
 	var s model.Store
-	err := r.db.QueryRowContext(ctx, "SELECT id, number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id FROM stores WHERE id=$1", id).
-		Scan(&s.ID, &s.Number, &s.Name, &s.CorporateName, &s.Address, &s.City, &s.State, &s.ZipCode, &s.AddressNumber, &s.EstablishmentID)
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, append(keys, tenantID)...).
+		Scan(&s.ID, &s.Number, &s.Name, &s.CorporateName, &s.Address, &s.City, &s.State, &s.ZipCode, &s.AddressNumber, &s.EstablishmentID, &s.Latitude, &s.Longitude, &s.AddressMismatch, &s.Version)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	return &s, nil
 }
 
 func (r *storeRepository) Update(ctx context.Context, s *model.Store) error {
-	_, err := r.db.ExecContext(ctx, `UPDATE stores SET number=$1, name=$2, corporate_name=$3, address=$4, city=$5, state=$6, zip_code=$7, address_number=$8, establishment_id=$9 WHERE id=$10`,
-		s.Number, s.Name, s.CorporateName, s.Address, s.City, s.State, s.ZipCode, s.AddressNumber, s.EstablishmentID, s.ID)
-	return err
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	err = conn(ctx, r.db).QueryRowContext(ctx, `UPDATE stores SET number=$1, name=$2, corporate_name=$3, address=$4, city=$5, state=$6, zip_code=$7, address_number=$8, establishment_id=$9, latitude=$10, longitude=$11, address_mismatch=$12, version=version+1 WHERE id=$13 AND tenant_id=$14 AND deleted_at IS NULL RETURNING version`,
+		s.Number, s.Name, s.CorporateName, s.Address, s.City, s.State, s.ZipCode, s.AddressNumber, s.EstablishmentID, s.Latitude, s.Longitude, s.AddressMismatch, s.ID, tenantID).Scan(&s.Version)
+	if err == sql.ErrNoRows {
+		return domainerr.NotFound("store", s.ID)
+	}
+	return translateError(err)
+}
+
+// storeColumns maps the columns a partial update may write to their value in a store
+var storeColumns = map[string]func(s *model.Store) interface{}{
+	"number":           func(s *model.Store) interface{} { return s.Number },
+	"name":             func(s *model.Store) interface{} { return s.Name },
+	"corporate_name":   func(s *model.Store) interface{} { return s.CorporateName },
+	"address":          func(s *model.Store) interface{} { return s.Address },
+	"city":             func(s *model.Store) interface{} { return s.City },
+	"state":            func(s *model.Store) interface{} { return s.State },
+	"zip_code":         func(s *model.Store) interface{} { return s.ZipCode },
+	"address_number":   func(s *model.Store) interface{} { return s.AddressNumber },
+	"establishment_id": func(s *model.Store) interface{} { return s.EstablishmentID },
+	"latitude":         func(s *model.Store) interface{} { return s.Latitude },
+	"longitude":        func(s *model.Store) interface{} { return s.Longitude },
+	"address_mismatch": func(s *model.Store) interface{} { return s.AddressMismatch },
+}
+
+func (r *storeRepository) UpdateColumns(ctx context.Context, s *model.Store, columns []string) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	query, args, err := updateColumnsQuery("stores", columns, storeColumns, s, s.ID, tenantID)
+	if err != nil {
+		return err
+	}
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, args...).Scan(&s.Version)
+	if err == sql.ErrNoRows {
+		return domainerr.NotFound("store", s.ID)
+	}
+	return translateError(err)
 }
 
 func (r *storeRepository) Delete(ctx context.Context, id int64) error {
-	_, err := r.db.ExecContext(ctx, "DELETE FROM stores WHERE id=$1", id)
-	return err
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE stores SET deleted_at = NOW(), version = version + 1 WHERE id=$1 AND tenant_id=$2 AND deleted_at IS NULL", id, tenantID)
+	if err != nil {
+		return translateError(err)
+	}
+	return requireAffected(res, "store", id)
+}
+
+func (r *storeRepository) Restore(ctx context.Context, id int64) (int64, error) {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var establishmentID int64
+	err = conn(ctx, r.db).QueryRowContext(ctx, "UPDATE stores SET deleted_at = NULL, version = version + 1 WHERE id=$1 AND tenant_id=$2 AND deleted_at IS NOT NULL RETURNING establishment_id", id, tenantID).
+		Scan(&establishmentID)
+	if err == sql.ErrNoRows {
+		return 0, domainerr.NotFound("deleted store", id)
+	}
+	return establishmentID, translateError(err)
+}
+
+func (r *storeRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM stores WHERE deleted_at < $1", before)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	n, err := res.RowsAffected()
+	return n, translateError(err)
 }
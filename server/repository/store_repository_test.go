@@ -1,30 +1,31 @@
 package repository
 
 import (
-	"context"
+	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
 	"github.com/yMaatheus/tech-challenge-snet/model"
 	"github.com/yMaatheus/tech-challenge-snet/testutil"
 )
 
 func TestStoreRepository_CRUD(t *testing.T) {
 	db := testutil.GetTestDB(t)
-
-	// Reset DB
-	testutil.ExecSQLFile(t, db, "../database/reset.sql")
-	testutil.ExecSQLFile(t, db, "../database/migration.sql")
+	resetDB(t, db)
 
 	// Cria Establishment dummy para FK
 	_, err := db.Exec(`
-		INSERT INTO establishments (number, name, corporate_name, address, city, state, zip_code, address_number)
-		VALUES ('E001', 'Est1', 'Corp', 'Rua', 'Cidade', 'ST', '12345678', '10')
+		INSERT INTO establishments (number, name, corporate_name, address, city, state, zip_code, address_number, tenant_id)
+		VALUES ('E001', 'Est1', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 'default')
 	`)
 	assert.NoError(t, err)
 
 	repo := NewStoreRepository(db)
-	ctx := context.Background()
+	ctx := tenantContext(tenant.Default)
 
 	// Create
 	store := &model.Store{
@@ -33,25 +34,36 @@ func TestStoreRepository_CRUD(t *testing.T) {
 		CorporateName:   "Corp Store",
 		Address:         "Rua A",
 		City:            "City",
-		State:           "ST",
-		ZipCode:         "000",
+		State:           "SP",
+		ZipCode:         "01310100",
 		AddressNumber:   "10",
 		EstablishmentID: 1, // agora existe
+		AddressMismatch: true,
 	}
 	err = repo.Create(ctx, store)
 	assert.NoError(t, err)
 	assert.True(t, store.ID > 0)
 
 	// FindAll
-	stores, err := repo.FindAll(ctx)
+	page, err := repo.FindAll(ctx, model.ListParams{})
 	assert.NoError(t, err)
-	assert.True(t, len(stores) >= 1)
+	assert.True(t, len(page.Items) >= 1)
+	assert.Equal(t, int64(1), page.Total)
 
 	// FindByID
 	got, err := repo.FindByID(ctx, store.ID)
 	assert.NoError(t, err)
 	assert.NotNil(t, got)
 	assert.Equal(t, "Loja Teste", got.Name)
+	assert.True(t, got.AddressMismatch)
+
+	// FindByNumber is scoped to the establishment
+	got, err = repo.FindByNumber(ctx, 1, "S001")
+	assert.NoError(t, err)
+	assert.Equal(t, store.ID, got.ID)
+	got, err = repo.FindByNumber(ctx, 2, "S001")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
 
 	// Update
 	store.Name = "Atualizada"
@@ -66,3 +78,218 @@ func TestStoreRepository_CRUD(t *testing.T) {
 	got, _ = repo.FindByID(ctx, store.ID)
 	assert.Nil(t, got)
 }
+
+func TestStoreRepository_FindAll_FilterByEstablishment(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	_, err := db.Exec(`
+		INSERT INTO establishments (number, name, corporate_name, address, city, state, zip_code, address_number, tenant_id)
+		VALUES ('E001', 'Est1', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 'default'),
+		       ('E002', 'Est2', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '20', 'default')
+	`)
+	assert.NoError(t, err)
+
+	repo := NewStoreRepository(db)
+	ctx := tenantContext(tenant.Default)
+	for i, estID := range []int64{1, 1, 1, 2} {
+		err := repo.Create(ctx, &model.Store{
+			Number: fmt.Sprintf("S%03d", i+1), Name: "Loja", Address: "Rua", City: "City",
+			State: "SP", ZipCode: "01310100", AddressNumber: "1", EstablishmentID: estID,
+		})
+		assert.NoError(t, err)
+	}
+
+	page, err := repo.FindAll(ctx, model.ListParams{EstablishmentID: 1, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), page.Total)
+	assert.Len(t, page.Items, 2)
+	assert.NotEmpty(t, page.NextCursor)
+
+	page, err = repo.FindAll(ctx, model.ListParams{EstablishmentID: 1, Limit: 2, Cursor: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, int64(1), page.Items[0].EstablishmentID)
+	assert.Empty(t, page.NextCursor)
+
+	var numbers []string
+	err = repo.StreamAll(ctx, model.ListParams{EstablishmentID: 1}, func(s *model.Store) error {
+		numbers = append(numbers, s.Number)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"S001", "S002", "S003"}, numbers)
+}
+
+func TestStoreRepository_Create_DuplicateNumberPerEstablishment(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	_, err := db.Exec(`
+		INSERT INTO establishments (number, name, corporate_name, address, city, state, zip_code, address_number, tenant_id)
+		VALUES ('E001', 'Est1', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 'default'),
+		       ('E002', 'Est2', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '20', 'default')
+	`)
+	assert.NoError(t, err)
+
+	repo := NewStoreRepository(db)
+	ctx := tenantContext(tenant.Default)
+	newStore := func(estID int64) *model.Store {
+		return &model.Store{
+			Number: "44555666000181", Name: "Loja", Address: "Rua", City: "City",
+			State: "SP", ZipCode: "01310100", AddressNumber: "1", EstablishmentID: estID,
+		}
+	}
+	assert.NoError(t, repo.Create(ctx, newStore(1)))
+
+	// Same number under another establishment is allowed
+	assert.NoError(t, repo.Create(ctx, newStore(2)))
+
+	err = repo.Create(ctx, newStore(1))
+	var conflict *domainerr.ConflictError
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "number", conflict.Field)
+}
+
+func TestStoreRepository_UpdateDelete_NotFound(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	repo := NewStoreRepository(db)
+	ctx := tenantContext(tenant.Default)
+
+	err := repo.Update(ctx, &model.Store{ID: 999999, Number: "44555666000181", Name: "Ghost", EstablishmentID: 1})
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+
+	err = repo.Delete(ctx, 999999)
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+}
+
+func TestStoreRepository_Create_MissingEstablishment(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	repo := NewStoreRepository(db)
+
+	err := repo.Create(tenantContext(tenant.Default), &model.Store{
+		Number: "44555666000181", Name: "Loja", Address: "Rua", City: "City",
+		State: "SP", ZipCode: "01310100", AddressNumber: "1", EstablishmentID: 999999,
+	})
+	var fk *domainerr.ForeignKeyError
+	assert.ErrorAs(t, err, &fk)
+	assert.Equal(t, "establishment_id", fk.Field)
+}
+
+func TestStoreRepository_SoftDeleteRestoreAndPurge(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	_, err := db.Exec(`
+		INSERT INTO establishments (number, name, corporate_name, address, city, state, zip_code, address_number, tenant_id)
+		VALUES ('E001', 'Est1', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 'default');
+		INSERT INTO stores (number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id, tenant_id)
+		VALUES ('S001', 'Loja 1', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 1, 'default'),
+		       ('S002', 'Loja 2', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 1, 'default');
+	`)
+	assert.NoError(t, err)
+	repo := NewStoreRepository(db)
+	ctx := tenantContext(tenant.Default)
+
+	assert.NoError(t, repo.Delete(ctx, 1))
+	got, err := repo.FindByID(ctx, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, got, "deleted stores are hidden")
+	err = repo.Update(ctx, &model.Store{ID: 1, Number: "S001", Name: "X", EstablishmentID: 1})
+	assert.ErrorIs(t, err, domainerr.ErrNotFound, "deleted stores cannot be updated")
+
+	page, err := repo.FindAll(ctx, model.ListParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.Total)
+	page, err = repo.FindAll(ctx, model.ListParams{IncludeDeleted: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total)
+	assert.NotNil(t, page.Items[0].DeletedAt)
+	assert.Nil(t, page.Items[1].DeletedAt)
+
+	establishmentID, err := repo.Restore(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), establishmentID)
+	_, err = repo.Restore(ctx, 1)
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+
+	_, err = db.Exec("UPDATE stores SET deleted_at = NOW() - INTERVAL '40 days' WHERE id = 2")
+	assert.NoError(t, err)
+	n, err := repo.Purge(ctx, time.Now().Add(-30*24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	var count int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM stores").Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
+func TestStoreRepository_FindNearby(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	_, err := db.Exec(`
+		INSERT INTO establishments (number, name, corporate_name, address, city, state, zip_code, address_number, tenant_id)
+		VALUES ('E001', 'Est1', 'Corp', 'Rua', 'São Paulo', 'SP', '01310100', '10', 'default'),
+		       ('E002', 'Est2', 'Corp', 'Rua', 'Campinas', 'SP', '13015000', '10', 'default')
+	`)
+	assert.NoError(t, err)
+
+	repo := NewStoreRepository(db)
+	ctx := tenantContext(tenant.Default)
+	// newStore creates a store at the given latitude and longitude, if any
+	newStore := func(number string, establishmentID int64, coordinates ...float64) *model.Store {
+		s := &model.Store{Number: number, Name: "Loja " + number, Address: "Rua", City: "Cidade", State: "SP",
+			ZipCode: "00000000", AddressNumber: "1", EstablishmentID: establishmentID}
+		if len(coordinates) == 2 {
+			s.Latitude, s.Longitude = &coordinates[0], &coordinates[1]
+		}
+		assert.NoError(t, repo.Create(ctx, s))
+		return s
+	}
+	paulista := newStore("S001", 1, -23.5613, -46.6565)
+	se := newStore("S002", 1, -23.5503, -46.6340)
+	campinas := newStore("S003", 2, -22.9056, -47.0608)
+	newStore("S004", 1)
+	deleted := newStore("S005", 1, -23.5613, -46.6565)
+	assert.NoError(t, repo.Delete(ctx, deleted.ID))
+
+	ids := func(stores []model.StoreDistance) []int64 {
+		ids := make([]int64, len(stores))
+		for i, s := range stores {
+			ids[i] = s.ID
+		}
+		return ids
+	}
+
+	stores, err := repo.FindNearby(ctx, model.NearbyParams{Latitude: -23.5613, Longitude: -46.6565, RadiusKm: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{paulista.ID, se.ID}, ids(stores))
+	assert.InDelta(t, 0, stores[0].DistanceKm, 0.001)
+	assert.InDelta(t, 2.6, stores[1].DistanceKm, 0.1)
+	assert.Equal(t, -23.5503, *stores[1].Latitude)
+
+	stores, err = repo.FindNearby(ctx, model.NearbyParams{Latitude: -23.5613, Longitude: -46.6565, RadiusKm: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{paulista.ID, se.ID, campinas.ID}, ids(stores))
+
+	stores, err = repo.FindNearby(ctx, model.NearbyParams{Latitude: -23.5613, Longitude: -46.6565, RadiusKm: 100, EstablishmentID: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{campinas.ID}, ids(stores))
+
+	stores, err = repo.FindNearby(ctx, model.NearbyParams{Latitude: -23.5613, Longitude: -46.6565, RadiusKm: 100, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{paulista.ID}, ids(stores))
+}
+
+func TestNewBoundingBox(t *testing.T) {
+	box := newBoundingBox(-23.5613, -46.6565, 10)
+	assert.InDelta(t, -23.6512, box.minLat, 0.001)
+	assert.InDelta(t, -23.4714, box.maxLat, 0.001)
+	assert.InDelta(t, -46.7546, box.minLng, 0.001)
+	assert.InDelta(t, -46.5584, box.maxLng, 0.001)
+
+	// Boxes around a pole or across the antimeridian span every longitude
+	box = newBoundingBox(89.95, 10, 10)
+	assert.Equal(t, boundingBox{minLat: 89.95 - 10/earthRadiusKm*180/math.Pi, maxLat: 90, minLng: -180, maxLng: 180}, box)
+	box = newBoundingBox(0, 179.99, 10)
+	assert.Equal(t, -180.0, box.minLng)
+	assert.Equal(t, 180.0, box.maxLng)
+}
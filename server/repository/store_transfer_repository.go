@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+// StoreTransferRepository stores the history of stores moved between establishments.
+type StoreTransferRepository interface {
+	Create(ctx context.Context, t *model.StoreTransfer) error
+	FindByStoreID(ctx context.Context, storeID int64) ([]model.StoreTransfer, error)
+}
+
+type storeTransferRepository struct {
+	db *sql.DB
+}
+
+func NewStoreTransferRepository(db *sql.DB) StoreTransferRepository {
+	return &storeTransferRepository{db}
+}
+
+func (r *storeTransferRepository) Create(ctx context.Context, t *model.StoreTransfer) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO store_transfers (store_id, from_establishment_id, to_establishment_id, reason, transferred_by, tenant_id)
+              VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, transferred_at`
+	err = conn(ctx, r.db).QueryRowContext(ctx, query, t.StoreID, t.FromEstablishmentID, t.ToEstablishmentID, t.Reason, t.TransferredBy, tenantID).
+		Scan(&t.ID, &t.TransferredAt)
+	return translateError(err)
+}
+
+func (r *storeTransferRepository) FindByStoreID(ctx context.Context, storeID int64) ([]model.StoreTransfer, error) {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT id, store_id, from_establishment_id, to_establishment_id, reason, transferred_by, transferred_at
+		FROM store_transfers WHERE store_id=$1 AND tenant_id=$2 ORDER BY transferred_at DESC, id DESC`, storeID, tenantID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+	transfers := []model.StoreTransfer{}
+	for rows.Next() {
+		var t model.StoreTransfer
+		if err := rows.Scan(&t.ID, &t.StoreID, &t.FromEstablishmentID, &t.ToEstablishmentID, &t.Reason, &t.TransferredBy, &t.TransferredAt); err != nil {
+			return nil, translateError(err)
+		}
+		transfers = append(transfers, t)
+	}
+	return transfers, translateError(rows.Err())
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/testutil"
+)
+
+func TestStoreTransferRepository_CreateAndFindByStoreID(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	_, err := db.Exec(`
+		INSERT INTO establishments (number, name, corporate_name, address, city, state, zip_code, address_number, tenant_id)
+		VALUES ('E001', 'Est1', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 'default'),
+		       ('E002', 'Est2', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 'default');
+		INSERT INTO stores (number, name, corporate_name, address, city, state, zip_code, address_number, establishment_id, tenant_id)
+		VALUES ('S001', 'Loja', 'Corp', 'Rua', 'Cidade', 'SP', '12345678', '10', 1, 'default');
+	`)
+	assert.NoError(t, err)
+
+	repo := NewStoreTransferRepository(db)
+	ctx := tenantContext(tenant.Default)
+
+	first := &model.StoreTransfer{StoreID: 1, FromEstablishmentID: 1, ToEstablishmentID: 2, Reason: "ida", TransferredBy: "maria"}
+	assert.NoError(t, repo.Create(ctx, first))
+	assert.NotZero(t, first.ID)
+	assert.False(t, first.TransferredAt.IsZero())
+	second := &model.StoreTransfer{StoreID: 1, FromEstablishmentID: 2, ToEstablishmentID: 1, Reason: "volta", TransferredBy: "joao"}
+	assert.NoError(t, repo.Create(ctx, second))
+
+	transfers, err := repo.FindByStoreID(ctx, 1)
+	assert.NoError(t, err)
+	assert.Len(t, transfers, 2)
+	assert.Equal(t, second.ID, transfers[0].ID, "newest transfer comes first")
+	assert.Equal(t, "maria", transfers[1].TransferredBy)
+
+	transfers, err = repo.FindByStoreID(ctx, 999)
+	assert.NoError(t, err)
+	assert.Empty(t, transfers)
+}
+
+func TestTransactor_WithinTx_RollsBackOnError(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	repo := NewEstablishmentRepository(db)
+	tx := NewTransactor(db)
+	ctx := tenantContext(tenant.Default)
+
+	failure := errors.New("abort")
+	err := tx.WithinTx(ctx, func(ctx context.Context) error {
+		est := &model.Establishment{Number: "T123", Name: "Rollback", CorporateName: "Corp", Address: "Rua", City: "Cidade", State: "SP", ZipCode: "01310100", AddressNumber: "1"}
+		if err := repo.Create(ctx, est); err != nil {
+			return err
+		}
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+
+	var count int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM establishments").Scan(&count))
+	assert.Zero(t, count)
+}
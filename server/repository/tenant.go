@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+)
+
+// errNoTenant is returned by queries run with a context that is not scoped to a tenant, which is a bug
+// in the caller: every request is scoped by the HTTP layer and maintenance jobs use tenant.WithAllTenants.
+var errNoTenant = errors.New("repository: the context is not scoped to a tenant")
+
+// tenantOf returns the tenant the queries of ctx are scoped to.
+func tenantOf(ctx context.Context) (string, error) {
+	id, ok := tenant.FromContext(ctx)
+	if !ok {
+		return "", errNoTenant
+	}
+	return id, nil
+}
+
+// TenantScoper scopes the queries of a request to a tenant. Each transaction of the request sets the tenant
+// in its session with set_config(..., true), for the row-level security policies of the schema to check on
+// top of the tenant filter of each query, so no connection is held between the transactions of a request.
+type TenantScoper interface {
+	// WithinTenant runs fn with a context scoped to tenantID.
+	WithinTenant(ctx context.Context, tenantID string, fn func(ctx context.Context) error) error
+}
+
+type sqlTenantScoper struct{}
+
+func NewTenantScoper() TenantScoper {
+	return sqlTenantScoper{}
+}
+
+func (sqlTenantScoper) WithinTenant(ctx context.Context, tenantID string, fn func(ctx context.Context) error) error {
+	return fn(tenant.WithTenant(ctx, tenantID))
+}
+
+// scopeTx sets the tenant of ctx, or access to every tenant, for the duration of tx. Statements run outside
+// a transaction see no rows of the tables under row-level security, so the services read them within one.
+func scopeTx(ctx context.Context, tx *sql.Tx) error {
+	if tenant.AllTenants(ctx) {
+		_, err := tx.ExecContext(ctx, "SELECT set_config('app.all_tenants', 'on', true)")
+		return err
+	}
+	if id, ok := tenant.FromContext(ctx); ok {
+		_, err := tx.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, true)", id)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/testutil"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+	establishments := NewEstablishmentRepository(db)
+	stores := NewStoreRepository(db)
+	acme, globex := tenantContext("acme"), tenantContext("globex")
+
+	est := &model.Establishment{Number: "11222333000181", Name: "Acme", CorporateName: "Acme SA", Address: "Rua", City: "São Paulo", State: "SP", ZipCode: "01310100", AddressNumber: "1"}
+	require.NoError(t, establishments.Create(acme, est))
+	store := &model.Store{Number: "22333444000155", Name: "Loja Acme", Address: "Rua", City: "São Paulo", State: "SP", ZipCode: "01310100", AddressNumber: "2", EstablishmentID: est.ID}
+	require.NoError(t, stores.Create(acme, store))
+
+	// Another tenant cannot read the rows
+	got, err := stores.FindByID(globex, store.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+	page, err := stores.FindAll(globex, model.ListParams{})
+	assert.NoError(t, err)
+	assert.Zero(t, page.Total)
+	all, err := establishments.FindAll(globex)
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+	gotEst, err := establishments.FindByID(globex, est.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, gotEst)
+	results, err := NewSearchRepository(db).Search(globex, model.SearchParams{Query: "acme", Terms: []string{"acme"}})
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	// nor change them
+	assert.ErrorIs(t, stores.Update(globex, &model.Store{ID: store.ID, Number: "22333444000155", Name: "Invadida", EstablishmentID: est.ID}), domainerr.ErrNotFound)
+	assert.ErrorIs(t, stores.UpdateColumns(globex, &model.Store{ID: store.ID, Name: "Invadida"}, []string{"name"}), domainerr.ErrNotFound)
+	assert.ErrorIs(t, stores.Delete(globex, store.ID), domainerr.ErrNotFound)
+	assert.ErrorIs(t, establishments.Delete(globex, est.ID), domainerr.ErrNotFound)
+
+	// nor create stores under an establishment of another tenant
+	err = stores.Create(globex, &model.Store{Number: "33444555000166", Name: "Intrusa", Address: "Rua", City: "São Paulo", State: "SP", ZipCode: "01310100", AddressNumber: "3", EstablishmentID: est.ID})
+	var fk *domainerr.ForeignKeyError
+	assert.ErrorAs(t, err, &fk)
+
+	got, err = stores.FindByID(acme, store.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Loja Acme", got.Name, "the owner still sees its store unchanged")
+
+	// Numbers are only unique within a tenant
+	assert.NoError(t, establishments.Create(globex, &model.Establishment{Number: est.Number, Name: "Globex", CorporateName: "Globex SA", Address: "Rua", City: "São Paulo", State: "SP", ZipCode: "01310100", AddressNumber: "1"}))
+}
+
+func TestTenantScoper_WithinTenant(t *testing.T) {
+	db := testutil.GetTestDB(t)
+	resetDB(t, db)
+
+	err := NewTenantScoper().WithinTenant(context.Background(), "acme", func(ctx context.Context) error {
+		return NewTransactor(db).WithinTx(ctx, func(ctx context.Context) error {
+			var setting string
+			if err := conn(ctx, db).QueryRowContext(ctx, "SELECT current_setting('app.tenant_id')").Scan(&setting); err != nil {
+				return err
+			}
+			assert.Equal(t, "acme", setting)
+			return NewEstablishmentRepository(db).Create(ctx, &model.Establishment{Number: "11222333000181", Name: "Acme", CorporateName: "Acme SA", Address: "Rua", City: "São Paulo", State: "SP", ZipCode: "01310100", AddressNumber: "1"})
+		})
+	})
+	assert.NoError(t, err)
+
+	var owner string
+	assert.NoError(t, db.QueryRow("SELECT tenant_id FROM establishments").Scan(&owner))
+	assert.Equal(t, "acme", owner)
+
+	// The tenant only lasts for the transaction, so the connection goes back to the pool without it
+	var setting string
+	assert.NoError(t, db.QueryRow("SELECT COALESCE(current_setting('app.tenant_id', true), '')").Scan(&setting))
+	assert.Empty(t, setting)
+
+	_, err = NewEstablishmentRepository(db).FindAll(context.Background())
+	assert.ErrorIs(t, err, errNoTenant)
+}
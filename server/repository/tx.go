@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DBTX is the subset of *sql.DB and *sql.Tx the repositories use, so the same
+// queries run either standalone or inside a transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Transactor runs a function inside a database transaction. Repositories called
+// with the context handed to the function join that transaction.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+type sqlTransactor struct {
+	db *sql.DB
+}
+
+func NewTransactor(db *sql.DB) Transactor {
+	return &sqlTransactor{db}
+}
+
+// WithinTx commits when fn succeeds and rolls back when it fails. Nested calls
+// join the outer transaction instead of opening a new one. The transaction is scoped
+// to the tenant of ctx.
+func (t *sqlTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return translateError(err)
+	}
+	if err := scopeTx(ctx, tx); err != nil {
+		_ = tx.Rollback()
+		return translateError(err)
+	}
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return translateError(tx.Commit())
+}
+
+// conn returns the transaction carried by ctx, or db when there is none.
+func conn(ctx context.Context, db *sql.DB) DBTX {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
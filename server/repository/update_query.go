@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+)
+
+// updateColumnsQuery builds the UPDATE of a partial write, setting only the given columns of the entity
+// with the given id and tenant and bumping its version. Only the columns in writable are accepted, which keeps caller supplied
+// names out of the SQL.
+func updateColumnsQuery[T any](table string, columns []string, writable map[string]func(T) interface{}, entity T, id int64, tenantID string) (string, []interface{}, error) {
+	if len(columns) == 0 {
+		return "", nil, fmt.Errorf("update %s: no columns to write", table)
+	}
+	set := make([]string, 0, len(columns)+1)
+	args := make([]interface{}, 0, len(columns)+1)
+	for _, column := range columns {
+		value, ok := writable[column]
+		if !ok {
+			return "", nil, fmt.Errorf("update %s: column %q cannot be written", table, column)
+		}
+		args = append(args, value(entity))
+		set = append(set, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	set = append(set, "version = version + 1")
+	args = append(args, id, tenantID)
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d AND tenant_id = $%d AND deleted_at IS NULL RETURNING version", table, strings.Join(set, ", "), len(args)-1, len(args))
+	return query, args, nil
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+func TestUpdateColumnsQuery(t *testing.T) {
+	s := &model.Store{ID: 7, Name: "Loja", EstablishmentID: 2}
+	query, args, err := updateColumnsQuery("stores", []string{"name", "establishment_id"}, storeColumns, s, s.ID, "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE stores SET name = $1, establishment_id = $2, version = version + 1 WHERE id = $3 AND tenant_id = $4 AND deleted_at IS NULL RETURNING version", query)
+	assert.Equal(t, []interface{}{"Loja", int64(2), int64(7), "acme"}, args)
+}
+
+func TestUpdateColumnsQuery_ColunaNaoPermitida(t *testing.T) {
+	e := &model.Establishment{ID: 1}
+	_, _, err := updateColumnsQuery("establishments", []string{"name; DROP TABLE establishments"}, establishmentColumns, e, e.ID, "acme")
+	assert.Error(t, err)
+	_, _, err = updateColumnsQuery("establishments", []string{"version"}, establishmentColumns, e, e.ID, "acme")
+	assert.Error(t, err)
+	_, _, err = updateColumnsQuery("establishments", nil, establishmentColumns, e, e.ID, "acme")
+	assert.Error(t, err)
+}
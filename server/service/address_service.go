@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/yMaatheus/tech-challenge-snet/cep"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/util"
+)
+
+// AddressService looks up addresses by zip code (CEP) and checks the addresses of records against them.
+type AddressService interface {
+	// Lookup returns the address of a zip code written as 12345-678 or 12345678.
+	Lookup(ctx context.Context, zipCode string) (*model.Address, error)
+	// Check compares a city and state with the address of the zip code, following the check mode. In flag
+	// mode it reports whether they disagree, and in reject mode it fails with a validation error when they do.
+	// With checks off it never reports a mismatch.
+	Check(ctx context.Context, zipCode, city, state string) (bool, error)
+}
+
+type addressService struct {
+	provider cep.Provider
+	mode     string
+}
+
+// NewAddressService creates the address service, checking records in one of model.AddressCheckModes.
+func NewAddressService(provider cep.Provider, mode string) AddressService {
+	return &addressService{provider: provider, mode: mode}
+}
+
+func (s *addressService) Lookup(ctx context.Context, zipCode string) (*model.Address, error) {
+	normalized, err := cep.Normalize(zipCode)
+	if err != nil {
+		return nil, domainerr.ValidationFields(map[string]string{"cep": "must have 8 digits, as 12345-678 or 12345678"})
+	}
+	address, err := s.provider.Lookup(ctx, normalized)
+	if errors.Is(err, cep.ErrNotFound) {
+		return nil, domainerr.NotFound("address", 0)
+	}
+	if err != nil {
+		return nil, domainerr.Unavailable(err)
+	}
+	return address, nil
+}
+
+// Check only fails in reject mode, where a provider failure is reported as unavailable instead of
+// letting an unchecked record through. Flag mode saves records it could not check without the flag.
+func (s *addressService) Check(ctx context.Context, zipCode, city, state string) (bool, error) {
+	if s.mode == model.AddressCheckOff {
+		return false, nil
+	}
+	reject := func(fields map[string]string) (bool, error) {
+		if s.mode == model.AddressCheckReject {
+			return false, domainerr.ValidationFields(fields)
+		}
+		return true, nil
+	}
+
+	normalized, err := cep.Normalize(zipCode)
+	if err != nil {
+		return reject(map[string]string{"zip_code": "must have 8 digits, as 12345-678 or 12345678"})
+	}
+	address, err := s.provider.Lookup(ctx, normalized)
+	if errors.Is(err, cep.ErrNotFound) {
+		return reject(map[string]string{"zip_code": "was not found"})
+	}
+	if err != nil {
+		if s.mode == model.AddressCheckReject {
+			return false, domainerr.Unavailable(err)
+		}
+		return false, nil
+	}
+
+	mismatches := map[string]string{}
+	// Accents and case are not worth flagging, only a different place is
+	if util.Fold(strings.TrimSpace(city)) != util.Fold(address.City) {
+		mismatches["city"] = "does not match the zip code, which is in " + address.City
+	}
+	if !strings.EqualFold(strings.TrimSpace(state), address.State) {
+		mismatches["state"] = "does not match the zip code, which is in " + address.State
+	}
+	if len(mismatches) == 0 {
+		return false, nil
+	}
+	return reject(mismatches)
+}
+
+// checkAddress checks the address of a record when the service has an address service
+func checkAddress(ctx context.Context, addresses AddressService, zipCode, city, state string) (bool, error) {
+	if addresses == nil {
+		return false, nil
+	}
+	return addresses.Check(ctx, zipCode, city, state)
+}
+
+// recheckAddress checks the address of a record again when a patch changed its zip code, city or state,
+// adding address_mismatch to the patched columns when the flag changes.
+func recheckAddress(ctx context.Context, addresses AddressService, columns []string, zipCode, city, state string, mismatch *bool) ([]string, error) {
+	if !slices.ContainsFunc(columns, func(c string) bool { return c == "zip_code" || c == "city" || c == "state" }) {
+		return columns, nil
+	}
+	flagged, err := checkAddress(ctx, addresses, zipCode, city, state)
+	if err != nil || flagged == *mismatch {
+		return columns, err
+	}
+	*mismatch = flagged
+	columns = append(columns, "address_mismatch")
+	sort.Strings(columns)
+	return columns, nil
+}
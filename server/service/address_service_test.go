@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yMaatheus/tech-challenge-snet/cep"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+// mockCEPProvider looks up with the function itself
+type mockCEPProvider func(ctx context.Context, cep string) (*model.Address, error)
+
+func (m mockCEPProvider) Lookup(ctx context.Context, cep string) (*model.Address, error) {
+	return m(ctx, cep)
+}
+
+// testAddresses is an address service over an offline provider that knows the CEPs 01310-100 and 50000-000
+func testAddresses(t *testing.T, mode string) AddressService {
+	provider, err := cep.ReadFileProvider(strings.NewReader("cep,street,neighborhood,city,state\n" +
+		"01310-100,Avenida Paulista,Bela Vista,São Paulo,SP\n50000-000,Rua da Aurora,Boa Vista,Recife,PE\n"))
+	require.NoError(t, err)
+	return NewAddressService(provider, mode)
+}
+
+func TestAddressService_Lookup(t *testing.T) {
+	service := testAddresses(t, model.AddressCheckOff)
+
+	address, err := service.Lookup(context.Background(), "01310-100")
+	require.NoError(t, err)
+	assert.Equal(t, &model.Address{CEP: "01310100", Street: "Avenida Paulista", Neighborhood: "Bela Vista", City: "São Paulo", State: "SP"}, address)
+
+	_, err = service.Lookup(context.Background(), "99999-999")
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+
+	_, err = service.Lookup(context.Background(), "0131")
+	assert.ErrorIs(t, err, domainerr.ErrValidation)
+
+	service = NewAddressService(mockCEPProvider(func(ctx context.Context, cep string) (*model.Address, error) {
+		return nil, errors.New("timeout")
+	}), model.AddressCheckOff)
+	_, err = service.Lookup(context.Background(), "01310100")
+	assert.ErrorIs(t, err, domainerr.ErrUnavailable)
+}
+
+func TestAddressService_Check(t *testing.T) {
+	flag := testAddresses(t, model.AddressCheckFlag)
+	reject := testAddresses(t, model.AddressCheckReject)
+	ctx := context.Background()
+
+	// Case, accents and formatting do not count as a mismatch
+	for _, service := range []AddressService{flag, reject} {
+		mismatch, err := service.Check(ctx, "01310100", " sao paulo ", "sp")
+		assert.NoError(t, err)
+		assert.False(t, mismatch)
+	}
+
+	mismatch, err := flag.Check(ctx, "01310-100", "Recife", "SP")
+	assert.NoError(t, err)
+	assert.True(t, mismatch)
+	mismatch, err = flag.Check(ctx, "99999999", "Recife", "PE")
+	assert.NoError(t, err)
+	assert.True(t, mismatch)
+
+	_, err = reject.Check(ctx, "01310-100", "Recife", "PE")
+	var validation *domainerr.ValidationError
+	require.ErrorAs(t, err, &validation)
+	assert.Equal(t, map[string]string{
+		"city":  "does not match the zip code, which is in São Paulo",
+		"state": "does not match the zip code, which is in SP",
+	}, validation.Fields)
+	_, err = reject.Check(ctx, "99999999", "Recife", "PE")
+	require.ErrorAs(t, err, &validation)
+	assert.Equal(t, map[string]string{"zip_code": "was not found"}, validation.Fields)
+
+	mismatch, err = testAddresses(t, model.AddressCheckOff).Check(ctx, "99999999", "Recife", "PE")
+	assert.NoError(t, err)
+	assert.False(t, mismatch)
+}
+
+func TestAddressService_Check_ProvedorIndisponivel(t *testing.T) {
+	provider := mockCEPProvider(func(ctx context.Context, cep string) (*model.Address, error) {
+		return nil, errors.New("timeout")
+	})
+
+	mismatch, err := NewAddressService(provider, model.AddressCheckFlag).Check(context.Background(), "01310100", "São Paulo", "SP")
+	assert.NoError(t, err)
+	assert.False(t, mismatch)
+
+	_, err = NewAddressService(provider, model.AddressCheckReject).Check(context.Background(), "01310100", "São Paulo", "SP")
+	assert.ErrorIs(t, err, domainerr.ErrUnavailable)
+}
+
+func TestEstablishmentService_Create_VerificaEndereco(t *testing.T) {
+	service := NewEstablishmentService(&mockRepo{}, &mockTx{}, &mockAuditRepo{}, testAddresses(t, model.AddressCheckFlag))
+	est := &model.Establishment{Name: "Loja", City: "Recife", State: "PE", ZipCode: "01310-100"}
+	require.NoError(t, service.Create(context.Background(), est))
+	assert.True(t, est.AddressMismatch)
+
+	service = NewEstablishmentService(&mockRepo{}, &mockTx{}, &mockAuditRepo{}, testAddresses(t, model.AddressCheckReject))
+	est = &model.Establishment{Name: "Loja", City: "Recife", State: "PE", ZipCode: "01310-100"}
+	assert.ErrorIs(t, service.Create(context.Background(), est), domainerr.ErrValidation)
+	assert.Zero(t, est.ID)
+}
+
+func TestEstablishmentService_Patch_VerificaEndereco(t *testing.T) {
+	repo := establishmentForPatch()
+	service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, testAddresses(t, model.AddressCheckFlag))
+
+	est, err := service.Patch(context.Background(), 1, 2, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"zip_code": "01310-100"}`)})
+	require.NoError(t, err)
+	assert.True(t, est.AddressMismatch)
+	assert.Equal(t, []string{"address_mismatch", "zip_code"}, repo.updatedColumns)
+
+	// Patches that leave the address alone do not look it up again
+	repo = establishmentForPatch()
+	repo.findByIDResult.ZipCode = "99999999"
+	service = NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, testAddresses(t, model.AddressCheckReject))
+	_, err = service.Patch(context.Background(), 1, 2, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"name": "Nova"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name"}, repo.updatedColumns)
+
+	// The flag is managed by the service
+	_, err = service.Patch(context.Background(), 1, 2, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"address_mismatch": true}`)})
+	assert.ErrorIs(t, err, domainerr.ErrValidation)
+}
+
+func TestStoreService_Update_VerificaEndereco(t *testing.T) {
+	var saved model.Store
+	repo := &mockStoreRepo{
+		FindByIDFn: existingStore,
+		UpdateFn: func(ctx context.Context, s *model.Store) error {
+			saved = *s
+			return nil
+		},
+	}
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, testAddresses(t, model.AddressCheckFlag))
+
+	require.NoError(t, service.Update(context.Background(), &model.Store{ID: 1, EstablishmentID: 1, City: "Sao Paulo", State: "SP", ZipCode: "01310100", AddressMismatch: true}))
+	assert.False(t, saved.AddressMismatch)
+	require.NoError(t, service.Update(context.Background(), &model.Store{ID: 1, EstablishmentID: 1, City: "Recife", State: "PE", ZipCode: "01310100"}))
+	assert.True(t, saved.AddressMismatch)
+}
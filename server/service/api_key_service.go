@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	"github.com/yMaatheus/tech-challenge-snet/domain/actor"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/repository"
+)
+
+// APIKeyService manages the API keys of machine-to-machine integrations and authenticates the requests
+// sent with them.
+type APIKeyService interface {
+	// Create issues a key in the tenant of ctx. The key itself is only returned here.
+	Create(ctx context.Context, req model.APIKeyRequest) (*model.CreatedAPIKey, error)
+	FindAll(ctx context.Context) ([]model.APIKey, error)
+	Revoke(ctx context.Context, id int64) error
+	// Rotate issues a key replacing the given one, with the same name, scopes and expiry. The old key keeps
+	// working for the overlap of req, so integrations can switch to the new key without downtime.
+	Rotate(ctx context.Context, id int64, req model.APIKeyRotateRequest) (*model.CreatedAPIKey, error)
+	// Authenticate returns the principal of an active key, failing with auth.ErrInvalidAPIKey for unknown
+	// keys and auth.ErrExpiredAPIKey for expired or revoked ones.
+	Authenticate(ctx context.Context, key string) (auth.Principal, error)
+}
+
+type apiKeyService struct {
+	tx   repository.Transactor
+	repo repository.APIKeyRepository
+	now  func() time.Time
+}
+
+func NewAPIKeyService(tx repository.Transactor, repo repository.APIKeyRepository) APIKeyService {
+	return &apiKeyService{tx: tx, repo: repo, now: time.Now}
+}
+
+func (s *apiKeyService) Create(ctx context.Context, req model.APIKeyRequest) (*model.CreatedAPIKey, error) {
+	fields := map[string]string{}
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		fields["scopes"] = err.Error()
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(s.now()) {
+		fields["expires_at"] = "must be in the future"
+	}
+	if len(fields) > 0 {
+		return nil, domainerr.ValidationFields(fields)
+	}
+	return s.issue(ctx, &model.APIKey{Name: strings.TrimSpace(req.Name), Scopes: scopes, ExpiresAt: req.ExpiresAt})
+}
+
+// normalizeScopes checks that every scope is known, dropping the repeated ones
+func normalizeScopes(scopes []string) ([]string, error) {
+	var normalized []string
+	for _, scope := range scopes {
+		if !auth.Scope(scope).Valid() {
+			known := make([]string, len(auth.Scopes))
+			for i, s := range auth.Scopes {
+				known[i] = string(s)
+			}
+			return nil, domainerr.Validation("must only hold the scopes " + strings.Join(known, ", "))
+		}
+		if !slices.Contains(normalized, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+	return normalized, nil
+}
+
+// issue generates the secret of k and stores it, attributed to the actor of ctx
+func (s *apiKeyService) issue(ctx context.Context, k *model.APIKey) (*model.CreatedAPIKey, error) {
+	key, prefix, err := auth.NewAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	k.Prefix = prefix
+	k.KeyHash = auth.HashAPIKey(key)
+	k.CreatedBy = actor.FromContext(ctx)
+	if err := s.repo.Create(ctx, k); err != nil {
+		return nil, err
+	}
+	return &model.CreatedAPIKey{APIKey: *k, Key: key}, nil
+}
+
+func (s *apiKeyService) FindAll(ctx context.Context) ([]model.APIKey, error) {
+	return s.repo.FindAll(ctx)
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, id int64) error {
+	return s.repo.Revoke(ctx, id)
+}
+
+func (s *apiKeyService) Rotate(ctx context.Context, id int64, req model.APIKeyRotateRequest) (*model.CreatedAPIKey, error) {
+	overlap := model.DefaultAPIKeyOverlap
+	if req.Overlap != nil {
+		d, err := time.ParseDuration(*req.Overlap)
+		if err != nil || d < 0 || d > model.MaxAPIKeyOverlap {
+			return nil, domainerr.ValidationFields(map[string]string{
+				"overlap": "must be a duration such as 24h, between 0s and " + model.MaxAPIKeyOverlap.String(),
+			})
+		}
+		overlap = d
+	}
+
+	var created *model.CreatedAPIKey
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		old, err := s.repo.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if old == nil {
+			return domainerr.NotFound("api key", id)
+		}
+		now := s.now()
+		if !old.Active(now) {
+			return &domainerr.ConflictError{Field: "id", Message: "only active api keys can be rotated"}
+		}
+		created, err = s.issue(ctx, &model.APIKey{Name: old.Name, Scopes: old.Scopes, ExpiresAt: old.ExpiresAt, RotatedFromID: &old.ID})
+		if err != nil {
+			return err
+		}
+		return s.repo.ExpireBy(ctx, id, now.Add(overlap))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (s *apiKeyService) Authenticate(ctx context.Context, key string) (auth.Principal, error) {
+	prefix, ok := auth.ParseAPIKey(key)
+	if !ok {
+		return auth.Principal{}, auth.ErrInvalidAPIKey
+	}
+	k, err := s.repo.FindByPrefix(ctx, prefix)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	if k == nil || !auth.APIKeyMatches(key, k.KeyHash) {
+		return auth.Principal{}, auth.ErrInvalidAPIKey
+	}
+	if !k.Active(s.now()) {
+		return auth.Principal{}, auth.ErrExpiredAPIKey
+	}
+	if err := s.repo.TouchLastUsed(ctx, k.ID); err != nil {
+		return auth.Principal{}, err
+	}
+	scopes := make([]auth.Scope, len(k.Scopes))
+	for i, scope := range k.Scopes {
+		scopes[i] = auth.Scope(scope)
+	}
+	return auth.Principal{
+		Subject: "api-key:" + k.Prefix,
+		Role:    auth.RoleForScopes(scopes),
+		Tenant:  k.TenantID,
+		Scopes:  scopes,
+	}, nil
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yMaatheus/tech-challenge-snet/auth"
+	"github.com/yMaatheus/tech-challenge-snet/domain/actor"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+type mockAPIKeyRepo struct {
+	keys    []*model.APIKey
+	expired map[int64]time.Time
+	touched []int64
+}
+
+func (m *mockAPIKeyRepo) Create(ctx context.Context, k *model.APIKey) error {
+	k.ID = int64(len(m.keys) + 1)
+	k.TenantID = "acme"
+	m.keys = append(m.keys, k)
+	return nil
+}
+func (m *mockAPIKeyRepo) FindAll(ctx context.Context) ([]model.APIKey, error) {
+	keys := []model.APIKey{}
+	for _, k := range m.keys {
+		keys = append(keys, *k)
+	}
+	return keys, nil
+}
+func (m *mockAPIKeyRepo) FindByID(ctx context.Context, id int64) (*model.APIKey, error) {
+	for _, k := range m.keys {
+		if k.ID == id {
+			return k, nil
+		}
+	}
+	return nil, nil
+}
+func (m *mockAPIKeyRepo) FindByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	for _, k := range m.keys {
+		if k.Prefix == prefix {
+			return k, nil
+		}
+	}
+	return nil, nil
+}
+func (m *mockAPIKeyRepo) Revoke(ctx context.Context, id int64) error {
+	k, _ := m.FindByID(ctx, id)
+	if k == nil || k.RevokedAt != nil {
+		return domainerr.NotFound("api key", id)
+	}
+	now := time.Now()
+	k.RevokedAt = &now
+	return nil
+}
+func (m *mockAPIKeyRepo) ExpireBy(ctx context.Context, id int64, at time.Time) error {
+	if m.expired == nil {
+		m.expired = map[int64]time.Time{}
+	}
+	m.expired[id] = at
+	k, _ := m.FindByID(ctx, id)
+	k.ExpiresAt = &at
+	return nil
+}
+func (m *mockAPIKeyRepo) TouchLastUsed(ctx context.Context, id int64) error {
+	m.touched = append(m.touched, id)
+	return nil
+}
+
+var apiKeyNow = time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestAPIKeyService(repo *mockAPIKeyRepo) *apiKeyService {
+	svc := NewAPIKeyService(&mockTx{}, repo).(*apiKeyService)
+	svc.now = func() time.Time { return apiKeyNow }
+	return svc
+}
+
+func TestAPIKeyService_CreateEAuthenticate(t *testing.T) {
+	repo := &mockAPIKeyRepo{}
+	svc := newTestAPIKeyService(repo)
+	ctx := actor.WithActor(context.Background(), "maria")
+
+	created, err := svc.Create(ctx, model.APIKeyRequest{Name: " ERP ", Scopes: []string{"read:stores", "write:stores", "read:stores"}})
+	require.NoError(t, err)
+	assert.Equal(t, "ERP", created.Name)
+	assert.Equal(t, []string{"read:stores", "write:stores"}, created.Scopes)
+	assert.Equal(t, "maria", created.CreatedBy)
+	assert.NotContains(t, repo.keys[0].KeyHash, created.Key, "only the hash is stored")
+
+	p, err := svc.Authenticate(context.Background(), created.Key)
+	require.NoError(t, err)
+	assert.Equal(t, "api-key:"+created.Prefix, p.Subject)
+	assert.Equal(t, auth.RoleEditor, p.Role)
+	assert.Equal(t, "acme", p.Tenant)
+	assert.Equal(t, []auth.Scope{auth.ScopeReadStores, auth.ScopeWriteStores}, p.Scopes)
+	assert.Equal(t, []int64{created.ID}, repo.touched)
+}
+
+func TestAPIKeyService_Create_Invalido(t *testing.T) {
+	svc := newTestAPIKeyService(&mockAPIKeyRepo{})
+	past := apiKeyNow.Add(-time.Minute)
+
+	_, err := svc.Create(context.Background(), model.APIKeyRequest{Name: "ERP", Scopes: []string{"read:stores", "admin"}, ExpiresAt: &past})
+	var verr *domainerr.ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Contains(t, verr.Fields, "scopes")
+	assert.Contains(t, verr.Fields, "expires_at")
+}
+
+func TestAPIKeyService_Authenticate_Recusada(t *testing.T) {
+	repo := &mockAPIKeyRepo{}
+	svc := newTestAPIKeyService(repo)
+	soon := apiKeyNow.Add(time.Hour)
+	active, err := svc.Create(context.Background(), model.APIKeyRequest{Name: "ativa", Scopes: []string{"read:stores"}, ExpiresAt: &soon})
+	require.NoError(t, err)
+	revoked, err := svc.Create(context.Background(), model.APIKeyRequest{Name: "revogada", Scopes: []string{"read:stores"}})
+	require.NoError(t, err)
+	require.NoError(t, svc.Revoke(context.Background(), revoked.ID))
+	other, _, err := auth.NewAPIKey()
+	require.NoError(t, err)
+
+	_, err = svc.Authenticate(context.Background(), "snet_nao_e_chave")
+	assert.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+	_, err = svc.Authenticate(context.Background(), other)
+	assert.ErrorIs(t, err, auth.ErrInvalidAPIKey, "unknown prefix")
+	forged := active.Key[:len(active.Key)-4] + "AAAA"
+	_, err = svc.Authenticate(context.Background(), forged)
+	assert.ErrorIs(t, err, auth.ErrInvalidAPIKey, "known prefix with the wrong secret")
+	_, err = svc.Authenticate(context.Background(), revoked.Key)
+	assert.ErrorIs(t, err, auth.ErrExpiredAPIKey)
+
+	svc.now = func() time.Time { return soon }
+	_, err = svc.Authenticate(context.Background(), active.Key)
+	assert.ErrorIs(t, err, auth.ErrExpiredAPIKey)
+	assert.Empty(t, repo.touched)
+}
+
+func TestAPIKeyService_Rotate(t *testing.T) {
+	repo := &mockAPIKeyRepo{}
+	svc := newTestAPIKeyService(repo)
+	old, err := svc.Create(context.Background(), model.APIKeyRequest{Name: "ERP", Scopes: []string{"write:establishments"}})
+	require.NoError(t, err)
+
+	overlap := "2h"
+	rotated, err := svc.Rotate(context.Background(), old.ID, model.APIKeyRotateRequest{Overlap: &overlap})
+	require.NoError(t, err)
+	assert.NotEqual(t, old.Key, rotated.Key)
+	assert.Equal(t, "ERP", rotated.Name)
+	assert.Equal(t, old.Scopes, rotated.Scopes)
+	assert.Equal(t, &old.ID, rotated.RotatedFromID)
+	assert.Equal(t, apiKeyNow.Add(2*time.Hour), repo.expired[old.ID])
+
+	_, err = svc.Authenticate(context.Background(), old.Key)
+	assert.NoError(t, err, "the old key works during the overlap")
+	svc.now = func() time.Time { return apiKeyNow.Add(2 * time.Hour) }
+	_, err = svc.Authenticate(context.Background(), old.Key)
+	assert.ErrorIs(t, err, auth.ErrExpiredAPIKey)
+	_, err = svc.Authenticate(context.Background(), rotated.Key)
+	assert.NoError(t, err)
+
+	_, err = svc.Rotate(context.Background(), old.ID, model.APIKeyRotateRequest{})
+	assert.ErrorIs(t, err, domainerr.ErrConflict, "expired keys cannot be rotated")
+}
+
+func TestAPIKeyService_Rotate_Erros(t *testing.T) {
+	svc := newTestAPIKeyService(&mockAPIKeyRepo{})
+
+	_, err := svc.Rotate(context.Background(), 1, model.APIKeyRotateRequest{})
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+	for _, overlap := range []string{"amanha", "-1h", "8760h"} {
+		_, err = svc.Rotate(context.Background(), 1, model.APIKeyRotateRequest{Overlap: &overlap})
+		assert.ErrorIs(t, err, domainerr.ErrValidation, overlap)
+	}
+}
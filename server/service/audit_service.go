@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/yMaatheus/tech-challenge-snet/domain/actor"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/repository"
+)
+
+// AuditService reads the audit log of changes to establishments and stores.
+type AuditService interface {
+	FindAll(ctx context.Context, params model.AuditParams) (*model.AuditPage, error)
+}
+
+type auditService struct {
+	tx   repository.Transactor
+	repo repository.AuditRepository
+}
+
+func NewAuditService(tx repository.Transactor, repo repository.AuditRepository) AuditService {
+	return &auditService{tx: tx, repo: repo}
+}
+
+func (s *auditService) FindAll(ctx context.Context, params model.AuditParams) (*model.AuditPage, error) {
+	var page *model.AuditPage
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		page, err = s.repo.FindAll(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// recordAudit writes an audit entry with the fields that differ between before and after, attributed
+// to the actor of ctx. before is nil for creates and after is nil for deletes. Callers run it in the
+// transaction of the change, so the entry and the change are kept or rolled back together.
+func recordAudit(ctx context.Context, repo repository.AuditRepository, entity string, id int64, action string, before, after interface{}) error {
+	changes, err := diff(before, after)
+	if err != nil {
+		return err
+	}
+	return repo.Create(ctx, &model.AuditEntry{
+		Entity:   entity,
+		EntityID: id,
+		Action:   action,
+		Actor:    actor.FromContext(ctx),
+		Changes:  changes,
+	})
+}
+
+// diff compares the JSON representation of two values field by field.
+func diff(before, after interface{}) (map[string]model.FieldChange, error) {
+	beforeFields, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]model.FieldChange{}
+	for field, value := range beforeFields {
+		if !reflect.DeepEqual(value, afterFields[field]) {
+			changes[field] = model.FieldChange{Before: value, After: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			changes[field] = model.FieldChange{Before: nil, After: value}
+		}
+	}
+	return changes, nil
+}
+
+func jsonFields(v interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if v == nil {
+		return fields, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return fields, json.Unmarshal(b, &fields)
+}
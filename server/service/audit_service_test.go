@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/domain/actor"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+type mockAuditRepo struct {
+	entries   []model.AuditEntry
+	createErr error
+	params    model.AuditParams
+}
+
+func (m *mockAuditRepo) Create(ctx context.Context, entry *model.AuditEntry) error {
+	if m.createErr != nil {
+		return m.createErr
+	}
+	m.entries = append(m.entries, *entry)
+	return nil
+}
+func (m *mockAuditRepo) FindAll(ctx context.Context, params model.AuditParams) (*model.AuditPage, error) {
+	m.params = params
+	return &model.AuditPage{Items: m.entries, Total: int64(len(m.entries))}, nil
+}
+
+func TestDiff_Update(t *testing.T) {
+	before := model.Establishment{ID: 1, Name: "Antigo", City: "Recife"}
+	after := model.Establishment{ID: 1, Name: "Novo", City: "Recife"}
+
+	changes, err := diff(before, after)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]model.FieldChange{"name": {Before: "Antigo", After: "Novo"}}, changes)
+}
+
+func TestDiff_CreateEDelete(t *testing.T) {
+	e := model.Establishment{ID: 1, Name: "Loja"}
+
+	created, err := diff(nil, e)
+	assert.NoError(t, err)
+	assert.Equal(t, model.FieldChange{Before: nil, After: "Loja"}, created["name"])
+
+	deleted, err := diff(e, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, model.FieldChange{Before: "Loja", After: nil}, deleted["name"])
+}
+
+func TestRecordAudit_UsaAtorDoContexto(t *testing.T) {
+	audit := &mockAuditRepo{}
+	ctx := actor.WithActor(context.Background(), "maria")
+
+	err := recordAudit(ctx, audit, model.AuditEntityStore, 7, model.AuditActionDelete, model.Store{ID: 7}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, audit.entries, 1)
+	assert.Equal(t, "maria", audit.entries[0].Actor)
+	assert.Equal(t, int64(7), audit.entries[0].EntityID)
+	assert.Equal(t, model.AuditActionDelete, audit.entries[0].Action)
+}
+
+func TestRecordAudit_Erro(t *testing.T) {
+	audit := &mockAuditRepo{createErr: errors.New("erro")}
+	err := recordAudit(context.Background(), audit, model.AuditEntityStore, 7, model.AuditActionCreate, nil, model.Store{ID: 7})
+	assert.Error(t, err)
+}
+
+func TestAuditService_FindAll(t *testing.T) {
+	audit := &mockAuditRepo{entries: []model.AuditEntry{{ID: 1}}}
+	svc := NewAuditService(&mockTx{}, audit)
+
+	page, err := svc.FindAll(context.Background(), model.AuditParams{Entity: model.AuditEntityStore, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, model.AuditEntityStore, audit.params.Entity)
+}
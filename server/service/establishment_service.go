@@ -2,79 +2,294 @@ package service
 
 import (
 	"context"
-	"errors"
 
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
 	"github.com/yMaatheus/tech-challenge-snet/model"
 	"github.com/yMaatheus/tech-challenge-snet/repository"
+	"github.com/yMaatheus/tech-challenge-snet/util"
 )
 
 // EstablishmentService defines business logic for establishments.
 type EstablishmentService interface {
 	Create(ctx context.Context, e *model.Establishment) error
-	FindAll(ctx context.Context) ([]model.EstablishmentWithStoresTotal, error)
+	FindAll(ctx context.Context, params model.ListParams) (*model.EstablishmentPage, error)
 	FindByID(ctx context.Context, id int64) (*model.EstablishmentWithStores, error)
+	// Update saves e when it is still at e.Version, setting e.Version to the new version.
 	Update(ctx context.Context, e *model.Establishment) error
-	Delete(ctx context.Context, id int64) error
+	// Patch applies a partial update to the establishment while it is at the given version, returning the result.
+	Patch(ctx context.Context, id, version int64, patch model.Patch) (*model.Establishment, error)
+	// Delete and DeleteCascade only remove the establishment while it is at the given version.
+	Delete(ctx context.Context, id, version int64) error
+	DeleteCascade(ctx context.Context, id, version int64) ([]int64, error)
+	Restore(ctx context.Context, id int64) error
 }
 
 // establishmentService implements EstablishmentService.
 type establishmentService struct {
-	repo repository.EstablishmentRepository
+	repo      repository.EstablishmentRepository
+	tx        repository.Transactor
+	audit     repository.AuditRepository
+	addresses AddressService
 }
 
-func NewEstablishmentService(r repository.EstablishmentRepository) EstablishmentService {
-	return &establishmentService{repo: r}
+// NewEstablishmentService creates the establishment service. The addresses of the establishments
+// it saves are checked against their zip code with addresses, which may be nil to skip checks.
+func NewEstablishmentService(r repository.EstablishmentRepository, tx repository.Transactor, audit repository.AuditRepository, addresses AddressService) EstablishmentService {
+	return &establishmentService{repo: r, tx: tx, audit: audit, addresses: addresses}
 }
 
 func (s *establishmentService) Create(ctx context.Context, e *model.Establishment) error {
-	return s.repo.Create(ctx, e)
+	e.Number = util.NormalizeCNPJ(e.Number)
+	e.State = util.NormalizeUF(e.State)
+	e.ZipCode = util.NormalizeCEP(e.ZipCode)
+	var err error
+	if e.AddressMismatch, err = checkAddress(ctx, s.addresses, e.ZipCode, e.City, e.State); err != nil {
+		return err
+	}
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Create(ctx, e); err != nil {
+			return err
+		}
+		return recordAudit(ctx, s.audit, model.AuditEntityEstablishment, e.ID, model.AuditActionCreate, nil, e)
+	})
 }
 
-func (s *establishmentService) FindAll(ctx context.Context) ([]model.EstablishmentWithStoresTotal, error) {
-	return s.repo.FindAllWithStoresTotal(ctx)
+func (s *establishmentService) FindAll(ctx context.Context, params model.ListParams) (*model.EstablishmentPage, error) {
+	var page *model.EstablishmentPage
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		page, err = s.repo.FindAllWithStoresTotal(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
 }
 
+// FindByID reads the establishment and its stores in one transaction.
 func (s *establishmentService) FindByID(ctx context.Context, id int64) (*model.EstablishmentWithStores, error) {
-	establishment, err := s.repo.FindByID(ctx, id)
+	var (
+		establishment *model.Establishment
+		storesList    []model.Store
+	)
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if establishment, err = s.repo.FindByID(ctx, id); err != nil || establishment == nil {
+			return err
+		}
+		storesList, err = s.repo.FindStoresByEstablishmentID(ctx, id)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 	if establishment == nil {
-		return nil, errors.New("establishment not found")
-	}
-
-	storesList, err := s.repo.FindStoresByEstablishmentID(ctx, id)
-	if err != nil {
-		return nil, err
+		return nil, domainerr.NotFound("establishment", id)
 	}
 
 	result := &model.EstablishmentWithStores{
-		ID:            establishment.ID,
-		Number:        establishment.Number,
-		Name:          establishment.Name,
-		CorporateName: establishment.CorporateName,
-		Address:       establishment.Address,
-		City:          establishment.City,
-		State:         establishment.State,
-		ZipCode:       establishment.ZipCode,
-		AddressNumber: establishment.AddressNumber,
-		Stores:        storesList,
+		ID:              establishment.ID,
+		Number:          establishment.Number,
+		Name:            establishment.Name,
+		CorporateName:   establishment.CorporateName,
+		Address:         establishment.Address,
+		City:            establishment.City,
+		State:           establishment.State,
+		ZipCode:         establishment.ZipCode,
+		AddressNumber:   establishment.AddressNumber,
+		AddressMismatch: establishment.AddressMismatch,
+		Version:         establishment.Version,
+		Stores:          storesList,
 	}
 
 	return result, nil
 }
 
 func (s *establishmentService) Update(ctx context.Context, e *model.Establishment) error {
-	return s.repo.Update(ctx, e)
+	e.Number = util.NormalizeCNPJ(e.Number)
+	e.State = util.NormalizeUF(e.State)
+	e.ZipCode = util.NormalizeCEP(e.ZipCode)
+	var err error
+	if e.AddressMismatch, err = checkAddress(ctx, s.addresses, e.ZipCode, e.City, e.State); err != nil {
+		return err
+	}
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.findForUpdate(ctx, e.ID)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion("establishment", e.ID, before.Version, e.Version); err != nil {
+			return err
+		}
+		if err := s.repo.Update(ctx, e); err != nil {
+			return err
+		}
+		return recordAudit(ctx, s.audit, model.AuditEntityEstablishment, e.ID, model.AuditActionUpdate, before, e)
+	})
 }
 
-func (s *establishmentService) Delete(ctx context.Context, id int64) error {
-	hasStores, err := s.repo.HasStores(ctx, id)
+// Patch applies the patch to the current establishment, validates the merged result and writes only the
+// columns that changed. A patch that changes nothing leaves the establishment and its version untouched.
+// The address is checked before the establishment is locked, so a slow provider does not block its writers.
+func (s *establishmentService) Patch(ctx context.Context, id, version int64, patch model.Patch) (*model.Establishment, error) {
+	return retryPatch(func() (*model.Establishment, error) {
+		current, err := s.find(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkVersion("establishment", id, current.Version, version); err != nil {
+			return nil, err
+		}
+		after, columns, err := s.merge(ctx, current, patch)
+		if err != nil {
+			return nil, err
+		}
+		if len(columns) == 0 {
+			return current, nil
+		}
+		err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+			before, err := s.findForUpdate(ctx, id)
+			if err != nil {
+				return err
+			}
+			if err := checkVersion("establishment", id, before.Version, version); err != nil {
+				return err
+			}
+			if before.Version != current.Version {
+				return errPatchRaced
+			}
+			if err := s.repo.UpdateColumns(ctx, after, columns); err != nil {
+				return err
+			}
+			return recordAudit(ctx, s.audit, model.AuditEntityEstablishment, id, model.AuditActionUpdate, before, after)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return after, nil
+	})
+}
+
+// merge applies the patch to current and checks the address of the result, returning it with the columns that changed.
+func (s *establishmentService) merge(ctx context.Context, current *model.Establishment, patch model.Patch) (*model.Establishment, []string, error) {
+	var after model.Establishment
+	if err := applyPatch(current, patch, &after); err != nil {
+		return nil, nil, err
+	}
+	if err := util.Validate.Struct(&after); err != nil {
+		return nil, nil, domainerr.ValidationFields(util.ParseValidationError(err))
+	}
+	after.Number = util.NormalizeCNPJ(after.Number)
+	after.State = util.NormalizeUF(after.State)
+	after.ZipCode = util.NormalizeCEP(after.ZipCode)
+
+	columns, err := patchedFields(current, &after)
 	if err != nil {
+		return nil, nil, err
+	}
+	if columns, err = recheckAddress(ctx, s.addresses, columns, after.ZipCode, after.City, after.State, &after.AddressMismatch); err != nil {
+		return nil, nil, err
+	}
+	return &after, columns, nil
+}
+
+// Delete soft deletes an establishment without stores. The establishment is locked before its stores are
+// counted, as the foreign key does not stop a store from being created under a soft deleted row.
+func (s *establishmentService) Delete(ctx context.Context, id, version int64) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.findForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion("establishment", id, before.Version, version); err != nil {
+			return err
+		}
+		hasStores, err := s.repo.HasStores(ctx, id)
+		if err != nil {
+			return err
+		}
+		if hasStores {
+			return &domainerr.ConflictError{Message: "cannot delete establishment: it has related stores"}
+		}
+		if err := s.repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return recordAudit(ctx, s.audit, model.AuditEntityEstablishment, id, model.AuditActionDelete, before, nil)
+	})
+}
+
+// DeleteCascade deletes an establishment together with all of its stores in a single transaction,
+// returning the ids of the removed stores. The establishment stays locked while its stores are
+// removed, so no store can be created under it halfway through.
+func (s *establishmentService) DeleteCascade(ctx context.Context, id, version int64) ([]int64, error) {
+	var storeIDs []int64
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		establishment, err := s.findForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion("establishment", id, establishment.Version, version); err != nil {
+			return err
+		}
+		stores, err := s.repo.FindStoresByEstablishmentID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if storeIDs, err = s.repo.DeleteStores(ctx, id); err != nil {
+			return err
+		}
+		if err := s.repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		for _, store := range stores {
+			if err := recordAudit(ctx, s.audit, model.AuditEntityStore, store.ID, model.AuditActionDelete, store, nil); err != nil {
+				return err
+			}
+		}
+		return recordAudit(ctx, s.audit, model.AuditEntityEstablishment, id, model.AuditActionDelete, establishment, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return storeIDs, nil
+}
+
+// Restore brings back a soft deleted establishment. Its stores stay deleted and are restored one by one.
+func (s *establishmentService) Restore(ctx context.Context, id int64) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Restore(ctx, id); err != nil {
+			return err
+		}
+		return recordAudit(ctx, s.audit, model.AuditEntityEstablishment, id, model.AuditActionRestore, nil, nil)
+	})
+}
+
+// find loads an establishment without locking it, failing with NotFound when it does not exist.
+func (s *establishmentService) find(ctx context.Context, id int64) (*model.Establishment, error) {
+	var establishment *model.Establishment
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		establishment, err = s.repo.FindByID(ctx, id)
 		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if establishment == nil {
+		return nil, domainerr.NotFound("establishment", id)
+	}
+	return establishment, nil
+}
+
+// findForUpdate loads and locks an establishment, failing with NotFound when it does not exist.
+func (s *establishmentService) findForUpdate(ctx context.Context, id int64) (*model.Establishment, error) {
+	establishment, err := s.repo.FindByIDForUpdate(ctx, id)
+	if err != nil {
+		return nil, err
 	}
-	if hasStores {
-		return errors.New("cannot delete establishment: it has related stores")
+	if establishment == nil {
+		return nil, domainerr.NotFound("establishment", id)
 	}
-	return s.repo.Delete(ctx, id)
+	return establishment, nil
 }
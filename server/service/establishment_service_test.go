@@ -4,22 +4,37 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
 	"github.com/yMaatheus/tech-challenge-snet/model"
 )
 
 type mockRepo struct {
 	hasStoresResult              bool
 	hasStoresErr                 error
+	locked                       bool
+	shared                       bool
+	hasStoresLocked              bool
 	deleteCalled                 bool
 	findByIDResult               *model.Establishment
 	findByIDErr                  error
-	findAllWithStoresTotalResult []model.EstablishmentWithStoresTotal
+	findAllWithStoresTotalResult *model.EstablishmentPage
 	findAllWithStoresTotalErr    error
 	findAllWithStoresTotalCalled bool
+	findAllWithStoresTotalParams model.ListParams
 	findStoresResult             []model.Store
 	findStoresErr                error
+	deleteStoresResult           []int64
+	deleteStoresErr              error
+	deleteStoresCalled           bool
+	restoreErr                   error
+	purged                       int64
+	purgeBefore                  time.Time
+	updatedColumns               []string
+	findByNumberResult           *model.Establishment
+	streamParams                 model.ListParams
 }
 
 func (m *mockRepo) Create(ctx context.Context, e *model.Establishment) error {
@@ -30,52 +45,97 @@ func (m *mockRepo) Create(ctx context.Context, e *model.Establishment) error {
 	return nil
 }
 func (m *mockRepo) FindAll(ctx context.Context) ([]model.Establishment, error) { return nil, nil }
-func (m *mockRepo) FindAllWithStoresTotal(ctx context.Context) ([]model.EstablishmentWithStoresTotal, error) {
+func (m *mockRepo) FindAllWithStoresTotal(ctx context.Context, params model.ListParams) (*model.EstablishmentPage, error) {
 	m.findAllWithStoresTotalCalled = true
+	m.findAllWithStoresTotalParams = params
 	if m.findAllWithStoresTotalErr != nil {
 		return nil, m.findAllWithStoresTotalErr
 	}
 	if m.findAllWithStoresTotalResult != nil {
 		return m.findAllWithStoresTotalResult, nil
 	}
-	return []model.EstablishmentWithStoresTotal{
-		{
-			ID:            1,
-			Number:        "E001",
-			Name:          "Test",
-			CorporateName: "Corp Test",
-			Address:       "Rua Teste",
-			AddressNumber: "10",
-			City:          "Cidade Teste",
-			State:         "ST",
-			ZipCode:       "12345678",
-			StoresTotal:   2,
+	return &model.EstablishmentPage{
+		Items: []model.EstablishmentWithStoresTotal{
+			{
+				ID:            1,
+				Number:        "E001",
+				Name:          "Test",
+				CorporateName: "Corp Test",
+				Address:       "Rua Teste",
+				AddressNumber: "10",
+				City:          "Cidade Teste",
+				State:         "SP",
+				ZipCode:       "01310100",
+				StoresTotal:   2,
+			},
 		},
+		Total: 1,
 	}, nil
 }
+func (m *mockRepo) StreamAllWithStoresTotal(ctx context.Context, params model.ListParams, fn func(*model.EstablishmentWithStoresTotal) error) error {
+	m.streamParams = params
+	page, err := m.FindAllWithStoresTotal(ctx, params)
+	if err != nil {
+		return err
+	}
+	for i := range page.Items {
+		if err := fn(&page.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 func (m *mockRepo) FindByID(ctx context.Context, id int64) (*model.Establishment, error) {
 	return m.findByIDResult, m.findByIDErr
 }
+func (m *mockRepo) FindByNumber(ctx context.Context, number string) (*model.Establishment, error) {
+	return m.findByNumberResult, nil
+}
+func (m *mockRepo) FindByIDForUpdate(ctx context.Context, id int64) (*model.Establishment, error) {
+	m.locked = true
+	return m.FindByID(ctx, id)
+}
+func (m *mockRepo) FindByIDForShare(ctx context.Context, id int64) (*model.Establishment, error) {
+	m.shared = true
+	return m.FindByID(ctx, id)
+}
 func (m *mockRepo) Update(ctx context.Context, e *model.Establishment) error {
 	if e.Name == "erro" {
 		return errors.New("erro ao atualizar")
 	}
 	return nil
 }
+func (m *mockRepo) UpdateColumns(ctx context.Context, e *model.Establishment, columns []string) error {
+	m.updatedColumns = columns
+	e.Version++
+	return nil
+}
 func (m *mockRepo) Delete(ctx context.Context, id int64) error {
 	m.deleteCalled = true
 	return nil
 }
 func (m *mockRepo) HasStores(ctx context.Context, id int64) (bool, error) {
+	m.hasStoresLocked = m.locked
 	return m.hasStoresResult, m.hasStoresErr
 }
+func (m *mockRepo) DeleteStores(ctx context.Context, establishmentID int64) ([]int64, error) {
+	m.deleteStoresCalled = true
+	return m.deleteStoresResult, m.deleteStoresErr
+}
+func (m *mockRepo) Restore(ctx context.Context, id int64) error {
+	return m.restoreErr
+}
+func (m *mockRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	m.purgeBefore = before
+	return m.purged, nil
+}
 func (m *mockRepo) FindStoresByEstablishmentID(ctx context.Context, establishmentID int64) ([]model.Store, error) {
 	return m.findStoresResult, m.findStoresErr
 }
 
 func TestEstablishmentService_Create(t *testing.T) {
 	repo := &mockRepo{}
-	service := NewEstablishmentService(repo)
+	service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, nil)
 
 	est := &model.Establishment{Name: "Loja"}
 	err := service.Create(context.Background(), est)
@@ -87,13 +147,38 @@ func TestEstablishmentService_Create(t *testing.T) {
 	assert.Error(t, err2)
 }
 
-func TestEstablishmentService_Update(t *testing.T) {
+func TestEstablishmentService_Create_NormalizesCNPJ(t *testing.T) {
 	repo := &mockRepo{}
-	service := NewEstablishmentService(repo)
+	service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, nil)
 
-	est := &model.Establishment{Name: "Loja"}
+	est := &model.Establishment{Name: "Loja", Number: "11.222.333/0001-81"}
+	err := service.Create(context.Background(), est)
+	assert.NoError(t, err)
+	assert.Equal(t, "11222333000181", est.Number)
+}
+
+func TestEstablishmentService_Create_NormalizesStateAndCEP(t *testing.T) {
+	repo := &mockRepo{}
+	service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, nil)
+
+	est := &model.Establishment{Name: "Loja", State: " pe", ZipCode: "50000-100"}
+	err := service.Create(context.Background(), est)
+	assert.NoError(t, err)
+	assert.Equal(t, "PE", est.State)
+	assert.Equal(t, "50000100", est.ZipCode)
+}
+
+func TestEstablishmentService_Update(t *testing.T) {
+	repo := &mockRepo{findByIDResult: &model.Establishment{ID: 1, Name: "Antiga"}}
+	audit := &mockAuditRepo{}
+	service := NewEstablishmentService(repo, &mockTx{}, audit, nil)
+
+	est := &model.Establishment{ID: 1, Name: "Loja"}
 	err := service.Update(context.Background(), est)
 	assert.NoError(t, err)
+	assert.Len(t, audit.entries, 1)
+	assert.Equal(t, model.AuditActionUpdate, audit.entries[0].Action)
+	assert.Equal(t, model.FieldChange{Before: "Antiga", After: "Loja"}, audit.entries[0].Changes["name"])
 
 	est2 := &model.Establishment{Name: "erro"}
 	err2 := service.Update(context.Background(), est2)
@@ -102,22 +187,27 @@ func TestEstablishmentService_Update(t *testing.T) {
 
 func TestEstablishmentService_FindAll(t *testing.T) {
 	repo := &mockRepo{}
-	service := NewEstablishmentService(repo)
+	tx := &mockTx{}
+	service := NewEstablishmentService(repo, tx, &mockAuditRepo{}, nil)
 
-	list, err := service.FindAll(context.Background())
+	params := model.ListParams{Limit: 10, Sort: "name", City: "Cidade Teste"}
+	page, err := service.FindAll(context.Background(), params)
 	assert.NoError(t, err)
+	assert.True(t, tx.called, "reads run within a transaction scoped to the tenant")
 	assert.True(t, repo.findAllWithStoresTotalCalled)
-	assert.Len(t, list, 1)
-	assert.Equal(t, int64(1), list[0].ID)
+	assert.Equal(t, params, repo.findAllWithStoresTotalParams)
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, int64(1), page.Items[0].ID)
+	assert.Equal(t, int64(1), page.Total)
 }
 
 func TestEstablishmentService_FindAll_ErroNoRepo(t *testing.T) {
 	repo := &mockRepo{findAllWithStoresTotalErr: errors.New("erro repo")}
-	service := NewEstablishmentService(repo)
+	service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, nil)
 
-	list, err := service.FindAll(context.Background())
+	page, err := service.FindAll(context.Background(), model.ListParams{})
 	assert.Error(t, err)
-	assert.Nil(t, list)
+	assert.Nil(t, page)
 }
 
 func TestEstablishmentService_FindByID_Sucesso(t *testing.T) {
@@ -137,7 +227,7 @@ func TestEstablishmentService_FindByID_Sucesso(t *testing.T) {
 			{ID: 1, Name: "Loja A"},
 		},
 	}
-	service := NewEstablishmentService(repo)
+	service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, nil)
 	est, err := service.FindByID(context.Background(), 2)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(2), est.ID)
@@ -149,9 +239,9 @@ func TestEstablishmentService_FindByID_NotFound(t *testing.T) {
 		findByIDResult: nil,
 		findByIDErr:    nil,
 	}
-	service := NewEstablishmentService(repo)
+	service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, nil)
 	est, err := service.FindByID(context.Background(), 123)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
 	assert.Nil(t, est)
 }
 
@@ -159,7 +249,7 @@ func TestEstablishmentService_FindByID_ErroNoRepo(t *testing.T) {
 	repo := &mockRepo{
 		findByIDErr: errors.New("falha repo"),
 	}
-	service := NewEstablishmentService(repo)
+	service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, nil)
 	est, err := service.FindByID(context.Background(), 3)
 	assert.Error(t, err)
 	assert.Nil(t, est)
@@ -170,35 +260,194 @@ func TestEstablishmentService_FindByID_ErroStores(t *testing.T) {
 		findByIDResult: &model.Establishment{ID: 1},
 		findStoresErr:  errors.New("erro stores"),
 	}
-	service := NewEstablishmentService(repo)
+	service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, nil)
 	est, err := service.FindByID(context.Background(), 1)
 	assert.Error(t, err)
 	assert.Nil(t, est)
 }
 
 func TestEstablishmentService_Delete_QuandoNaoTemStores_DeveDeletar(t *testing.T) {
-	repo := &mockRepo{hasStoresResult: false}
-	service := NewEstablishmentService(repo)
+	repo := &mockRepo{hasStoresResult: false, findByIDResult: &model.Establishment{ID: 1}}
+	audit := &mockAuditRepo{}
+	service := NewEstablishmentService(repo, &mockTx{}, audit, nil)
 
-	err := service.Delete(context.Background(), 1)
+	err := service.Delete(context.Background(), 1, model.AnyVersion)
 	assert.NoError(t, err)
 	assert.True(t, repo.deleteCalled, "Delete deve ser chamado")
+	assert.Len(t, audit.entries, 1)
+	assert.Equal(t, model.AuditActionDelete, audit.entries[0].Action)
+}
+
+func TestEstablishmentService_Update_NaoEncontrado(t *testing.T) {
+	repo := &mockRepo{}
+	audit := &mockAuditRepo{}
+	service := NewEstablishmentService(repo, &mockTx{}, audit, nil)
+
+	err := service.Update(context.Background(), &model.Establishment{ID: 1, Name: "Loja"})
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+	assert.Empty(t, audit.entries)
 }
 
 func TestEstablishmentService_Delete_QuandoTemStores_DeveRetornarErro(t *testing.T) {
-	repo := &mockRepo{hasStoresResult: true}
-	service := NewEstablishmentService(repo)
+	repo := &mockRepo{hasStoresResult: true, findByIDResult: &model.Establishment{ID: 1}}
+	service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, nil)
 
-	err := service.Delete(context.Background(), 1)
+	err := service.Delete(context.Background(), 1, model.AnyVersion)
 	assert.Error(t, err)
 	assert.EqualError(t, err, "cannot delete establishment: it has related stores")
+	assert.ErrorIs(t, err, domainerr.ErrConflict)
+	assert.True(t, repo.hasStoresLocked, "stores are counted with the establishment locked")
+	assert.False(t, repo.deleteCalled)
 }
 
 func TestEstablishmentService_Delete_HasStoresRetornaErro(t *testing.T) {
-	repo := &mockRepo{hasStoresErr: errors.New("db error")}
-	service := NewEstablishmentService(repo)
+	repo := &mockRepo{hasStoresErr: errors.New("db error"), findByIDResult: &model.Establishment{ID: 1}}
+	service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, nil)
 
-	err := service.Delete(context.Background(), 1)
+	err := service.Delete(context.Background(), 1, model.AnyVersion)
 	assert.Error(t, err)
 	assert.EqualError(t, err, "db error")
 }
+
+func TestEstablishmentService_DeleteCascade(t *testing.T) {
+	repo := &mockRepo{findByIDResult: &model.Establishment{ID: 1}, deleteStoresResult: []int64{3, 4}}
+	tx := &mockTx{}
+	service := NewEstablishmentService(repo, tx, &mockAuditRepo{}, nil)
+
+	ids, err := service.DeleteCascade(context.Background(), 1, model.AnyVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3, 4}, ids)
+	assert.True(t, tx.called, "deve rodar em uma transação")
+	assert.True(t, repo.deleteCalled)
+}
+
+func TestEstablishmentService_DeleteCascade_NaoEncontrado(t *testing.T) {
+	repo := &mockRepo{}
+	service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, nil)
+
+	_, err := service.DeleteCascade(context.Background(), 1, model.AnyVersion)
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+	assert.False(t, repo.deleteStoresCalled)
+	assert.False(t, repo.deleteCalled)
+}
+
+func TestEstablishmentService_DeleteCascade_ErroAoRemoverStores(t *testing.T) {
+	repo := &mockRepo{findByIDResult: &model.Establishment{ID: 1}, deleteStoresErr: errors.New("db error")}
+	tx := &mockTx{}
+	service := NewEstablishmentService(repo, tx, &mockAuditRepo{}, nil)
+
+	_, err := service.DeleteCascade(context.Background(), 1, model.AnyVersion)
+	assert.EqualError(t, err, "db error")
+	assert.EqualError(t, tx.err, "db error", "a transação deve ser revertida")
+	assert.False(t, repo.deleteCalled)
+}
+
+func TestEstablishmentService_Restore(t *testing.T) {
+	repo := &mockRepo{restoreErr: domainerr.NotFound("deleted establishment", 1)}
+	service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, nil)
+	err := service.Restore(context.Background(), 1)
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+}
+
+func TestEstablishmentService_Update_VersaoDesatualizada(t *testing.T) {
+	repo := &mockRepo{findByIDResult: &model.Establishment{ID: 1, Name: "Antiga", Version: 3}}
+	audit := &mockAuditRepo{}
+	service := NewEstablishmentService(repo, &mockTx{}, audit, nil)
+
+	err := service.Update(context.Background(), &model.Establishment{ID: 1, Name: "erro", Version: 2})
+	assert.ErrorIs(t, err, domainerr.ErrPrecondition)
+	assert.Empty(t, audit.entries)
+
+	err = service.Update(context.Background(), &model.Establishment{ID: 1, Name: "Loja", Version: 3})
+	assert.NoError(t, err)
+}
+
+func TestEstablishmentService_Delete_VersaoDesatualizada(t *testing.T) {
+	repo := &mockRepo{findByIDResult: &model.Establishment{ID: 1, Version: 3}}
+	service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, nil)
+
+	err := service.Delete(context.Background(), 1, 2)
+	assert.ErrorIs(t, err, domainerr.ErrPrecondition)
+	assert.False(t, repo.deleteCalled)
+
+	_, err = service.DeleteCascade(context.Background(), 1, 2)
+	assert.ErrorIs(t, err, domainerr.ErrPrecondition)
+	assert.False(t, repo.deleteStoresCalled)
+}
+
+func establishmentForPatch() *mockRepo {
+	return &mockRepo{findByIDResult: &model.Establishment{
+		ID: 1, Number: "11222333000181", Name: "Antiga", Address: "Rua", City: "Recife",
+		State: "PE", ZipCode: "50000000", AddressNumber: "10", Version: 2,
+	}}
+}
+
+func TestEstablishmentService_Patch_MergePatch(t *testing.T) {
+	repo := establishmentForPatch()
+	audit := &mockAuditRepo{}
+	service := NewEstablishmentService(repo, &mockTx{}, audit, nil)
+
+	patch := model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"name": "Nova", "number": "11.222.333/0001-81", "state": "pe", "zip_code": "50000-000"}`)}
+	est, err := service.Patch(context.Background(), 1, 2, patch)
+	assert.NoError(t, err)
+	assert.Equal(t, "Nova", est.Name)
+	assert.Equal(t, "Recife", est.City)
+	assert.Equal(t, int64(3), est.Version)
+	assert.Equal(t, []string{"name"}, repo.updatedColumns, "only the changed columns are written")
+	assert.Len(t, audit.entries, 1)
+}
+
+func TestEstablishmentService_Patch_JSONPatch(t *testing.T) {
+	repo := establishmentForPatch()
+	service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, nil)
+
+	patch := model.Patch{Format: model.PatchFormatJSON, Document: []byte(`[
+		{"op": "test", "path": "/city", "value": "Recife"},
+		{"op": "replace", "path": "/city", "value": "Olinda"},
+		{"op": "copy", "from": "/city", "path": "/corporate_name"}
+	]`)}
+	est, err := service.Patch(context.Background(), 1, model.AnyVersion, patch)
+	assert.NoError(t, err)
+	assert.Equal(t, "Olinda", est.City)
+	assert.Equal(t, []string{"city", "corporate_name"}, repo.updatedColumns)
+}
+
+func TestEstablishmentService_Patch_SemMudancas(t *testing.T) {
+	repo := establishmentForPatch()
+	audit := &mockAuditRepo{}
+	service := NewEstablishmentService(repo, &mockTx{}, audit, nil)
+
+	est, err := service.Patch(context.Background(), 1, 2, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"name": "Antiga"}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), est.Version)
+	assert.Nil(t, repo.updatedColumns)
+	assert.Empty(t, audit.entries)
+}
+
+func TestEstablishmentService_Patch_Erros(t *testing.T) {
+	cases := []struct {
+		name    string
+		version int64
+		patch   model.Patch
+		want    error
+	}{
+		{"versao desatualizada", 1, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"name": "Nova"}`)}, domainerr.ErrPrecondition},
+		{"campo obrigatorio removido", 2, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"name": null}`)}, domainerr.ErrValidation},
+		{"campo somente leitura", 2, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"id": 5}`)}, domainerr.ErrValidation},
+		{"uf invalida", 2, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"state": "XX"}`)}, domainerr.ErrValidation},
+		{"cep invalido", 2, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"zip_code": "5000-000"}`)}, domainerr.ErrValidation},
+		{"campo desconhecido", 2, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"nome": "Nova"}`)}, domainerr.ErrValidation},
+		{"tipo invalido", 2, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"name": 5}`)}, domainerr.ErrValidation},
+		{"patch malformado", 2, model.Patch{Format: model.PatchFormatJSON, Document: []byte(`{"op": "add"}`)}, domainerr.ErrValidation},
+		{"teste falhou", 2, model.Patch{Format: model.PatchFormatJSON, Document: []byte(`[{"op": "test", "path": "/city", "value": "Olinda"}]`)}, domainerr.ErrConflict},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := establishmentForPatch()
+			service := NewEstablishmentService(repo, &mockTx{}, &mockAuditRepo{}, nil)
+			_, err := service.Patch(context.Background(), 1, c.version, c.patch)
+			assert.ErrorIs(t, err, c.want)
+			assert.Nil(t, repo.updatedColumns)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/repository"
+)
+
+// ExportService streams every establishment or store matching the list filters, one at a time,
+// so exports of any size are written without loading them in memory. Each export reads within a
+// transaction, which holds its connection until the last row is written.
+type ExportService interface {
+	ExportEstablishments(ctx context.Context, params model.ListParams, fn func(*model.EstablishmentWithStoresTotal) error) error
+	ExportStores(ctx context.Context, params model.ListParams, fn func(*model.Store) error) error
+}
+
+type exportService struct {
+	tx             repository.Transactor
+	establishments repository.EstablishmentRepository
+	stores         repository.StoreRepository
+}
+
+func NewExportService(tx repository.Transactor, establishments repository.EstablishmentRepository, stores repository.StoreRepository) ExportService {
+	return &exportService{tx: tx, establishments: establishments, stores: stores}
+}
+
+func (s *exportService) ExportEstablishments(ctx context.Context, params model.ListParams, fn func(*model.EstablishmentWithStoresTotal) error) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return s.establishments.StreamAllWithStoresTotal(ctx, params, fn)
+	})
+}
+
+func (s *exportService) ExportStores(ctx context.Context, params model.ListParams, fn func(*model.Store) error) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return s.stores.StreamAll(ctx, params, fn)
+	})
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+func TestExportService_ExportEstablishments(t *testing.T) {
+	repo := &mockRepo{}
+	svc := NewExportService(&mockTx{}, repo, &mockStoreRepo{})
+	params := model.ListParams{City: "Recife", Sort: "name"}
+
+	var names []string
+	err := svc.ExportEstablishments(context.Background(), params, func(e *model.EstablishmentWithStoresTotal) error {
+		names = append(names, e.Name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Test"}, names)
+	assert.Equal(t, params, repo.streamParams)
+}
+
+func TestExportService_ExportStores_PropagaErro(t *testing.T) {
+	stores := &mockStoreRepo{StreamAllFn: func(ctx context.Context, params model.ListParams, fn func(*model.Store) error) error {
+		return fn(&model.Store{ID: 1})
+	}}
+	svc := NewExportService(&mockTx{}, &mockRepo{}, stores)
+	stop := errors.New("cliente desconectou")
+
+	err := svc.ExportStores(context.Background(), model.ListParams{}, func(s *model.Store) error { return stop })
+	assert.ErrorIs(t, err, stop)
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/repository"
+)
+
+const (
+	// IdempotencyAbandonAfter is how long a request may hold its Idempotency-Key without a heartbeat. Past it the
+	// request is taken as lost, e.g. with the instance that ran it, and a retry may run it again.
+	IdempotencyAbandonAfter = 5 * time.Minute
+	// IdempotencyHeartbeatInterval is how often a running request refreshes its Idempotency-Key, well within
+	// IdempotencyAbandonAfter so a slow heartbeat does not let a retry run it again
+	IdempotencyHeartbeatInterval = time.Minute
+)
+
+// IdempotencyService keeps the response of the first request sent by a client with an Idempotency-Key, so
+// its retries get that response instead of repeating the request.
+type IdempotencyService interface {
+	// Begin reserves key for a request of client with the given fingerprint and returns nil, in which case
+	// the request runs and its response goes to Complete or, when it failed, Release. It returns the record
+	// of an earlier request with the same key and fingerprint to replay its response instead, and a
+	// conflict when the key is being used by a request still running or was used with another request.
+	Begin(ctx context.Context, client, key, fingerprint string) (*model.IdempotencyRecord, error)
+	// Heartbeat is called every IdempotencyHeartbeatInterval while the request of a reserved key runs, so
+	// requests that take longer than IdempotencyAbandonAfter are not run again by a retry.
+	Heartbeat(ctx context.Context, client, key string) error
+	Complete(ctx context.Context, client, key string, response model.IdempotentResponse) error
+	Release(ctx context.Context, client, key string) error
+	// DeleteExpired removes the expired keys of every tenant.
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type idempotencyService struct {
+	tx   repository.Transactor
+	repo repository.IdempotencyRepository
+	ttl  time.Duration
+	now  func() time.Time
+}
+
+// NewIdempotencyService keeps the responses for ttl.
+func NewIdempotencyService(tx repository.Transactor, repo repository.IdempotencyRepository, ttl time.Duration) IdempotencyService {
+	return &idempotencyService{tx: tx, repo: repo, ttl: ttl, now: time.Now}
+}
+
+func (s *idempotencyService) Begin(ctx context.Context, client, key, fingerprint string) (*model.IdempotencyRecord, error) {
+	now := s.now()
+	var existing *model.IdempotencyRecord
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		existing, err = s.repo.Reserve(ctx, &model.IdempotencyRecord{
+			Client:      client,
+			Key:         key,
+			Fingerprint: fingerprint,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(s.ttl),
+		}, now.Add(-IdempotencyAbandonAfter))
+		return err
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case existing == nil:
+		return nil, nil
+	case existing.Fingerprint != fingerprint:
+		return nil, &domainerr.ConflictError{Field: "Idempotency-Key", Message: "this Idempotency-Key was already used with a different request"}
+	case existing.Response == nil:
+		return nil, &domainerr.ConflictError{Field: "Idempotency-Key", Message: "a request with this Idempotency-Key is still being processed"}
+	}
+	return existing, nil
+}
+
+func (s *idempotencyService) Heartbeat(ctx context.Context, client, key string) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return s.repo.Heartbeat(ctx, client, key, s.now())
+	})
+}
+
+func (s *idempotencyService) Complete(ctx context.Context, client, key string, response model.IdempotentResponse) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return s.repo.Complete(ctx, client, key, response)
+	})
+}
+
+func (s *idempotencyService) Release(ctx context.Context, client, key string) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return s.repo.Release(ctx, client, key)
+	})
+}
+
+func (s *idempotencyService) DeleteExpired(ctx context.Context) (int64, error) {
+	var n int64
+	err := s.tx.WithinTx(tenant.WithAllTenants(ctx), func(ctx context.Context) error {
+		var err error
+		n, err = s.repo.DeleteExpired(ctx, s.now())
+		return err
+	})
+	return n, err
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+// mockIdempotencyRepo keeps the records by client and key, like the table does within a tenant
+type mockIdempotencyRepo struct {
+	records         map[string]*model.IdempotencyRecord
+	heartbeats      map[string]time.Time
+	abandonedBefore time.Time
+	deletedBefore   time.Time
+	allTenants      bool
+}
+
+func (m *mockIdempotencyRepo) Reserve(ctx context.Context, r *model.IdempotencyRecord, abandonedBefore time.Time) (*model.IdempotencyRecord, error) {
+	m.abandonedBefore = abandonedBefore
+	if existing, ok := m.records[r.Client+"/"+r.Key]; ok && existing.ExpiresAt.After(r.CreatedAt) &&
+		(existing.Response != nil || !m.heartbeats[r.Client+"/"+r.Key].Before(abandonedBefore)) {
+		return existing, nil
+	}
+	m.records[r.Client+"/"+r.Key] = r
+	m.heartbeats[r.Client+"/"+r.Key] = r.CreatedAt
+	return nil, nil
+}
+func (m *mockIdempotencyRepo) Heartbeat(ctx context.Context, client, key string, at time.Time) error {
+	if record, ok := m.records[client+"/"+key]; ok && record.Response == nil {
+		m.heartbeats[client+"/"+key] = at
+	}
+	return nil
+}
+func (m *mockIdempotencyRepo) Complete(ctx context.Context, client, key string, response model.IdempotentResponse) error {
+	m.records[client+"/"+key].Response = &response
+	return nil
+}
+func (m *mockIdempotencyRepo) Release(ctx context.Context, client, key string) error {
+	delete(m.records, client+"/"+key)
+	return nil
+}
+func (m *mockIdempotencyRepo) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	m.deletedBefore = before
+	m.allTenants = tenant.AllTenants(ctx)
+	return 2, nil
+}
+
+func newMockIdempotencyRepo() *mockIdempotencyRepo {
+	return &mockIdempotencyRepo{records: map[string]*model.IdempotencyRecord{}, heartbeats: map[string]time.Time{}}
+}
+
+var idempotencyNow = time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestIdempotencyService(repo *mockIdempotencyRepo) *idempotencyService {
+	svc := NewIdempotencyService(&mockTx{}, repo, 24*time.Hour).(*idempotencyService)
+	svc.now = func() time.Time { return idempotencyNow }
+	return svc
+}
+
+func TestIdempotencyService_Replay(t *testing.T) {
+	repo := newMockIdempotencyRepo()
+	svc := newTestIdempotencyService(repo)
+	ctx := context.Background()
+
+	record, err := svc.Begin(ctx, "user:maria", "chave-1", "abc")
+	require.NoError(t, err)
+	assert.Nil(t, record, "the first request runs")
+	assert.Equal(t, idempotencyNow.Add(24*time.Hour), repo.records["user:maria/chave-1"].ExpiresAt)
+	assert.Equal(t, idempotencyNow.Add(-IdempotencyAbandonAfter), repo.abandonedBefore)
+
+	_, err = svc.Begin(ctx, "user:maria", "chave-1", "abc")
+	assert.ErrorIs(t, err, domainerr.ErrConflict, "the first request is still running")
+
+	response := model.IdempotentResponse{Status: 201, Header: map[string]string{"Content-Type": "application/json"}, Body: []byte(`{"id":1}`)}
+	require.NoError(t, svc.Complete(ctx, "user:maria", "chave-1", response))
+	record, err = svc.Begin(ctx, "user:maria", "chave-1", "abc")
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, response, *record.Response)
+
+	_, err = svc.Begin(ctx, "user:maria", "chave-1", "outro")
+	var conflict *domainerr.ConflictError
+	require.ErrorAs(t, err, &conflict, "same key, different body")
+	assert.Equal(t, "Idempotency-Key", conflict.Field)
+
+	record, err = svc.Begin(ctx, "user:joao", "chave-1", "outro")
+	require.NoError(t, err)
+	assert.Nil(t, record, "keys of other clients never clash")
+
+	svc.now = func() time.Time { return idempotencyNow.Add(24 * time.Hour) }
+	record, err = svc.Begin(ctx, "user:maria", "chave-1", "outro")
+	require.NoError(t, err)
+	assert.Nil(t, record, "expired keys can be used again")
+}
+
+func TestIdempotencyService_Release(t *testing.T) {
+	repo := newMockIdempotencyRepo()
+	svc := newTestIdempotencyService(repo)
+	ctx := context.Background()
+
+	_, err := svc.Begin(ctx, "user:maria", "chave-1", "abc")
+	require.NoError(t, err)
+	require.NoError(t, svc.Release(ctx, "user:maria", "chave-1"))
+	record, err := svc.Begin(ctx, "user:maria", "chave-1", "abc")
+	require.NoError(t, err)
+	assert.Nil(t, record, "a failed request runs again on retry")
+
+	n, err := svc.DeleteExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, idempotencyNow, repo.deletedBefore)
+	assert.True(t, repo.allTenants)
+}
+
+func TestIdempotencyService_Heartbeat(t *testing.T) {
+	repo := newMockIdempotencyRepo()
+	svc := newTestIdempotencyService(repo)
+	ctx := context.Background()
+
+	_, err := svc.Begin(ctx, "user:maria", "chave-1", "abc")
+	require.NoError(t, err)
+
+	// A long import keeps its key while the heartbeats go on
+	for minutes := 1; minutes <= 20; minutes++ {
+		at := idempotencyNow.Add(time.Duration(minutes) * IdempotencyHeartbeatInterval)
+		svc.now = func() time.Time { return at }
+		require.NoError(t, svc.Heartbeat(ctx, "user:maria", "chave-1"))
+	}
+	_, err = svc.Begin(ctx, "user:maria", "chave-1", "abc")
+	assert.ErrorIs(t, err, domainerr.ErrConflict, "the retry does not run the import again")
+
+	// and loses it once they stop
+	svc.now = func() time.Time {
+		return idempotencyNow.Add(20*IdempotencyHeartbeatInterval + IdempotencyAbandonAfter + time.Second)
+	}
+	record, err := svc.Begin(ctx, "user:maria", "chave-1", "abc")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+}
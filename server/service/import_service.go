@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/repository"
+	"github.com/yMaatheus/tech-challenge-snet/util"
+)
+
+// ImportService creates or updates establishments and stores in bulk from the rows of an import file.
+type ImportService interface {
+	ImportEstablishments(ctx context.Context, rows []model.ImportRow, opts model.ImportOptions) (*model.ImportReport, error)
+	ImportStores(ctx context.Context, rows []model.ImportRow, opts model.ImportOptions) (*model.ImportReport, error)
+}
+
+type importService struct {
+	tx                 repository.Transactor
+	establishmentsRepo repository.EstablishmentRepository
+	storesRepo         repository.StoreRepository
+	establishments     EstablishmentService
+	stores             StoreService
+}
+
+// NewImportService writes through the establishment and store services, so imported rows get
+// the same normalization, checks and audit entries as the ones sent one by one.
+func NewImportService(tx repository.Transactor, establishmentsRepo repository.EstablishmentRepository, storesRepo repository.StoreRepository, establishments EstablishmentService, stores StoreService) ImportService {
+	return &importService{tx: tx, establishmentsRepo: establishmentsRepo, storesRepo: storesRepo, establishments: establishments, stores: stores}
+}
+
+// errRollback discards the writes of a dry run or of a failed atomic import
+var errRollback = errors.New("rollback import")
+
+// importItem is a row that passed validation, waiting to be written
+type importItem[T any] struct {
+	line   int
+	entity T
+}
+
+func (s *importService) ImportEstablishments(ctx context.Context, rows []model.ImportRow, opts model.ImportOptions) (*model.ImportReport, error) {
+	report := newImportReport(opts, len(rows))
+	seen := map[string]int{}
+	var items []importItem[*model.Establishment]
+	for _, row := range rows {
+		e := &model.Establishment{
+			Number:        row.Values["number"],
+			Name:          row.Values["name"],
+			CorporateName: row.Values["corporate_name"],
+			Address:       row.Values["address"],
+			AddressNumber: row.Values["address_number"],
+			City:          row.Values["city"],
+			State:         row.Values["state"],
+			ZipCode:       row.Values["zip_code"],
+		}
+		if err := util.Validate.Struct(e); err != nil {
+			failRow(report, row.Line, domainerr.ValidationFields(util.ParseValidationError(err)))
+			continue
+		}
+		e.Number = util.NormalizeCNPJ(e.Number)
+		if line, ok := seen[e.Number]; ok {
+			failRow(report, row.Line, duplicatedRow(line))
+			continue
+		}
+		seen[e.Number] = row.Line
+		items = append(items, importItem[*model.Establishment]{line: row.Line, entity: e})
+	}
+
+	err := importItems(ctx, s.tx, report, opts, items, func(ctx context.Context, e *model.Establishment) (bool, error) {
+		if opts.Mode == model.ImportModeUpsert {
+			existing, err := s.establishmentsRepo.FindByNumber(ctx, e.Number)
+			if err != nil {
+				return false, err
+			}
+			if existing != nil {
+				e.ID = existing.ID
+				return false, s.establishments.Update(ctx, e)
+			}
+		}
+		return true, s.establishments.Create(ctx, e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (s *importService) ImportStores(ctx context.Context, rows []model.ImportRow, opts model.ImportOptions) (*model.ImportReport, error) {
+	report := newImportReport(opts, len(rows))
+	seen := map[string]int{}
+	establishmentIDs := map[string]int64{}
+	var items []importItem[*model.Store]
+	for _, row := range rows {
+		store := &model.Store{
+			Number:        row.Values["number"],
+			Name:          row.Values["name"],
+			CorporateName: row.Values["corporate_name"],
+			Address:       row.Values["address"],
+			AddressNumber: row.Values["address_number"],
+			City:          row.Values["city"],
+			State:         row.Values["state"],
+			ZipCode:       row.Values["zip_code"],
+		}
+		establishmentID, err := s.resolveEstablishment(ctx, row, establishmentIDs)
+		if err != nil {
+			if !isRowError(err) {
+				return nil, err
+			}
+			failRow(report, row.Line, err)
+			continue
+		}
+		store.EstablishmentID = establishmentID
+		if err := util.Validate.Struct(store); err != nil {
+			failRow(report, row.Line, domainerr.ValidationFields(util.ParseValidationError(err)))
+			continue
+		}
+		store.Number = util.NormalizeCNPJ(store.Number)
+		key := fmt.Sprintf("%d/%s", store.EstablishmentID, store.Number)
+		if line, ok := seen[key]; ok {
+			failRow(report, row.Line, duplicatedRow(line))
+			continue
+		}
+		seen[key] = row.Line
+		items = append(items, importItem[*model.Store]{line: row.Line, entity: store})
+	}
+
+	err := importItems(ctx, s.tx, report, opts, items, func(ctx context.Context, store *model.Store) (bool, error) {
+		if opts.Mode == model.ImportModeUpsert {
+			existing, err := s.storesRepo.FindByNumber(ctx, store.EstablishmentID, store.Number)
+			if err != nil {
+				return false, err
+			}
+			if existing != nil {
+				store.ID = existing.ID
+				return false, s.stores.Update(ctx, store)
+			}
+		}
+		return true, s.stores.Create(ctx, store)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// resolveEstablishment reads the establishment of a store row from establishment_id or, when
+// it is empty, from establishment_number, caching the numbers already looked up.
+// A row without either is left for the validation to report.
+func (s *importService) resolveEstablishment(ctx context.Context, row model.ImportRow, cache map[string]int64) (int64, error) {
+	if raw := strings.TrimSpace(row.Values["establishment_id"]); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			return 0, domainerr.ValidationFields(map[string]string{"establishment_id": "must be a positive integer"})
+		}
+		return id, nil
+	}
+	number := util.NormalizeCNPJ(row.Values["establishment_number"])
+	if number == "" {
+		return 0, nil
+	}
+	if id, ok := cache[number]; ok {
+		return id, nil
+	}
+	var establishment *model.Establishment
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		establishment, err = s.establishmentsRepo.FindByNumber(ctx, number)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if establishment == nil {
+		return 0, &domainerr.ForeignKeyError{Field: "establishment_number", Message: "establishment does not exist"}
+	}
+	cache[number] = establishment.ID
+	return establishment.ID, nil
+}
+
+// importItems writes the validated rows. An atomic import runs in a single transaction that is rolled
+// back at the first failure, and writes nothing when a row already failed validation. Otherwise each
+// row runs in its own transaction and a failure only skips that row. A dry run rolls every write back.
+// Rejected rows are reported, while any other error aborts the import.
+func importItems[T any](ctx context.Context, tx repository.Transactor, report *model.ImportReport, opts model.ImportOptions, items []importItem[T], write func(ctx context.Context, entity T) (bool, error)) error {
+	apply := func(ctx context.Context, item importItem[T]) error {
+		created, err := write(ctx, item.entity)
+		if err != nil {
+			if isRowError(err) {
+				failRow(report, item.line, err)
+				return errRollback
+			}
+			return err
+		}
+		if created {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+		return nil
+	}
+
+	if !opts.Atomic {
+		for _, item := range items {
+			err := tx.WithinTx(ctx, func(ctx context.Context) error {
+				if err := apply(ctx, item); err != nil {
+					return err
+				}
+				if opts.DryRun {
+					return errRollback
+				}
+				return nil
+			})
+			if err != nil && !errors.Is(err, errRollback) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if report.Failed > 0 {
+		return nil
+	}
+	err := tx.WithinTx(ctx, func(ctx context.Context) error {
+		for _, item := range items {
+			if err := apply(ctx, item); err != nil {
+				return err
+			}
+		}
+		if opts.DryRun {
+			return errRollback
+		}
+		return nil
+	})
+	if report.Failed > 0 {
+		report.Created, report.Updated = 0, 0
+	}
+	if err != nil && !errors.Is(err, errRollback) {
+		return err
+	}
+	return nil
+}
+
+func newImportReport(opts model.ImportOptions, rows int) *model.ImportReport {
+	return &model.ImportReport{
+		Mode:   opts.Mode,
+		DryRun: opts.DryRun,
+		Atomic: opts.Atomic,
+		Rows:   rows,
+		Errors: map[int]model.ImportRowError{},
+	}
+}
+
+// isRowError reports whether err rejects the row itself, as opposed to a failure of the import
+func isRowError(err error) bool {
+	return errors.Is(err, domainerr.ErrValidation) || errors.Is(err, domainerr.ErrConflict) ||
+		errors.Is(err, domainerr.ErrForeignKey) || errors.Is(err, domainerr.ErrNotFound)
+}
+
+// failRow records why the row at line was not imported
+func failRow(report *model.ImportReport, line int, err error) {
+	rowErr := model.ImportRowError{Message: err.Error()}
+	var (
+		validation *domainerr.ValidationError
+		conflict   *domainerr.ConflictError
+		foreignKey *domainerr.ForeignKeyError
+	)
+	switch {
+	case errors.As(err, &validation):
+		rowErr.Errors = validation.Fields
+	case errors.As(err, &conflict) && conflict.Field != "":
+		rowErr.Errors = map[string]string{conflict.Field: conflict.Error()}
+	case errors.As(err, &foreignKey) && foreignKey.Field != "":
+		rowErr.Errors = map[string]string{foreignKey.Field: foreignKey.Error()}
+	}
+	report.Errors[line] = rowErr
+	report.Failed++
+}
+
+func duplicatedRow(line int) error {
+	return &domainerr.ConflictError{Field: "number", Message: fmt.Sprintf("number is repeated from line %d of the file", line)}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+func establishmentRow(line int, number, name string) model.ImportRow {
+	return model.ImportRow{Line: line, Values: map[string]string{
+		"number": number, "name": name, "corporate_name": "Razão", "address": "Rua A",
+		"address_number": "10", "city": "São Paulo", "state": "SP", "zip_code": "01001000",
+	}}
+}
+
+func newTestImportService(establishments *mockRepo, stores *mockStoreRepo, tx *mockTx) ImportService {
+	audit := &mockAuditRepo{}
+	return NewImportService(tx, establishments, stores,
+		NewEstablishmentService(establishments, tx, audit, nil),
+		NewStoreService(stores, establishments, tx, audit, nil, nil))
+}
+
+func TestImportService_ImportEstablishments_MelhorEsforco(t *testing.T) {
+	svc := newTestImportService(&mockRepo{}, &mockStoreRepo{}, &mockTx{})
+	rows := []model.ImportRow{
+		establishmentRow(2, "11.222.333/0001-81", "Loja A"),
+		establishmentRow(3, "11444777000161", ""),
+		establishmentRow(4, "11222333000181", "Loja B"),
+		establishmentRow(5, "11444777000161", "Loja C"),
+	}
+
+	report, err := svc.ImportEstablishments(context.Background(), rows, model.ImportOptions{Mode: model.ImportModeCreate})
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Rows)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 2, report.Failed)
+	assert.Contains(t, report.Errors[3].Errors, "name")
+	assert.Equal(t, "number is repeated from line 2 of the file", report.Errors[4].Errors["number"])
+}
+
+func TestImportService_ImportEstablishments_AtomicoNaoGravaComErro(t *testing.T) {
+	tx := &mockTx{}
+	svc := newTestImportService(&mockRepo{}, &mockStoreRepo{}, tx)
+	rows := []model.ImportRow{
+		establishmentRow(2, "11222333000181", "Loja A"),
+		establishmentRow(3, "123", "Loja B"),
+	}
+
+	report, err := svc.ImportEstablishments(context.Background(), rows, model.ImportOptions{Mode: model.ImportModeCreate, Atomic: true})
+	require.NoError(t, err)
+	assert.False(t, tx.called)
+	assert.Equal(t, 0, report.Created)
+	assert.Equal(t, 1, report.Failed)
+	assert.Contains(t, report.Errors[3].Errors, "number")
+}
+
+func TestImportService_ImportEstablishments_Upsert(t *testing.T) {
+	repo := &mockRepo{
+		findByNumberResult: &model.Establishment{ID: 4, Number: "11222333000181", Version: 2},
+		findByIDResult:     &model.Establishment{ID: 4, Number: "11222333000181", Version: 2},
+	}
+	svc := newTestImportService(repo, &mockStoreRepo{}, &mockTx{})
+
+	report, err := svc.ImportEstablishments(context.Background(), []model.ImportRow{establishmentRow(2, "11222333000181", "Loja A")},
+		model.ImportOptions{Mode: model.ImportModeUpsert, Atomic: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, 0, report.Created)
+	assert.Empty(t, report.Errors)
+}
+
+func TestImportService_ImportEstablishments_DryRunDesfaz(t *testing.T) {
+	tx := &mockTx{}
+	svc := newTestImportService(&mockRepo{}, &mockStoreRepo{}, tx)
+
+	report, err := svc.ImportEstablishments(context.Background(), []model.ImportRow{establishmentRow(2, "11222333000181", "Loja A")},
+		model.ImportOptions{Mode: model.ImportModeCreate, Atomic: true, DryRun: true})
+	require.NoError(t, err)
+	assert.ErrorIs(t, tx.err, errRollback)
+	assert.Equal(t, 1, report.Created)
+	assert.True(t, report.DryRun)
+}
+
+func TestImportService_ImportEstablishments_ErroInesperadoInterrompe(t *testing.T) {
+	svc := newTestImportService(&mockRepo{}, &mockStoreRepo{}, &mockTx{})
+
+	report, err := svc.ImportEstablishments(context.Background(), []model.ImportRow{establishmentRow(2, "11222333000181", "erro")},
+		model.ImportOptions{Mode: model.ImportModeCreate})
+	assert.Error(t, err)
+	assert.Nil(t, report)
+}
+
+func TestImportService_ImportStores_PorNumeroDoEstabelecimento(t *testing.T) {
+	establishments := existingEstablishmentRepo()
+	establishments.findByNumberResult = &model.Establishment{ID: 7}
+	var created []*model.Store
+	stores := &mockStoreRepo{CreateFn: func(ctx context.Context, s *model.Store) error {
+		created = append(created, s)
+		return nil
+	}}
+	svc := newTestImportService(establishments, stores, &mockTx{})
+
+	first := establishmentRow(2, "11222333000181", "Loja A")
+	first.Values["establishment_number"] = "11.444.777/0001-61"
+	second := establishmentRow(3, "11444777000161", "Loja B")
+	second.Values["establishment_id"] = "3"
+	invalid := establishmentRow(4, "11444777000161", "Loja C")
+	invalid.Values["establishment_id"] = "abc"
+
+	report, err := svc.ImportStores(context.Background(), []model.ImportRow{first, second, invalid}, model.ImportOptions{Mode: model.ImportModeCreate})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Created)
+	require.Len(t, created, 2)
+	assert.Equal(t, int64(7), created[0].EstablishmentID)
+	assert.Equal(t, int64(3), created[1].EstablishmentID)
+	assert.Contains(t, report.Errors[4].Errors, "establishment_id")
+}
+
+func TestImportService_ImportStores_EstabelecimentoInexistente(t *testing.T) {
+	svc := newTestImportService(&mockRepo{}, &mockStoreRepo{}, &mockTx{})
+	row := establishmentRow(2, "11222333000181", "Loja A")
+	row.Values["establishment_number"] = "11444777000161"
+
+	report, err := svc.ImportStores(context.Background(), []model.ImportRow{row}, model.ImportOptions{Mode: model.ImportModeCreate})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, "establishment does not exist", report.Errors[2].Errors["establishment_number"])
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/util"
+)
+
+// readOnlyFields are managed by the API and cannot be changed by a patch
+var readOnlyFields = []string{"id", "version", "deleted_at", "address_mismatch"}
+
+// maxPatchAttempts bounds how many times a patch is merged again into a row that changed while its address was resolved
+const maxPatchAttempts = 3
+
+// errPatchRaced is returned by an attempt of retryPatch whose row changed between the merge and the write
+var errPatchRaced = errors.New("service: the row changed while the patch was merged")
+
+// retryPatch runs attempt until it does not fail with errPatchRaced, at most maxPatchAttempts times. Each attempt
+// merges the patch into the current row and resolves its address without holding a lock, as that may call slow
+// providers, then writes the result in a transaction that locks the row and fails with errPatchRaced when it is no
+// longer at the version merged into. That only happens to patches sent without a version, as the others fail
+// the version check instead.
+func retryPatch[T any](attempt func() (*T, error)) (*T, error) {
+	for i := 1; ; i++ {
+		result, err := attempt()
+		if !errors.Is(err, errPatchRaced) {
+			return result, err
+		}
+		if i == maxPatchAttempts {
+			return nil, &domainerr.ConflictError{Message: "the record kept changing while the patch was applied; try again"}
+		}
+	}
+}
+
+// applyPatch applies patch to the JSON representation of current and decodes the result into target.
+// Members target does not have are rejected, so a misspelled field is not silently ignored.
+func applyPatch(current interface{}, patch model.Patch, target interface{}) error {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	var patched []byte
+	switch patch.Format {
+	case model.PatchFormatMerge:
+		patched, err = util.MergePatch(doc, patch.Document)
+	case model.PatchFormatJSON:
+		patched, err = util.JSONPatch(doc, patch.Document)
+	default:
+		return domainerr.Validation("Unsupported patch format.")
+	}
+	switch {
+	case errors.Is(err, util.ErrInvalidPatch):
+		return domainerr.Validation(err.Error())
+	case errors.Is(err, util.ErrPatchConflict):
+		return &domainerr.ConflictError{Message: err.Error()}
+	case err != nil:
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return domainerr.ValidationFields(map[string]string{typeErr.Field: "must be a " + typeErr.Type.String()})
+		}
+		return domainerr.Validation("The patched document is invalid: " + strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return nil
+}
+
+// patchedFields lists the fields that differ between before and after, sorted, failing when a read-only one changed.
+func patchedFields(before, after interface{}) ([]string, error) {
+	changes, err := diff(before, after)
+	if err != nil {
+		return nil, err
+	}
+	invalid := map[string]string{}
+	for _, field := range readOnlyFields {
+		if _, ok := changes[field]; ok {
+			invalid[field] = "is read-only"
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, domainerr.ValidationFields(invalid)
+	}
+	fields := make([]string, 0, len(changes))
+	for field := range changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
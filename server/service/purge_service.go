@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/repository"
+)
+
+// PurgeService permanently removes soft deleted establishments and stores.
+type PurgeService interface {
+	Purge(ctx context.Context, before time.Time) (*model.PurgeResult, error)
+}
+
+type purgeService struct {
+	tx                 repository.Transactor
+	establishmentsRepo repository.EstablishmentRepository
+	storesRepo         repository.StoreRepository
+}
+
+func NewPurgeService(tx repository.Transactor, establishmentsRepo repository.EstablishmentRepository, storesRepo repository.StoreRepository) PurgeService {
+	return &purgeService{tx: tx, establishmentsRepo: establishmentsRepo, storesRepo: storesRepo}
+}
+
+// Purge hard deletes the rows of every tenant soft deleted before the given time. Stores go first,
+// so an establishment deleted together with its stores is purged in the same run.
+func (s *purgeService) Purge(ctx context.Context, before time.Time) (*model.PurgeResult, error) {
+	result := &model.PurgeResult{}
+	err := s.tx.WithinTx(tenant.WithAllTenants(ctx), func(ctx context.Context) error {
+		var err error
+		if result.Stores, err = s.storesRepo.Purge(ctx, before); err != nil {
+			return err
+		}
+		result.Establishments, err = s.establishmentsRepo.Purge(ctx, before)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
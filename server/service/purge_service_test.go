@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/domain/tenant"
+)
+
+func TestPurgeService_Purge(t *testing.T) {
+	establishments := &mockRepo{purged: 1}
+	var (
+		storesBefore time.Time
+		allTenants   bool
+	)
+	stores := &mockStoreRepo{
+		PurgeFn: func(ctx context.Context, before time.Time) (int64, error) {
+			storesBefore = before
+			allTenants = tenant.AllTenants(ctx)
+			return 3, nil
+		},
+	}
+	tx := &mockTx{}
+	service := NewPurgeService(tx, establishments, stores)
+
+	before := time.Now().Add(-time.Hour)
+	result, err := service.Purge(context.Background(), before)
+	assert.NoError(t, err)
+	assert.True(t, tx.called)
+	assert.Equal(t, int64(3), result.Stores)
+	assert.Equal(t, int64(1), result.Establishments)
+	assert.Equal(t, before, storesBefore)
+	assert.Equal(t, before, establishments.purgeBefore)
+	assert.True(t, allTenants, "the purge spans every tenant")
+}
+
+func TestPurgeService_Purge_ErroNasStores(t *testing.T) {
+	establishments := &mockRepo{}
+	stores := &mockStoreRepo{
+		PurgeFn: func(ctx context.Context, before time.Time) (int64, error) {
+			return 0, errors.New("db error")
+		},
+	}
+	service := NewPurgeService(&mockTx{}, establishments, stores)
+
+	result, err := service.Purge(context.Background(), time.Now())
+	assert.EqualError(t, err, "db error")
+	assert.Nil(t, result)
+	assert.True(t, establishments.purgeBefore.IsZero(), "establishments must not be purged")
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/repository"
+	"github.com/yMaatheus/tech-challenge-snet/util"
+)
+
+// SearchService searches establishments and stores by text.
+type SearchService interface {
+	Search(ctx context.Context, params model.SearchParams) (*model.SearchResults, error)
+}
+
+type searchService struct {
+	tx   repository.Transactor
+	repo repository.SearchRepository
+}
+
+func NewSearchService(tx repository.Transactor, repo repository.SearchRepository) SearchService {
+	return &searchService{tx: tx, repo: repo}
+}
+
+func (s *searchService) Search(ctx context.Context, params model.SearchParams) (*model.SearchResults, error) {
+	params.Query = strings.TrimSpace(params.Query)
+	params.Terms = searchTerms(params.Query)
+	if utf8.RuneCountInString(params.Query) < model.MinSearchQueryLength || len(params.Terms) == 0 {
+		return nil, domainerr.ValidationFields(map[string]string{"q": "must have at least 2 letters or digits"})
+	}
+
+	var results []model.SearchResult
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		results, err = s.repo.Search(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	number := strings.ToLower(util.NormalizeCNPJ(params.Query))
+	for i := range results {
+		res := &results[i]
+		fields := map[string]string{
+			"name":           highlight(res.Name, params.Terms),
+			"corporate_name": highlight(res.CorporateName, params.Terms),
+			"number":         highlight(res.Number, append([]string{number}, params.Terms...)),
+			"address":        highlight(res.Address, params.Terms),
+			"city":           highlight(res.City, params.Terms),
+		}
+		for field, text := range fields {
+			if text == "" {
+				continue
+			}
+			if res.Highlights == nil {
+				res.Highlights = map[string]string{}
+			}
+			res.Highlights[field] = text
+		}
+	}
+	return &model.SearchResults{Items: results}, nil
+}
+
+// searchTerms splits the query into its words of letters and digits, folded to lower case without accents
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(util.Fold(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// highlight returns text HTML-escaped with the words starting with one of the terms wrapped in <mark>,
+// ignoring case and accents, or an empty string when nothing matches. Results matched only by
+// similarity, such as a misspelled query, have no highlight.
+func highlight(text string, terms []string) string {
+	original := []rune(text)
+	folded := []rune(util.Fold(text))
+	marked := make([]bool, len(folded))
+	found := false
+	for _, term := range terms {
+		t := []rune(term)
+		if len(t) == 0 {
+			continue
+		}
+		for i := 0; i+len(t) <= len(folded); i++ {
+			// only match at the start of a word, like the prefix search does
+			if i > 0 && (unicode.IsLetter(folded[i-1]) || unicode.IsDigit(folded[i-1])) {
+				continue
+			}
+			if string(folded[i:i+len(t)]) != term {
+				continue
+			}
+			for j := i; j < i+len(t); j++ {
+				marked[j] = true
+			}
+			found = true
+		}
+	}
+	if !found {
+		return ""
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(original); {
+		j := i
+		for j < len(original) && marked[j] == marked[i] {
+			j++
+		}
+		if marked[i] {
+			b.WriteString("<mark>" + html.EscapeString(string(original[i:j])) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(string(original[i:j])))
+		}
+		i = j
+	}
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+type mockSearchRepo struct {
+	params  model.SearchParams
+	results []model.SearchResult
+}
+
+func (m *mockSearchRepo) Search(ctx context.Context, params model.SearchParams) ([]model.SearchResult, error) {
+	m.params = params
+	return m.results, nil
+}
+
+func TestSearchService_Search(t *testing.T) {
+	repo := &mockSearchRepo{results: []model.SearchResult{
+		{Type: model.SearchTypeStore, ID: 3, EstablishmentID: 1, Number: "11222333000181", Name: "Açaí <São> Paulo", City: "São Paulo"},
+		{Type: model.SearchTypeEstablishment, ID: 1, Number: "11444777000161", Name: "Sapataria", City: "Recife"},
+	}}
+	svc := NewSearchService(&mockTx{}, repo)
+
+	results, err := svc.Search(context.Background(), model.SearchParams{Query: "  ACAI sao-pa ", Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, "ACAI sao-pa", repo.params.Query)
+	assert.Equal(t, []string{"acai", "sao", "pa"}, repo.params.Terms)
+
+	require.Len(t, results.Items, 2)
+	assert.Equal(t, map[string]string{
+		"name": "<mark>Açaí</mark> &lt;<mark>São</mark>&gt; <mark>Pa</mark>ulo",
+		"city": "<mark>São</mark> <mark>Pa</mark>ulo",
+	}, results.Items[0].Highlights)
+	assert.Nil(t, results.Items[1].Highlights, "sapataria only matches by similarity, so nothing is highlighted")
+}
+
+func TestSearchService_Search_DestacaNumero(t *testing.T) {
+	repo := &mockSearchRepo{results: []model.SearchResult{{Type: model.SearchTypeEstablishment, ID: 1, Number: "11222333000181"}}}
+	svc := NewSearchService(&mockTx{}, repo)
+
+	results, err := svc.Search(context.Background(), model.SearchParams{Query: "11.222.333"})
+	require.NoError(t, err)
+	assert.Equal(t, "<mark>11222333</mark>000181", results.Items[0].Highlights["number"])
+}
+
+func TestSearchService_Search_ConsultaCurta(t *testing.T) {
+	svc := NewSearchService(&mockTx{}, &mockSearchRepo{})
+	for _, q := range []string{"", " a ", "--"} {
+		_, err := svc.Search(context.Background(), model.SearchParams{Query: q})
+		assert.ErrorIs(t, err, domainerr.ErrValidation, q)
+	}
+}
@@ -2,43 +2,348 @@ package service
 
 import (
 	"context"
+	"errors"
 
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/geo"
 	"github.com/yMaatheus/tech-challenge-snet/model"
 	"github.com/yMaatheus/tech-challenge-snet/repository"
+	"github.com/yMaatheus/tech-challenge-snet/util"
 )
 
 type StoreService interface {
 	Create(ctx context.Context, store *model.Store) error
-	FindAll(ctx context.Context) ([]model.Store, error)
+	FindAll(ctx context.Context, params model.ListParams) (*model.StorePage, error)
+	// FindNearby returns the stores within the radius of a point, nearest first.
+	FindNearby(ctx context.Context, params model.NearbyParams) (*model.NearbyStores, error)
 	FindByID(ctx context.Context, id int64) (*model.Store, error)
+	FindAllByEstablishment(ctx context.Context, establishmentID int64, params model.ListParams) (*model.StorePage, error)
+	FindByIDInEstablishment(ctx context.Context, establishmentID, id int64) (*model.Store, error)
+	// Update saves store when it is still at store.Version, setting store.Version to the new version.
 	Update(ctx context.Context, store *model.Store) error
-	Delete(ctx context.Context, id int64) error
+	// Patch applies a partial update to the store while it is at the given version, returning the result.
+	Patch(ctx context.Context, id, version int64, patch model.Patch) (*model.Store, error)
+	// Delete only removes the store while it is at the given version.
+	Delete(ctx context.Context, id, version int64) error
+	Restore(ctx context.Context, id int64) error
 }
 
 type storeService struct {
-	repo repository.StoreRepository
+	repo               repository.StoreRepository
+	establishmentsRepo repository.EstablishmentRepository
+	tx                 repository.Transactor
+	audit              repository.AuditRepository
+	geocoder           geo.Geocoder
+	addresses          AddressService
 }
 
-func NewStoreService(repo repository.StoreRepository) StoreService {
-	return &storeService{repo}
+// NewStoreService creates the store service. Stores saved without coordinates are geocoded from their
+// zip code with geocoder, which may be nil to leave them without coordinates. Their addresses are checked
+// against the zip code with addresses, which may be nil to skip checks.
+func NewStoreService(repo repository.StoreRepository, establishmentsRepo repository.EstablishmentRepository, tx repository.Transactor, audit repository.AuditRepository, geocoder geo.Geocoder, addresses AddressService) StoreService {
+	return &storeService{repo: repo, establishmentsRepo: establishmentsRepo, tx: tx, audit: audit, geocoder: geocoder, addresses: addresses}
 }
 
 func (s *storeService) Create(ctx context.Context, store *model.Store) error {
-	return s.repo.Create(ctx, store)
+	store.Number = util.NormalizeCNPJ(store.Number)
+	store.State = util.NormalizeUF(store.State)
+	store.ZipCode = util.NormalizeCEP(store.ZipCode)
+	if err := s.locate(ctx, store); err != nil {
+		return err
+	}
+	var err error
+	if store.AddressMismatch, err = checkAddress(ctx, s.addresses, store.ZipCode, store.City, store.State); err != nil {
+		return err
+	}
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.checkEstablishment(ctx, store.EstablishmentID); err != nil {
+			return err
+		}
+		if err := s.repo.Create(ctx, store); err != nil {
+			return err
+		}
+		return recordAudit(ctx, s.audit, model.AuditEntityStore, store.ID, model.AuditActionCreate, nil, store)
+	})
 }
 
-func (s *storeService) FindAll(ctx context.Context) ([]model.Store, error) {
-	return s.repo.FindAll(ctx)
+func (s *storeService) FindAll(ctx context.Context, params model.ListParams) (*model.StorePage, error) {
+	var page *model.StorePage
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		page, err = s.repo.FindAll(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (s *storeService) FindNearby(ctx context.Context, params model.NearbyParams) (*model.NearbyStores, error) {
+	var stores []model.StoreDistance
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		stores, err = s.repo.FindNearby(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.NearbyStores{Items: stores}, nil
 }
 
 func (s *storeService) FindByID(ctx context.Context, id int64) (*model.Store, error) {
-	return s.repo.FindByID(ctx, id)
+	var store *model.Store
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		store, err = s.repo.FindByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return nil, domainerr.NotFound("store", id)
+	}
+	return store, nil
+}
+
+// FindAllByEstablishment lists the stores of an establishment, failing with NotFound when it does not exist.
+func (s *storeService) FindAllByEstablishment(ctx context.Context, establishmentID int64, params model.ListParams) (*model.StorePage, error) {
+	var page *model.StorePage
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		establishment, err := s.establishmentsRepo.FindByID(ctx, establishmentID)
+		if err != nil {
+			return err
+		}
+		if establishment == nil {
+			return domainerr.NotFound("establishment", establishmentID)
+		}
+		params.EstablishmentID = establishmentID
+		page, err = s.repo.FindAll(ctx, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// FindByIDInEstablishment returns a store only if it belongs to the given establishment,
+// so a store is never reachable under another establishment's path.
+func (s *storeService) FindByIDInEstablishment(ctx context.Context, establishmentID, id int64) (*model.Store, error) {
+	store, err := s.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if store.EstablishmentID != establishmentID {
+		return nil, domainerr.NotFound("store", id)
+	}
+	return store, nil
 }
 
 func (s *storeService) Update(ctx context.Context, store *model.Store) error {
-	return s.repo.Update(ctx, store)
+	store.Number = util.NormalizeCNPJ(store.Number)
+	store.State = util.NormalizeUF(store.State)
+	store.ZipCode = util.NormalizeCEP(store.ZipCode)
+	if err := s.locate(ctx, store); err != nil {
+		return err
+	}
+	var err error
+	if store.AddressMismatch, err = checkAddress(ctx, s.addresses, store.ZipCode, store.City, store.State); err != nil {
+		return err
+	}
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.findForUpdate(ctx, store.ID)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion("store", store.ID, before.Version, store.Version); err != nil {
+			return err
+		}
+		if err := checkSameEstablishment(before, store); err != nil {
+			return err
+		}
+		if err := s.checkEstablishment(ctx, store.EstablishmentID); err != nil {
+			return err
+		}
+		if err := s.repo.Update(ctx, store); err != nil {
+			return err
+		}
+		return recordAudit(ctx, s.audit, model.AuditEntityStore, store.ID, model.AuditActionUpdate, before, store)
+	})
+}
+
+// Patch applies the patch to the current store, validates the merged result and writes only the
+// columns that changed. A patch that changes nothing leaves the store and its version untouched.
+// The store is geocoded and its address checked before it is locked, so a slow provider does not block its writers.
+func (s *storeService) Patch(ctx context.Context, id, version int64, patch model.Patch) (*model.Store, error) {
+	return retryPatch(func() (*model.Store, error) {
+		current, err := s.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkVersion("store", id, current.Version, version); err != nil {
+			return nil, err
+		}
+		after, columns, err := s.merge(ctx, current, patch)
+		if err != nil {
+			return nil, err
+		}
+		if len(columns) == 0 {
+			return current, nil
+		}
+		err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+			before, err := s.findForUpdate(ctx, id)
+			if err != nil {
+				return err
+			}
+			if err := checkVersion("store", id, before.Version, version); err != nil {
+				return err
+			}
+			if before.Version != current.Version {
+				return errPatchRaced
+			}
+			if err := s.repo.UpdateColumns(ctx, after, columns); err != nil {
+				return err
+			}
+			return recordAudit(ctx, s.audit, model.AuditEntityStore, id, model.AuditActionUpdate, before, after)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return after, nil
+	})
+}
+
+// merge applies the patch to current, geocoding the result and checking its address, and returns it with the
+// columns that changed.
+func (s *storeService) merge(ctx context.Context, current *model.Store, patch model.Patch) (*model.Store, []string, error) {
+	var after model.Store
+	if err := applyPatch(current, patch, &after); err != nil {
+		return nil, nil, err
+	}
+	if err := checkSameEstablishment(current, &after); err != nil {
+		return nil, nil, err
+	}
+	if err := util.Validate.Struct(&after); err != nil {
+		return nil, nil, domainerr.ValidationFields(util.ParseValidationError(err))
+	}
+	after.Number = util.NormalizeCNPJ(after.Number)
+	after.State = util.NormalizeUF(after.State)
+	after.ZipCode = util.NormalizeCEP(after.ZipCode)
+	// Coordinates of the old zip code no longer apply when the patch moves the store without relocating it
+	if s.geocoder != nil && after.ZipCode != current.ZipCode && sameCoordinates(current, &after) {
+		after.Latitude, after.Longitude = nil, nil
+	}
+	if err := s.locate(ctx, &after); err != nil {
+		return nil, nil, err
+	}
+
+	columns, err := patchedFields(current, &after)
+	if err != nil {
+		return nil, nil, err
+	}
+	if columns, err = recheckAddress(ctx, s.addresses, columns, after.ZipCode, after.City, after.State, &after.AddressMismatch); err != nil {
+		return nil, nil, err
+	}
+	return &after, columns, nil
+}
+
+// checkSameEstablishment rejects moving a store by PUT or PATCH, which would leave no trace in its
+// transfer history; stores only change establishment through StoreTransferService.Transfer.
+func checkSameEstablishment(before, after *model.Store) error {
+	if after.EstablishmentID != before.EstablishmentID {
+		return domainerr.ValidationFields(map[string]string{
+			"establishment_id": "cannot be changed here; move the store with POST /stores/{id}/transfer",
+		})
+	}
+	return nil
+}
+
+func (s *storeService) Delete(ctx context.Context, id, version int64) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.findForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion("store", id, before.Version, version); err != nil {
+			return err
+		}
+		if err := s.repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return recordAudit(ctx, s.audit, model.AuditEntityStore, id, model.AuditActionDelete, before, nil)
+	})
+}
+
+// Restore brings back a soft deleted store. A store is only restored together with a live
+// establishment, so the establishment has to be restored first when it was deleted too. The
+// establishment stays locked until the store is back, so it cannot be deleted meanwhile.
+func (s *storeService) Restore(ctx context.Context, id int64) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		establishmentID, err := s.repo.Restore(ctx, id)
+		if err != nil {
+			return err
+		}
+		establishment, err := s.establishmentsRepo.FindByIDForShare(ctx, establishmentID)
+		if err != nil {
+			return err
+		}
+		if establishment == nil {
+			return &domainerr.ConflictError{Field: "establishment_id", Message: "cannot restore store: its establishment is deleted"}
+		}
+		return recordAudit(ctx, s.audit, model.AuditEntityStore, id, model.AuditActionRestore, nil, nil)
+	})
+}
+
+// findForUpdate loads and locks a store, failing with NotFound when it does not exist.
+func (s *storeService) findForUpdate(ctx context.Context, id int64) (*model.Store, error) {
+	store, err := s.repo.FindByIDForUpdate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return nil, domainerr.NotFound("store", id)
+	}
+	return store, nil
+}
+
+// locate geocodes the zip code of a store sent without coordinates. A zip code the geocoder does
+// not know leaves the store without coordinates, so it is only missing from nearby searches.
+func (s *storeService) locate(ctx context.Context, store *model.Store) error {
+	if s.geocoder == nil || store.Latitude != nil || store.Longitude != nil {
+		return nil
+	}
+	point, err := s.geocoder.Geocode(ctx, store.ZipCode)
+	if errors.Is(err, geo.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return domainerr.Unavailable(err)
+	}
+	store.Latitude, store.Longitude = &point.Latitude, &point.Longitude
+	return nil
+}
+
+func sameCoordinates(a, b *model.Store) bool {
+	return equalFloat(a.Latitude, b.Latitude) && equalFloat(a.Longitude, b.Longitude)
+}
+
+func equalFloat(a, b *float64) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
 }
 
-func (s *storeService) Delete(ctx context.Context, id int64) error {
-	return s.repo.Delete(ctx, id)
+// checkEstablishment ensures the store references an existing establishment, so a bad
+// establishment_id is reported as such instead of surfacing as a database failure. It runs within
+// the transaction writing the store and locks the establishment, which cannot be deleted until then.
+func (s *storeService) checkEstablishment(ctx context.Context, establishmentID int64) error {
+	establishment, err := s.establishmentsRepo.FindByIDForShare(ctx, establishmentID)
+	if err != nil {
+		return err
+	}
+	if establishment == nil {
+		return &domainerr.ForeignKeyError{Field: "establishment_id", Message: "establishment does not exist"}
+	}
+	return nil
 }
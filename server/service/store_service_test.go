@@ -3,18 +3,31 @@ package service
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/geo"
 	"github.com/yMaatheus/tech-challenge-snet/model"
 )
 
 type mockStoreRepo struct {
-	CreateFn   func(ctx context.Context, store *model.Store) error
-	FindAllFn  func(ctx context.Context) ([]model.Store, error)
-	FindByIDFn func(ctx context.Context, id int64) (*model.Store, error)
-	UpdateFn   func(ctx context.Context, store *model.Store) error
-	DeleteFn   func(ctx context.Context, id int64) error
+	CreateFn     func(ctx context.Context, store *model.Store) error
+	FindAllFn    func(ctx context.Context, params model.ListParams) (*model.StorePage, error)
+	StreamAllFn  func(ctx context.Context, params model.ListParams, fn func(*model.Store) error) error
+	FindNearbyFn func(ctx context.Context, params model.NearbyParams) ([]model.StoreDistance, error)
+	FindByIDFn   func(ctx context.Context, id int64) (*model.Store, error)
+	// FindByIDForUpdateFn defaults to FindByIDFn
+	FindByIDForUpdateFn func(ctx context.Context, id int64) (*model.Store, error)
+	FindByNumberFn      func(ctx context.Context, establishmentID int64, number string) (*model.Store, error)
+	UpdateFn            func(ctx context.Context, store *model.Store) error
+	UpdateColumnsFn     func(ctx context.Context, store *model.Store, columns []string) error
+	DeleteFn            func(ctx context.Context, id int64) error
+	RestoreFn           func(ctx context.Context, id int64) (int64, error)
+	PurgeFn             func(ctx context.Context, before time.Time) (int64, error)
 }
 
 func (m *mockStoreRepo) Create(ctx context.Context, s *model.Store) error {
@@ -23,11 +36,23 @@ func (m *mockStoreRepo) Create(ctx context.Context, s *model.Store) error {
 	}
 	return nil
 }
-func (m *mockStoreRepo) FindAll(ctx context.Context) ([]model.Store, error) {
+func (m *mockStoreRepo) FindAll(ctx context.Context, params model.ListParams) (*model.StorePage, error) {
 	if m.FindAllFn != nil {
-		return m.FindAllFn(ctx)
+		return m.FindAllFn(ctx, params)
 	}
-	return []model.Store{}, nil
+	return &model.StorePage{Items: []model.Store{}}, nil
+}
+func (m *mockStoreRepo) StreamAll(ctx context.Context, params model.ListParams, fn func(*model.Store) error) error {
+	if m.StreamAllFn != nil {
+		return m.StreamAllFn(ctx, params, fn)
+	}
+	return nil
+}
+func (m *mockStoreRepo) FindNearby(ctx context.Context, params model.NearbyParams) ([]model.StoreDistance, error) {
+	if m.FindNearbyFn != nil {
+		return m.FindNearbyFn(ctx, params)
+	}
+	return []model.StoreDistance{}, nil
 }
 func (m *mockStoreRepo) FindByID(ctx context.Context, id int64) (*model.Store, error) {
 	if m.FindByIDFn != nil {
@@ -35,12 +60,30 @@ func (m *mockStoreRepo) FindByID(ctx context.Context, id int64) (*model.Store, e
 	}
 	return nil, nil
 }
+func (m *mockStoreRepo) FindByIDForUpdate(ctx context.Context, id int64) (*model.Store, error) {
+	if m.FindByIDForUpdateFn != nil {
+		return m.FindByIDForUpdateFn(ctx, id)
+	}
+	return m.FindByID(ctx, id)
+}
+func (m *mockStoreRepo) FindByNumber(ctx context.Context, establishmentID int64, number string) (*model.Store, error) {
+	if m.FindByNumberFn != nil {
+		return m.FindByNumberFn(ctx, establishmentID, number)
+	}
+	return nil, nil
+}
 func (m *mockStoreRepo) Update(ctx context.Context, s *model.Store) error {
 	if m.UpdateFn != nil {
 		return m.UpdateFn(ctx, s)
 	}
 	return nil
 }
+func (m *mockStoreRepo) UpdateColumns(ctx context.Context, s *model.Store, columns []string) error {
+	if m.UpdateColumnsFn != nil {
+		return m.UpdateColumnsFn(ctx, s, columns)
+	}
+	return nil
+}
 func (m *mockStoreRepo) Delete(ctx context.Context, id int64) error {
 	if m.DeleteFn != nil {
 		return m.DeleteFn(ctx, id)
@@ -48,6 +91,29 @@ func (m *mockStoreRepo) Delete(ctx context.Context, id int64) error {
 	return nil
 }
 
+func (m *mockStoreRepo) Restore(ctx context.Context, id int64) (int64, error) {
+	if m.RestoreFn != nil {
+		return m.RestoreFn(ctx, id)
+	}
+	return 1, nil
+}
+func (m *mockStoreRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	if m.PurgeFn != nil {
+		return m.PurgeFn(ctx, before)
+	}
+	return 0, nil
+}
+
+// existingStore is a FindByIDFn that finds every store
+func existingStore(ctx context.Context, id int64) (*model.Store, error) {
+	return &model.Store{ID: id, EstablishmentID: 1, Name: "Loja"}, nil
+}
+
+// existingEstablishmentRepo returns an establishment repository mock where every establishment exists
+func existingEstablishmentRepo() *mockRepo {
+	return &mockRepo{findByIDResult: &model.Establishment{ID: 1}}
+}
+
 func TestStoreService_Create(t *testing.T) {
 	repo := &mockStoreRepo{
 		CreateFn: func(ctx context.Context, s *model.Store) error {
@@ -55,11 +121,15 @@ func TestStoreService_Create(t *testing.T) {
 			return nil
 		},
 	}
-	service := NewStoreService(repo)
+	establishments := existingEstablishmentRepo()
+	tx := &mockTx{}
+	service := NewStoreService(repo, establishments, tx, &mockAuditRepo{}, nil, nil)
 	store := &model.Store{Name: "Loja"}
 	err := service.Create(context.Background(), store)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(99), store.ID)
+	assert.True(t, tx.called)
+	assert.True(t, establishments.shared, "the establishment is locked so it is not deleted under the new store")
 }
 
 func TestStoreService_Create_Erro(t *testing.T) {
@@ -68,42 +138,86 @@ func TestStoreService_Create_Erro(t *testing.T) {
 			return errors.New("erro ao criar")
 		},
 	}
-	service := NewStoreService(repo)
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
 	store := &model.Store{Name: "Loja"}
 	err := service.Create(context.Background(), store)
 	assert.Error(t, err)
 }
 
+func TestStoreService_Update_NormalizesCNPJ(t *testing.T) {
+	var saved string
+	repo := &mockStoreRepo{
+		FindByIDFn: existingStore,
+		UpdateFn: func(ctx context.Context, s *model.Store) error {
+			saved = s.Number
+			return nil
+		},
+	}
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
+	err := service.Update(context.Background(), &model.Store{Number: "12.abc.345/01de-35", EstablishmentID: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "12ABC34501DE35", saved)
+}
+
+func TestStoreService_Create_EstablishmentNotFound(t *testing.T) {
+	createCalled := false
+	repo := &mockStoreRepo{
+		CreateFn: func(ctx context.Context, s *model.Store) error {
+			createCalled = true
+			return nil
+		},
+	}
+	service := NewStoreService(repo, &mockRepo{}, &mockTx{}, &mockAuditRepo{}, nil, nil)
+	err := service.Create(context.Background(), &model.Store{Name: "Loja", EstablishmentID: 42})
+	var fk *domainerr.ForeignKeyError
+	assert.ErrorAs(t, err, &fk)
+	assert.Equal(t, "establishment_id", fk.Field)
+	assert.False(t, createCalled)
+}
+
+func TestStoreService_Update_EstablishmentNotFound(t *testing.T) {
+	service := NewStoreService(&mockStoreRepo{FindByIDFn: existingStore}, &mockRepo{}, &mockTx{}, &mockAuditRepo{}, nil, nil)
+	err := service.Update(context.Background(), &model.Store{ID: 1, EstablishmentID: 1})
+	assert.ErrorIs(t, err, domainerr.ErrForeignKey)
+}
+
+func TestStoreService_Create_EstablishmentLookupError(t *testing.T) {
+	service := NewStoreService(&mockStoreRepo{}, &mockRepo{findByIDErr: domainerr.Unavailable(errors.New("down"))}, &mockTx{}, &mockAuditRepo{}, nil, nil)
+	err := service.Create(context.Background(), &model.Store{EstablishmentID: 1})
+	assert.ErrorIs(t, err, domainerr.ErrUnavailable)
+}
+
 func TestStoreService_FindAll(t *testing.T) {
 	repo := &mockStoreRepo{
-		FindAllFn: func(ctx context.Context) ([]model.Store, error) {
-			return []model.Store{{ID: 1, Name: "Loja"}}, nil
+		FindAllFn: func(ctx context.Context, params model.ListParams) (*model.StorePage, error) {
+			assert.Equal(t, int64(3), params.EstablishmentID)
+			return &model.StorePage{Items: []model.Store{{ID: 1, Name: "Loja"}}, Total: 1}, nil
 		},
 	}
-	service := NewStoreService(repo)
-	stores, err := service.FindAll(context.Background())
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
+	page, err := service.FindAll(context.Background(), model.ListParams{EstablishmentID: 3})
 	assert.NoError(t, err)
-	assert.Len(t, stores, 1)
+	assert.Len(t, page.Items, 1)
 }
 
 func TestStoreService_FindAll_Erro(t *testing.T) {
 	repo := &mockStoreRepo{
-		FindAllFn: func(ctx context.Context) ([]model.Store, error) {
+		FindAllFn: func(ctx context.Context, params model.ListParams) (*model.StorePage, error) {
 			return nil, errors.New("erro find all")
 		},
 	}
-	service := NewStoreService(repo)
-	stores, err := service.FindAll(context.Background())
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
+	page, err := service.FindAll(context.Background(), model.ListParams{})
 	assert.Error(t, err)
-	assert.Nil(t, stores)
+	assert.Nil(t, page)
 }
 
 func TestStoreService_FindAll_Default(t *testing.T) {
 	repo := &mockStoreRepo{}
-	service := NewStoreService(repo)
-	stores, err := service.FindAll(context.Background())
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
+	page, err := service.FindAll(context.Background(), model.ListParams{})
 	assert.NoError(t, err)
-	assert.Len(t, stores, 0)
+	assert.Len(t, page.Items, 0)
 }
 
 func TestStoreService_FindByID(t *testing.T) {
@@ -115,7 +229,7 @@ func TestStoreService_FindByID(t *testing.T) {
 			return nil, nil
 		},
 	}
-	service := NewStoreService(repo)
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
 	store, err := service.FindByID(context.Background(), 1)
 	assert.NoError(t, err)
 	assert.NotNil(t, store)
@@ -127,9 +241,9 @@ func TestStoreService_FindByID_NaoEncontrado(t *testing.T) {
 			return nil, nil
 		},
 	}
-	service := NewStoreService(repo)
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
 	store, err := service.FindByID(context.Background(), 2)
-	assert.NoError(t, err)
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
 	assert.Nil(t, store)
 }
 
@@ -139,7 +253,7 @@ func TestStoreService_FindByID_Erro(t *testing.T) {
 			return nil, errors.New("erro ao buscar")
 		},
 	}
-	service := NewStoreService(repo)
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
 	store, err := service.FindByID(context.Background(), 1)
 	assert.Error(t, err)
 	assert.Nil(t, store)
@@ -147,44 +261,359 @@ func TestStoreService_FindByID_Erro(t *testing.T) {
 
 func TestStoreService_Update(t *testing.T) {
 	repo := &mockStoreRepo{
+		FindByIDFn: existingStore,
 		UpdateFn: func(ctx context.Context, s *model.Store) error {
 			return nil
 		},
 	}
-	service := NewStoreService(repo)
-	err := service.Update(context.Background(), &model.Store{})
+	audit := &mockAuditRepo{}
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, audit, nil, nil)
+	err := service.Update(context.Background(), &model.Store{ID: 3, EstablishmentID: 1, Name: "Loja Nova"})
 	assert.NoError(t, err)
+	assert.Len(t, audit.entries, 1)
+	assert.Equal(t, model.AuditEntityStore, audit.entries[0].Entity)
+	assert.Equal(t, model.FieldChange{Before: "Loja", After: "Loja Nova"}, audit.entries[0].Changes["name"])
 }
 
 func TestStoreService_Update_Erro(t *testing.T) {
 	repo := &mockStoreRepo{
+		FindByIDFn: existingStore,
 		UpdateFn: func(ctx context.Context, s *model.Store) error {
 			return errors.New("erro update")
 		},
 	}
-	service := NewStoreService(repo)
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
 	err := service.Update(context.Background(), &model.Store{})
 	assert.Error(t, err)
 }
 
 func TestStoreService_Delete(t *testing.T) {
 	repo := &mockStoreRepo{
+		FindByIDFn: existingStore,
 		DeleteFn: func(ctx context.Context, id int64) error {
 			return nil
 		},
 	}
-	service := NewStoreService(repo)
-	err := service.Delete(context.Background(), 1)
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
+	err := service.Delete(context.Background(), 1, model.AnyVersion)
 	assert.NoError(t, err)
 }
 
 func TestStoreService_Delete_Erro(t *testing.T) {
 	repo := &mockStoreRepo{
+		FindByIDFn: existingStore,
 		DeleteFn: func(ctx context.Context, id int64) error {
 			return errors.New("erro delete")
 		},
 	}
-	service := NewStoreService(repo)
-	err := service.Delete(context.Background(), 1)
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
+	err := service.Delete(context.Background(), 1, model.AnyVersion)
 	assert.Error(t, err)
 }
+
+func TestStoreService_FindAllByEstablishment(t *testing.T) {
+	var got model.ListParams
+	repo := &mockStoreRepo{
+		FindAllFn: func(ctx context.Context, params model.ListParams) (*model.StorePage, error) {
+			got = params
+			return &model.StorePage{Items: []model.Store{{ID: 1}}}, nil
+		},
+	}
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
+	page, err := service.FindAllByEstablishment(context.Background(), 1, model.ListParams{Limit: 5, EstablishmentID: 9})
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, int64(1), got.EstablishmentID)
+	assert.Equal(t, 5, got.Limit)
+}
+
+func TestStoreService_FindAllByEstablishment_NotFound(t *testing.T) {
+	service := NewStoreService(&mockStoreRepo{}, &mockRepo{}, &mockTx{}, &mockAuditRepo{}, nil, nil)
+	page, err := service.FindAllByEstablishment(context.Background(), 1, model.ListParams{})
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+	assert.Nil(t, page)
+}
+
+func TestStoreService_FindByIDInEstablishment(t *testing.T) {
+	repo := &mockStoreRepo{
+		FindByIDFn: func(ctx context.Context, id int64) (*model.Store, error) {
+			return &model.Store{ID: id, EstablishmentID: 1}, nil
+		},
+	}
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
+
+	store, err := service.FindByIDInEstablishment(context.Background(), 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), store.ID)
+
+	store, err = service.FindByIDInEstablishment(context.Background(), 2, 10)
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+	assert.Nil(t, store)
+}
+
+func TestStoreService_Restore(t *testing.T) {
+	tx := &mockTx{}
+	establishments := existingEstablishmentRepo()
+	service := NewStoreService(&mockStoreRepo{}, establishments, tx, &mockAuditRepo{}, nil, nil)
+	err := service.Restore(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.True(t, tx.called)
+	assert.True(t, establishments.shared)
+}
+
+func TestStoreService_Restore_EstabelecimentoRemovido(t *testing.T) {
+	tx := &mockTx{}
+	service := NewStoreService(&mockStoreRepo{}, &mockRepo{}, tx, &mockAuditRepo{}, nil, nil)
+	err := service.Restore(context.Background(), 1)
+	var conflict *domainerr.ConflictError
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "establishment_id", conflict.Field)
+	assert.Error(t, tx.err, "a transação deve ser revertida")
+}
+
+func TestStoreService_Restore_NaoEncontrada(t *testing.T) {
+	repo := &mockStoreRepo{
+		RestoreFn: func(ctx context.Context, id int64) (int64, error) {
+			return 0, domainerr.NotFound("deleted store", id)
+		},
+	}
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
+	err := service.Restore(context.Background(), 1)
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+}
+
+func TestStoreService_VersaoDesatualizada(t *testing.T) {
+	written := false
+	repo := &mockStoreRepo{
+		FindByIDFn: func(ctx context.Context, id int64) (*model.Store, error) {
+			return &model.Store{ID: id, EstablishmentID: 1, Version: 4}, nil
+		},
+		UpdateFn: func(ctx context.Context, s *model.Store) error {
+			written = true
+			return nil
+		},
+		DeleteFn: func(ctx context.Context, id int64) error {
+			written = true
+			return nil
+		},
+	}
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
+
+	err := service.Update(context.Background(), &model.Store{ID: 1, EstablishmentID: 1, Version: 3})
+	assert.ErrorIs(t, err, domainerr.ErrPrecondition)
+	err = service.Delete(context.Background(), 1, 3)
+	assert.ErrorIs(t, err, domainerr.ErrPrecondition)
+	assert.False(t, written)
+
+	assert.NoError(t, service.Delete(context.Background(), 1, 4))
+	assert.True(t, written)
+}
+
+func TestStoreService_TrocaEstabelecimento_Recusada(t *testing.T) {
+	written := false
+	repo := &mockStoreRepo{
+		FindByIDFn: func(ctx context.Context, id int64) (*model.Store, error) {
+			return &model.Store{ID: id, Number: "44555666000262", Name: "Loja", Address: "Rua", City: "Recife",
+				State: "PE", ZipCode: "50000000", AddressNumber: "1", EstablishmentID: 1, Version: 1}, nil
+		},
+		UpdateFn: func(ctx context.Context, s *model.Store) error {
+			written = true
+			return nil
+		},
+		UpdateColumnsFn: func(ctx context.Context, s *model.Store, c []string) error {
+			written = true
+			return nil
+		},
+	}
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
+
+	patch := model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"establishment_id": 2}`)}
+	_, err := service.Patch(context.Background(), 1, 1, patch)
+	var verr *domainerr.ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Contains(t, verr.Fields["establishment_id"], "/stores/{id}/transfer")
+
+	err = service.Update(context.Background(), &model.Store{ID: 1, EstablishmentID: 2, Name: "Loja", Version: 1})
+	require.ErrorAs(t, err, &verr, "moving a store by PUT skips the transfer history too")
+	assert.False(t, written)
+}
+
+// mockGeocoder geocodes with the function itself
+type mockGeocoder func(ctx context.Context, zipCode string) (geo.Point, error)
+
+func (m mockGeocoder) Geocode(ctx context.Context, zipCode string) (geo.Point, error) {
+	return m(ctx, zipCode)
+}
+
+// testGeocoder is an offline geocoder that knows the CEP 01310-100 and the region 50000
+func testGeocoder(t *testing.T) geo.Geocoder {
+	dataset, err := geo.ReadCEPDataset(strings.NewReader("cep,latitude,longitude\n01310-100,-23.5613,-46.6565\n50000,-8.0476,-34.8770\n"))
+	require.NoError(t, err)
+	return dataset
+}
+
+func TestStoreService_Create_Geocodifica(t *testing.T) {
+	var saved model.Store
+	repo := &mockStoreRepo{CreateFn: func(ctx context.Context, s *model.Store) error {
+		saved = *s
+		return nil
+	}}
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, testGeocoder(t), nil)
+
+	require.NoError(t, service.Create(context.Background(), &model.Store{Name: "Loja", ZipCode: "01310100"}))
+	require.NotNil(t, saved.Latitude)
+	assert.Equal(t, -23.5613, *saved.Latitude)
+	assert.Equal(t, -46.6565, *saved.Longitude)
+
+	// Coordinates sent by the client are kept
+	lat, lng := -23.5, -46.6
+	require.NoError(t, service.Create(context.Background(), &model.Store{Name: "Loja", ZipCode: "01310100", Latitude: &lat, Longitude: &lng}))
+	assert.Equal(t, -23.5, *saved.Latitude)
+
+	// An unknown zip code leaves the store without coordinates
+	require.NoError(t, service.Create(context.Background(), &model.Store{Name: "Loja", ZipCode: "99999999"}))
+	assert.Nil(t, saved.Latitude)
+	assert.Nil(t, saved.Longitude)
+}
+
+func TestStoreService_Create_GeocodificadorIndisponivel(t *testing.T) {
+	createCalled := false
+	repo := &mockStoreRepo{CreateFn: func(ctx context.Context, s *model.Store) error {
+		createCalled = true
+		return nil
+	}}
+	geocoder := mockGeocoder(func(ctx context.Context, zipCode string) (geo.Point, error) {
+		return geo.Point{}, errors.New("timeout")
+	})
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, geocoder, nil)
+	err := service.Create(context.Background(), &model.Store{Name: "Loja", ZipCode: "01310100"})
+	assert.ErrorIs(t, err, domainerr.ErrUnavailable)
+	assert.False(t, createCalled)
+}
+
+func TestStoreService_Patch_TrocaCEPGeocodifica(t *testing.T) {
+	lat, lng := -23.5613, -46.6565
+	var saved model.Store
+	var columns []string
+	repo := &mockStoreRepo{
+		FindByIDFn: func(ctx context.Context, id int64) (*model.Store, error) {
+			return &model.Store{ID: id, Number: "44555666000262", Name: "Loja", Address: "Rua", City: "São Paulo", State: "SP",
+				ZipCode: "01310100", AddressNumber: "1", EstablishmentID: 1, Latitude: &lat, Longitude: &lng, Version: 1}, nil
+		},
+		UpdateColumnsFn: func(ctx context.Context, s *model.Store, c []string) error {
+			saved, columns = *s, c
+			return nil
+		},
+	}
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, testGeocoder(t), nil)
+
+	_, err := service.Patch(context.Background(), 1, 1, model.Patch{Format: model.PatchFormatMerge,
+		Document: []byte(`{"city": "Recife", "state": "PE", "zip_code": "50000100"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"city", "latitude", "longitude", "state", "zip_code"}, columns)
+	assert.Equal(t, -8.0476, *saved.Latitude)
+
+	// Coordinates sent with the new zip code win over geocoding
+	_, err = service.Patch(context.Background(), 1, 1, model.Patch{Format: model.PatchFormatMerge,
+		Document: []byte(`{"zip_code": "50010000", "latitude": -8.1, "longitude": -34.9}`)})
+	require.NoError(t, err)
+	assert.Equal(t, -8.1, *saved.Latitude)
+
+	// Only one coordinate is rejected
+	_, err = service.Patch(context.Background(), 1, 1, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"longitude": null}`)})
+	assert.ErrorIs(t, err, domainerr.ErrValidation)
+}
+
+// activeTx runs the function directly, telling whether a transaction is open
+type activeTx struct {
+	active bool
+}
+
+func (m *activeTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	m.active = true
+	defer func() { m.active = false }()
+	return fn(ctx)
+}
+
+func TestStoreService_Patch_GeocodificaForaDaTransacao(t *testing.T) {
+	tx := &activeTx{}
+	locked := 0
+	repo := &mockStoreRepo{
+		FindByIDFn: func(ctx context.Context, id int64) (*model.Store, error) {
+			return &model.Store{ID: id, Number: "44555666000262", Name: "Loja", Address: "Rua", City: "Recife", State: "PE",
+				ZipCode: "50000100", AddressNumber: "1", EstablishmentID: 1, Version: 1}, nil
+		},
+	}
+	repo.FindByIDForUpdateFn = func(ctx context.Context, id int64) (*model.Store, error) {
+		locked++
+		return repo.FindByIDFn(ctx, id)
+	}
+	geocoder := mockGeocoder(func(ctx context.Context, zipCode string) (geo.Point, error) {
+		assert.False(t, tx.active, "no row is locked while the geocoder runs")
+		assert.Zero(t, locked)
+		return geo.Point{Latitude: -8.05, Longitude: -34.9}, nil
+	})
+	service := NewStoreService(repo, existingEstablishmentRepo(), tx, &mockAuditRepo{}, geocoder, nil)
+
+	store, err := service.Patch(context.Background(), 1, 1, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"zip_code": "50010000"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, -8.05, *store.Latitude)
+	assert.Equal(t, 1, locked)
+}
+
+func TestStoreService_Patch_LinhaMudouNoMeio(t *testing.T) {
+	version := int64(1)
+	stored := func(id, version int64) *model.Store {
+		return &model.Store{ID: id, Number: "44555666000262", Name: "Loja", Address: "Rua", City: "Recife", State: "PE",
+			ZipCode: "50000100", AddressNumber: "1", EstablishmentID: 1, Version: version}
+	}
+	var columns []string
+	repo := &mockStoreRepo{
+		FindByIDFn: func(ctx context.Context, id int64) (*model.Store, error) {
+			return stored(id, version), nil
+		},
+		UpdateColumnsFn: func(ctx context.Context, s *model.Store, c []string) error {
+			columns = c
+			return nil
+		},
+	}
+	// Another writer bumps the version between the merge and the lock
+	repo.FindByIDForUpdateFn = func(ctx context.Context, id int64) (*model.Store, error) {
+		store, _ := repo.FindByIDFn(ctx, id)
+		if version == 1 {
+			version = 2
+			store.Version = 2
+		}
+		return store, nil
+	}
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
+	_, err := service.Patch(context.Background(), 1, 1, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"name": "Nova"}`)})
+	assert.ErrorIs(t, err, domainerr.ErrPrecondition, "a patch sent with a version is not merged again")
+	assert.Nil(t, columns)
+
+	// Without a version the patch is merged into the new row
+	version = 1
+	store, err := service.Patch(context.Background(), 1, model.AnyVersion, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"name": "Nova"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), store.Version)
+	assert.Equal(t, []string{"name"}, columns)
+
+	// and gives up on a row that keeps changing
+	repo.FindByIDForUpdateFn = func(ctx context.Context, id int64) (*model.Store, error) {
+		return stored(id, version+1), nil
+	}
+	_, err = service.Patch(context.Background(), 1, model.AnyVersion, model.Patch{Format: model.PatchFormatMerge, Document: []byte(`{"name": "Outra"}`)})
+	assert.ErrorIs(t, err, domainerr.ErrConflict)
+}
+
+func TestStoreService_FindNearby(t *testing.T) {
+	params := model.NearbyParams{Latitude: -23.56, Longitude: -46.65, RadiusKm: 5, EstablishmentID: 1, Limit: 10}
+	repo := &mockStoreRepo{FindNearbyFn: func(ctx context.Context, p model.NearbyParams) ([]model.StoreDistance, error) {
+		assert.Equal(t, params, p)
+		return []model.StoreDistance{{Store: model.Store{ID: 3}, DistanceKm: 1.2}}, nil
+	}}
+	service := NewStoreService(repo, existingEstablishmentRepo(), &mockTx{}, &mockAuditRepo{}, nil, nil)
+	result, err := service.FindNearby(context.Background(), params)
+	require.NoError(t, err)
+	assert.Equal(t, []model.StoreDistance{{Store: model.Store{ID: 3}, DistanceKm: 1.2}}, result.Items)
+}
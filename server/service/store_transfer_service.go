@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+
+	"github.com/yMaatheus/tech-challenge-snet/domain/actor"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+	"github.com/yMaatheus/tech-challenge-snet/repository"
+)
+
+// StoreTransferService moves stores between establishments and keeps their transfer history.
+type StoreTransferService interface {
+	Transfer(ctx context.Context, storeID int64, req model.StoreTransferRequest) (*model.StoreTransfer, error)
+	FindByStoreID(ctx context.Context, storeID int64) ([]model.StoreTransfer, error)
+}
+
+type storeTransferService struct {
+	tx                 repository.Transactor
+	storesRepo         repository.StoreRepository
+	establishmentsRepo repository.EstablishmentRepository
+	repo               repository.StoreTransferRepository
+	audit              repository.AuditRepository
+}
+
+func NewStoreTransferService(tx repository.Transactor, storesRepo repository.StoreRepository, establishmentsRepo repository.EstablishmentRepository, repo repository.StoreTransferRepository, audit repository.AuditRepository) StoreTransferService {
+	return &storeTransferService{tx: tx, storesRepo: storesRepo, establishmentsRepo: establishmentsRepo, repo: repo, audit: audit}
+}
+
+// Transfer moves a store to another establishment and records the transfer in the same transaction.
+// The store row stays locked until the transaction ends, so concurrent transfers of the same store
+// are applied one after the other and each history entry reflects the establishment it really left.
+// The target establishment is locked too, so it cannot be deleted while the store moves in.
+func (s *storeTransferService) Transfer(ctx context.Context, storeID int64, req model.StoreTransferRequest) (*model.StoreTransfer, error) {
+	var transfer *model.StoreTransfer
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		store, err := s.storesRepo.FindByIDForUpdate(ctx, storeID)
+		if err != nil {
+			return err
+		}
+		if store == nil {
+			return domainerr.NotFound("store", storeID)
+		}
+		if store.EstablishmentID == req.ToEstablishmentID {
+			return &domainerr.ConflictError{Field: "to_establishment_id", Message: "store already belongs to this establishment"}
+		}
+
+		from, err := s.establishmentsRepo.FindByID(ctx, store.EstablishmentID)
+		if err != nil {
+			return err
+		}
+		if from == nil {
+			return domainerr.NotFound("establishment", store.EstablishmentID)
+		}
+		to, err := s.establishmentsRepo.FindByIDForShare(ctx, req.ToEstablishmentID)
+		if err != nil {
+			return err
+		}
+		if to == nil {
+			return &domainerr.ForeignKeyError{Field: "to_establishment_id", Message: "establishment does not exist"}
+		}
+
+		before := *store
+		store.EstablishmentID = req.ToEstablishmentID
+		if err := s.storesRepo.Update(ctx, store); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, s.audit, model.AuditEntityStore, storeID, model.AuditActionUpdate, before, store); err != nil {
+			return err
+		}
+
+		transfer = &model.StoreTransfer{
+			StoreID:             storeID,
+			FromEstablishmentID: from.ID,
+			ToEstablishmentID:   to.ID,
+			Reason:              req.Reason,
+			TransferredBy:       actor.FromContext(ctx),
+		}
+		return s.repo.Create(ctx, transfer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// FindByStoreID lists the transfers of a store, newest first, failing with NotFound when the store does not exist.
+func (s *storeTransferService) FindByStoreID(ctx context.Context, storeID int64) ([]model.StoreTransfer, error) {
+	var transfers []model.StoreTransfer
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		store, err := s.storesRepo.FindByID(ctx, storeID)
+		if err != nil {
+			return err
+		}
+		if store == nil {
+			return domainerr.NotFound("store", storeID)
+		}
+		transfers, err = s.repo.FindByStoreID(ctx, storeID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
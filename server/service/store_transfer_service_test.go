@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yMaatheus/tech-challenge-snet/domain/actor"
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+// mockTx runs the function directly, recording whether it was called and what it returned
+type mockTx struct {
+	called bool
+	err    error
+}
+
+func (m *mockTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	m.called = true
+	m.err = fn(ctx)
+	return m.err
+}
+
+// establishmentsByID is an establishment repository mock where only the given establishments exist
+type establishmentsByID struct {
+	mockRepo
+	ids    map[int64]bool
+	shared []int64
+}
+
+func (m *establishmentsByID) FindByID(ctx context.Context, id int64) (*model.Establishment, error) {
+	if !m.ids[id] {
+		return nil, nil
+	}
+	return &model.Establishment{ID: id}, nil
+}
+
+func (m *establishmentsByID) FindByIDForShare(ctx context.Context, id int64) (*model.Establishment, error) {
+	m.shared = append(m.shared, id)
+	return m.FindByID(ctx, id)
+}
+
+type mockStoreTransferRepo struct {
+	created  *model.StoreTransfer
+	listed   []model.StoreTransfer
+	listedID int64
+}
+
+func (m *mockStoreTransferRepo) Create(ctx context.Context, t *model.StoreTransfer) error {
+	t.ID = 1
+	m.created = t
+	return nil
+}
+func (m *mockStoreTransferRepo) FindByStoreID(ctx context.Context, storeID int64) ([]model.StoreTransfer, error) {
+	m.listedID = storeID
+	return m.listed, nil
+}
+
+func storeInEstablishment(establishmentID int64) *mockStoreRepo {
+	return &mockStoreRepo{
+		FindByIDFn: func(ctx context.Context, id int64) (*model.Store, error) {
+			return &model.Store{ID: id, Name: "Loja", EstablishmentID: establishmentID}, nil
+		},
+	}
+}
+
+func TestStoreTransferService_Transfer(t *testing.T) {
+	stores := storeInEstablishment(1)
+	var updated *model.Store
+	stores.UpdateFn = func(ctx context.Context, s *model.Store) error {
+		updated = s
+		return nil
+	}
+	tx := &mockTx{}
+	transfers := &mockStoreTransferRepo{}
+	establishments := &establishmentsByID{ids: map[int64]bool{1: true, 2: true}}
+	service := NewStoreTransferService(tx, stores, establishments, transfers, &mockAuditRepo{})
+
+	ctx := actor.WithActor(context.Background(), "maria")
+	transfer, err := service.Transfer(ctx, 5, model.StoreTransferRequest{ToEstablishmentID: 2, Reason: "reorganização"})
+	assert.NoError(t, err)
+	assert.True(t, tx.called)
+	assert.Equal(t, []int64{2}, establishments.shared, "the target establishment is locked")
+	assert.Equal(t, int64(2), updated.EstablishmentID)
+	assert.Equal(t, transfers.created, transfer)
+	assert.Equal(t, int64(5), transfer.StoreID)
+	assert.Equal(t, int64(1), transfer.FromEstablishmentID)
+	assert.Equal(t, int64(2), transfer.ToEstablishmentID)
+	assert.Equal(t, "reorganização", transfer.Reason)
+	assert.Equal(t, "maria", transfer.TransferredBy)
+}
+
+func TestStoreTransferService_Transfer_SemActor(t *testing.T) {
+	transfers := &mockStoreTransferRepo{}
+	service := NewStoreTransferService(&mockTx{}, storeInEstablishment(1), &establishmentsByID{ids: map[int64]bool{1: true, 2: true}}, transfers, &mockAuditRepo{})
+	_, err := service.Transfer(context.Background(), 5, model.StoreTransferRequest{ToEstablishmentID: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, actor.Anonymous, transfers.created.TransferredBy)
+}
+
+func TestStoreTransferService_Transfer_StoreNaoEncontrada(t *testing.T) {
+	tx := &mockTx{}
+	service := NewStoreTransferService(tx, &mockStoreRepo{}, &establishmentsByID{ids: map[int64]bool{2: true}}, &mockStoreTransferRepo{}, &mockAuditRepo{})
+	_, err := service.Transfer(context.Background(), 5, model.StoreTransferRequest{ToEstablishmentID: 2})
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+	assert.ErrorIs(t, tx.err, domainerr.ErrNotFound, "the transaction must be rolled back")
+}
+
+func TestStoreTransferService_Transfer_MesmoEstabelecimento(t *testing.T) {
+	transfers := &mockStoreTransferRepo{}
+	service := NewStoreTransferService(&mockTx{}, storeInEstablishment(2), &establishmentsByID{ids: map[int64]bool{2: true}}, transfers, &mockAuditRepo{})
+	_, err := service.Transfer(context.Background(), 5, model.StoreTransferRequest{ToEstablishmentID: 2})
+	var conflict *domainerr.ConflictError
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "to_establishment_id", conflict.Field)
+	assert.Nil(t, transfers.created)
+}
+
+func TestStoreTransferService_Transfer_DestinoInexistente(t *testing.T) {
+	stores := storeInEstablishment(1)
+	stores.UpdateFn = func(ctx context.Context, s *model.Store) error {
+		t.Fatal("store must not be updated")
+		return nil
+	}
+	transfers := &mockStoreTransferRepo{}
+	service := NewStoreTransferService(&mockTx{}, stores, &establishmentsByID{ids: map[int64]bool{1: true}}, transfers, &mockAuditRepo{})
+	_, err := service.Transfer(context.Background(), 5, model.StoreTransferRequest{ToEstablishmentID: 2})
+	var fk *domainerr.ForeignKeyError
+	assert.ErrorAs(t, err, &fk)
+	assert.Equal(t, "to_establishment_id", fk.Field)
+	assert.Nil(t, transfers.created)
+}
+
+func TestStoreTransferService_Transfer_ErroNoUpdate(t *testing.T) {
+	stores := storeInEstablishment(1)
+	stores.UpdateFn = func(ctx context.Context, s *model.Store) error {
+		return errors.New("db error")
+	}
+	transfers := &mockStoreTransferRepo{}
+	service := NewStoreTransferService(&mockTx{}, stores, &establishmentsByID{ids: map[int64]bool{1: true, 2: true}}, transfers, &mockAuditRepo{})
+	_, err := service.Transfer(context.Background(), 5, model.StoreTransferRequest{ToEstablishmentID: 2})
+	assert.EqualError(t, err, "db error")
+	assert.Nil(t, transfers.created)
+}
+
+func TestStoreTransferService_FindByStoreID(t *testing.T) {
+	transfers := &mockStoreTransferRepo{listed: []model.StoreTransfer{{ID: 1, StoreID: 5}}}
+	service := NewStoreTransferService(&mockTx{}, storeInEstablishment(1), &establishmentsByID{}, transfers, &mockAuditRepo{})
+	result, err := service.FindByStoreID(context.Background(), 5)
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, int64(5), transfers.listedID)
+}
+
+func TestStoreTransferService_FindByStoreID_NaoEncontrada(t *testing.T) {
+	service := NewStoreTransferService(&mockTx{}, &mockStoreRepo{}, &establishmentsByID{}, &mockStoreTransferRepo{}, &mockAuditRepo{})
+	_, err := service.FindByStoreID(context.Background(), 5)
+	assert.ErrorIs(t, err, domainerr.ErrNotFound)
+}
@@ -0,0 +1,15 @@
+package service
+
+import (
+	domainerr "github.com/yMaatheus/tech-challenge-snet/domain/errors"
+	"github.com/yMaatheus/tech-challenge-snet/model"
+)
+
+// checkVersion fails with PreconditionFailed when the entity moved past the version the client expects.
+// Callers hold the row lock, so the version cannot change between the check and the write.
+func checkVersion(entity string, id, current, expected int64) error {
+	if expected != model.AnyVersion && expected != current {
+		return domainerr.PreconditionFailed(entity, id)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package util
+
+import (
+	"slices"
+	"strings"
+)
+
+// UFs lists the abbreviations of the 26 Brazilian states and the Federal District
+var UFs = []string{
+	"AC", "AL", "AP", "AM", "BA", "CE", "DF", "ES", "GO", "MA", "MT", "MS", "MG", "PA",
+	"PB", "PR", "PE", "PI", "RJ", "RN", "RS", "RO", "RR", "SC", "SP", "SE", "TO",
+}
+
+// NormalizeUF trims and upper-cases a state abbreviation, so " sp" is stored as "SP".
+func NormalizeUF(s string) string {
+	return strings.ToUpper(strings.TrimSpace(s))
+}
+
+// IsValidUF reports whether s is the abbreviation of a Brazilian state or the Federal District, in any case.
+func IsValidUF(s string) bool {
+	return slices.Contains(UFs, NormalizeUF(s))
+}
+
+// NormalizeCEP returns the canonical form of a CEP, its 8 digits, so "01310-100" is stored as "01310100".
+// Malformed CEPs are only trimmed.
+func NormalizeCEP(s string) string {
+	s = strings.TrimSpace(s)
+	if !IsValidCEP(s) {
+		return s
+	}
+	return strings.Replace(s, "-", "", 1)
+}
+
+// IsValidCEP reports whether s is a CEP written as 12345-678 or 12345678.
+func IsValidCEP(s string) bool {
+	s = strings.TrimSpace(s)
+	if len(s) == 9 && s[5] == '-' {
+		s = s[:5] + s[6:]
+	}
+	if len(s) != 8 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValidUF(t *testing.T) {
+	assert.Len(t, UFs, 27)
+	for _, uf := range []string{"SP", "sp", " Pe ", "DF", "TO"} {
+		assert.True(t, IsValidUF(uf), uf)
+	}
+	for _, uf := range []string{"", "CA", "CB", "S", "SPP", "S P"} {
+		assert.False(t, IsValidUF(uf), uf)
+	}
+	assert.Equal(t, "SP", NormalizeUF(" sp "))
+}
+
+func TestCEP(t *testing.T) {
+	for in, want := range map[string]string{"01310-100": "01310100", "01310100": "01310100", " 50030-230 ": "50030230"} {
+		assert.True(t, IsValidCEP(in), in)
+		assert.Equal(t, want, NormalizeCEP(in), in)
+	}
+	for _, in := range []string{"", "0131010", "013101000", "01310-10", "01.310-100", "0131a100", "0131-0100", "01310_100"} {
+		assert.False(t, IsValidCEP(in), in)
+	}
+	assert.Equal(t, "0131-0100", NormalizeCEP(" 0131-0100"))
+}
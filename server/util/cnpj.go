@@ -0,0 +1,65 @@
+package util
+
+import "strings"
+
+// NormalizeCNPJ strips the punctuation of a CNPJ and upper-cases its letters,
+// so "12.345.678/0001-95" is stored as "12345678000195".
+func NormalizeCNPJ(s string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '/', '-', ' ':
+			return -1
+		}
+		return r
+	}, s))
+}
+
+// IsValidCNPJ reports whether s is a valid CNPJ, formatted or not. Besides the
+// numeric format it accepts the alphanumeric one, where the first 12 characters
+// may be letters and only the two check digits must be numeric.
+func IsValidCNPJ(s string) bool {
+	cnpj := NormalizeCNPJ(s)
+	if len(cnpj) != 14 {
+		return false
+	}
+
+	values := make([]int, 14)
+	allEqual := true
+	for i := 0; i < 14; i++ {
+		c := cnpj[i]
+		switch {
+		case c >= '0' && c <= '9':
+		case c >= 'A' && c <= 'Z' && i < 12:
+		default:
+			return false
+		}
+		// Letters are worth their ASCII code minus 48, so digits keep their face value
+		values[i] = int(c) - '0'
+		if c != cnpj[0] {
+			allEqual = false
+		}
+	}
+	if allEqual {
+		return false
+	}
+
+	return values[12] == cnpjCheckDigit(values[:12]) && values[13] == cnpjCheckDigit(values[:13])
+}
+
+// cnpjCheckDigit computes the modulo 11 check digit of the given values,
+// weighting them from right to left with 2 to 9 cyclically.
+func cnpjCheckDigit(values []int) int {
+	sum := 0
+	weight := 2
+	for i := len(values) - 1; i >= 0; i-- {
+		sum += values[i] * weight
+		weight++
+		if weight > 9 {
+			weight = 2
+		}
+	}
+	if rest := sum % 11; rest >= 2 {
+		return 11 - rest
+	}
+	return 0
+}
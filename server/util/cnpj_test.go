@@ -0,0 +1,45 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValidCNPJ(t *testing.T) {
+	cases := map[string]bool{
+		"11.222.333/0001-81": true,
+		"11222333000181":     true,
+		"12.ABC.345/01DE-35": true,
+		"12abc34501de35":     true,
+		"11.222.333/0001-82": false,
+		"12.ABC.345/01DE-36": false,
+		"00000000000000":     false,
+		"1122233300018":      false,
+		"112223330001810":    false,
+		"12ABC34501DEX5":     false,
+		"E001":               false,
+		"":                   false,
+	}
+	for cnpj, want := range cases {
+		assert.Equal(t, want, IsValidCNPJ(cnpj), cnpj)
+	}
+}
+
+func TestNormalizeCNPJ(t *testing.T) {
+	assert.Equal(t, "11222333000181", NormalizeCNPJ("11.222.333/0001-81"))
+	assert.Equal(t, "12ABC34501DE35", NormalizeCNPJ("12.abc.345/01de-35"))
+}
+
+func TestParseValidationError_DescribesFields(t *testing.T) {
+	type payload struct {
+		Number string `json:"number" validate:"required,cnpj"`
+		State  string `json:"state" validate:"required,len=2"`
+		Name   string `json:"name" validate:"required"`
+	}
+	err := Validate.Struct(&payload{Number: "123", State: "SPP"})
+	errs := ParseValidationError(err)
+	assert.Contains(t, errs["number"], "valid CNPJ")
+	assert.Equal(t, "must be exactly 2 characters long", errs["state"])
+	assert.Equal(t, "is required", errs["name"])
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points after a row of a list: the row with the given sort value and id, in the order given by Sort,
+// such as "-name", so a cursor is not followed under another order
+type Cursor struct {
+	Sort  string `json:"s,omitempty"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+// EncodeCursor builds an opaque cursor out of c
+func EncodeCursor(c Cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor returns the cursor built by EncodeCursor
+func DecodeCursor(s string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID <= 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
@@ -0,0 +1,399 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Bounds of a JSON Patch. Copying a node into itself doubles the document, so a short patch could
+// otherwise grow it past the memory of the server.
+const (
+	// MaxJSONPatchOps is the most operations a JSON Patch may have
+	MaxJSONPatchOps = 100
+	// MaxPatchedSize is how large, encoded, a document may grow while a JSON Patch is applied
+	MaxPatchedSize = 64 << 10
+)
+
+var (
+	// ErrInvalidPatch is returned when a patch document is malformed
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrPatchConflict is returned when a well formed patch does not apply to the document,
+	// such as a path that does not exist or a failed JSON Patch test
+	ErrPatchConflict = errors.New("patch does not apply")
+)
+
+// MergePatch applies an RFC 7396 JSON Merge Patch to doc: objects are merged recursively,
+// null removes a member and any other value replaces it.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	target, err := decodeJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	p, err := decodeJSON(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+		} else {
+			t[key] = mergePatch(t[key], value)
+		}
+	}
+	return t
+}
+
+// jsonPatchOp is an operation of an RFC 6902 JSON Patch
+type jsonPatchOp struct {
+	op, path, from string
+	value          interface{}
+	hasValue       bool
+}
+
+// JSONPatch applies an RFC 6902 JSON Patch to doc. The operations are applied in order and
+// the patch is all or nothing: doc is left untouched when any operation fails. Patches with more than
+// MaxJSONPatchOps operations, or growing the document past MaxPatchedSize, are invalid.
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+	target, err := decodeJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := parseJSONPatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	var patched []byte
+	for i, op := range ops {
+		if target, err = op.apply(target); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.op, op.path, err)
+		}
+		if patched, err = json.Marshal(target); err != nil {
+			return nil, err
+		}
+		if len(patched) > MaxPatchedSize {
+			return nil, fmt.Errorf("%w: operation %d (%s %s) grows the document past %d KB", ErrInvalidPatch, i, op.op, op.path, MaxPatchedSize>>10)
+		}
+	}
+	if patched == nil {
+		return json.Marshal(target)
+	}
+	return patched, nil
+}
+
+func parseJSONPatch(patch []byte) ([]jsonPatchOp, error) {
+	var raw []map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &raw); err != nil {
+		return nil, fmt.Errorf("%w: a JSON Patch must be an array of operations", ErrInvalidPatch)
+	}
+	if len(raw) > MaxJSONPatchOps {
+		return nil, fmt.Errorf("%w: a JSON Patch may have at most %d operations", ErrInvalidPatch, MaxJSONPatchOps)
+	}
+	ops := make([]jsonPatchOp, 0, len(raw))
+	for i, fields := range raw {
+		var op jsonPatchOp
+		for name, target := range map[string]*string{"op": &op.op, "path": &op.path, "from": &op.from} {
+			if value, ok := fields[name]; ok {
+				if err := json.Unmarshal(value, target); err != nil {
+					return nil, fmt.Errorf("%w: operation %d: %q must be a string", ErrInvalidPatch, i, name)
+				}
+			}
+		}
+		if value, ok := fields["value"]; ok {
+			v, err := decodeJSON(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+			}
+			op.value, op.hasValue = v, true
+		}
+
+		switch op.op {
+		case "add", "replace", "test":
+			if !op.hasValue {
+				return nil, fmt.Errorf("%w: operation %d: %q requires a value", ErrInvalidPatch, i, op.op)
+			}
+		case "move", "copy":
+			if _, ok := fields["from"]; !ok {
+				return nil, fmt.Errorf("%w: operation %d: %q requires from", ErrInvalidPatch, i, op.op)
+			}
+			if _, err := parsePointer(op.from); err != nil {
+				return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("%w: operation %d: unknown op %q", ErrInvalidPatch, i, op.op)
+		}
+		if _, ok := fields["path"]; !ok {
+			return nil, fmt.Errorf("%w: operation %d: path is required", ErrInvalidPatch, i)
+		}
+		if _, err := parsePointer(op.path); err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+func (op jsonPatchOp) apply(doc interface{}) (interface{}, error) {
+	path, _ := parsePointer(op.path)
+	switch op.op {
+	case "add":
+		return setAt(doc, path, op.value, true)
+	case "replace":
+		return setAt(doc, path, op.value, false)
+	case "remove":
+		return removeAt(doc, path)
+	case "copy", "move":
+		from, _ := parsePointer(op.from)
+		value, err := getAt(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.op == "copy" {
+			return setAt(doc, path, deepCopy(value), true)
+		}
+		if op.path == op.from {
+			return doc, nil
+		}
+		if strings.HasPrefix(op.path, op.from+"/") {
+			return nil, fmt.Errorf("%w: cannot move a value into itself", ErrPatchConflict)
+		}
+		if doc, err = removeAt(doc, from); err != nil {
+			return nil, err
+		}
+		return setAt(doc, path, value, true)
+	default: // test
+		value, err := getAt(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(value, op.value) {
+			return nil, fmt.Errorf("%w: test failed", ErrPatchConflict)
+		}
+		return doc, nil
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped reference tokens
+func parsePointer(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("%q is not a JSON Pointer", s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func getAt(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: member %q does not exist", ErrPatchConflict, token)
+			}
+			doc = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("%w: %q is not inside an object or array", ErrPatchConflict, token)
+		}
+	}
+	return doc, nil
+}
+
+// setAt adds (insert) or replaces the value at path, returning the updated document.
+// Replacing requires the target to exist, while adding to an array inserts before the index.
+func setAt(doc interface{}, path []string, value interface{}, insert bool) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := node[token]; !ok && !insert {
+				return nil, fmt.Errorf("%w: member %q does not exist", ErrPatchConflict, token)
+			}
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			if !insert {
+				i, err := arrayIndex(token, len(node)-1)
+				if err != nil {
+					return nil, err
+				}
+				node[i] = value
+				return node, nil
+			}
+			i := len(node)
+			if token != "-" {
+				var err error
+				if i, err = arrayIndex(token, len(node)); err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		default:
+			return nil, fmt.Errorf("%w: %q is not inside an object or array", ErrPatchConflict, token)
+		}
+	})
+}
+
+func removeAt(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: cannot remove the whole document", ErrPatchConflict)
+	}
+	return updateParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := node[token]; !ok {
+				return nil, fmt.Errorf("%w: member %q does not exist", ErrPatchConflict, token)
+			}
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:i], node[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("%w: %q is not inside an object or array", ErrPatchConflict, token)
+		}
+	})
+}
+
+// updateParent calls fn with the container holding the last token of path and stores the
+// container it returns back into the document, since changing an array may reallocate it.
+func updateParent(doc interface{}, path []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+	child, err := getAt(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	child, err = updateParent(child, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		node[path[0]] = child
+	case []interface{}:
+		i, _ := arrayIndex(path[0], len(node)-1)
+		node[i] = child
+	}
+	return doc, nil
+}
+
+// arrayIndex parses an array index token, which must be between 0 and max without leading zeros
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: %q is not an array index", ErrPatchConflict, token)
+	}
+	if i > max {
+		return 0, fmt.Errorf("%w: index %d is out of bounds", ErrPatchConflict, i)
+	}
+	return i, nil
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(node))
+		for key, value := range node {
+			c[key] = deepCopy(value)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(node))
+		for i, value := range node {
+			c[i] = deepCopy(value)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// jsonEqual compares two decoded JSON values, treating numbers by value so 1 equals 1.0
+func jsonEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, ok := y[key]
+			if !ok || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	default:
+		return a == b
+	}
+}
+
+// decodeJSON decodes a single JSON value, keeping numbers as json.Number so large integers survive
+func decodeJSON(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return v, nil
+}
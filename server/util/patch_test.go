@@ -0,0 +1,113 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch(t *testing.T) {
+	// Examples from RFC 7396, appendix A
+	cases := []struct{ doc, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`{"id":9007199254740993}`, `{"name":"x"}`, `{"id":9007199254740993,"name":"x"}`},
+	}
+	for _, c := range cases {
+		got, err := MergePatch([]byte(c.doc), []byte(c.patch))
+		assert.NoError(t, err, c.patch)
+		assert.JSONEq(t, c.want, string(got), c.patch)
+	}
+}
+
+func TestMergePatch_Invalido(t *testing.T) {
+	_, err := MergePatch([]byte(`{}`), []byte(`{"a":`))
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+}
+
+func TestJSONPatch(t *testing.T) {
+	// Examples adapted from RFC 6902, appendix A
+	cases := []struct{ doc, patch, want string }{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`, `{"a":{"b":1},"c":{"b":2}}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"/":9,"~1":10}`, `[{"op":"replace","path":"/~01","value":11},{"op":"replace","path":"/~1","value":1}]`, `{"/":1,"~1":11}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"","value":{"baz":null}}]`, `{"baz":null}`},
+	}
+	for _, c := range cases {
+		got, err := JSONPatch([]byte(c.doc), []byte(c.patch))
+		assert.NoError(t, err, c.patch)
+		assert.JSONEq(t, c.want, string(got), c.patch)
+	}
+}
+
+func TestJSONPatch_NaoAplica(t *testing.T) {
+	cases := []struct{ doc, patch string }{
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":1}]`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":"x"}]`},
+		{`{"foo":["bar"]}`, `[{"op":"remove","path":"/foo/01"}]`},
+		{`{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/c"}]`},
+	}
+	for _, c := range cases {
+		_, err := JSONPatch([]byte(c.doc), []byte(c.patch))
+		assert.ErrorIs(t, err, ErrPatchConflict, c.patch)
+	}
+}
+
+func TestJSONPatch_Invalido(t *testing.T) {
+	for _, patch := range []string{
+		`{"op":"add","path":"/a","value":1}`,
+		`[{"op":"add","path":"/a"}]`,
+		`[{"op":"jump","path":"/a"}]`,
+		`[{"op":"remove"}]`,
+		`[{"op":"remove","path":"a"}]`,
+		`[{"op":"copy","path":"/a"}]`,
+	} {
+		_, err := JSONPatch([]byte(`{"a":1}`), []byte(patch))
+		assert.ErrorIs(t, err, ErrInvalidPatch, patch)
+	}
+}
+
+func TestJSONPatch_NaoAlteraDocumentoQuandoFalha(t *testing.T) {
+	doc := []byte(`{"a":1}`)
+	_, err := JSONPatch(doc, []byte(`[{"op":"replace","path":"/a","value":2},{"op":"test","path":"/a","value":3}]`))
+	assert.ErrorIs(t, err, ErrPatchConflict)
+	assert.JSONEq(t, `{"a":1}`, string(doc))
+}
+
+func TestJSONPatch_Limites(t *testing.T) {
+	ops := make([]string, MaxJSONPatchOps+1)
+	for i := range ops {
+		ops[i] = `{"op":"test","path":"/a","value":1}`
+	}
+	_, err := JSONPatch([]byte(`{"a":1}`), []byte("["+strings.Join(ops, ",")+"]"))
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+
+	// each copy of the array into itself doubles it
+	ops = []string{`{"op":"add","path":"/x","value":["0123456789"]}`}
+	for range 20 {
+		ops = append(ops, `{"op":"copy","from":"/x","path":"/x/-"}`)
+	}
+	_, err = JSONPatch([]byte(`{"a":1}`), []byte("["+strings.Join(ops, ",")+"]"))
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+	assert.Contains(t, err.Error(), "grows the document")
+}
@@ -0,0 +1,31 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+)
+
+// accents maps the accented letters used in Portuguese and other Latin languages to their base letter
+var accents = map[rune]rune{
+	'á': 'a', 'à': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a',
+	'é': 'e', 'è': 'e', 'ê': 'e', 'ë': 'e',
+	'í': 'i', 'ì': 'i', 'î': 'i', 'ï': 'i',
+	'ó': 'o', 'ò': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o',
+	'ú': 'u', 'ù': 'u', 'û': 'u', 'ü': 'u',
+	'ç': 'c', 'ñ': 'n', 'ý': 'y', 'ÿ': 'y',
+}
+
+// foldRune lower-cases r and strips its accent, so "Ç" becomes "c"
+func foldRune(r rune) rune {
+	r = unicode.ToLower(r)
+	if base, ok := accents[r]; ok {
+		return base
+	}
+	return r
+}
+
+// Fold lower-cases s and strips its accents, matching what Postgres' lower(unaccent(s)) does for
+// Portuguese text. Each rune is mapped to a single rune, so positions in s and in the result line up.
+func Fold(s string) string {
+	return strings.Map(foldRune, s)
+}
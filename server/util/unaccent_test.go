@@ -0,0 +1,13 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFold(t *testing.T) {
+	assert.Equal(t, "sao joao - acai & pao", Fold("São João - Açaí & PÃO"))
+	assert.Equal(t, "loja 1", Fold("loja 1"))
+	assert.Equal(t, len([]rune("ÁÉÍÓÚ")), len([]rune(Fold("ÁÉÍÓÚ"))))
+}
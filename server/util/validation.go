@@ -1,15 +1,83 @@
 package util
 
-import "github.com/go-playground/validator/v10"
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
 
-var Validate = validator.New()
+	"github.com/go-playground/validator/v10"
+)
+
+var Validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// Report fields by their JSON name, which is what API clients send
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "" || name == "-" {
+			return f.Name
+		}
+		return name
+	})
+	v.RegisterValidation("cnpj", func(fl validator.FieldLevel) bool {
+		return IsValidCNPJ(fl.Field().String())
+	})
+	v.RegisterValidation("uf", func(fl validator.FieldLevel) bool {
+		return IsValidUF(fl.Field().String())
+	})
+	v.RegisterValidation("cep", func(fl validator.FieldLevel) bool {
+		return IsValidCEP(fl.Field().String())
+	})
+	return v
+}
 
 func ParseValidationError(err error) map[string]string {
 	errors := make(map[string]string)
 	if validationErrors, ok := err.(validator.ValidationErrors); ok {
 		for _, ve := range validationErrors {
-			errors[ve.Field()] = ve.Tag()
+			errors[ve.Field()] = validationMessage(ve)
 		}
 	}
 	return errors
 }
+
+// validationMessage describes why a field failed validation
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "len":
+		return fmt.Sprintf("must be exactly %s characters long", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters long", fe.Param())
+	case "required_with":
+		return fmt.Sprintf("is required when %s is set", snakeCase(fe.Param()))
+	case "latitude":
+		return "must be a latitude between -90 and 90"
+	case "longitude":
+		return "must be a longitude between -180 and 180"
+	case "uf":
+		return "must be the abbreviation of a Brazilian state (UF), such as SP"
+	case "cep":
+		return "must be a CEP written as 12345-678 or 12345678"
+	case "cnpj":
+		return "must be a valid CNPJ: 14 characters (the first 12 may be letters) with valid check digits"
+	default:
+		return fmt.Sprintf("failed on the '%s' validation", fe.Tag())
+	}
+}
+
+// snakeCase turns a Go field name such as EstablishmentID into the JSON name of the field, establishment_id
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
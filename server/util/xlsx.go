@@ -0,0 +1,410 @@
+package util
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// MaxXLSXPartSize bounds how large each part of a workbook may be once decompressed, so a small zip bomb
+// cannot exhaust the memory while it is decoded
+const MaxXLSXPartSize = 64 << 20
+
+// ErrInvalidXLSX is returned when a file is not a readable XLSX workbook
+var ErrInvalidXLSX = errors.New("invalid xlsx file")
+
+// ErrXLSXTooLarge is returned when a part of a workbook decompresses to more than MaxXLSXPartSize
+var ErrXLSXTooLarge = fmt.Errorf("%w: a part is larger than %d MB once decompressed", ErrInvalidXLSX, MaxXLSXPartSize>>20)
+
+// ErrXLSXTooManyRows is returned when a sheet has a row past the most the caller reads
+var ErrXLSXTooManyRows = errors.New("the sheet has too many rows")
+
+// ReadXLSX reads the rows of the first worksheet of an XLSX workbook as text, at most maxRows of them
+// and the first maxColumns cells of each. Rows and cells missing from the sheet are returned empty, so
+// every cell keeps the position of its column. The gaps are padded only up to those bounds, since the
+// row and cell references of a tiny file could otherwise ask for millions of them: a row past maxRows
+// fails with ErrXLSXTooManyRows and the cells past maxColumns are dropped.
+func ReadXLSX(r io.ReaderAt, size int64, maxRows, maxColumns int) ([][]string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidXLSX, err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheet, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+	var shared []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if shared, err = readSharedStrings(f); err != nil {
+			return nil, err
+		}
+	}
+	f, ok := files[sheet]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidXLSX, sheet)
+	}
+	return readSheet(f, shared, maxRows, maxColumns)
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// firstSheetPath follows the workbook relationships to the part holding the first sheet
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	var workbook xlsxWorkbook
+	if err := decodeXMLPart(files, "xl/workbook.xml", &workbook); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", fmt.Errorf("%w: the workbook has no sheets", ErrInvalidXLSX)
+	}
+	var rels xlsxRelationships
+	if err := decodeXMLPart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].RelID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", fmt.Errorf("%w: the first sheet has no relationship", ErrInvalidXLSX)
+}
+
+func decodeXMLPart(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("%w: missing %s", ErrInvalidXLSX, name)
+	}
+	return decodeXMLFile(f, v)
+}
+
+func decodeXMLFile(f *zip.File, v interface{}) error {
+	rc, err := openPart(f)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return partError(f, err)
+	}
+	return nil
+}
+
+// openPart opens a part of the workbook for reading, at most MaxXLSXPartSize bytes of it. Parts whose zip
+// header declares more are rejected before anything is inflated, and the reader fails once it goes past
+// the limit whatever the header says.
+func openPart(f *zip.File) (io.ReadCloser, error) {
+	if f.UncompressedSize64 > MaxXLSXPartSize {
+		return nil, ErrXLSXTooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidXLSX, err)
+	}
+	return &limitedPart{ReadCloser: rc, left: MaxXLSXPartSize}, nil
+}
+
+// limitedPart fails with ErrXLSXTooLarge once more than left bytes are read
+type limitedPart struct {
+	io.ReadCloser
+	left int64
+}
+
+func (p *limitedPart) Read(b []byte) (int, error) {
+	if p.left < 0 {
+		return 0, ErrXLSXTooLarge
+	}
+	if int64(len(b)) > p.left+1 {
+		b = b[:p.left+1]
+	}
+	n, err := p.ReadCloser.Read(b)
+	p.left -= int64(n)
+	if p.left < 0 {
+		return 0, ErrXLSXTooLarge
+	}
+	return n, err
+}
+
+// partError reports a part that could not be decoded, keeping ErrXLSXTooLarge as is
+func partError(f *zip.File, err error) error {
+	if errors.Is(err, ErrXLSXTooLarge) {
+		return ErrXLSXTooLarge
+	}
+	return fmt.Errorf("%w: %s: %v", ErrInvalidXLSX, f.Name, err)
+}
+
+// xlsxText is a string item, either plain or split into rich text runs
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+func readSharedStrings(f *zip.File) ([]string, error) {
+	var sst struct {
+		Items []xlsxText `xml:"si"`
+	}
+	if err := decodeXMLFile(f, &sst); err != nil {
+		return nil, err
+	}
+	shared := make([]string, len(sst.Items))
+	for i, item := range sst.Items {
+		shared[i] = item.String()
+	}
+	return shared, nil
+}
+
+type xlsxCell struct {
+	Ref       string   `xml:"r,attr"`
+	Type      string   `xml:"t,attr"`
+	Value     string   `xml:"v"`
+	InlineStr xlsxText `xml:"is"`
+}
+
+type xlsxRow struct {
+	Index int        `xml:"r,attr"`
+	Cells []xlsxCell `xml:"c"`
+}
+
+// readSheet streams the rows of a worksheet, so only the decoded values are kept in memory
+func readSheet(f *zip.File, shared []string, maxRows, maxColumns int) ([][]string, error) {
+	rc, err := openPart(f)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var rows [][]string
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, partError(f, err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		var row xlsxRow
+		if err := dec.DecodeElement(&row, &start); err != nil {
+			return nil, partError(f, err)
+		}
+		index := len(rows)
+		if row.Index > 0 {
+			index = row.Index - 1
+		}
+		if index < len(rows) {
+			return nil, fmt.Errorf("%w: row %d is out of order", ErrInvalidXLSX, row.Index)
+		}
+		if index >= maxRows {
+			return nil, ErrXLSXTooManyRows
+		}
+		for len(rows) < index {
+			rows = append(rows, nil)
+		}
+		values, err := readRow(row, shared, maxColumns)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, values)
+	}
+}
+
+func readRow(row xlsxRow, shared []string, maxColumns int) ([]string, error) {
+	var values []string
+	for _, cell := range row.Cells {
+		column := len(values)
+		if cell.Ref != "" {
+			var err error
+			if column, err = columnIndex(cell.Ref); err != nil {
+				return nil, err
+			}
+		}
+		if column < len(values) {
+			return nil, fmt.Errorf("%w: cell %s is out of order", ErrInvalidXLSX, cell.Ref)
+		}
+		if column >= maxColumns {
+			break
+		}
+		for len(values) < column {
+			values = append(values, "")
+		}
+		value, err := cellValue(cell, shared)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func cellValue(cell xlsxCell, shared []string) (string, error) {
+	switch cell.Type {
+	case "s":
+		i, err := strconv.Atoi(cell.Value)
+		if err != nil || i < 0 || i >= len(shared) {
+			return "", fmt.Errorf("%w: cell %s references a missing shared string", ErrInvalidXLSX, cell.Ref)
+		}
+		return shared[i], nil
+	case "inlineStr":
+		return cell.InlineStr.String(), nil
+	case "b":
+		if cell.Value == "1" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	default:
+		// numbers, formula strings and errors are stored as their text
+		return cell.Value, nil
+	}
+}
+
+// columnIndex returns the zero based column of a cell reference such as "AB12"
+func columnIndex(ref string) (int, error) {
+	column := 0
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		column = column*26 + int(ref[i]-'A'+1)
+	}
+	if i == 0 || i == len(ref) || column > 16384 {
+		return 0, fmt.Errorf("%w: invalid cell reference %q", ErrInvalidXLSX, ref)
+	}
+	return column - 1, nil
+}
+
+// XLSXWriter writes a workbook with a single sheet row by row, so the rows are streamed
+// to the underlying writer instead of being held in memory. Close must be called to finish the file.
+type XLSXWriter struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	rows  int
+}
+
+// NewXLSXWriter starts a workbook on w with one sheet with the given name
+func NewXLSXWriter(w io.Writer, sheetName string) (*XLSXWriter, error) {
+	var name strings.Builder
+	if err := xml.EscapeText(&name, []byte(sheetName)); err != nil {
+		return nil, err
+	}
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="` + name.String() + `" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+	}
+	for _, part := range parts {
+		pw, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(pw, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, xml.Header+`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+	return &XLSXWriter{zw: zw, sheet: sheet}, nil
+}
+
+// Write appends a row. Integers and floats are written as numbers, nil as an empty cell and
+// any other value as the text of fmt.Sprint.
+func (x *XLSXWriter) Write(row []interface{}) error {
+	x.rows++
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, x.rows)
+	for i, value := range row {
+		ref := columnName(i) + strconv.Itoa(x.rows)
+		switch v := value.(type) {
+		case nil:
+			continue
+		case int, int32, int64, float32, float64:
+			fmt.Fprintf(&b, `<c r="%s"><v>%v</v></c>`, ref, v)
+		default:
+			text := fmt.Sprint(v)
+			if text == "" {
+				continue
+			}
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			if err := xml.EscapeText(&b, []byte(text)); err != nil {
+				return err
+			}
+			b.WriteString(`</t></is></c>`)
+		}
+	}
+	b.WriteString(`</row>`)
+	_, err := io.WriteString(x.sheet, b.String())
+	return err
+}
+
+// Close ends the sheet and writes the zip directory. It does not close the underlying writer.
+func (x *XLSXWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
+
+// columnName returns the letters of the zero based column, such as "AB" for 27
+func columnName(column int) string {
+	var name []byte
+	for column++; column > 0; column = (column - 1) / 26 {
+		name = append([]byte{byte('A' + (column-1)%26)}, name...)
+	}
+	return string(name)
+}
@@ -0,0 +1,172 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildXLSX zips the given parts into a workbook
+func buildXLSX(t *testing.T, parts map[string]string) *bytes.Reader {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return bytes.NewReader(buf.Bytes())
+}
+
+const (
+	testWorkbook = `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Lojas" sheetId="1" r:id="rId3"/><sheet name="Outra" sheetId="2" r:id="rId1"/></sheets></workbook>`
+	testWorkbookRels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"/>
+<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+)
+
+func TestReadXLSX(t *testing.T) {
+	r := buildXLSX(t, map[string]string{
+		"xl/workbook.xml":            testWorkbook,
+		"xl/_rels/workbook.xml.rels": testWorkbookRels,
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>number</t></si><si><t>name</t></si><si><r><t>Loja </t></r><r><t>Centro</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>
+<row r="3"><c r="A3"><v>12345678000190</v></c><c r="B3" t="s"><v>2</v></c><c r="D3" t="inlineStr"><is><t>SP</t></is></c></row>
+<row r="4"><c r="B4" t="b"><v>1</v></c></row>
+</sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="inlineStr"><is><t>errada</t></is></c></row></sheetData></worksheet>`,
+	})
+
+	rows, err := ReadXLSX(r, r.Size(), 10, 10)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"number", "name"},
+		nil,
+		{"12345678000190", "Loja Centro", "", "SP"},
+		{"", "TRUE"},
+	}, rows)
+}
+
+func TestReadXLSX_Invalido(t *testing.T) {
+	_, err := ReadXLSX(bytes.NewReader([]byte("number,name\n")), 12, 10, 10)
+	assert.ErrorIs(t, err, ErrInvalidXLSX)
+
+	r := buildXLSX(t, map[string]string{
+		"xl/workbook.xml":            testWorkbook,
+		"xl/_rels/workbook.xml.rels": testWorkbookRels,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c></row></sheetData></worksheet>`,
+	})
+	_, err = ReadXLSX(r, r.Size(), 10, 10)
+	assert.ErrorIs(t, err, ErrInvalidXLSX)
+}
+
+func TestReadXLSX_ZipBomb(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{"xl/workbook.xml": testWorkbook, "xl/_rels/workbook.xml.rels": testWorkbookRels} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	w, err := zw.Create("xl/worksheets/sheet1.xml")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(`<worksheet><sheetData>`))
+	require.NoError(t, err)
+	blank := bytes.Repeat([]byte(" "), 1<<20)
+	for i := 0; i <= MaxXLSXPartSize>>20; i++ {
+		_, err = w.Write(blank)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.Less(t, buf.Len(), 1<<20, "the bomb is small once compressed")
+
+	_, err = ReadXLSX(bytes.NewReader(buf.Bytes()), int64(buf.Len()), 10, 10)
+	assert.ErrorIs(t, err, ErrXLSXTooLarge)
+	assert.ErrorIs(t, err, ErrInvalidXLSX)
+}
+
+func TestReadXLSX_ReferenciasDistantes(t *testing.T) {
+	sheet := func(rows string) *bytes.Reader {
+		return buildXLSX(t, map[string]string{
+			"xl/workbook.xml":            testWorkbook,
+			"xl/_rels/workbook.xml.rels": testWorkbookRels,
+			"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+				rows + `</sheetData></worksheet>`,
+		})
+	}
+
+	r := sheet(`<row r="1"><c r="A1"><v>1</v></c></row><row r="20000000"><c r="A20000000"><v>2</v></c></row>`)
+	_, err := ReadXLSX(r, r.Size(), 3, 2)
+	assert.ErrorIs(t, err, ErrXLSXTooManyRows)
+
+	r = sheet(`<row r="3"><c r="A3"><v>1</v></c><c r="B3"><v>2</v></c><c r="C3"><v>3</v></c><c r="XFD3"><v>4</v></c></row>`)
+	rows, err := ReadXLSX(r, r.Size(), 3, 2)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{nil, nil, {"1", "2"}}, rows, "cells past the last column are dropped")
+}
+
+func TestLimitedPart(t *testing.T) {
+	part := &limitedPart{ReadCloser: io.NopCloser(strings.NewReader("abcdef")), left: 4}
+	_, err := io.ReadAll(part)
+	assert.ErrorIs(t, err, ErrXLSXTooLarge, "the limit holds whatever the zip header says")
+
+	part = &limitedPart{ReadCloser: io.NopCloser(strings.NewReader("abcd")), left: 4}
+	data, err := io.ReadAll(part)
+	assert.NoError(t, err)
+	assert.Equal(t, "abcd", string(data))
+}
+
+func TestColumnIndex(t *testing.T) {
+	for ref, want := range map[string]int{"A1": 0, "Z9": 25, "AA10": 26, "AB2": 27, "XFD1": 16383} {
+		got, err := columnIndex(ref)
+		assert.NoError(t, err, ref)
+		assert.Equal(t, want, got, ref)
+	}
+	for _, ref := range []string{"", "12", "A", "a1", "XFE1"} {
+		_, err := columnIndex(ref)
+		assert.ErrorIs(t, err, ErrInvalidXLSX, ref)
+	}
+}
+
+func TestXLSXWriter_IdaEVolta(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewXLSXWriter(&buf, "Lojas & cia")
+	require.NoError(t, err)
+	require.NoError(t, w.Write([]interface{}{"id", "name", "storesTotal"}))
+	require.NoError(t, w.Write([]interface{}{int64(1), "Loja <A> & \"B\"", 3}))
+	require.NoError(t, w.Write([]interface{}{int64(2), nil, "", 1.5}))
+	require.NoError(t, w.Close())
+
+	rows, err := ReadXLSX(bytes.NewReader(buf.Bytes()), int64(buf.Len()), 10, 10)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"id", "name", "storesTotal"},
+		{"1", "Loja <A> & \"B\"", "3"},
+		{"2", "", "", "1.5"},
+	}, rows)
+}
+
+func TestColumnName(t *testing.T) {
+	for column, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA", 16383: "XFD"} {
+		assert.Equal(t, want, columnName(column))
+		got, err := columnIndex(want + "1")
+		assert.NoError(t, err)
+		assert.Equal(t, column, got)
+	}
+}
@@ -1,7 +1,7 @@
-export default defineNuxtRouteMiddleware(() => {
-  if (!import.meta.client) return;
+import { useApiToken } from "~/services/auth-header";
 
-  if (!localStorage.getItem("logged")) {
+export default defineNuxtRouteMiddleware(() => {
+  if (!useApiToken().value) {
     return navigateTo("/");
   }
 });
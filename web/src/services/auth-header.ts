@@ -0,0 +1,11 @@
+// useApiToken is the token the user signed in with. It is kept in a cookie rather than localStorage
+// so the pages rendered on the server send it to the API as well.
+export function useApiToken() {
+  return useCookie<string | null>("api_token", { sameSite: "strict", maxAge: 60 * 60 * 12 });
+}
+
+// authHeader builds the Authorization header every request to the API needs
+export function authHeader(): Record<string, string> {
+  const token = useApiToken().value;
+  return token ? { Authorization: `Bearer ${token}` } : {};
+}
@@ -1,4 +1,5 @@
 import type { Establishment } from "~/types/establishment";
+import { authHeader } from "~/services/auth-header"
 
 export async function createEstablishment(payload: Partial<Establishment>) {
   const config = useRuntimeConfig()
@@ -6,6 +7,7 @@ export async function createEstablishment(payload: Partial<Establishment>) {
     `${config.public.apiBase}/establishments`,
     {
       method: 'POST',
+      headers: authHeader(),
       body: {
         number: payload.number,
         name: payload.name,
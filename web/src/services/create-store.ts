@@ -1,4 +1,5 @@
 import type { Store } from "~/types/store";
+import { authHeader } from "~/services/auth-header";
 
 type CreateStorePayload = {
   establishment_id: number;
@@ -19,6 +20,7 @@ export async function createStore(payload: CreateStorePayload) {
     `${config.public.apiBase}/stores`,
     {
       method: "POST",
+      headers: authHeader(),
       body: {
         establishment_id: payload.establishment_id,
 
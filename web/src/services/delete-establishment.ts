@@ -1,10 +1,14 @@
-export async function deleteEstablishment(id: string): Promise<void> {
+import { ifMatch } from "~/services/if-match";
+import { authHeader } from "~/services/auth-header";
+
+export async function deleteEstablishment(id: string, version: number): Promise<void> {
   const config = useRuntimeConfig();
   
   await $fetch(
     `${config.public.apiBase}/establishments/${id}`,
     {
       method: "DELETE",
+      headers: { ...authHeader(), ...ifMatch(version) },
     }
   );
 }
@@ -1,4 +1,5 @@
 import type { EstablishmentWithStores } from "~/types/establishment";
+import { authHeader } from "~/services/auth-header";
 
 export async function fetchEstablishmentById(establishmentId: string) {
   const config = useRuntimeConfig();
@@ -7,7 +8,7 @@ export async function fetchEstablishmentById(establishmentId: string) {
     `${config.public.apiBase}/establishments/${establishmentId}`,
     {
       method: "GET",
-      headers: { "Content-Type": "application/json" },
+      headers: { ...authHeader(), "Content-Type": "application/json" },
     }
   );
   return response;
@@ -1,14 +1,32 @@
-import type { EstablishmentWithStoresTotal } from "~/types/establishment";
+import type { EstablishmentPage } from "~/types/establishment";
+import { authHeader } from "~/services/auth-header";
 
+// The API returns at most this many establishments per page
+const PAGE_LIMIT = 100;
+
+// fetchEstablishments follows next_cursor until the last page, so the dashboard lists every establishment
 export async function fetchEstablishments() {
   const config = useRuntimeConfig();
+  // read before the first await, while the Nuxt context is still available
+  const headers = { ...authHeader(), "Content-Type": "application/json" };
 
-  const response = await useFetch<EstablishmentWithStoresTotal[]>(
-    `${config.public.apiBase}/establishments`,
-    {
-      method: "GET",
-      headers: { "Content-Type": "application/json" },
-    }
-  );
+  const response = await useAsyncData<EstablishmentPage>("establishments", async () => {
+    const all: EstablishmentPage = { items: [], total: 0 };
+    let cursor: string | undefined;
+    do {
+      const page = await $fetch<EstablishmentPage>(
+        `${config.public.apiBase}/establishments`,
+        {
+          method: "GET",
+          headers,
+          query: { limit: PAGE_LIMIT, cursor },
+        }
+      );
+      all.items.push(...page.items);
+      all.total = page.total;
+      cursor = page.next_cursor;
+    } while (cursor);
+    return all;
+  });
   return response;
 }
@@ -0,0 +1,5 @@
+// ifMatch builds the If-Match header the API requires to change or remove a record: the ETag it was
+// read with, i.e. its version in quotes. A version that moved since then is answered with 412.
+export function ifMatch(version: number) {
+  return { "If-Match": `"${version}"` };
+}
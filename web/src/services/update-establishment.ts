@@ -1,11 +1,14 @@
 import type { Establishment } from "~/types/establishment"
+import { ifMatch } from "~/services/if-match"
+import { authHeader } from "~/services/auth-header"
 
-export async function updateEstablishment(id: string | number, payload: Partial<Establishment>) {
+export async function updateEstablishment(id: string | number, version: number, payload: Partial<Establishment>) {
   const config = useRuntimeConfig()
   const response = await $fetch<Establishment>(
     `${config.public.apiBase}/establishments/${id}`,
     {
       method: 'PUT',
+      headers: { ...authHeader(), ...ifMatch(version) },
       body: {
         number: payload.number,
         name: payload.name,
@@ -1,4 +1,6 @@
 import type { Store } from "~/types/store";
+import { ifMatch } from "~/services/if-match";
+import { authHeader } from "~/services/auth-header";
 
 type UpdateStoreParams = {
   establishment_id: number;
@@ -13,12 +15,13 @@ type UpdateStoreParams = {
   zip_code: string;
 };
 
-export async function updateStore(id: string | number, payload: UpdateStoreParams) {
+export async function updateStore(id: string | number, version: number, payload: UpdateStoreParams) {
   const config = useRuntimeConfig();
   const response = await $fetch<Store>(
     `${config.public.apiBase}/stores/${id}`,
     {
       method: "PUT",
+      headers: { ...authHeader(), ...ifMatch(version) },
       body: {
         establishment_id: payload.establishment_id,
         number: payload.number,
@@ -10,6 +10,14 @@ export interface Establishment {
   city: string
   state: string
   zip_code: string
+  // version is sent back as If-Match when the establishment is changed
+  version: number
+}
+
+export interface EstablishmentPage {
+  items: EstablishmentWithStoresTotal[]
+  next_cursor?: string
+  total: number
 }
 
 export interface EstablishmentWithStoresTotal extends Establishment {
@@ -9,6 +9,8 @@ export interface Store {
   city: string;
   state: string;
   zip_code: string;
+  // version is sent back as If-Match when the store is changed
+  version: number;
 }
 
 export interface StoreWithEstablishment extends Store {